@@ -0,0 +1,196 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	albumRelated  int
+	albumTimezone string
+)
+
+var albumCmd = &cobra.Command{
+	Use:   "album <artist> <album>",
+	Short: "Shows the user's history with an album",
+	Long: `Shows everything about listening to one album: when it was discovered, total plays and how it
+ranks among the artist's albums, plays of each track, plays in every month, estimated full
+play-throughs, tags, and the other albums most often played in the same listening sessions. The
+names are matched ignoring case if there's no exact match.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printAlbum(cmd.Context(), os.Stdout, db, viper.GetString("user"), args[0], args[1])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(albumCmd)
+
+	albumCmd.Flags().IntVarP(&albumRelated, "number", "n", 10, "number of albums played alongside it to show")
+	albumCmd.Flags().StringVar(&albumTimezone, "timezone", "", "timezone which months start in (e.g. America/Los_Angeles), default is local time")
+}
+
+func printAlbum(ctx context.Context, out io.Writer, db store.Store, user, artist, album string) error {
+	analyzer := &AlbumAnalyzer{Config: defaultAlbumHistoryConfig()}
+	params := map[string]string{"artist": artist, "album": album, "n": strconv.Itoa(albumRelated), "timezone": albumTimezone}
+	if err := analyzer.Configure(params); err != nil {
+		return err
+	}
+
+	summary, tables, err := analyzer.analyze(ctx, db, user, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, summary)
+	for _, t := range tables {
+		fmt.Fprintf(out, "\n%s:\n", t.title)
+		fmt.Fprint(out, Analysis{results: t.results})
+	}
+	return nil
+}
+
+func defaultAlbumHistoryConfig() analysis.AlbumHistoryConfig {
+	return analysis.AlbumHistoryConfig{
+		PlayThrough: analysis.PlayThroughConfig{
+			Gap: analysis.DefaultSessionGap, MinCoverage: analysis.DefaultMinAlbumCoverage, MinTracks: analysis.DefaultMinAlbumTracks,
+		},
+		Tags:    5,
+		Related: 10,
+	}
+}
+
+type AlbumAnalyzer struct {
+	Config analysis.AlbumHistoryConfig
+	Artist string
+	Album  string
+}
+
+func (t *AlbumAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["artist"]; ok {
+		t.Artist = val
+	}
+	if val, ok := params["album"]; ok {
+		t.Album = val
+	}
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.Config.Related = n
+	}
+	if val, ok := params["timezone"]; ok && val != "" {
+		loc, err := time.LoadLocation(val)
+		if err != nil {
+			return fmt.Errorf("loading timezone %q: %w", val, err)
+		}
+		t.Config.Location = loc
+	}
+	return nil
+}
+
+func (t *AlbumAnalyzer) GetName() string {
+	if t.Album == "" {
+		return "Album history"
+	}
+	return fmt.Sprintf("History with %s by %s", t.Album, t.Artist)
+}
+
+// GetResults covers the whole history up to end. The results are HTML for emails.
+func (t *AlbumAnalyzer) GetResults(ctx context.Context, db store.Store, user string, _ time.Time, end time.Time) (Analysis, error) {
+	var a Analysis
+	summary, tables, err := t.analyze(ctx, db, user, end)
+	if err != nil {
+		return a, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div>%s</div>\n", strings.ReplaceAll(html.EscapeString(summary), "\n", "<br>\n"))
+	for _, table := range tables {
+		fmt.Fprintf(&sb, "<h3>%s</h3>", html.EscapeString(table.title))
+		writeHTMLTable(&sb, table.results)
+	}
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns a text summary of the album history and tables of its details. Tables without any
+// rows are left out.
+func (t *AlbumAnalyzer) analyze(ctx context.Context, db store.Store, user string, end time.Time) (string, []titledTable, error) {
+	if t.Artist == "" || t.Album == "" {
+		return "", nil, fmt.Errorf("the album analysis needs 'artist' and 'album' params")
+	}
+	history, err := analysis.GetAlbumHistory(ctx, db, user, t.Artist, t.Album, end, t.Config)
+	if err != nil {
+		return "", nil, fmt.Errorf("album history: %w", err)
+	}
+	if history.Plays == 0 {
+		return fmt.Sprintf("No listens to %q by %q.\n", t.Album, t.Artist), nil, nil
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s by %s: %d plays in %d sessions\n", history.Album, history.Artist, history.Plays, history.Sessions)
+	fmt.Fprintf(&summary, "Discovered: %s\n", history.FirstListen.Format("2006-01-02"))
+	fmt.Fprintf(&summary, "Last listen: %s\n", history.LastListen.Format("2006-01-02"))
+	fmt.Fprintf(&summary, "Rank among %s's albums: %d of %d\n", history.Artist, history.ArtistRank, history.ArtistAlbums)
+	if n := len(history.PlayThroughs); n > 0 {
+		fmt.Fprintf(&summary, "Full play-throughs: %d, last on %s\n", n, history.PlayThroughs[n-1].Start.In(history.LastListen.Location()).Format("2006-01-02"))
+	} else {
+		fmt.Fprintln(&summary, "Full play-throughs: none")
+	}
+	if len(history.Tags) > 0 {
+		fmt.Fprintf(&summary, "Tags: %s\n", strings.Join(history.Tags, ", "))
+	}
+
+	var tables []titledTable
+	add := func(title string, results [][]string) {
+		if len(results) > 1 {
+			tables = append(tables, titledTable{title: title, results: results})
+		}
+	}
+	tracks := [][]string{{"Track", "Plays", "Share"}}
+	for _, track := range history.Tracks {
+		share := float64(track.Plays) / float64(history.Plays)
+		tracks = append(tracks, []string{track.Name, strconv.FormatInt(track.Plays, 10), fmt.Sprintf("%.0f%%", share*100)})
+	}
+	add("Tracks", tracks)
+	add("Plays by month", monthlyTimeline(history.Months))
+	related := [][]string{{"Album", "Artist", "Sessions"}}
+	for _, r := range history.Related {
+		related = append(related, []string{r.Album, r.Artist, strconv.Itoa(r.Sessions)})
+	}
+	add("Played alongside", related)
+	return summary.String(), tables, nil
+}
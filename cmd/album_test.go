@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestAlbumAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	add := func(ts time.Time, album, track string) {
+		tracks = append(tracks, store.TrackImport{Artist: "Radiohead", Album: album, TrackName: track, DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	// The whole of Kid A then a track from Amnesiac in one session, and one more track a day later.
+	start := time.Date(2020, 11, 30, 12, 0, 0, 0, time.UTC)
+	for i, track := range []string{"Everything", "Kid A", "The National Anthem", "Idioteque"} {
+		add(start.Add(time.Duration(i)*5*time.Minute), "Kid A", track)
+	}
+	add(start.Add(20*time.Minute), "Amnesiac", "Pyramid Song")
+	add(start.AddDate(0, 0, 1), "Kid A", "Everything")
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &AlbumAnalyzer{Config: defaultAlbumHistoryConfig()}
+	if err := analyzer.Configure(map[string]string{"artist": "radiohead", "album": "kid a", "n": "5", "timezone": "UTC"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	summary, tables, err := analyzer.analyze(context.Background(), s, user, end)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, line := range []string{
+		"Kid A by Radiohead: 5 plays in 2 sessions\n",
+		"Discovered: 2020-11-30\n",
+		"Last listen: 2020-12-01\n",
+		"Rank among Radiohead's albums: 1 of 2\n",
+		"Full play-throughs: 1, last on 2020-11-30\n",
+	} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("summary missing %q. Got:\n%s", line, summary)
+		}
+	}
+
+	var titles []string
+	for _, table := range tables {
+		titles = append(titles, table.title)
+	}
+	if want := []string{"Tracks", "Plays by month", "Played alongside"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("tables = %v, want %v", titles, want)
+	}
+	wantTracks := [][]string{
+		{"Track", "Plays", "Share"},
+		{"Everything", "2", "40%"},
+		{"Idioteque", "1", "20%"},
+		{"Kid A", "1", "20%"},
+		{"The National Anthem", "1", "20%"},
+	}
+	if !reflect.DeepEqual(tables[0].results, wantTracks) {
+		t.Errorf("tracks = %v, want %v", tables[0].results, wantTracks)
+	}
+	if want := [][]string{{"Album", "Artist", "Sessions"}, {"Amnesiac", "Radiohead", "1"}}; !reflect.DeepEqual(tables[2].results, want) {
+		t.Errorf("played alongside = %v, want %v", tables[2].results, want)
+	}
+
+	if err := (&AlbumAnalyzer{}).Configure(map[string]string{"n": "ten"}); err == nil {
+		t.Errorf("Configure with an invalid n succeeded")
+	}
+	if _, err := (&AlbumAnalyzer{}).GetResults(context.Background(), s, user, end, end); err == nil {
+		t.Errorf("GetResults without an album succeeded")
+	}
+}
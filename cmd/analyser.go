@@ -17,10 +17,16 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"html"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -41,7 +47,7 @@ type AnalyserConfig struct {
 }
 
 type Analyser interface {
-	GetResults(dbPath string, user string, start time.Time, end time.Time) (Analysis, error)
+	GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error)
 
 	GetName() string
 }
@@ -50,6 +56,19 @@ type Configurable interface {
 	Configure(params map[string]string) error
 }
 
+// withStore opens the database at dbPath, which update creates, and passes it to fn.
+func withStore(dbPath string, fn func(db store.Store) error) error {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return fmt.Errorf("Database doesn't exist - run update first.")
+	}
+	db, err := store.New(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	return fn(db)
+}
+
 func (a Analysis) String() string {
 	if a.BodyOverride != "" {
 		return a.BodyOverride
@@ -68,3 +87,30 @@ func (a Analysis) String() string {
 	}
 	return out.String()
 }
+
+// writeHTMLTable writes results, header first, as an HTML table for analyses which render their
+// own HTML.
+func writeHTMLTable(sb *strings.Builder, results [][]string) {
+	sb.WriteString("<table><thead><tr>")
+	for _, header := range results[0] {
+		fmt.Fprintf(sb, "<th>%s</th>", header)
+	}
+	sb.WriteString("</tr></thead><tbody>")
+	for _, row := range results[1:] {
+		sb.WriteString("<tr>")
+		for _, column := range row {
+			fmt.Fprintf(sb, "<td>%s</td>", html.EscapeString(column))
+		}
+		sb.WriteString("</tr>")
+	}
+	sb.WriteString("</tbody></table>")
+}
+
+// parseTagScoring parses the tag_scoring param of analyses which weight tags.
+func parseTagScoring(val string) (analysis.TagScoring, error) {
+	switch scoring := analysis.TagScoring(val); scoring {
+	case analysis.TagScoringBinary, analysis.TagScoringCount, analysis.TagScoringTFIDF:
+		return scoring, nil
+	}
+	return "", fmt.Errorf("invalid value for 'tag_scoring': %q, must be binary, count or tfidf", val)
+}
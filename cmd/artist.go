@@ -0,0 +1,234 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	artistTop      int
+	artistGapDays  int
+	artistTimezone string
+)
+
+var artistCmd = &cobra.Command{
+	Use:   "artist <name>",
+	Short: "Shows the user's history with an artist",
+	Long: `Shows everything about listening to one artist: the first and last listen, total plays, peak
+years, plays in every month, top albums and tracks, tags, how the artist ranked in each year, the
+longest streak of days with a listen and any long gaps between listens. The name is matched
+ignoring case if there's no exact match.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printArtist(cmd.Context(), os.Stdout, db, viper.GetString("user"), args[0])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(artistCmd)
+
+	artistCmd.Flags().IntVarP(&artistTop, "number", "n", 10, "number of top albums and tracks to show")
+	artistCmd.Flags().IntVar(&artistGapDays, "gap", 180, "shortest gap between listens, in days, to show as dormant")
+	artistCmd.Flags().StringVar(&artistTimezone, "timezone", "", "timezone which days, months and years start in (e.g. America/Los_Angeles), default is local time")
+}
+
+func printArtist(ctx context.Context, out io.Writer, db store.Store, user, artist string) error {
+	analyzer := &ArtistAnalyzer{Config: defaultArtistHistoryConfig()}
+	params := map[string]string{"artist": artist, "n": strconv.Itoa(artistTop), "gap_days": strconv.Itoa(artistGapDays), "timezone": artistTimezone}
+	if err := analyzer.Configure(params); err != nil {
+		return err
+	}
+
+	summary, tables, err := analyzer.analyze(ctx, db, user, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, summary)
+	for _, t := range tables {
+		fmt.Fprintf(out, "\n%s:\n", t.title)
+		fmt.Fprint(out, Analysis{results: t.results})
+	}
+	return nil
+}
+
+func defaultArtistHistoryConfig() analysis.ArtistHistoryConfig {
+	return analysis.ArtistHistoryConfig{Top: 10, Tags: 5, MinGap: analysis.DefaultDormancyGap}
+}
+
+type ArtistAnalyzer struct {
+	Config analysis.ArtistHistoryConfig
+	Artist string
+}
+
+func (t *ArtistAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["artist"]; ok {
+		t.Artist = val
+	}
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.Config.Top = n
+	}
+	if val, ok := params["gap_days"]; ok {
+		days, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'gap_days': %v", err)
+		}
+		t.Config.MinGap = time.Duration(days) * 24 * time.Hour
+	}
+	if val, ok := params["timezone"]; ok && val != "" {
+		loc, err := time.LoadLocation(val)
+		if err != nil {
+			return fmt.Errorf("loading timezone %q: %w", val, err)
+		}
+		t.Config.Location = loc
+	}
+	return nil
+}
+
+func (t *ArtistAnalyzer) GetName() string {
+	if t.Artist == "" {
+		return "Artist history"
+	}
+	return fmt.Sprintf("History with %s", t.Artist)
+}
+
+// GetResults covers the whole history up to end. The results are HTML for emails.
+func (t *ArtistAnalyzer) GetResults(ctx context.Context, db store.Store, user string, _ time.Time, end time.Time) (Analysis, error) {
+	var a Analysis
+	summary, tables, err := t.analyze(ctx, db, user, end)
+	if err != nil {
+		return a, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div>%s</div>\n", strings.ReplaceAll(html.EscapeString(summary), "\n", "<br>\n"))
+	for _, table := range tables {
+		fmt.Fprintf(&sb, "<h3>%s</h3>", html.EscapeString(table.title))
+		writeHTMLTable(&sb, table.results)
+	}
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+type titledTable struct {
+	title   string
+	results [][]string
+}
+
+// analyze returns a text summary of the artist history and tables of its details. Tables without
+// any rows are left out.
+func (t *ArtistAnalyzer) analyze(ctx context.Context, db store.Store, user string, end time.Time) (string, []titledTable, error) {
+	if t.Artist == "" {
+		return "", nil, fmt.Errorf("the artist analysis needs an 'artist' param")
+	}
+	history, err := analysis.GetArtistHistory(ctx, db, user, t.Artist, end, t.Config)
+	if err != nil {
+		return "", nil, fmt.Errorf("artist history: %w", err)
+	}
+	if history.Plays == 0 {
+		return fmt.Sprintf("No listens to %q.\n", t.Artist), nil, nil
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s: %d plays\n", history.Artist, history.Plays)
+	fmt.Fprintf(&summary, "First listen: %s\n", history.FirstListen.Format("2006-01-02"))
+	fmt.Fprintf(&summary, "Last listen: %s\n", history.LastListen.Format("2006-01-02"))
+	if history.PeakYears != "" {
+		fmt.Fprintf(&summary, "Peak years: %s\n", history.PeakYears)
+	}
+	if len(history.Tags) > 0 {
+		fmt.Fprintf(&summary, "Tags: %s\n", strings.Join(history.Tags, ", "))
+	}
+	fmt.Fprintf(&summary, "Longest streak: %s\n", formatStreak(history.LongestStreak))
+
+	var tables []titledTable
+	add := func(title string, results [][]string) {
+		if len(results) > 1 {
+			tables = append(tables, titledTable{title: title, results: results})
+		}
+	}
+	add("Plays by month", monthlyTimeline(history.Months))
+
+	albums := [][]string{{"Album", "Plays"}}
+	for _, album := range history.TopAlbums {
+		albums = append(albums, []string{album.Name, strconv.FormatInt(album.Plays, 10)})
+	}
+	add("Top albums", albums)
+	tracks := [][]string{{"Track", "Plays"}}
+	for _, track := range history.TopTracks {
+		tracks = append(tracks, []string{track.Name, strconv.FormatInt(track.Plays, 10)})
+	}
+	add("Top tracks", tracks)
+
+	years := [][]string{{"Year", "Plays", "Rank"}}
+	for _, y := range history.Years {
+		years = append(years, []string{strconv.Itoa(y.Year), strconv.FormatInt(y.Plays, 10), fmt.Sprintf("%d of %d", y.Rank, y.Artists)})
+	}
+	add("Rank by year", years)
+
+	gaps := [][]string{{"From", "To", "Days"}}
+	for _, g := range history.Gaps {
+		gaps = append(gaps, []string{g.From.Format("2006-01-02"), g.To.Format("2006-01-02"), strconv.Itoa(g.Days())})
+	}
+	add("Dormant periods", gaps)
+	return summary.String(), tables, nil
+}
+
+// monthlyTimeline lays out monthly plays with a row for each year. Months outside of the history are
+// left blank.
+func monthlyTimeline(months []analysis.MonthPlays) [][]string {
+	results := [][]string{{"Year", "Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec", "Total"}}
+	var row []string
+	var total int64
+	for _, m := range months {
+		if row == nil || m.Month.Month() == time.January {
+			if row != nil {
+				results = append(results, append(row, strconv.FormatInt(total, 10)))
+			}
+			row = make([]string, 13)
+			row[0] = strconv.Itoa(m.Month.Year())
+			total = 0
+		}
+		row[m.Month.Month()] = strconv.FormatInt(m.Plays, 10)
+		total += m.Plays
+	}
+	if row != nil {
+		results = append(results, append(row, strconv.FormatInt(total, 10)))
+	}
+	return results
+}
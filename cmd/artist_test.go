@@ -0,0 +1,107 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestArtistAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	for _, l := range []struct {
+		month, day int
+		album      string
+	}{{11, 30, "Kid A"}, {12, 1, "Kid A"}, {12, 2, "Amnesiac"}} {
+		ts := time.Date(2020, time.Month(l.month), l.day, 12, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: "Radiohead", Album: l.album, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &ArtistAnalyzer{Config: defaultArtistHistoryConfig()}
+	if err := analyzer.Configure(map[string]string{"artist": "radiohead", "n": "1", "timezone": "UTC"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	summary, tables, err := analyzer.analyze(context.Background(), s, user, end)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, line := range []string{
+		"Radiohead: 3 plays\n",
+		"First listen: 2020-11-30\n",
+		"Longest streak: 3 days (2020-11-30 to 2020-12-02)\n",
+	} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("summary missing %q. Got:\n%s", line, summary)
+		}
+	}
+
+	var titles []string
+	for _, table := range tables {
+		titles = append(titles, table.title)
+	}
+	// There are no gaps, so no dormant periods.
+	if want := []string{"Plays by month", "Top albums", "Top tracks", "Rank by year"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("tables = %v, want %v", titles, want)
+	}
+	wantTimeline := [][]string{
+		{"Year", "Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec", "Total"},
+		{"2020", "", "", "", "", "", "", "", "", "", "", "1", "2", "3"},
+	}
+	if !reflect.DeepEqual(tables[0].results, wantTimeline) {
+		t.Errorf("timeline = %v, want %v", tables[0].results, wantTimeline)
+	}
+	if want := [][]string{{"Album", "Plays"}, {"Kid A", "2"}}; !reflect.DeepEqual(tables[1].results, want) {
+		t.Errorf("top albums = %v, want %v", tables[1].results, want)
+	}
+	if want := [][]string{{"Year", "Plays", "Rank"}, {"2020", "3", "1 of 1"}}; !reflect.DeepEqual(tables[3].results, want) {
+		t.Errorf("ranks = %v, want %v", tables[3].results, want)
+	}
+
+	if err := (&ArtistAnalyzer{}).Configure(map[string]string{"gap_days": "half a year"}); err == nil {
+		t.Errorf("Configure with an invalid gap succeeded")
+	}
+	if _, err := (&ArtistAnalyzer{}).GetResults(context.Background(), s, user, end, end); err == nil {
+		t.Errorf("GetResults without an artist succeeded")
+	}
+}
+
+func TestArtistTimeline(t *testing.T) {
+	months := []analysis.MonthPlays{
+		{Month: time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC), Plays: 4},
+		{Month: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Plays: 0},
+		{Month: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), Plays: 2},
+	}
+	want := [][]string{
+		{"Year", "Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec", "Total"},
+		{"2019", "", "", "", "", "", "", "", "", "", "", "", "4", "4"},
+		{"2020", "0", "2", "", "", "", "", "", "", "", "", "", "", "2"},
+	}
+	if got := monthlyTimeline(months); !reflect.DeepEqual(got, want) {
+		t.Errorf("monthlyTimeline = %v, want %v", got, want)
+	}
+}
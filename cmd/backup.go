@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type BackupConfig struct {
+	DbPath     string
+	Dir        string
+	Gzip       bool
+	KeepDaily  int
+	KeepWeekly int
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Snapshots the database",
+	Long: `Writes a consistent, timestamped snapshot of the live database using SQLite's online backup API,
+then deletes old snapshots so that only the newest one from each of the last --keep_daily days and
+--keep_weekly weeks remain. Set both to 0 to keep every snapshot.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := BackupConfig{
+			DbPath:     viper.GetString("database"),
+			Dir:        viper.GetString("backup_dir"),
+			Gzip:       viper.GetBool("backup_gzip"),
+			KeepDaily:  viper.GetInt("keep_daily"),
+			KeepWeekly: viper.GetInt("keep_weekly"),
+		}
+		if config.Dir == "" {
+			config.Dir = filepath.Join(filepath.Dir(config.DbPath), "backups")
+		}
+		path, err := backupDatabase(cmd.Context(), config, time.Now())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Backed up database to %s\n", path)
+
+		deleted, err := rotateBackups(config)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, d := range deleted {
+			fmt.Printf("Deleted old backup %s\n", d)
+		}
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup file>",
+	Short: "Replaces the database with a backup",
+	Long: `Checks that the backup is intact and compatible with this version's schema, then swaps it in
+place of the database. The previous database is kept alongside with a .before-restore suffix.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := restoreDatabase(cmd.Context(), viper.GetString("database"), args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %s from %s\n", viper.GetString("database"), args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	var dir string
+	backupCmd.Flags().StringVar(&dir, "backup_dir", "", "Directory to write backups to (default is a 'backups' directory next to the database)")
+	viper.BindPFlag("backup_dir", backupCmd.Flags().Lookup("backup_dir"))
+
+	var compress bool
+	backupCmd.Flags().BoolVar(&compress, "gzip", false, "Compress backups with gzip")
+	viper.BindPFlag("backup_gzip", backupCmd.Flags().Lookup("gzip"))
+
+	var keepDaily int
+	backupCmd.Flags().IntVar(&keepDaily, "keep_daily", 7, "Number of daily backups to keep")
+	viper.BindPFlag("keep_daily", backupCmd.Flags().Lookup("keep_daily"))
+
+	var keepWeekly int
+	backupCmd.Flags().IntVar(&keepWeekly, "keep_weekly", 4, "Number of weekly backups to keep")
+	viper.BindPFlag("keep_weekly", backupCmd.Flags().Lookup("keep_weekly"))
+}
+
+const backupTimeFormat = "20060102-150405"
+
+// backupPattern matches backups of the database with the given base name, e.g. lastfm-20240101-120000.db.gz
+func backupPattern(dbPath string) *regexp.Regexp {
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(base) + `-(\d{8}-\d{6})\.db(\.gz)?$`)
+}
+
+func backupDatabase(ctx context.Context, config BackupConfig, now time.Time) (string, error) {
+	if _, err := os.Stat(config.DbPath); err != nil {
+		return "", fmt.Errorf("database doesn't exist: %w", err)
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return "", fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	db, err := store.New(config.DbPath)
+	if err != nil {
+		return "", fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	base := strings.TrimSuffix(filepath.Base(config.DbPath), filepath.Ext(config.DbPath))
+	path := filepath.Join(config.Dir, fmt.Sprintf("%s-%s.db", base, now.Format(backupTimeFormat)))
+
+	// Write to a temporary file first, so that a partial backup never looks like a complete one.
+	tmpPath := path + ".tmp"
+	defer os.Remove(tmpPath)
+	if err := db.Backup(ctx, tmpPath); err != nil {
+		return "", fmt.Errorf("backing up database: %w", err)
+	}
+
+	if config.Gzip {
+		path += ".gz"
+		if err := gzipFile(tmpPath, path); err != nil {
+			return "", fmt.Errorf("compressing backup: %w", err)
+		}
+		return path, nil
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("moving backup into place: %w", err)
+	}
+	return path, nil
+}
+
+func gzipFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dest)
+	if _, err := io.Copy(zw, src); err != nil {
+		dest.Close()
+		os.Remove(destPath)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dest.Close()
+		os.Remove(destPath)
+		return err
+	}
+	return dest.Close()
+}
+
+func gunzipFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	zr, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, zr); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+type backupFile struct {
+	Path string
+	Time time.Time
+}
+
+// rotateBackups deletes backups which aren't selected by selectBackupsToKeep, returning their paths.
+func rotateBackups(config BackupConfig) ([]string, error) {
+	if config.KeepDaily <= 0 && config.KeepWeekly <= 0 {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+
+	pattern := backupPattern(config.DbPath)
+	var backups []backupFile
+	for _, e := range entries {
+		matches := pattern.FindStringSubmatch(e.Name())
+		if matches == nil {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, matches[1], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{Path: filepath.Join(config.Dir, e.Name()), Time: t})
+	}
+
+	keep := selectBackupsToKeep(backups, config.KeepDaily, config.KeepWeekly)
+	var deleted []string
+	for _, b := range backups {
+		if keep[b.Path] {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil {
+			return deleted, fmt.Errorf("deleting backup: %w", err)
+		}
+		deleted = append(deleted, b.Path)
+	}
+	return deleted, nil
+}
+
+// selectBackupsToKeep returns the newest backup from each of the most recent daily days, plus the
+// newest backup from each of the most recent weekly ISO weeks.
+func selectBackupsToKeep(backups []backupFile, daily, weekly int) map[string]bool {
+	sorted := make([]backupFile, len(backups))
+	copy(sorted, backups)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time)
+	})
+
+	keep := make(map[string]bool)
+
+	days := make(map[string]bool)
+	for _, b := range sorted {
+		if len(days) >= daily {
+			break
+		}
+		day := b.Time.Format("2006-01-02")
+		if !days[day] {
+			days[day] = true
+			keep[b.Path] = true
+		}
+	}
+
+	weeks := make(map[string]bool)
+	for _, b := range sorted {
+		if len(weeks) >= weekly {
+			break
+		}
+		year, week := b.Time.ISOWeek()
+		key := fmt.Sprintf("%d-%02d", year, week)
+		if !weeks[key] {
+			weeks[key] = true
+			keep[b.Path] = true
+		}
+	}
+
+	return keep
+}
+
+func restoreDatabase(ctx context.Context, dbPath, backupPath string) error {
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("reading backup: %w", err)
+	}
+
+	// Stage the restored database next to the destination, so the final rename is atomic.
+	stagedPath := dbPath + ".restoring"
+	defer os.Remove(stagedPath)
+
+	var err error
+	if strings.HasSuffix(backupPath, ".gz") {
+		err = gunzipFile(backupPath, stagedPath)
+	} else {
+		err = copyFile(backupPath, stagedPath)
+	}
+	if err != nil {
+		return fmt.Errorf("staging backup: %w", err)
+	}
+
+	if err := store.CheckCompatibility(ctx, stagedPath); err != nil {
+		return fmt.Errorf("backup is not compatible: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".before-restore"); err != nil {
+			return fmt.Errorf("moving current database aside: %w", err)
+		}
+	}
+	if err := os.Rename(stagedPath, dbPath); err != nil {
+		return fmt.Errorf("moving backup into place: %w", err)
+	}
+	return nil
+}
+
+func copyFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
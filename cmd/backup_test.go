@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		db, dbPath := createTestDb(t)
+		user := "testuser"
+		if err := createUser(db, user); err != nil {
+			t.Fatalf("createUser: %v", err)
+		}
+		if err := createListenForDate(db, user, time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatalf("createListenForDate: %v", err)
+		}
+
+		config := BackupConfig{
+			DbPath: dbPath,
+			Dir:    filepath.Join(t.TempDir(), "backups"),
+			Gzip:   compress,
+		}
+		path, err := backupDatabase(context.Background(), config, time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local))
+		if err != nil {
+			t.Fatalf("backupDatabase: %v", err)
+		}
+		if want := "lastfm-20240102-030405.db"; !strings.HasPrefix(filepath.Base(path), want) {
+			t.Errorf("Backup name = %q, want prefix %q", filepath.Base(path), want)
+		}
+		if compress != strings.HasSuffix(path, ".gz") {
+			t.Errorf("Backup %q doesn't match gzip=%v", path, compress)
+		}
+
+		// Changes after the backup should be undone by restoring.
+		if _, err := db.Exec("DELETE FROM Listen"); err != nil {
+			t.Fatalf("deleting listens: %v", err)
+		}
+		db.Close()
+
+		if err := restoreDatabase(context.Background(), dbPath, path); err != nil {
+			t.Fatalf("restoreDatabase: %v", err)
+		}
+
+		restored, err := openDb(dbPath)
+		if err != nil {
+			t.Fatalf("openDb: %v", err)
+		}
+		var count int
+		if err := restored.QueryRow("SELECT COUNT(*) FROM Listen").Scan(&count); err != nil {
+			t.Fatalf("counting listens: %v", err)
+		}
+		restored.Close()
+		if count != 1 {
+			t.Errorf("Expected 1 listen after restore, got %d", count)
+		}
+		if _, err := os.Stat(dbPath + ".before-restore"); err != nil {
+			t.Errorf("Expected previous database to be kept: %v", err)
+		}
+	}
+}
+
+func TestRestoreRejectsIncompatibleBackup(t *testing.T) {
+	_, dbPath := createTestDb(t)
+
+	backupPath := filepath.Join(t.TempDir(), "bogus.db")
+	bogus, err := openDb(backupPath)
+	if err != nil {
+		t.Fatalf("openDb: %v", err)
+	}
+	if _, err := bogus.Exec("CREATE TABLE Unrelated (id INTEGER)"); err != nil {
+		t.Fatalf("creating table: %v", err)
+	}
+	bogus.Close()
+
+	if err := restoreDatabase(context.Background(), dbPath, backupPath); err == nil {
+		t.Fatal("restoreDatabase should have rejected a database without the expected schema")
+	}
+	if _, err := os.Stat(dbPath + ".before-restore"); err == nil {
+		t.Error("Current database should not have been moved aside")
+	}
+}
+
+func TestSelectBackupsToKeep(t *testing.T) {
+	// Two backups a day for 21 days, ending on Sunday 2024-01-21.
+	var backups []backupFile
+	end := time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)
+	for d := 0; d < 21; d++ {
+		for _, hour := range []int{6, 18} {
+			ts := end.AddDate(0, 0, -d).Add(time.Duration(hour) * time.Hour)
+			backups = append(backups, backupFile{Path: ts.Format(backupTimeFormat), Time: ts})
+		}
+	}
+
+	keep := selectBackupsToKeep(backups, 3, 2)
+
+	want := []string{
+		// Newest from each of the last 3 days. The newest also covers the current ISO week.
+		"20240121-180000",
+		"20240120-180000",
+		"20240119-180000",
+		// Newest from the previous ISO week, which ended on Sunday 2024-01-14.
+		"20240114-180000",
+	}
+	if len(keep) != len(want) {
+		t.Errorf("Kept %d backups, want %d: %v", len(keep), len(want), keep)
+	}
+	for _, w := range want {
+		if !keep[w] {
+			t.Errorf("Expected to keep %s", w)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	chartType   string
+	chartNumber int
+	chartTop    int
+	chartSort   string
+)
+
+var weeklyChartCmd = &cobra.Command{
+	Use:   "weekly-chart [date (optional)]",
+	Short: "Shows the user's weekly chart",
+	Long: `Shows the chart for the week containing the date, or for last week if no date is given. Date
+strings look like 'yyyy-mm-dd'. Chart weeks run from Monday 00:00 UTC, and charts are computed from
+the local listening history.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		week := store.WeekStart(time.Now()).AddDate(0, 0, -7)
+		if len(args) == 1 {
+			date, err := parseSingleDatestring(args[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			week = store.WeekStart(date.Date)
+		}
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printWeeklyChart(cmd.Context(), os.Stdout, db, viper.GetString("user"), week)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var chartRecordsCmd = &cobra.Command{
+	Use:   "chart-records [from (optional)] [to (optional)]",
+	Short: "Shows weekly chart records: weeks at #1, peaks and longest chart runs",
+	Long: `Uses the weeks starting in the specified date or date range, or all weeks if none is given. Date
+strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'. Only the top positions (see --top) count as
+being on the chart.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		start, end := time.Unix(0, 0), time.Unix(math.MaxInt32, 0)
+		if len(args) > 0 {
+			var err error
+			start, end, err = parseDateRangeFromArgs(args)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printChartRecords(cmd.Context(), os.Stdout, db, viper.GetString("user"), start, end)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var chartHistoryCmd = &cobra.Command{
+	Use:   "chart-history <artist>",
+	Short: "Shows an artist's weekly chart history",
+	Long: `Shows the artist's position in each week's chart from its first week on the chart to its last, and
+the peaks of its albums and tracks. Only the top positions (see --top) count as being on the chart.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printChartHistory(cmd.Context(), os.Stdout, db, viper.GetString("user"), args[0])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(weeklyChartCmd)
+	rootCmd.AddCommand(chartRecordsCmd)
+	rootCmd.AddCommand(chartHistoryCmd)
+
+	for _, cmd := range []*cobra.Command{weeklyChartCmd, chartRecordsCmd} {
+		cmd.Flags().StringVar(&chartType, "type", "artists", "what to chart: 'artists', 'albums' or 'tracks'")
+		cmd.Flags().IntVarP(&chartNumber, "number", "n", 20, "number of results to return")
+	}
+	for _, cmd := range []*cobra.Command{chartRecordsCmd, chartHistoryCmd} {
+		cmd.Flags().IntVar(&chartTop, "top", 10, "number of chart positions which count as being on the chart")
+	}
+	chartRecordsCmd.Flags().StringVar(&chartSort, "sort", "top", "sort order: 'top' (weeks at #1), 'weeks' (weeks on the chart) or 'run' (longest run)")
+}
+
+func parseChartKind(s string) (store.ChartKind, error) {
+	switch s {
+	case "artists":
+		return store.ChartArtists, nil
+	case "albums":
+		return store.ChartAlbums, nil
+	case "tracks":
+		return store.ChartTracks, nil
+	}
+	return "", fmt.Errorf("invalid chart type %q, must be 'artists', 'albums' or 'tracks'", s)
+}
+
+// updateCharts brings the user's weekly charts up to date.
+func updateCharts(ctx context.Context, db store.Store, user string) error {
+	if _, err := db.UpdateWeeklyCharts(ctx, user, time.Now()); err != nil {
+		return fmt.Errorf("updating weekly charts: %w", err)
+	}
+	return nil
+}
+
+func chartHeader(kind store.ChartKind) []string {
+	switch kind {
+	case store.ChartAlbums:
+		return []string{"Artist", "Album"}
+	case store.ChartTracks:
+		return []string{"Artist", "Track"}
+	}
+	return []string{"Artist"}
+}
+
+func chartNames(kind store.ChartKind, artist, name string) []string {
+	if kind == store.ChartArtists {
+		return []string{artist}
+	}
+	return []string{artist, name}
+}
+
+func printWeeklyChart(ctx context.Context, out io.Writer, db store.Store, user string, week time.Time) error {
+	kind, err := parseChartKind(chartType)
+	if err != nil {
+		return err
+	}
+	if err := updateCharts(ctx, db, user); err != nil {
+		return err
+	}
+
+	entries, err := db.GetWeeklyCharts(ctx, user, kind, week, week.AddDate(0, 0, 7))
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(out, "No chart for the week of %s.\n", week.Format("2006-01-02"))
+		return nil
+	}
+	previous, err := db.GetWeeklyCharts(ctx, user, kind, week.AddDate(0, 0, -7), week)
+	if err != nil {
+		return err
+	}
+	lastWeek := make(map[store.AlbumKey]int)
+	for _, e := range previous {
+		lastWeek[store.AlbumKey{Artist: e.Artist, Name: e.Name}] = e.Rank
+	}
+
+	fmt.Fprintf(out, "Week of %s\n", week.Format("2006-01-02"))
+	table := tablewriter.NewWriter(out)
+	table.Header(append(append([]string{"Rank"}, chartHeader(kind)...), "Listens", "Last Week"))
+	for _, e := range entries[:limitChart(len(entries), chartNumber)] {
+		last := "new"
+		if rank, ok := lastWeek[store.AlbumKey{Artist: e.Artist, Name: e.Name}]; ok {
+			last = strconv.Itoa(rank)
+		}
+		row := append([]string{strconv.Itoa(e.Rank)}, chartNames(kind, e.Artist, e.Name)...)
+		table.Append(append(row, strconv.FormatInt(e.Count, 10), last))
+	}
+	table.Render()
+	return nil
+}
+
+func printChartRecords(ctx context.Context, out io.Writer, db store.Store, user string, start, end time.Time) error {
+	kind, err := parseChartKind(chartType)
+	if err != nil {
+		return err
+	}
+	if err := updateCharts(ctx, db, user); err != nil {
+		return err
+	}
+
+	entries, err := db.GetWeeklyCharts(ctx, user, kind, start, end)
+	if err != nil {
+		return err
+	}
+	records := analysis.ChartRecords(entries, chartTop)
+	switch chartSort {
+	case "top":
+	case "weeks":
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].WeeksOnChart > records[j].WeeksOnChart
+		})
+	case "run":
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].LongestRun > records[j].LongestRun
+		})
+	default:
+		return fmt.Errorf("invalid sort order %q, must be 'top', 'weeks' or 'run'", chartSort)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(out, "No weekly charts found - run update first.")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.Header(append(chartHeader(kind), "Peak", "Weeks at Peak", "Weeks at Top", "Weeks on Chart", "Longest Run", "First Week", "Last Week"))
+	for _, r := range records[:limitChart(len(records), chartNumber)] {
+		table.Append(append(chartNames(kind, r.Artist, r.Name),
+			strconv.Itoa(r.Peak), strconv.Itoa(r.WeeksAtPeak), strconv.Itoa(r.WeeksAtTop), strconv.Itoa(r.WeeksOnChart),
+			strconv.Itoa(r.LongestRun), r.FirstWeek.Format("2006-01-02"), r.LastWeek.Format("2006-01-02")))
+	}
+	table.Render()
+	return nil
+}
+
+func printChartHistory(ctx context.Context, out io.Writer, db store.Store, user, artist string) error {
+	if err := updateCharts(ctx, db, user); err != nil {
+		return err
+	}
+
+	allTime := [2]time.Time{time.Unix(0, 0), time.Unix(math.MaxInt32, 0)}
+	artists, err := db.GetWeeklyCharts(ctx, user, store.ChartArtists, allTime[0], allTime[1])
+	if err != nil {
+		return err
+	}
+	points := analysis.ChartTrajectory(artists, artist, "", chartTop)
+	if len(points) == 0 {
+		fmt.Fprintf(out, "%s hasn't been in the top %d of a weekly chart.\n", artist, chartTop)
+		return nil
+	}
+
+	fmt.Fprintf(out, "## %s\n", artist)
+	table := tablewriter.NewWriter(out)
+	table.Header([]string{"Week", "Rank", "Listens"})
+	for _, p := range points {
+		rank, count := "-", ""
+		if p.Rank != 0 {
+			rank, count = strconv.Itoa(p.Rank), strconv.FormatInt(p.Count, 10)
+		}
+		table.Append([]string{p.Week.Format("2006-01-02"), rank, count})
+	}
+	table.Render()
+
+	for _, kind := range []store.ChartKind{store.ChartAlbums, store.ChartTracks} {
+		entries, err := db.GetWeeklyCharts(ctx, user, kind, allTime[0], allTime[1])
+		if err != nil {
+			return err
+		}
+		var records []analysis.ChartRecord
+		for _, r := range analysis.ChartRecords(entries, chartTop) {
+			if r.Artist == artist {
+				records = append(records, r)
+			}
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		header := chartHeader(kind)[1]
+		fmt.Fprintf(out, "\n## %ss\n", header)
+		table := tablewriter.NewWriter(out)
+		table.Header([]string{header, "Peak", "Weeks at Top", "Weeks on Chart", "Longest Run", "First Week"})
+		for _, r := range records {
+			table.Append([]string{r.Name, strconv.Itoa(r.Peak), strconv.Itoa(r.WeeksAtTop), strconv.Itoa(r.WeeksOnChart),
+				strconv.Itoa(r.LongestRun), r.FirstWeek.Format("2006-01-02")})
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func limitChart(n, limit int) int {
+	if limit > 0 && limit < n {
+		return limit
+	}
+	return n
+}
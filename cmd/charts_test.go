@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestCharts(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	addListens := func(week time.Time, artist, album string, count int) {
+		for i := 0; i < count; i++ {
+			ts := week.Add(time.Duration(len(tracks)) * time.Minute)
+			tracks = append(tracks, store.TrackImport{Artist: artist, Album: album, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+	}
+	week1 := time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC)
+	week2 := week1.AddDate(0, 0, 7)
+	addListens(week1, "Air", "Moon Safari", 5)
+	addListens(week1, "Blur", "Parklife", 3)
+	addListens(week2, "Blur", "Parklife", 4)
+	addListens(week2, "Cake", "Fashion Nugget", 1)
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	chartType, chartNumber, chartTop, chartSort = "artists", 20, 10, "top"
+
+	var out bytes.Buffer
+	if err := printWeeklyChart(context.Background(), &out, s, user, week2); err != nil {
+		t.Fatalf("printWeeklyChart: %v", err)
+	}
+	for _, want := range []string{"Week of 2020-03-09", "│ 1    │ Blur   │ 4       │ 2         │", "│ 2    │ Cake   │ 1       │ new       │"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printWeeklyChart() missing %q. Got:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := printChartHistory(context.Background(), &out, s, user, "Blur"); err != nil {
+		t.Fatalf("printChartHistory: %v", err)
+	}
+	for _, want := range []string{"## Blur", "│ 2020-03-02 │ 2    │ 3       │", "│ 2020-03-09 │ 1    │ 4       │", "## Albums", "│ Parklife │ 1    │ 1            │ 2              │ 2           │"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printChartHistory() missing %q. Got:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	chartSort = "weeks"
+	if err := printChartRecords(context.Background(), &out, s, user, time.Unix(0, 0), time.Now()); err != nil {
+		t.Fatalf("printChartRecords: %v", err)
+	}
+	if blur, air := strings.Index(out.String(), "Blur"), strings.Index(out.String(), "Air"); blur < 0 || air < 0 || blur > air {
+		t.Errorf("printChartRecords(sort=weeks) should list Blur before Air. Got:\n%s", out.String())
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"sort"
@@ -29,7 +30,9 @@ var checkSourcesCmd = &cobra.Command{
 	Short: "Checks for gaps in scrobbling activity",
 	Long:  `Analyzes scrobbles over a specified number of days (default 14) to detect potential failures in desktop (Work Hours) or mobile (Other Hours) scrobblers.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := checkSources(viper.GetString("database"), viper.GetString("user"), daysToCheck, historyDays)
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return checkSources(cmd.Context(), db, viper.GetString("user"), daysToCheck, historyDays)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -49,7 +52,7 @@ func init() {
 	checkSourcesCmd.Flags().StringVar(&workHours, "work-hours", "09-17", "Work hours interval (start-end)")
 }
 
-func checkSources(dbPath, user string, days int, history int) error {
+func checkSources(ctx context.Context, db store.Store, user string, days int, history int) error {
 	analyzer := &CheckSourcesAnalyzer{}
 	params := map[string]string{
 		"days":           strconv.Itoa(days),
@@ -70,7 +73,7 @@ func checkSources(dbPath, user string, days int, history int) error {
 		// Loop from past to present
 		for i := history; i >= 0; i-- {
 			simulatedDate := time.Now().AddDate(0, 0, -i)
-			res, err := analyzer.GetResults(dbPath, user, time.Time{}, simulatedDate)
+			res, err := analyzer.GetResults(ctx, db, user, time.Time{}, simulatedDate)
 			if err == ErrSkipReport {
 				continue
 			}
@@ -89,7 +92,7 @@ func checkSources(dbPath, user string, days int, history int) error {
 	}
 
 	// Use dummy times for GetResults as it calculates its own window based on 'days'
-	res, err := analyzer.GetResults(dbPath, user, time.Time{}, time.Time{})
+	res, err := analyzer.GetResults(ctx, db, user, time.Time{}, time.Time{})
 	if err == ErrSkipReport {
 		fmt.Println("No scrobbling issues detected.")
 		return nil
@@ -172,13 +175,7 @@ func (c *CheckSourcesAnalyzer) Configure(params map[string]string) error {
 	return nil
 }
 
-func (c *CheckSourcesAnalyzer) GetResults(dbPath string, user string, _ time.Time, endTime time.Time) (Analysis, error) {
-	db, err := store.New(dbPath)
-	if err != nil {
-		return Analysis{}, fmt.Errorf("opening database: %w", err)
-	}
-	defer db.Close()
-
+func (c *CheckSourcesAnalyzer) GetResults(ctx context.Context, db store.Store, user string, _ time.Time, endTime time.Time) (Analysis, error) {
 	// Resolve Location
 	loc := time.Local
 	if c.Timezone != "" {
@@ -200,7 +197,7 @@ func (c *CheckSourcesAnalyzer) GetResults(dbPath string, user string, _ time.Tim
 	start := todayStart.AddDate(0, 0, -c.Days)
 	end := now
 
-	listens, err := db.GetListensInRange(user, start, end)
+	listens, err := db.GetListensInRange(ctx, user, start, end)
 	if err != nil {
 		return Analysis{}, fmt.Errorf("getting listens: %w", err)
 	}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -9,56 +10,43 @@ import (
 )
 
 func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
-	// Setup DB
-	dbRaw, dbPath := createTestDb(t)
-	defer dbRaw.Close()
-	
-	// We need to initialize the Store to create tables properly if createTestDb doesn't do it fully?
-	// createTestDb calls createDatabase(dbPath), which is likely cmd/db_legacy.go's version.
-	// Let's rely on internal/store to ensure schema is correct for GetListensInRange.
-	s, err := store.New(dbPath)
-	if err != nil {
-		t.Fatalf("store.New: %v", err)
-	}
-	s.Close() // Close so we can re-open in GetResults or AddRecentTracks
-
 	user := "testuser"
 	// Use a fixed time for hermetic tests. 2024-06-03 is a Monday.
 	testNow := time.Date(2024, 6, 3, 12, 0, 0, 0, time.Local)
 
 	t.Run("All Good", func(t *testing.T) {
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 		
 		// Populate with recent data
 		for i := 0; i < 10; i++ {
 			d := testNow.AddDate(0, 0, -i)
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "14"}) // Set defaults + days
 		
-		_, err := analyzer.GetResults(dbPath, user, time.Time{}, testNow)
+		_, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, testNow)
 		if err != ErrSkipReport {
 			t.Errorf("Expected ErrSkipReport, got %v", err)
 		}
 	})
 
 	t.Run("Work Failure", func(t *testing.T) {
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		for i := 0; i < 10; i++ {
 			d := testNow.AddDate(0, 0, -i)
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "14"})
 
-		res, err := analyzer.GetResults(dbPath, user, time.Time{}, testNow)
+		res, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, testNow)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -69,21 +57,21 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	})
 
 	t.Run("Weekend Failure", func(t *testing.T) {
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		for i := 0; i < 20; i++ {
 			d := testNow.AddDate(0, 0, -i)
 			if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
 				continue // Skip weekend
 			}
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "20"})
 
-		res, err := analyzer.GetResults(dbPath, user, time.Time{}, testNow)
+		res, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, testNow)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -96,22 +84,22 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	t.Run("Weekend Failure - Mid-week Suppression", func(t *testing.T) {
 		// 2024-06-05 is a Wednesday.
 		midWeekNow := time.Date(2024, 6, 5, 12, 0, 0, 0, time.Local)
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		for i := 0; i < 20; i++ {
 			d := midWeekNow.AddDate(0, 0, -i)
 			if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
 				continue // Skip weekend
 			}
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "20", "weekend_streak": "2"})
 
-		_, err := analyzer.GetResults(dbPath, user, time.Time{}, midWeekNow)
+		_, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, midWeekNow)
 		// Currently (without fix), this will NOT be ErrSkipReport because weekendStreak=2 >= threshold.
 		// After fix, it should be ErrSkipReport.
 		if err != ErrSkipReport {
@@ -122,22 +110,22 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	t.Run("Weekend Failure - Monday Alert", func(t *testing.T) {
 		// 2024-06-03 is a Monday.
 		mondayNow := time.Date(2024, 6, 3, 12, 0, 0, 0, time.Local)
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		for i := 0; i < 20; i++ {
 			d := mondayNow.AddDate(0, 0, -i)
 			if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
 				continue // Skip weekend
 			}
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "20", "weekend_streak": "2"})
 
-		res, err := analyzer.GetResults(dbPath, user, time.Time{}, mondayNow)
+		res, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, mondayNow)
 		if err != nil {
 			t.Errorf("Unexpected error on Monday: %v", err)
 		}
@@ -150,19 +138,19 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	t.Run("Work Failure - Sunday Suppression", func(t *testing.T) {
 		// 2024-06-02 is a Sunday.
 		sundayNow := time.Date(2024, 6, 2, 12, 0, 0, 0, time.Local)
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		// 5 days of work silence (Mon-Fri)
 		for i := 0; i < 10; i++ {
 			d := sundayNow.AddDate(0, 0, -i)
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "14"})
 
-		_, err := analyzer.GetResults(dbPath, user, time.Time{}, sundayNow)
+		_, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, sundayNow)
 		if err != ErrSkipReport {
 			t.Errorf("Expected ErrSkipReport on Sunday for Work Failure, got %v", err)
 		}
@@ -171,19 +159,19 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	t.Run("Work Failure - Saturday Alert", func(t *testing.T) {
 		// 2024-06-01 is a Saturday.
 		saturdayNow := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		// 5 days of work silence (Mon-Fri)
 		for i := 0; i < 10; i++ {
 			d := saturdayNow.AddDate(0, 0, -i)
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 		}
 
 		analyzer := &CheckSourcesAnalyzer{}
 		analyzer.Configure(map[string]string{"days": "14"})
 
-		res, err := analyzer.GetResults(dbPath, user, time.Time{}, saturdayNow)
+		res, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, saturdayNow)
 		if err != nil {
 			t.Errorf("Unexpected error on Saturday: %v", err)
 		}
@@ -194,26 +182,26 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	})
 
 	t.Run("Sensitivity Configuration", func(t *testing.T) {
-		dbRaw, dbPath := createTestDb(t)
-		defer dbRaw.Close()
+		db := store.NewMemory()
+		defer db.Close()
 
 		// Create a scenario: 5 days of silence during Work Hours
 		// Add listens for 10 days, but SKIP work hours for the last 5 days
 		for i := 0; i < 10; i++ {
 			d := testNow.AddDate(0, 0, -i)
 			// Always add Other Hours
-			addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
+			addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 20, 0, 0, 0, time.Local))
 			
 			// Add Work Hours ONLY for days 6-10 (older)
 			if i >= 6 {
-				addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
+				addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 12, 0, 0, 0, time.Local))
 			}
 		}
 		
 		// Test A: Default Threshold (3). Should Alert.
 		analyzerDefault := &CheckSourcesAnalyzer{}
 		analyzerDefault.Configure(map[string]string{"days": "14"})
-		res, err := analyzerDefault.GetResults(dbPath, user, time.Time{}, testNow)
+		res, err := analyzerDefault.GetResults(context.Background(), db, user, time.Time{}, testNow)
 		if err != nil {
 			t.Errorf("Default: Unexpected error: %v", err)
 		}
@@ -224,7 +212,7 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 		// Test B: High Threshold (10). Streak (8) < 10. Should NOT Alert.
 		analyzerHigh := &CheckSourcesAnalyzer{}
 		analyzerHigh.Configure(map[string]string{"days": "14", "work_streak": "10"})
-		_, err = analyzerHigh.GetResults(dbPath, user, time.Time{}, testNow)
+		_, err = analyzerHigh.GetResults(context.Background(), db, user, time.Time{}, testNow)
 		if err != ErrSkipReport {
 			t.Errorf("High Threshold: Expected ErrSkipReport, got %v", err)
 		}
@@ -235,21 +223,21 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 		
 		// Subtest A: Listen at 09:30 (Other Hours). Window (10-18) is silent. Expect Alert.
 		t.Run("Alert Triggered", func(t *testing.T) {
-			dbRaw, dbPath := createTestDb(t)
-			defer dbRaw.Close()
+			db := store.NewMemory()
+			defer db.Close()
 			
 			for i := 0; i < 10; i++ {
 				d := testNow.AddDate(0, 0, -i)
 				// Listen at 09:30.
 				// If default (9-17), this is WORK. Streak = 0. No Alert.
 				// If custom (10-18), this is OTHER. Work Streak = 10. Alert.
-				addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 9, 30, 0, 0, time.Local))
+				addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 9, 30, 0, 0, time.Local))
 			}
 
 			analyzer := &CheckSourcesAnalyzer{}
 			analyzer.Configure(map[string]string{"days": "14", "work_hours": "10-18"})
 			
-			res, err := analyzer.GetResults(dbPath, user, time.Time{}, testNow)
+			res, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, testNow)
 			if err != nil {
 				t.Errorf("Unexpected error: %v", err)
 			}
@@ -260,18 +248,18 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 
 		// Subtest B: Listen at 10:30 (Work Hours). Expect No Alert.
 		t.Run("Alert Suppressed", func(t *testing.T) {
-			dbRaw, dbPath := createTestDb(t)
-			defer dbRaw.Close()
+			db := store.NewMemory()
+			defer db.Close()
 			
 			for i := 0; i < 10; i++ {
 				d := testNow.AddDate(0, 0, -i)
-				addListenForDB(t, dbPath, user, time.Date(d.Year(), d.Month(), d.Day(), 10, 30, 0, 0, time.Local))
+				addListenForDB(t, db, user, time.Date(d.Year(), d.Month(), d.Day(), 10, 30, 0, 0, time.Local))
 			}
 
 			analyzer := &CheckSourcesAnalyzer{}
 			analyzer.Configure(map[string]string{"days": "14", "work_hours": "10-18"})
 			
-			_, err := analyzer.GetResults(dbPath, user, time.Time{}, testNow)
+			_, err := analyzer.GetResults(context.Background(), db, user, time.Time{}, testNow)
 			if err != ErrSkipReport {
 				t.Errorf("Expected ErrSkipReport, got %v", err)
 			}
@@ -279,15 +267,9 @@ func TestCheckSourcesAnalyzer_GetResults(t *testing.T) {
 	})
 }
 
-func addListenForDB(t *testing.T, dbPath, user string, ts time.Time) {
-	s, err := store.New(dbPath)
-	if err != nil {
-		t.Fatalf("store.New: %v", err)
-	}
-	defer s.Close()
-
-	s.CreateUser(user)
-	err = s.AddRecentTracks(user, []store.TrackImport{
+func addListenForDB(t *testing.T, db store.Store, user string, ts time.Time) {
+	db.CreateUser(context.Background(), user)
+	err := db.AddRecentTracks(context.Background(), user, []store.TrackImport{
 		{
 			Artist:    "Artist",
 			Album:     "Album",
@@ -0,0 +1,251 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	cohortsOffsets  string
+	cohortsMinPlays int64
+	cohortsNumber   int
+	cohortsFormat   string
+)
+
+var cohortsCmd = &cobra.Command{
+	Use:   "cohorts [from (optional)] [to (optional)]",
+	Short: "Shows how many newly discovered artists kept being played",
+	Long: `Groups artists by the month of their first listen, over the whole history or the specified date
+range, and shows what share of each month's new artists were still played 1, 3, 6 and 12 months
+later. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'. Artists whose first year has
+passed are also classified as staples (played in at least half of the 12 months after their first),
+slow burns (played most at least 3 months after their first) or one-month wonders (never played
+after their first month).`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printCohorts(cmd.Context(), os.Stdout, db, viper.GetString("user"), args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cohortsCmd)
+
+	cohortsCmd.Flags().StringVar(&cohortsOffsets, "offsets", "1/3/6/12", "months after the first month at which retention is shown, separated by '/'")
+	cohortsCmd.Flags().Int64Var(&cohortsMinPlays, "min-plays", analysis.DefaultMinCohortPlays, "fewest plays in an artist's first year for them to be classified")
+	cohortsCmd.Flags().IntVarP(&cohortsNumber, "number", "n", 10, "max artists shown per class")
+	cohortsCmd.Flags().StringVar(&cohortsFormat, "format", "table", "output format: table, csv (the retention table) or json")
+}
+
+func printCohorts(ctx context.Context, out io.Writer, db store.Store, user string, args []string) error {
+	start := time.Unix(0, 0)
+	end := time.Now()
+	if len(args) > 0 {
+		var err error
+		start, end, err = parseDateRangeFromArgs(args)
+		if err != nil {
+			return err
+		}
+	}
+
+	analyzer := &CohortsAnalyzer{Config: defaultCohortConfig()}
+	err := analyzer.Configure(map[string]string{
+		"offsets":   cohortsOffsets,
+		"min_plays": strconv.FormatInt(cohortsMinPlays, 10),
+		"n":         strconv.Itoa(cohortsNumber),
+	})
+	if err != nil {
+		return err
+	}
+	cohorts, tables, err := analyzer.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return fmt.Errorf("printCohorts: %w", err)
+	}
+
+	switch cohortsFormat {
+	case "table":
+		if len(cohorts.Cohorts) == 0 {
+			fmt.Fprintln(out, "No new artists.")
+		}
+		for i, t := range tables {
+			if i > 0 {
+				fmt.Fprintf(out, "\n%s:\n", t.title)
+			}
+			fmt.Fprint(out, Analysis{results: t.results})
+		}
+	case "csv":
+		w := csv.NewWriter(out)
+		w.WriteAll(tables[0].results)
+		err = w.Error()
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(cohorts)
+	default:
+		return fmt.Errorf("invalid format %q, must be table, csv or json", cohortsFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("printCohorts: %w", err)
+	}
+	return nil
+}
+
+func defaultCohortConfig() analysis.CohortConfig {
+	return analysis.CohortConfig{
+		Offsets:         analysis.DefaultRetentionOffsets,
+		MinPlays:        analysis.DefaultMinCohortPlays,
+		ArtistsPerClass: 10,
+	}
+}
+
+// parseCohortOffsets parses months separated by '/', like "1/3/6/12".
+func parseCohortOffsets(val string) ([]int, error) {
+	var offsets []int
+	for _, part := range strings.Split(val, "/") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("%d should be at least 1 month", n)
+		}
+		offsets = append(offsets, n)
+	}
+	return offsets, nil
+}
+
+type CohortsAnalyzer struct {
+	Config analysis.CohortConfig
+}
+
+func (c *CohortsAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["offsets"]; ok {
+		offsets, err := parseCohortOffsets(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'offsets': %v", err)
+		}
+		c.Config.Offsets = offsets
+	}
+	if val, ok := params["min_plays"]; ok {
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'min_plays': %v", err)
+		}
+		c.Config.MinPlays = n
+	}
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		c.Config.ArtistsPerClass = n
+	}
+	return nil
+}
+
+func (c *CohortsAnalyzer) GetName() string {
+	return "Discovery cohorts"
+}
+
+// GetResults shows the cohorts of the CohortClassMonths months before the email's period as well as
+// its own, so that the longest retention is filled in. The results are HTML for emails.
+func (c *CohortsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
+	var a Analysis
+	cohorts, tables, err := c.analyze(ctx, db, user, start.AddDate(0, -analysis.CohortClassMonths, 0), end)
+	if err != nil {
+		return a, err
+	}
+
+	var sb strings.Builder
+	if len(cohorts.Cohorts) == 0 {
+		sb.WriteString("<div>No new artists.</div>\n")
+	}
+	for i, table := range tables {
+		if i > 0 {
+			fmt.Fprintf(&sb, "<h4>%s</h4>", html.EscapeString(table.title))
+		}
+		writeHTMLTable(&sb, table.results)
+	}
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns the cohorts, and tables of their retention, then the artists in each class with
+// any. Retention which hasn't been observed yet is shown as "-".
+func (c *CohortsAnalyzer) analyze(ctx context.Context, db store.Store, user string, start, end time.Time) (analysis.Cohorts, []titledTable, error) {
+	cohorts, err := analysis.GetCohorts(ctx, db, user, start, end, c.Config)
+	if err != nil {
+		return cohorts, nil, err
+	}
+
+	retention := [][]string{{"Cohort", "Artists"}}
+	for _, offset := range c.Config.Offsets {
+		retention[0] = append(retention[0], fmt.Sprintf("+%dm", offset))
+	}
+	for _, cohort := range cohorts.Cohorts {
+		row := []string{cohort.Month, strconv.Itoa(cohort.Artists)}
+		for _, r := range cohort.Retention {
+			if r.Observed {
+				row = append(row, fmt.Sprintf("%.0f%%", 100*r.Share))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		retention = append(retention, row)
+	}
+	tables := []titledTable{{title: "Retention", results: retention}}
+
+	for _, class := range analysis.CohortClasses {
+		artists := cohorts.Artists[class]
+		if len(artists) == 0 {
+			continue
+		}
+		results := [][]string{{"Artist", "Cohort", "Plays", "First month", "Active months", "Peak"}}
+		for _, a := range artists {
+			results = append(results, []string{
+				a.Artist,
+				a.Cohort,
+				strconv.FormatInt(a.Plays, 10),
+				strconv.FormatInt(a.FirstMonthPlays, 10),
+				strconv.Itoa(a.ActiveMonths),
+				a.PeakMonth,
+			})
+		}
+		title := fmt.Sprintf("%s (%d)", strings.ToUpper(class[:1])+class[1:]+"s", cohorts.ClassCounts[class])
+		tables = append(tables, titledTable{title: title, results: results})
+	}
+	return cohorts, tables, nil
+}
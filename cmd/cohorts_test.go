@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestCohorts(t *testing.T) {
+	s, user := createTestStore(t)
+	ctx := context.Background()
+	// Air is played every month of 2019, and Blur only in January.
+	var tracks []store.TrackImport
+	add := func(artist string, year, month, plays int) {
+		for i := 0; i < plays; i++ {
+			ts := time.Date(year, time.Month(month), 1, i+1, 0, 0, 0, time.UTC)
+			tracks = append(tracks, store.TrackImport{Artist: artist, Album: artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+	}
+	for m := 1; m <= 12; m++ {
+		add("Air", 2019, m, 2)
+	}
+	add("Blur", 2019, 1, 3)
+	add("Cher", 2019, 3, 1)
+	if err := s.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &CohortsAnalyzer{Config: defaultCohortConfig()}
+	if err := analyzer.Configure(map[string]string{"offsets": "1/12", "min_plays": "3", "n": "5"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	end := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, tables, err := analyzer.analyze(ctx, s, user, time.Unix(0, 0), end)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	want := []titledTable{
+		{title: "Retention", results: [][]string{
+			{"Cohort", "Artists", "+1m", "+12m"},
+			{"2019-01", "2", "50%", "0%"},
+			{"2019-03", "1", "0%", "-"},
+		}},
+		{title: "Staples (1)", results: [][]string{
+			{"Artist", "Cohort", "Plays", "First month", "Active months", "Peak"},
+			{"Air", "2019-01", "24", "2", "11", "2019-01"},
+		}},
+		{title: "One-month wonders (1)", results: [][]string{
+			{"Artist", "Cohort", "Plays", "First month", "Active months", "Peak"},
+			{"Blur", "2019-01", "3", "3", "0", "2019-01"},
+		}},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables = %+v\nwant %+v", tables, want)
+	}
+
+	email, err := analyzer.GetResults(ctx, s, user, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	if !strings.Contains(email.BodyOverride, "<td>2019-03</td><td>1</td><td>0%</td><td>-</td>") || strings.Contains(email.BodyOverride, "2019-01") {
+		t.Errorf("GetResults() HTML should have only the cohorts of the year before the period:\n%s", email.BodyOverride)
+	}
+
+	for _, params := range []map[string]string{{"offsets": "1/x"}, {"offsets": "0"}, {"min_plays": "few"}, {"n": "ten"}} {
+		if err := analyzer.Configure(params); err == nil {
+			t.Errorf("Configure(%v) succeeded, want an error", params)
+		}
+	}
+
+	cohortsOffsets = "1"
+	cohortsFormat = "csv"
+	defer func() { cohortsOffsets, cohortsFormat = "1/3/6/12", "table" }()
+	var out bytes.Buffer
+	if err := printCohorts(ctx, &out, s, user, []string{"2019-03", "2019-04"}); err != nil {
+		t.Fatalf("printCohorts: %v", err)
+	}
+	if want := "Cohort,Artists,+1m\n2019-03,1,-\n"; out.String() != want {
+		t.Errorf("printCohorts() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	compareNumber       int
+	compareMovers       int
+	compareAlbums       bool
+	comparePreviousFrom string
+	comparePreviousTo   string
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare [from] [to (optional)]",
+	Short: "Compares the user's top artists or albums with a previous period",
+	Long: `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+By default the period is compared with the one of the same length just before it (e.g. 2020-03 is
+compared with 2020-02). Shows each entry's rank and listens in both periods, new entries, drop-outs
+and the biggest risers and fallers.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printCompare(cmd.Context(), db, args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().IntVarP(&compareNumber, "number", "n", 20, "number of entries in each chart")
+	compareCmd.Flags().IntVar(&compareMovers, "movers", 5, "number of risers and fallers to show")
+	compareCmd.Flags().BoolVar(&compareAlbums, "albums", false, "compare albums instead of artists")
+	compareCmd.Flags().StringVar(&comparePreviousFrom, "previous_from", "", "start of the period to compare with, defaults to the period just before")
+	compareCmd.Flags().StringVar(&comparePreviousTo, "previous_to", "", "end of the period to compare with (optional)")
+}
+
+func printCompare(ctx context.Context, db store.Store, args []string) error {
+	start, end, err := parseDateRangeFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	analyzer := &CompareAnalyzer{NumToReturn: compareNumber, Movers: compareMovers, Albums: compareAlbums}
+	if comparePreviousFrom != "" {
+		previousArgs := []string{comparePreviousFrom}
+		if comparePreviousTo != "" {
+			previousArgs = append(previousArgs, comparePreviousTo)
+		}
+		analyzer.Previous.Start, analyzer.Previous.End, err = parseDateRangeFromArgs(previousArgs)
+		if err != nil {
+			return fmt.Errorf("parsing previous period: %w", err)
+		}
+	} else if comparePreviousTo != "" {
+		return fmt.Errorf("--previous_to requires --previous_from")
+	}
+
+	out, err := analyzer.GetResults(ctx, db, viper.GetString("user"), start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+type CompareAnalyzer struct {
+	// Number of entries in each chart, default is all of them.
+	NumToReturn int
+	// Number of risers and fallers to show.
+	Movers int
+	// Compare albums instead of artists.
+	Albums bool
+	// Period to compare with. If unset, this is the period of the same length just before the
+	// one being reported on.
+	Previous analysis.Period
+}
+
+func (t *CompareAnalyzer) Configure(params map[string]string) error {
+	t.NumToReturn = 20
+	t.Movers = 5
+
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.NumToReturn = n
+	}
+	if val, ok := params["movers"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'movers': %v", err)
+		}
+		t.Movers = n
+	}
+	if val, ok := params["type"]; ok {
+		switch val {
+		case "artists":
+			t.Albums = false
+		case "albums":
+			t.Albums = true
+		default:
+			return fmt.Errorf("invalid value for 'type': %q, must be 'artists' or 'albums'", val)
+		}
+	}
+	return nil
+}
+
+func (t *CompareAnalyzer) GetName() string {
+	if t.Albums {
+		return "Top albums compared with the previous period"
+	}
+	return "Top artists compared with the previous period"
+}
+
+func (t *CompareAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (a Analysis, err error) {
+	previous := t.Previous
+	if previous.Start.IsZero() && previous.End.IsZero() {
+		previous.Start, previous.End = previousDateRange(start, end)
+	}
+	comparison, err := analysis.Compare(ctx, db, user, analysis.CompareConfig{
+		Current:   analysis.Period{Start: start, End: end},
+		Previous:  previous,
+		Albums:    t.Albums,
+		ChartSize: t.NumToReturn,
+		Movers:    t.Movers,
+	})
+	if err != nil {
+		err = fmt.Errorf("printCompare: %w", err)
+		return
+	}
+
+	if t.Albums {
+		a.results = [][]string{{"Rank", "Artist", "Album", "Listens", "Previous Rank", "Previous Listens", "Change"}}
+	} else {
+		a.results = [][]string{{"Rank", "Artist", "Listens", "Previous Rank", "Previous Listens", "Change"}}
+	}
+	for _, c := range comparison.Chart {
+		row := []string{strconv.Itoa(c.Rank), c.Artist}
+		if t.Albums {
+			row = append(row, c.Album)
+		}
+		prevRank := "-"
+		if c.PrevRank != 0 {
+			prevRank = strconv.Itoa(c.PrevRank)
+		}
+		row = append(row, strconv.FormatInt(c.Count, 10), prevRank, strconv.FormatInt(c.PrevCount, 10), formatRankChange(c))
+		a.results = append(a.results, row)
+	}
+
+	const dateFormat = "2006-01-02"
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "From %s to %s, compared with %s to %s\n",
+		start.Format(dateFormat), end.Format(dateFormat), previous.Start.Format(dateFormat), previous.End.Format(dateFormat))
+	writeRankChanges(&summary, "New entries", comparison.NewEntries, t.Albums, false)
+	writeRankChanges(&summary, "Drop-outs", comparison.DropOuts, t.Albums, false)
+	writeRankChanges(&summary, "Biggest risers", comparison.Risers, t.Albums, true)
+	writeRankChanges(&summary, "Biggest fallers", comparison.Fallers, t.Albums, true)
+	a.summary = summary.String()
+
+	return
+}
+
+// formatRankChange formats how many places an entry moved, e.g. "+3", "-2", "=" or "new".
+func formatRankChange(c analysis.RankChange) string {
+	switch delta := c.Delta(); {
+	case c.PrevRank == 0:
+		return "new"
+	case delta > 0:
+		return fmt.Sprintf("+%d", delta)
+	case delta < 0:
+		return strconv.Itoa(delta)
+	default:
+		return "="
+	}
+}
+
+func writeRankChanges(out *strings.Builder, label string, changes []analysis.RankChange, albums bool, withDelta bool) {
+	if len(changes) == 0 {
+		return
+	}
+	var names []string
+	for _, c := range changes {
+		name := c.Artist
+		if albums {
+			name = fmt.Sprintf("%s - %s", c.Album, c.Artist)
+		}
+		if withDelta {
+			name = fmt.Sprintf("%s (%s)", name, formatRankChange(c))
+		}
+		names = append(names, name)
+	}
+	fmt.Fprintf(out, "%s: %s\n", label, strings.Join(names, ", "))
+}
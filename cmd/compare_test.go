@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestCompareAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	addListens := func(month time.Month, artist string, count int) {
+		for i := 0; i < count; i++ {
+			ts := time.Date(2020, month, 2, i, 0, 0, 0, time.UTC)
+			tracks = append(tracks, store.TrackImport{Artist: artist, Album: "Album", TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+	}
+	addListens(time.February, "Air", 5)
+	addListens(time.February, "Blur", 3)
+	addListens(time.March, "Blur", 4)
+	addListens(time.March, "Air", 2)
+	addListens(time.March, "Cake", 1)
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &CompareAnalyzer{}
+	if err := analyzer.Configure(map[string]string{"n": "3"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	start, end, err := parseDateRangeFromArgs([]string{"2020-03"})
+	if err != nil {
+		t.Fatalf("parseDateRangeFromArgs: %v", err)
+	}
+	got, err := analyzer.GetResults(context.Background(), s, user, start, end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+
+	want := [][]string{
+		{"Rank", "Artist", "Listens", "Previous Rank", "Previous Listens", "Change"},
+		{"1", "Blur", "4", "2", "3", "+1"},
+		{"2", "Air", "2", "1", "5", "-1"},
+		{"3", "Cake", "1", "-", "0", "new"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("GetResults() = %v, want %v", got.results, want)
+	}
+	for _, line := range []string{
+		"compared with 2020-02-01 to 2020-03-01",
+		"New entries: Cake\n",
+		"Biggest risers: Blur (+1)\n",
+		"Biggest fallers: Air (-1)\n",
+	} {
+		if !strings.Contains(got.summary, line) {
+			t.Errorf("GetResults() summary missing %q. Got:\n%s", line, got.summary)
+		}
+	}
+	if strings.Contains(got.summary, "Drop-outs") {
+		t.Errorf("GetResults() summary should have no drop-outs. Got:\n%s", got.summary)
+	}
+
+	if err := analyzer.Configure(map[string]string{"type": "tracks"}); err == nil {
+		t.Errorf("Configure(type=tracks) should fail")
+	}
+}
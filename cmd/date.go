@@ -147,3 +147,19 @@ func parseSingleDatestring(ds string) (date ParsedDate, err error) {
 	err = fmt.Errorf("Invalid format: %q", ds)
 	return
 }
+
+// previousDateRange returns the period of the same length that ends where start..end begins. Whole
+// years and months step back by a calendar year or month, so the period before March is all of
+// February rather than the 31 days before March.
+func previousDateRange(start, end time.Time) (prevStart time.Time, prevEnd time.Time) {
+	prevEnd = start
+	switch {
+	case start.AddDate(1, 0, 0).Equal(end) && start.YearDay() == 1:
+		prevStart = start.AddDate(-1, 0, 0)
+	case start.AddDate(0, 1, 0).Equal(end) && start.Day() == 1:
+		prevStart = start.AddDate(0, -1, 0)
+	default:
+		prevStart = start.Add(-end.Sub(start))
+	}
+	return
+}
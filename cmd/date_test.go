@@ -153,3 +153,25 @@ func TestGetExplicitDateRange_invalid(t *testing.T) {
 		t.Fatalf("Expected error when parsing invalid datestring")
 	}
 }
+
+func TestPreviousDateRange(t *testing.T) {
+	cases := []struct {
+		start, end, wantStart string
+	}{
+		{"2020-01-01", "2021-01-01", "2019-01-01"},
+		{"2020-03-01", "2020-04-01", "2020-02-01"},
+		{"2020-03-10", "2020-03-17", "2020-03-03"},
+		{"2020-03-15", "2020-04-15", "2020-02-13"},
+	}
+	for _, c := range cases {
+		start, _ := time.Parse("2006-01-02", c.start)
+		end, _ := time.Parse("2006-01-02", c.end)
+		prevStart, prevEnd := previousDateRange(start, end)
+		if got := prevStart.Format("2006-01-02"); got != c.wantStart {
+			t.Errorf("previousDateRange(%s, %s) start = %s, want %s", c.start, c.end, got, c.wantStart)
+		}
+		if !prevEnd.Equal(start) {
+			t.Errorf("previousDateRange(%s, %s) end = %s, want %s", c.start, c.end, prevEnd, start)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	diversityGranularity string
+	diversityTop         int
+	diversityFreshDays   int
+	diversityFormat      string
+)
+
+var diversityCmd = &cobra.Command{
+	Use:   "diversity [from (optional)] [to (optional)]",
+	Short: "Shows how varied the user's listening was over time",
+	Long: `Measures how the user's plays were spread across artists in each month, quarter or year, over the
+whole history or the specified date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+For each period, shows the Shannon entropy (in bits) and Gini coefficient of the plays across
+artists, the share of the plays from the top artists, the unique artists per 1000 scrobbles, and the
+share of fresh plays, of artists first listened to recently. Also shows the trend of each measure.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printDiversity(cmd.Context(), os.Stdout, db, viper.GetString("user"), args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diversityCmd)
+
+	diversityCmd.Flags().StringVar(&diversityGranularity, "granularity", analysis.GranularityYear, "length of each period: month, quarter or year")
+	diversityCmd.Flags().IntVar(&diversityTop, "top", 10, "number of top artists whose share of the plays is shown")
+	diversityCmd.Flags().IntVar(&diversityFreshDays, "fresh", int(analysis.DefaultFreshWindow/(24*time.Hour)), "plays within this many days of an artist's first listen are fresh")
+	diversityCmd.Flags().StringVar(&diversityFormat, "format", "table", "output format: table, csv or json")
+}
+
+func printDiversity(ctx context.Context, out io.Writer, db store.Store, user string, args []string) error {
+	start := time.Unix(0, 0)
+	end := time.Now()
+	if len(args) > 0 {
+		var err error
+		start, end, err = parseDateRangeFromArgs(args)
+		if err != nil {
+			return err
+		}
+	}
+
+	analyzer := &DiversityAnalyzer{Config: defaultDiversityConfig()}
+	err := analyzer.Configure(map[string]string{
+		"granularity": diversityGranularity,
+		"top":         strconv.Itoa(diversityTop),
+		"fresh_days":  strconv.Itoa(diversityFreshDays),
+	})
+	if err != nil {
+		return err
+	}
+	diversity, a, err := analyzer.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return fmt.Errorf("printDiversity: %w", err)
+	}
+
+	switch diversityFormat {
+	case "table":
+		fmt.Fprint(out, a)
+		if len(diversity.Periods) > 0 {
+			fmt.Fprint(out, Analysis{results: diversityTrendResults(diversity, analyzer.Config)})
+		}
+	case "csv":
+		w := csv.NewWriter(out)
+		w.WriteAll(a.results)
+		err = w.Error()
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diversity)
+	default:
+		return fmt.Errorf("invalid format %q, must be table, csv or json", diversityFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("printDiversity: %w", err)
+	}
+	return nil
+}
+
+func defaultDiversityConfig() analysis.DiversityConfig {
+	return analysis.DiversityConfig{
+		Granularity: analysis.GranularityYear,
+		FreshWindow: analysis.DefaultFreshWindow,
+		TopArtists:  10,
+	}
+}
+
+type DiversityAnalyzer struct {
+	Config analysis.DiversityConfig
+}
+
+func (d *DiversityAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["granularity"]; ok {
+		switch val {
+		case analysis.GranularityMonth, analysis.GranularityQuarter, analysis.GranularityYear:
+			d.Config.Granularity = val
+		default:
+			return fmt.Errorf("invalid value for 'granularity': %q, must be month, quarter or year", val)
+		}
+	}
+	if val, ok := params["top"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'top': %v", err)
+		}
+		d.Config.TopArtists = n
+	}
+	if val, ok := params["fresh_days"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'fresh_days': %v", err)
+		}
+		d.Config.FreshWindow = time.Duration(n) * 24 * time.Hour
+	}
+	return nil
+}
+
+func (d *DiversityAnalyzer) GetName() string {
+	return "Listening diversity"
+}
+
+// GetResults covers the whole history up to end, like the genre timeline, so that there's a trend to
+// show. The results are HTML for emails.
+func (d *DiversityAnalyzer) GetResults(ctx context.Context, db store.Store, user string, _ time.Time, end time.Time) (Analysis, error) {
+	diversity, a, err := d.analyze(ctx, db, user, time.Unix(0, 0), end)
+	if err != nil {
+		return a, err
+	}
+	if len(diversity.Periods) == 0 {
+		return a, nil
+	}
+
+	var sb strings.Builder
+	writeHTMLTable(&sb, a.results)
+	sb.WriteString("<h3>Trends</h3>")
+	writeHTMLTable(&sb, diversityTrendResults(diversity, d.Config))
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns the diversity, with a row for each period.
+func (d *DiversityAnalyzer) analyze(ctx context.Context, db store.Store, user string, start, end time.Time) (diversity analysis.Diversity, a Analysis, err error) {
+	diversity, err = analysis.GetDiversity(ctx, db, user, start, end, d.Config)
+	if err != nil {
+		return diversity, a, err
+	}
+
+	a.results = [][]string{{"Period", "Listens", "Artists", "Entropy", "Gini", fmt.Sprintf("Top %d share", d.Config.TopArtists), "Artists/1000", "Fresh"}}
+	for _, p := range diversity.Periods {
+		a.results = append(a.results, []string{
+			p.Label,
+			strconv.FormatInt(p.Listens, 10),
+			strconv.Itoa(p.Artists),
+			strconv.FormatFloat(p.Entropy, 'f', 2, 64),
+			strconv.FormatFloat(p.Gini, 'f', 2, 64),
+			fmt.Sprintf("%.0f%%", 100*p.TopShare),
+			strconv.FormatFloat(p.ArtistsPerThousand, 'f', 1, 64),
+			fmt.Sprintf("%.0f%%", 100*p.FreshShare),
+		})
+	}
+	return diversity, a, nil
+}
+
+func diversityTrendResults(diversity analysis.Diversity, config analysis.DiversityConfig) [][]string {
+	results := [][]string{{"Measure", fmt.Sprintf("Change per %s", config.Granularity)}}
+	for _, t := range diversity.Trends {
+		results = append(results, []string{t.Metric, strconv.FormatFloat(t.Slope, 'f', 4, 64)})
+	}
+	return results
+}
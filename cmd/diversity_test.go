@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestDiversity(t *testing.T) {
+	s, user := createTestStore(t)
+	ctx := context.Background()
+	// 2019 is split between Air and Blur. 2020 is mostly Air, who is no longer fresh.
+	var tracks []store.TrackImport
+	for i, l := range []struct {
+		year, month int
+		artist      string
+	}{{2019, 1, "Air"}, {2019, 2, "Air"}, {2019, 3, "Blur"}, {2019, 4, "Blur"}, {2020, 1, "Air"}, {2020, 2, "Air"}, {2020, 3, "Air"}, {2020, 4, "Cher"}} {
+		ts := time.Date(l.year, time.Month(l.month), 1, i, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &DiversityAnalyzer{Config: defaultDiversityConfig()}
+	if err := analyzer.Configure(map[string]string{"granularity": "year", "top": "1", "fresh_days": "90"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	_, a, err := analyzer.analyze(ctx, s, user, time.Unix(0, 0), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	want := [][]string{
+		{"Period", "Listens", "Artists", "Entropy", "Gini", "Top 1 share", "Artists/1000", "Fresh"},
+		{"2019", "4", "2", "1.00", "0.00", "50%", "500.0", "100%"},
+		{"2020", "4", "2", "0.81", "0.25", "75%", "500.0", "25%"},
+	}
+	if !reflect.DeepEqual(a.results, want) {
+		t.Errorf("results = %v, want %v", a.results, want)
+	}
+
+	email, err := analyzer.GetResults(ctx, s, user, time.Time{}, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	if !strings.Contains(email.BodyOverride, "<td>2020</td><td>4</td><td>2</td>") || !strings.Contains(email.BodyOverride, "<td>fresh_share</td><td>-0.7500</td>") {
+		t.Errorf("GetResults() HTML missing the periods or trends:\n%s", email.BodyOverride)
+	}
+
+	for _, params := range []map[string]string{{"granularity": "week"}, {"top": "ten"}, {"fresh_days": "x"}} {
+		if err := analyzer.Configure(params); err == nil {
+			t.Errorf("Configure(%v) succeeded, want an error", params)
+		}
+	}
+
+	diversityGranularity = "year"
+	diversityTop = 1
+	diversityFormat = "csv"
+	defer func() { diversityFormat = "table" }()
+	var out bytes.Buffer
+	if err := printDiversity(ctx, &out, s, user, []string{"2020"}); err != nil {
+		t.Fatalf("printDiversity: %v", err)
+	}
+	if want := "Period,Listens,Artists,Entropy,Gini,Top 1 share,Artists/1000,Fresh\n2020,4,2,0.81,0.25,75%,500.0,25%\n"; out.String() != want {
+		t.Errorf("printDiversity() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the database for integrity problems",
+	Long: `Runs a suite of integrity checks against the database: orphaned rows, duplicate listens,
+unparsable or future dates, empty artist names and schema drift versus the schema a new database gets.
+With --fix, repairs every problem found inside a single transaction.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runDoctor(cmd.Context(), os.Stdout, viper.GetString("database"), doctorFix)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair the problems found")
+}
+
+func runDoctor(ctx context.Context, out io.Writer, dbPath string, fix bool) error {
+	db, err := store.New(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	var issues []store.IntegrityIssue
+	if fix {
+		issues, err = db.RepairIntegrity(ctx, time.Now())
+	} else {
+		issues, err = db.CheckIntegrity(ctx, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+
+	header := []string{"Check", "Issues", "Examples"}
+	if fix {
+		header = append(header, "Fixed")
+	}
+	table := tablewriter.NewWriter(out)
+	table.Header(header)
+
+	total := 0
+	for _, issue := range issues {
+		row := []string{issue.Check, strconv.Itoa(issue.Count), strings.Join(issue.Examples, "\n")}
+		if fix {
+			row = append(row, strconv.FormatInt(issue.Fixed, 10))
+		}
+		table.Append(row)
+		total += issue.Count
+	}
+	table.Render()
+
+	switch {
+	case total == 0:
+		fmt.Fprintln(out, "No problems found.")
+	case fix:
+		fmt.Fprintf(out, "Repaired %d problems.\n", total)
+	default:
+		fmt.Fprintf(out, "Found %d problems. Run with --fix to repair them.\n", total)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/smtp"
 	"os"
@@ -23,6 +24,8 @@ import (
 	"time"
     "errors"
 
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -49,7 +52,7 @@ var emailCmd = &cobra.Command{
 	Use:   "email <address> <analysis_name...> [date] [date]",
 	Short: "Sends an email report",
 	Long: `Emails history to the specified user.
-  <analysis_name> is one or more of: top-artists, top-albums, new-artists, new-albums, forgotten, top-n, taste-report.
+  <analysis_name> is one or more of: top-artists, top-albums, top-tracks, compare, sessions, play-throughs, streaks, when, genre-timeline, diversity, new-artists, new-albums, cohorts, forgotten, rediscovered, top-n, taste-report, year-review, artist, album.
   Optional date arguments can be provided at the end (e.g. '2023-01' or '2023-01 2023-06').
   If no dates are provided, defaults to the previous month.`,
 	Args: cobra.MinimumNArgs(2),
@@ -140,7 +143,7 @@ var emailCmd = &cobra.Command{
 			Start:        start,
 			End:          end,
 		}
-		err = sendEmail(config)
+		err = sendEmail(cmd.Context(), config)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -158,7 +161,7 @@ func init() {
 	emailCmd.Flags().StringArray("params", nil, "Parameters for reports, matched by index (e.g. --params 'n=20')")
 }
 
-func sendEmail(config SendEmailConfig) error {
+func sendEmail(ctx context.Context, config SendEmailConfig) error {
 	actions := make([]Analyser, 0)
 	for i, actionName := range config.Types {
 		action, err := getActionFromName(actionName)
@@ -180,7 +183,7 @@ func sendEmail(config SendEmailConfig) error {
 
 		actions = append(actions, action)
 	}
-	subject, out, err := generateEmailContent(config, actions)
+	subject, out, err := generateEmailContent(ctx, config, actions)
 	
 	shouldSend := true
 	if err == ErrNoDataToReport {
@@ -237,7 +240,13 @@ func sendEmail(config SendEmailConfig) error {
 	return nil
 }
 
-func generateEmailContent(config SendEmailConfig, actions []Analyser) (subject string, body string, err error) {
+func generateEmailContent(ctx context.Context, config SendEmailConfig, actions []Analyser) (subject string, body string, err error) {
+	db, err := store.New(config.DbPath)
+	if err != nil {
+		return "", "", fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
 	out := `
 <html>
   <head>
@@ -259,7 +268,7 @@ table, th, td {
 		<div>
 `
 		out += fmt.Sprintf("<h2>%s for %s %s to %s:</h2>\n", action.GetName(), config.User, config.Start.Format("2006-01-02"), config.End.Format("2006-01-02"))
-		        		analysis, err := action.GetResults(config.DbPath, config.User, config.Start, config.End)
+		        		analysis, err := action.GetResults(ctx, db, config.User, config.Start, config.End)
 		        		if err == ErrSkipReport {
 		        			fmt.Printf("Skipping report %q (check-sources): no issues detected.\n", config.ReportName)
 		        			continue
@@ -268,14 +277,19 @@ table, th, td {
 		        		    return "", "", fmt.Errorf("getting results for %s: %w", action.GetName(), err)
 		        		}
 
-				if analysis.summary != "" {
+				if analysis.summary != "" && analysis.BodyOverride == "" {
 					out += fmt.Sprintf("<div>%s</div>\n", strings.ReplaceAll(analysis.summary, "\n", "<br>\n"))
 				}
 
-		        		if len(analysis.results) <= 1 {
+		        		switch {
+		        		case analysis.BodyOverride != "":
+		        		// The analysis renders its own HTML.
+		        		hasContent = true
+		        		out += analysis.BodyOverride + "\n"
+		        		case len(analysis.results) <= 1:
 		        		// No listens found
 		        		out += "<div>No listens found.</div>\n"
-		        		} else {
+		        		default:
 		        		hasContent = true
 		        		out += `
 		        		<table>
@@ -323,11 +337,26 @@ func getActionFromName(actionName string) (Analyser, error) {
 	actionMap := map[string]Analyser{
 		"top-artists":  &TopArtistsAnalyzer{Config: AnalyserConfig{20, 15}},
 		"top-albums":   &TopAlbumsAnalyzer{Config: AnalyserConfig{20, 15}},
+		"top-tracks":   &TopTracksAnalyzer{Config: AnalyserConfig{20, 5}},
+		"compare":      &CompareAnalyzer{NumToReturn: 20, Movers: 5},
+		"sessions":     &SessionsAnalyzer{Config: analysis.SessionConfig{Gap: analysis.DefaultSessionGap, Longest: 5, Artists: 5}},
+		"play-throughs": &PlayThroughsAnalyzer{Config: analysis.PlayThroughConfig{
+			Gap: analysis.DefaultSessionGap, MinCoverage: analysis.DefaultMinAlbumCoverage, MinTracks: analysis.DefaultMinAlbumTracks, Albums: 10,
+		}},
+		"streaks":      &StreaksAnalyzer{Config: analysis.StreakConfig{Artists: 5}},
+		"when":         &WhenAnalyzer{Config: analysis.WhenConfig{Artists: 3, Tags: 3}},
+		"genre-timeline": &GenreTimelineAnalyzer{Config: defaultGenreTimelineConfig()},
+		"diversity":    &DiversityAnalyzer{Config: defaultDiversityConfig()},
 		"new-artists":  &NewArtistsAnalyzer{Config: AnalyserConfig{0, 5}},
 		"new-albums":   &NewAlbumsAnalyzer{Config: AnalyserConfig{0, 5}},
+		"cohorts":      &CohortsAnalyzer{Config: defaultCohortConfig()},
 		"forgotten":    &ForgottenAnalyzer{},
+		"rediscovered": &RediscoveredAnalyzer{Config: defaultRediscoveredConfig()},
 		"top-n":        &TopNAnalyzer{},
-		"taste-report": &TasteReportAnalyzer{},
+		"taste-report": &TasteReportAnalyzer{Config: analysis.DefaultTasteReportConfig()},
+		"year-review":  &YearReviewAnalyzer{Config: defaultYearReviewConfig()},
+		"artist":       &ArtistAnalyzer{Config: defaultArtistHistoryConfig()},
+		"album":        &AlbumAnalyzer{Config: defaultAlbumHistoryConfig()},
 		"check-sources": &CheckSourcesAnalyzer{},
 	}
 
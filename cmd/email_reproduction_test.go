@@ -1,17 +1,48 @@
 package cmd
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
 )
 
 type MockSkipAnalyzer struct{}
 
 func (m *MockSkipAnalyzer) GetName() string { return "Mock Skip" }
-func (m *MockSkipAnalyzer) GetResults(dbPath string, user string, start, end time.Time) (Analysis, error) {
+func (m *MockSkipAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start, end time.Time) (Analysis, error) {
 	return Analysis{}, ErrSkipReport
 }
 
+type MockHTMLAnalyzer struct{}
+
+func (m *MockHTMLAnalyzer) GetName() string { return "Mock HTML" }
+func (m *MockHTMLAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start, end time.Time) (Analysis, error) {
+	return Analysis{BodyOverride: "<p>Mock heatmap</p>"}, nil
+}
+
+func TestGenerateEmailContent_WithBodyOverride(t *testing.T) {
+	db, dbPath := createTestDb(t)
+	defer db.Close()
+
+	config := SendEmailConfig{
+		DbPath: dbPath,
+		User:   "testuser",
+		Start:  time.Now(),
+		End:    time.Now(),
+	}
+
+	_, body, err := generateEmailContent(context.Background(), config, []Analyser{&MockHTMLAnalyzer{}})
+	if err != nil {
+		t.Fatalf("generateEmailContent: %v", err)
+	}
+	if !strings.Contains(body, "<p>Mock heatmap</p>") || strings.Contains(body, "No listens found") {
+		t.Errorf("email body doesn't contain the analysis HTML:\n%s", body)
+	}
+}
+
 func TestGenerateEmailContent_WithErrSkipReport(t *testing.T) {
 	db, dbPath := createTestDb(t)
 	defer db.Close()
@@ -34,7 +65,7 @@ func TestGenerateEmailContent_WithErrSkipReport(t *testing.T) {
 		&MockSkipAnalyzer{},
 	}
 
-	subject, body, err := generateEmailContent(config, actions)
+	subject, body, err := generateEmailContent(context.Background(), config, actions)
 
 	if err != ErrNoDataToReport {
 		t.Errorf("Expected ErrNoDataToReport, got: %v", err)
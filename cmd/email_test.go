@@ -17,6 +17,7 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
@@ -101,7 +102,7 @@ func TestGenerateEmailContent(t *testing.T) {
 		(&TopArtistsAnalyzer{}).SetConfig(AnalyserConfig{20, 0}),
 	}
 
-	subject, body, err := generateEmailContent(config, actions)
+	subject, body, err := generateEmailContent(context.Background(), config, actions)
 	if err != nil {
 		t.Fatalf("generateEmailContent failed: %v", err)
 	}
@@ -154,7 +155,7 @@ func TestGenerateEmailContentNoData(t *testing.T) {
 		(&TopArtistsAnalyzer{}).SetConfig(AnalyserConfig{20, 0}),
 	}
 
-	subject, body, err := generateEmailContent(config, actions)
+	subject, body, err := generateEmailContent(context.Background(), config, actions)
 	if err != ErrNoDataToReport {
 		t.Fatalf("Expected ErrNoDataToReport, got: %v", err)
 	}
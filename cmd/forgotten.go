@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -17,20 +18,30 @@ import (
 var (
 	minArtistScrobbles   int
 	minAlbumScrobbles    int
+	minTrackScrobbles    int
 	resultsPerBand       int
 	sortBy               string
 	lastListenAfterStr   string
 	lastListenBeforeStr  string
 	firstListenAfterStr  string
 	firstListenBeforeStr string
+	bandMode             string
+	artistBandsStr       string
+	albumBandsStr        string
+	trackBandsStr        string
+	percentilesStr       string
 )
 
 var forgottenCmd = &cobra.Command{
 	Use:   "forgotten",
-	Short: "Surfaces artists and albums heavily listened to in the past but not recently",
-	Long:  `Identifies music that has fallen out of rotation based on dormancy and historical listen counts.`,
+	Short: "Surfaces artists, albums and tracks heavily listened to in the past but not recently",
+	Long: `Identifies music that has fallen out of rotation based on dormancy and historical listen counts.
+Items can be snoozed, ignored or marked as revisited with the subcommands, so that each report
+surfaces fresh suggestions.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := printForgotten(viper.GetString("database"))
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printForgotten(cmd.Context(), db)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -43,12 +54,89 @@ func init() {
 
 	forgottenCmd.Flags().IntVar(&minArtistScrobbles, "min-artist", 10, "Minimum scrobbles for artist inclusion")
 	forgottenCmd.Flags().IntVar(&minAlbumScrobbles, "min-album", 5, "Minimum scrobbles for album inclusion")
+	forgottenCmd.Flags().IntVar(&minTrackScrobbles, "min-track", 5, "Minimum scrobbles for track inclusion")
 	forgottenCmd.Flags().IntVar(&resultsPerBand, "results", 10, "Max results shown per interest band")
-	forgottenCmd.Flags().StringVar(&sortBy, "sort", "dormancy", "Sort order: 'dormancy' or 'listens'")
+	forgottenCmd.Flags().StringVar(&sortBy, "sort", "dormancy", "Sort order: 'dormancy', 'listens' or 'score'")
 	forgottenCmd.Flags().StringVar(&lastListenAfterStr, "last_listen_after", "", "Only include entities with last listen after this date (YYYY-MM-DD)")
 	forgottenCmd.Flags().StringVar(&lastListenBeforeStr, "last_listen_before", "90d", "Only include entities with last listen before this date (YYYY-MM-DD or duration like 90d)")
 	forgottenCmd.Flags().StringVar(&firstListenAfterStr, "first_listen_after", "", "Only include entities with first listen after this date (YYYY-MM-DD)")
 	forgottenCmd.Flags().StringVar(&firstListenBeforeStr, "first_listen_before", "", "Only include entities with first listen before this date (YYYY-MM-DD)")
+	forgottenCmd.Flags().StringVar(&bandMode, "bands", "fixed", "How interest bands are chosen: 'fixed' or 'percentile'")
+	forgottenCmd.Flags().StringVar(&artistBandsStr, "artist-bands", "", "Fixed artist band thresholds as obsession/strong/moderate (default 120/50/15)")
+	forgottenCmd.Flags().StringVar(&albumBandsStr, "album-bands", "", "Fixed album band thresholds as obsession/strong/moderate (default 60/30/10)")
+	forgottenCmd.Flags().StringVar(&trackBandsStr, "track-bands", "", "Fixed track band thresholds as obsession/strong/moderate (default 40/20/8)")
+	forgottenCmd.Flags().StringVar(&percentilesStr, "percentiles", "", "Percentiles of your scrobbles per artist, album or track for percentile bands, as obsession/strong/moderate (default 99/95/85)")
+}
+
+// parseBandValues parses "obsession/strong/moderate" into three numbers. Commas separate report
+// params, so they can't separate the numbers.
+func parseBandValues(val string) ([3]float64, error) {
+	var values [3]float64
+	parts := strings.Split(val, "/")
+	if len(parts) != len(values) {
+		return values, fmt.Errorf("%q should be three numbers: obsession/strong/moderate", val)
+	}
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return values, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func parseBandThresholds(val string) (analysis.BandThresholds, error) {
+	v, err := parseBandValues(val)
+	if err != nil {
+		return analysis.BandThresholds{}, err
+	}
+	return analysis.BandThresholds{Obsession: int64(v[0]), Strong: int64(v[1]), Moderate: int64(v[2])}, nil
+}
+
+func parseBandPercentiles(val string) (analysis.BandPercentiles, error) {
+	v, err := parseBandValues(val)
+	if err != nil {
+		return analysis.BandPercentiles{}, err
+	}
+	return analysis.BandPercentiles{Obsession: v[0], Strong: v[1], Moderate: v[2]}, nil
+}
+
+// configureBands sets the band config from the bands, artist-bands, album-bands, track-bands and
+// percentiles params, which are also the flag names.
+func configureBands(config *analysis.ForgottenConfig, params map[string]string) error {
+	if val, ok := params["bands"]; ok {
+		config.BandMode = val
+	}
+	if val, ok := params["artist-bands"]; ok && val != "" {
+		bands, err := parseBandThresholds(val)
+		if err != nil {
+			return fmt.Errorf("invalid artist-bands: %w", err)
+		}
+		config.ArtistBands = bands
+	}
+	if val, ok := params["album-bands"]; ok && val != "" {
+		bands, err := parseBandThresholds(val)
+		if err != nil {
+			return fmt.Errorf("invalid album-bands: %w", err)
+		}
+		config.AlbumBands = bands
+	}
+	if val, ok := params["track-bands"]; ok && val != "" {
+		bands, err := parseBandThresholds(val)
+		if err != nil {
+			return fmt.Errorf("invalid track-bands: %w", err)
+		}
+		config.TrackBands = bands
+	}
+	if val, ok := params["percentiles"]; ok && val != "" {
+		percentiles, err := parseBandPercentiles(val)
+		if err != nil {
+			return fmt.Errorf("invalid percentiles: %w", err)
+		}
+		config.Percentiles = percentiles
+	}
+	return nil
 }
 
 type ForgottenAnalyzer struct {
@@ -59,6 +147,7 @@ func (f *ForgottenAnalyzer) Configure(params map[string]string) error {
 	// Defaults
 	f.Config.MinArtistScrobbles = 10
 	f.Config.MinAlbumScrobbles = 5
+	f.Config.MinTrackScrobbles = 5
 	f.Config.ResultsPerBand = 10
 	f.Config.SortBy = "dormancy"
 	f.Config.LastListenBefore = time.Now().AddDate(0, 0, -90)
@@ -80,6 +169,13 @@ func (f *ForgottenAnalyzer) Configure(params map[string]string) error {
 		}
 		f.Config.MinAlbumScrobbles = v
 	}
+	if val, ok := params["min-track"]; ok {
+		v, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid min-track: %w", err)
+		}
+		f.Config.MinTrackScrobbles = v
+	}
 	if val, ok := params["results"]; ok {
 		v, err := strconv.Atoi(val)
 		if err != nil {
@@ -90,6 +186,9 @@ func (f *ForgottenAnalyzer) Configure(params map[string]string) error {
 	if val, ok := params["sort"]; ok {
 		f.Config.SortBy = val
 	}
+	if err := configureBands(&f.Config, params); err != nil {
+		return err
+	}
 	if val, ok := params["last_listen_before"]; ok {
 		pd, err := parseSingleDatestring(val)
 		if err != nil {
@@ -125,14 +224,8 @@ func (f *ForgottenAnalyzer) GetName() string {
 	return "Forgotten"
 }
 
-func (f *ForgottenAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (Analysis, error) {
+func (f *ForgottenAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
 	var a Analysis
-	db, err := store.New(dbPath)
-	if err != nil {
-		return a, fmt.Errorf("opening database: %w", err)
-	}
-	defer db.Close()
-
 	// Use config from struct, but ensure defaults if not set?
 	// Configure sets defaults if called. If not called, we might have zero values.
 	// But CLI usage calls SetConfig manually or we construct it.
@@ -142,66 +235,108 @@ func (f *ForgottenAnalyzer) GetResults(dbPath string, user string, start time.Ti
 		f.Config.LastListenBefore = time.Now().AddDate(0, 0, -90)
 	}
 
-	artists, err := analysis.GetForgottenArtists(db, user, f.Config, time.Now())
-	if err != nil {
-		return a, err
-	}
-
-	albums, err := analysis.GetForgottenAlbums(db, user, f.Config, time.Now())
+	report, err := analysis.GetForgotten(ctx, db, user, f.Config, time.Now())
 	if err != nil {
 		return a, err
 	}
-
+	config, artists, albums, tracks := report.Config, report.Artists, report.Albums, report.Tracks
+	showScore := config.SortBy == analysis.SortScore
 	var sb strings.Builder
 	sb.WriteString("<h3>Forgotten Artists</h3>")
-	sb.WriteString(formatArtistBandHTML(artists, analysis.BandObsession))
-	sb.WriteString(formatArtistBandHTML(artists, analysis.BandStrong))
-	sb.WriteString(formatArtistBandHTML(artists, analysis.BandModerate))
+	sb.WriteString(formatArtistBandHTML(artists, analysis.BandObsession, config.ArtistBands, showScore))
+	sb.WriteString(formatArtistBandHTML(artists, analysis.BandStrong, config.ArtistBands, showScore))
+	sb.WriteString(formatArtistBandHTML(artists, analysis.BandModerate, config.ArtistBands, showScore))
 
 	sb.WriteString("<h3>Forgotten Albums</h3>")
-	sb.WriteString(formatAlbumBandHTML(albums, analysis.BandObsession))
-	sb.WriteString(formatAlbumBandHTML(albums, analysis.BandStrong))
-	sb.WriteString(formatAlbumBandHTML(albums, analysis.BandModerate))
+	sb.WriteString(formatAlbumBandHTML(albums, analysis.BandObsession, config.AlbumBands, showScore))
+	sb.WriteString(formatAlbumBandHTML(albums, analysis.BandStrong, config.AlbumBands, showScore))
+	sb.WriteString(formatAlbumBandHTML(albums, analysis.BandModerate, config.AlbumBands, showScore))
+
+	sb.WriteString("<h3>Forgotten Tracks</h3>")
+	sb.WriteString(formatTrackBandHTML(tracks, analysis.BandObsession, config.TrackBands, showScore))
+	sb.WriteString(formatTrackBandHTML(tracks, analysis.BandStrong, config.TrackBands, showScore))
+	sb.WriteString(formatTrackBandHTML(tracks, analysis.BandModerate, config.TrackBands, showScore))
 
 	a.BodyOverride = sb.String()
 	return a, nil
 }
 
-func formatArtistBandHTML(results map[string][]analysis.ForgottenArtist, band string) string {
+func formatArtistBandHTML(results map[string][]analysis.ForgottenArtist, band string, bands analysis.BandThresholds, showScore bool) string {
 	items, ok := results[band]
 	if !ok || len(items) == 0 {
 		return ""
 	}
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("<h4>%s Interest (%d+ scrobbles)</h4>", band, analysis.GetThreshold(band, true)))
-	sb.WriteString("<table><thead><tr><th>Artist</th><th>Scrobbles</th><th>Last Listen</th></tr></thead><tbody>")
+	sb.WriteString(fmt.Sprintf("<h4>%s Interest (%d+ scrobbles)</h4>", band, bands.Threshold(band)))
+	sb.WriteString("<table><thead><tr><th>Artist</th><th>Scrobbles</th><th>Last Listen</th>")
+	if showScore {
+		sb.WriteString("<th>Score</th>")
+	}
+	sb.WriteString("</tr></thead><tbody>")
 	for _, a := range items {
-		sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%s</td></tr>",
+		sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%s</td>",
 			a.Artist, a.TotalScrobbles, a.LastListen.Format("2006-01-02")))
+		if showScore {
+			sb.WriteString(fmt.Sprintf("<td>%.2f</td>", a.Score))
+		}
+		sb.WriteString("</tr>")
 	}
 	sb.WriteString("</tbody></table>")
 	return sb.String()
 }
 
-func formatAlbumBandHTML(results map[string][]analysis.ForgottenAlbum, band string) string {
+func formatAlbumBandHTML(results map[string][]analysis.ForgottenAlbum, band string, bands analysis.BandThresholds, showScore bool) string {
 	items, ok := results[band]
 	if !ok || len(items) == 0 {
 		return ""
 	}
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("<h4>%s Interest (%d+ scrobbles)</h4>", band, analysis.GetThreshold(band, false)))
-	sb.WriteString("<table><thead><tr><th>Artist</th><th>Album</th><th>Scrobbles</th><th>Last Listen</th></tr></thead><tbody>")
+	sb.WriteString(fmt.Sprintf("<h4>%s Interest (%d+ scrobbles)</h4>", band, bands.Threshold(band)))
+	sb.WriteString("<table><thead><tr><th>Artist</th><th>Album</th><th>Scrobbles</th><th>Last Listen</th>")
+	if showScore {
+		sb.WriteString("<th>Score</th>")
+	}
+	sb.WriteString("</tr></thead><tbody>")
 	for _, a := range items {
-		sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%d</td><td>%s</td></tr>",
+		sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%d</td><td>%s</td>",
 			a.Artist, a.Album, a.TotalScrobbles, a.LastListen.Format("2006-01-02")))
+		if showScore {
+			sb.WriteString(fmt.Sprintf("<td>%.2f</td>", a.Score))
+		}
+		sb.WriteString("</tr>")
 	}
 	sb.WriteString("</tbody></table>")
 	return sb.String()
 }
 
-func printForgotten(dbPath string) error {
+func formatTrackBandHTML(results map[string][]analysis.ForgottenTrack, band string, bands analysis.BandThresholds, showScore bool) string {
+	items, ok := results[band]
+	if !ok || len(items) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<h4>%s Interest (%d+ scrobbles)</h4>", band, bands.Threshold(band)))
+	sb.WriteString("<table><thead><tr><th>Artist</th><th>Track</th><th>Scrobbles</th><th>Last Listen</th>")
+	if showScore {
+		sb.WriteString("<th>Score</th>")
+	}
+	sb.WriteString("</tr></thead><tbody>")
+	for _, t := range items {
+		sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%d</td><td>%s</td>",
+			t.Artist, t.Track, t.TotalScrobbles, t.LastListen.Format("2006-01-02")))
+		if showScore {
+			sb.WriteString(fmt.Sprintf("<td>%.2f</td>", t.Score))
+		}
+		sb.WriteString("</tr>")
+	}
+	sb.WriteString("</tbody></table>")
+	return sb.String()
+}
+
+func printForgotten(ctx context.Context, db store.Store) error {
 	// Determine time range from global flags
 	var lastListenBefore time.Time
 	if lastListenBeforeStr != "" {
@@ -254,83 +389,134 @@ func printForgotten(dbPath string) error {
 		FirstListenBefore:  firstListenBefore,
 		MinArtistScrobbles: minArtistScrobbles,
 		MinAlbumScrobbles:  minAlbumScrobbles,
+		MinTrackScrobbles:  minTrackScrobbles,
 		ResultsPerBand:     resultsPerBand,
 		SortBy:             sortBy,
 	}
-
-	db, err := store.New(dbPath)
-	if err != nil {
-		return fmt.Errorf("opening database: %w", err)
+	bandParams := map[string]string{"bands": bandMode, "artist-bands": artistBandsStr, "album-bands": albumBandsStr, "track-bands": trackBandsStr, "percentiles": percentilesStr}
+	if err := configureBands(&config, bandParams); err != nil {
+		return err
 	}
-	defer db.Close()
 
 	user := viper.GetString("user")
-
-	// 1. Forgotten Artists
-	artists, err := analysis.GetForgottenArtists(db, user, config, time.Now())
+	report, err := analysis.GetForgotten(ctx, db, user, config, time.Now())
 	if err != nil {
 		return err
 	}
+	config, artists, albums, tracks := report.Config, report.Artists, report.Albums, report.Tracks
+	showScore := config.SortBy == analysis.SortScore
+
+	// 1. Forgotten Artists
 
 	fmt.Println("## Forgotten Artists")
-	printArtistBand(artists, analysis.BandObsession)
-	printArtistBand(artists, analysis.BandStrong)
-	printArtistBand(artists, analysis.BandModerate)
+	printArtistBand(artists, analysis.BandObsession, config.ArtistBands, showScore)
+	printArtistBand(artists, analysis.BandStrong, config.ArtistBands, showScore)
+	printArtistBand(artists, analysis.BandModerate, config.ArtistBands, showScore)
 	fmt.Println()
 
 	// 2. Forgotten Albums
-	albums, err := analysis.GetForgottenAlbums(db, user, config, time.Now())
-	if err != nil {
-		return err
-	}
 
 	fmt.Println("## Forgotten Albums")
-	printAlbumBand(albums, analysis.BandObsession)
-	printAlbumBand(albums, analysis.BandStrong)
-	printAlbumBand(albums, analysis.BandModerate)
+	printAlbumBand(albums, analysis.BandObsession, config.AlbumBands, showScore)
+	printAlbumBand(albums, analysis.BandStrong, config.AlbumBands, showScore)
+	printAlbumBand(albums, analysis.BandModerate, config.AlbumBands, showScore)
+	fmt.Println()
+
+	// 3. Forgotten Tracks
+
+	fmt.Println("## Forgotten Tracks")
+	printTrackBand(tracks, analysis.BandObsession, config.TrackBands, showScore)
+	printTrackBand(tracks, analysis.BandStrong, config.TrackBands, showScore)
+	printTrackBand(tracks, analysis.BandModerate, config.TrackBands, showScore)
 
 	return nil
 }
 
-func printArtistBand(results map[string][]analysis.ForgottenArtist, band string) {
+func printArtistBand(results map[string][]analysis.ForgottenArtist, band string, bands analysis.BandThresholds, showScore bool) {
 	items, ok := results[band]
 	if !ok || len(items) == 0 {
 		return
 	}
 
-	fmt.Printf("\n### %s Interest (%d+ scrobbles)\n", band, analysis.GetThreshold(band, true))
+	fmt.Printf("\n### %s Interest (%d+ scrobbles)\n", band, bands.Threshold(band))
 	
 	table := tablewriter.NewWriter(os.Stdout)
-	table.Header([]string{"Artist", "Scrobbles", "Last Listen"})
+	header := []string{"Artist", "Scrobbles", "Last Listen"}
+	if showScore {
+		header = append(header, "Score")
+	}
+	table.Header(header)
 	
 	for _, a := range items {
-		table.Append([]string{
+		row := []string{
 			a.Artist,
 			strconv.FormatInt(a.TotalScrobbles, 10),
 			a.LastListen.Format("2006-01-02"),
-		})
+		}
+		if showScore {
+			row = append(row, fmt.Sprintf("%.2f", a.Score))
+		}
+		table.Append(row)
 	}
 	table.Render()
 }
 
-func printAlbumBand(results map[string][]analysis.ForgottenAlbum, band string) {
+func printAlbumBand(results map[string][]analysis.ForgottenAlbum, band string, bands analysis.BandThresholds, showScore bool) {
 	items, ok := results[band]
 	if !ok || len(items) == 0 {
 		return
 	}
 
-	fmt.Printf("\n### %s Interest (%d+ scrobbles)\n", band, analysis.GetThreshold(band, false))
+	fmt.Printf("\n### %s Interest (%d+ scrobbles)\n", band, bands.Threshold(band))
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.Header([]string{"Artist", "Album", "Scrobbles", "Last Listen"})
+	header := []string{"Artist", "Album", "Scrobbles", "Last Listen"}
+	if showScore {
+		header = append(header, "Score")
+	}
+	table.Header(header)
 
 	for _, a := range items {
-		table.Append([]string{
+		row := []string{
 			a.Artist,
 			a.Album,
 			strconv.FormatInt(a.TotalScrobbles, 10),
 			a.LastListen.Format("2006-01-02"),
-		})
+		}
+		if showScore {
+			row = append(row, fmt.Sprintf("%.2f", a.Score))
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
+func printTrackBand(results map[string][]analysis.ForgottenTrack, band string, bands analysis.BandThresholds, showScore bool) {
+	items, ok := results[band]
+	if !ok || len(items) == 0 {
+		return
+	}
+
+	fmt.Printf("\n### %s Interest (%d+ scrobbles)\n", band, bands.Threshold(band))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"Artist", "Track", "Scrobbles", "Last Listen"}
+	if showScore {
+		header = append(header, "Score")
+	}
+	table.Header(header)
+
+	for _, t := range items {
+		row := []string{
+			t.Artist,
+			t.Track,
+			strconv.FormatInt(t.TotalScrobbles, 10),
+			t.LastListen.Format("2006-01-02"),
+		}
+		if showScore {
+			row = append(row, fmt.Sprintf("%.2f", t.Score))
+		}
+		table.Append(row)
 	}
 	table.Render()
-}
\ No newline at end of file
+}
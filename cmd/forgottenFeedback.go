@@ -0,0 +1,213 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	forgottenFeedbackAlbum string
+	forgottenFeedbackTrack string
+	forgottenSnoozeMonths  int
+)
+
+var forgottenSnoozeCmd = &cobra.Command{
+	Use:   "snooze <artist>",
+	Short: "Hides an artist, album or track from the forgotten results for a while",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runForgottenFeedback(cmd, args[0], store.FeedbackSnoozed)
+	},
+}
+
+var forgottenIgnoreCmd = &cobra.Command{
+	Use:   "ignore <artist>",
+	Short: "Hides an artist, album or track from the forgotten results for good",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runForgottenFeedback(cmd, args[0], store.FeedbackIgnored)
+	},
+}
+
+var forgottenRevisitedCmd = &cobra.Command{
+	Use:   "revisited <artist>",
+	Short: "Marks an artist, album or track as listened to somewhere that doesn't scrobble",
+	Long: `The item counts as listened to now, so it's left out of the forgotten results until it hasn't
+been listened to for long enough to be forgotten again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runForgottenFeedback(cmd, args[0], store.FeedbackRevisited)
+	},
+}
+
+var forgottenUnmarkCmd = &cobra.Command{
+	Use:   "unmark <artist>",
+	Short: "Removes the feedback on an artist, album or track, so it can be in the forgotten results again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return unmarkForgotten(cmd.Context(), os.Stdout, db, viper.GetString("user"), args[0], forgottenFeedbackAlbum, forgottenFeedbackTrack)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var forgottenFeedbackCmd = &cobra.Command{
+	Use:   "feedback",
+	Short: "Lists the snoozed, ignored and revisited items",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return listForgottenFeedback(cmd.Context(), os.Stdout, db, viper.GetString("user"), time.Now())
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{forgottenSnoozeCmd, forgottenIgnoreCmd, forgottenRevisitedCmd, forgottenUnmarkCmd} {
+		forgottenCmd.AddCommand(cmd)
+		cmd.Flags().StringVar(&forgottenFeedbackAlbum, "album", "", "The album by the artist, rather than the artist")
+		cmd.Flags().StringVar(&forgottenFeedbackTrack, "track", "", "The track by the artist, rather than the artist")
+	}
+	forgottenCmd.AddCommand(forgottenFeedbackCmd)
+
+	forgottenSnoozeCmd.Flags().IntVar(&forgottenSnoozeMonths, "months", 3, "Number of months to snooze for")
+}
+
+func runForgottenFeedback(cmd *cobra.Command, artist string, state store.FeedbackState) {
+	feedback, err := newForgottenFeedback(artist, forgottenFeedbackAlbum, forgottenFeedbackTrack, state, forgottenSnoozeMonths, time.Now())
+	if err == nil {
+		err = withStore(viper.GetString("database"), func(db store.Store) error {
+			return markForgotten(cmd.Context(), os.Stdout, db, viper.GetString("user"), feedback)
+		})
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// forgottenItem returns the kind and name of the item named by the artist and the --album or
+// --track flag.
+func forgottenItem(artist, album, track string) (store.FeedbackKind, string, error) {
+	switch {
+	case album != "" && track != "":
+		return "", "", fmt.Errorf("only one of --album and --track can be set")
+	case album != "":
+		return store.FeedbackAlbum, album, nil
+	case track != "":
+		return store.FeedbackTrack, track, nil
+	}
+	return store.FeedbackArtist, "", nil
+}
+
+func newForgottenFeedback(artist, album, track string, state store.FeedbackState, months int, now time.Time) (store.ForgottenFeedback, error) {
+	kind, name, err := forgottenItem(artist, album, track)
+	if err != nil {
+		return store.ForgottenFeedback{}, err
+	}
+	feedback := store.ForgottenFeedback{Kind: kind, Artist: artist, Name: name, State: state, Marked: now}
+	if state == store.FeedbackSnoozed {
+		if months < 1 {
+			return feedback, fmt.Errorf("invalid value for 'months': %d, must be at least 1", months)
+		}
+		feedback.Until = now.AddDate(0, months, 0)
+	}
+	return feedback, nil
+}
+
+func describeFeedbackItem(kind store.FeedbackKind, artist, name string) string {
+	if kind == store.FeedbackArtist {
+		return fmt.Sprintf("%q", artist)
+	}
+	return fmt.Sprintf("%s %q by %q", kind, name, artist)
+}
+
+func markForgotten(ctx context.Context, out io.Writer, db store.Store, user string, feedback store.ForgottenFeedback) error {
+	if err := db.SetForgottenFeedback(ctx, user, feedback); err != nil {
+		return err
+	}
+	item := describeFeedbackItem(feedback.Kind, feedback.Artist, feedback.Name)
+	switch feedback.State {
+	case store.FeedbackSnoozed:
+		fmt.Fprintf(out, "Snoozed %s until %s\n", item, feedback.Until.Format("2006-01-02"))
+	case store.FeedbackIgnored:
+		fmt.Fprintf(out, "Ignoring %s\n", item)
+	case store.FeedbackRevisited:
+		fmt.Fprintf(out, "Marked %s as revisited\n", item)
+	}
+	return nil
+}
+
+func unmarkForgotten(ctx context.Context, out io.Writer, db store.Store, user, artist, album, track string) error {
+	kind, name, err := forgottenItem(artist, album, track)
+	if err != nil {
+		return err
+	}
+	deleted, err := db.DeleteForgottenFeedback(ctx, user, kind, artist, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("no feedback on %s", describeFeedbackItem(kind, artist, name))
+	}
+	fmt.Fprintf(out, "Removed the feedback on %s\n", describeFeedbackItem(kind, artist, name))
+	return nil
+}
+
+// listForgottenFeedback lists the user's feedback. Snoozes which have ended are shown as expired.
+func listForgottenFeedback(ctx context.Context, out io.Writer, db store.Store, user string, now time.Time) error {
+	feedback, err := db.GetForgottenFeedback(ctx, user)
+	if err != nil {
+		return err
+	}
+	if len(feedback) == 0 {
+		fmt.Fprintln(out, "No feedback.")
+		return nil
+	}
+	table := tablewriter.NewWriter(out)
+	table.Header([]string{"Kind", "Artist", "Name", "State", "Marked", "Until"})
+	for _, f := range feedback {
+		state := string(f.State)
+		until := ""
+		if f.State == store.FeedbackSnoozed {
+			until = f.Until.Format("2006-01-02")
+			if !now.Before(f.Until) {
+				state = "snooze expired"
+			}
+		}
+		table.Append([]string{string(f.Kind), f.Artist, f.Name, state, f.Marked.Format("2006-01-02"), until})
+	}
+	table.Render()
+	return nil
+}
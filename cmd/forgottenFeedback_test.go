@@ -0,0 +1,112 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestForgottenFeedback(t *testing.T) {
+	ctx := context.Background()
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	listened := time.Now().AddDate(-2, 0, 0)
+	for i := 0; i < 50; i++ {
+		ts := listened.Add(time.Duration(i) * time.Minute)
+		tracks = append(tracks, store.TrackImport{Artist: "Can", Album: "Tago Mago", TrackName: "Halleluwah", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	results := func() string {
+		t.Helper()
+		f := &ForgottenAnalyzer{}
+		if err := f.Configure(map[string]string{}); err != nil {
+			t.Fatalf("Configure: %v", err)
+		}
+		a, err := f.GetResults(ctx, s, user, time.Time{}, time.Now())
+		if err != nil {
+			t.Fatalf("GetResults: %v", err)
+		}
+		return a.BodyOverride
+	}
+	if got := results(); !strings.Contains(got, "<td>Halleluwah</td>") || !strings.Contains(got, "<td>Tago Mago</td>") {
+		t.Fatalf("Expected the track and album in the forgotten results, got:\n%s", got)
+	}
+
+	now := time.Now()
+	if _, err := newForgottenFeedback("Can", "Tago Mago", "Halleluwah", store.FeedbackIgnored, 0, now); err == nil {
+		t.Errorf("newForgottenFeedback with both an album and a track succeeded")
+	}
+	if _, err := newForgottenFeedback("Can", "", "", store.FeedbackSnoozed, 0, now); err == nil {
+		t.Errorf("newForgottenFeedback with a zero month snooze succeeded")
+	}
+	var out bytes.Buffer
+	for _, item := range []struct {
+		album, track string
+		state        store.FeedbackState
+	}{
+		{"", "Halleluwah", store.FeedbackIgnored},
+		{"Tago Mago", "", store.FeedbackSnoozed},
+	} {
+		feedback, err := newForgottenFeedback("Can", item.album, item.track, item.state, 6, now)
+		if err != nil {
+			t.Fatalf("newForgottenFeedback: %v", err)
+		}
+		if err := markForgotten(ctx, &out, s, user, feedback); err != nil {
+			t.Fatalf("markForgotten: %v", err)
+		}
+	}
+	if want := fmt.Sprintf("Snoozed album \"Tago Mago\" by \"Can\" until %s\n", now.AddDate(0, 6, 0).Format("2006-01-02")); !strings.Contains(out.String(), want) {
+		t.Errorf("markForgotten output missing %q. Got:\n%s", want, out.String())
+	}
+	if got := results(); strings.Contains(got, "Halleluwah") || strings.Contains(got, "Tago Mago") {
+		t.Errorf("Expected the ignored track and snoozed album to be hidden, got:\n%s", got)
+	}
+
+	// After the snooze ends, it's listed as expired.
+	out.Reset()
+	if err := listForgottenFeedback(ctx, &out, s, user, now.AddDate(1, 0, 0)); err != nil {
+		t.Fatalf("listForgottenFeedback: %v", err)
+	}
+	for _, row := range [][]string{
+		{"album", "Can", "Tago Mago", "snooze expired"},
+		{"track", "Can", "Halleluwah", "ignored"},
+	} {
+		if !containsRow(out.String(), row) {
+			t.Errorf("listForgottenFeedback output missing row %v:\n%s", row, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := unmarkForgotten(ctx, &out, s, user, "Can", "", "Halleluwah"); err != nil {
+		t.Fatalf("unmarkForgotten: %v", err)
+	}
+	if err := unmarkForgotten(ctx, &out, s, user, "Can", "", "Halleluwah"); err == nil {
+		t.Errorf("unmarkForgotten without feedback succeeded")
+	}
+	if got := results(); !strings.Contains(got, "<td>Halleluwah</td>") {
+		t.Errorf("Expected the unmarked track in the forgotten results, got:\n%s", got)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestForgottenConfigureBands(t *testing.T) {
+	f := &ForgottenAnalyzer{}
+	params := map[string]string{
+		"bands":        "percentile",
+		"artist-bands": "200 / 80 / 20",
+		"percentiles":  "98/90/75",
+		"sort":         "score",
+	}
+	if err := f.Configure(params); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if f.Config.BandMode != analysis.BandModePercentile || f.Config.SortBy != analysis.SortScore {
+		t.Errorf("BandMode = %q, SortBy = %q", f.Config.BandMode, f.Config.SortBy)
+	}
+	if want := (analysis.BandThresholds{Obsession: 200, Strong: 80, Moderate: 20}); f.Config.ArtistBands != want {
+		t.Errorf("ArtistBands = %+v, want %+v", f.Config.ArtistBands, want)
+	}
+	if f.Config.AlbumBands != (analysis.BandThresholds{}) {
+		t.Errorf("AlbumBands = %+v, want unset", f.Config.AlbumBands)
+	}
+	if want := (analysis.BandPercentiles{Obsession: 98, Strong: 90, Moderate: 75}); f.Config.Percentiles != want {
+		t.Errorf("Percentiles = %+v, want %+v", f.Config.Percentiles, want)
+	}
+
+	for _, bad := range []map[string]string{
+		{"album-bands": "60/30"},
+		{"percentiles": "99/95/most"},
+	} {
+		if err := (&ForgottenAnalyzer{}).Configure(bad); err == nil {
+			t.Errorf("Configure(%v) succeeded", bad)
+		}
+	}
+}
+
+func TestForgottenAnalyzerPercentileBands(t *testing.T) {
+	s, user := createTestStore(t)
+	// Ten artists with 1 to 10 scrobbles, all in 2020.
+	var tracks []store.TrackImport
+	for i := 1; i <= 10; i++ {
+		for j := 0; j < i; j++ {
+			ts := time.Date(2020, 1, i, j, 0, 0, 0, time.UTC)
+			tracks = append(tracks, store.TrackImport{Artist: fmt.Sprintf("Artist %d", i), Album: "Album", TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	// The bands are 9, 5 and 2 scrobbles, which are below the default minimum of 10 for artists.
+	f := &ForgottenAnalyzer{}
+	if err := f.Configure(map[string]string{"bands": "percentile", "percentiles": "90/50/20"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	a, err := f.GetResults(context.Background(), s, user, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	artists, _, _ := strings.Cut(a.BodyOverride, "<h3>Forgotten Albums</h3>")
+	for heading, rows := range map[string]int{
+		"<h4>Obsession Interest (9+ scrobbles)</h4>": 2,
+		"<h4>Strong Interest (5+ scrobbles)</h4>":    4,
+		"<h4>Moderate Interest (2+ scrobbles)</h4>":  3,
+	} {
+		_, band, found := strings.Cut(artists, heading)
+		if !found {
+			t.Errorf("GetResults() artists missing %q:\n%s", heading, artists)
+			continue
+		}
+		band, _, _ = strings.Cut(band, "</table>")
+		if got := strings.Count(band, "<tr><td>"); got != rows {
+			t.Errorf("%s has %d artists, want %d", heading, got, rows)
+		}
+	}
+}
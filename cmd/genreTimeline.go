@@ -0,0 +1,234 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	genreTimelineGranularity string
+	genreTimelineTags        int
+	genreTimelineTrends      int
+	genreTimelineFormat      string
+	genreTimelineScoring     string
+)
+
+var genreTimelineCmd = &cobra.Command{
+	Use:   "genre-timeline [from (optional)] [to (optional)]",
+	Short: "Shows how the weights of the user's top tags changed over time",
+	Long: `Weights the user's top tags in each month, quarter or year, like the taste report, over the whole
+history or the specified date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+Shows each tag's peak period, and the tags which are emerging and declining the most, ranked by the
+slope of their weight over time.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printGenreTimeline(cmd.Context(), os.Stdout, db, viper.GetString("user"), args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(genreTimelineCmd)
+
+	genreTimelineCmd.Flags().StringVar(&genreTimelineGranularity, "granularity", analysis.GranularityYear, "length of each period: month, quarter or year")
+	genreTimelineCmd.Flags().IntVar(&genreTimelineTags, "tags", 10, "number of top tags to show")
+	genreTimelineCmd.Flags().IntVar(&genreTimelineTrends, "trends", 5, "number of emerging and declining tags to show")
+	genreTimelineCmd.Flags().StringVar(&genreTimelineScoring, "tag_scoring", string(analysis.TagScoringBinary), "how tags are weighted: binary (every tag gets the whole listen), count (shared by tag count) or tfidf (count, scaled down for tags common in the library)")
+	genreTimelineCmd.Flags().StringVar(&genreTimelineFormat, "format", "table", "output format: table, csv (the tag weight matrix) or json")
+}
+
+func printGenreTimeline(ctx context.Context, out io.Writer, db store.Store, user string, args []string) error {
+	start := time.Unix(0, 0)
+	end := time.Now()
+	if len(args) > 0 {
+		var err error
+		start, end, err = parseDateRangeFromArgs(args)
+		if err != nil {
+			return err
+		}
+	}
+
+	analyzer := &GenreTimelineAnalyzer{Config: defaultGenreTimelineConfig()}
+	err := analyzer.Configure(map[string]string{
+		"granularity": genreTimelineGranularity,
+		"tags":        strconv.Itoa(genreTimelineTags),
+		"trends":      strconv.Itoa(genreTimelineTrends),
+		"tag_scoring": genreTimelineScoring,
+	})
+	if err != nil {
+		return err
+	}
+	timeline, a, err := analyzer.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return fmt.Errorf("printGenreTimeline: %w", err)
+	}
+
+	switch genreTimelineFormat {
+	case "table":
+		fmt.Fprint(out, a)
+		if len(timeline.Trends) > 0 {
+			fmt.Fprint(out, Analysis{results: genreTrendResults(timeline)})
+		}
+	case "csv":
+		w := csv.NewWriter(out)
+		w.WriteAll(a.results)
+		err = w.Error()
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(timeline)
+	default:
+		return fmt.Errorf("invalid format %q, must be table, csv or json", genreTimelineFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("printGenreTimeline: %w", err)
+	}
+	return nil
+}
+
+func defaultGenreTimelineConfig() analysis.GenreTimelineConfig {
+	return analysis.GenreTimelineConfig{
+		Granularity: analysis.GranularityYear,
+		Tags:        10,
+		Trends:      5,
+		TagFilter:   analysis.DefaultTagFilter,
+		TagScoring:  analysis.TagScoringBinary,
+	}
+}
+
+type GenreTimelineAnalyzer struct {
+	Config analysis.GenreTimelineConfig
+}
+
+func (t *GenreTimelineAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["granularity"]; ok {
+		switch val {
+		case analysis.GranularityMonth, analysis.GranularityQuarter, analysis.GranularityYear:
+			t.Config.Granularity = val
+		default:
+			return fmt.Errorf("invalid value for 'granularity': %q, must be month, quarter or year", val)
+		}
+	}
+	if val, ok := params["tags"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'tags': %v", err)
+		}
+		t.Config.Tags = n
+	}
+	if val, ok := params["trends"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'trends': %v", err)
+		}
+		t.Config.Trends = n
+	}
+	if val, ok := params["tag_scoring"]; ok {
+		scoring, err := parseTagScoring(val)
+		if err != nil {
+			return err
+		}
+		t.Config.TagScoring = scoring
+	}
+	return nil
+}
+
+func (t *GenreTimelineAnalyzer) GetName() string {
+	return "Genre timeline"
+}
+
+// GetResults covers the whole history up to end, since a timeline of the email's period alone would
+// usually be a single period. The results are HTML for emails.
+func (t *GenreTimelineAnalyzer) GetResults(ctx context.Context, db store.Store, user string, _ time.Time, end time.Time) (Analysis, error) {
+	timeline, a, err := t.analyze(ctx, db, user, time.Unix(0, 0), end)
+	if err != nil {
+		return a, err
+	}
+	if len(timeline.Tags) == 0 {
+		return a, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div>%s</div>\n", strings.ReplaceAll(html.EscapeString(a.summary), "\n", "<br>\n"))
+	writeHTMLTable(&sb, a.results)
+	sb.WriteString("<h3>Tag trends</h3>")
+	writeHTMLTable(&sb, genreTrendResults(timeline))
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns the timeline, with a summary of the emerging and declining tags and the weight
+// matrix, with a row for each period and a column for each tag.
+func (t *GenreTimelineAnalyzer) analyze(ctx context.Context, db store.Store, user string, start, end time.Time) (timeline analysis.GenreTimeline, a Analysis, err error) {
+	timeline, err = analysis.GetGenreTimeline(ctx, db, user, start, end, t.Config)
+	if err != nil {
+		return timeline, a, err
+	}
+
+	trends := func(tags []analysis.TagTrend) string {
+		var parts []string
+		for _, tag := range tags {
+			parts = append(parts, fmt.Sprintf("%s (%+.3f/%s)", tag.Tag, tag.Slope, t.Config.Granularity))
+		}
+		return strings.Join(parts, ", ")
+	}
+	var summary []string
+	if len(timeline.Emerging) > 0 {
+		summary = append(summary, "Emerging: "+trends(timeline.Emerging))
+	}
+	if len(timeline.Declining) > 0 {
+		summary = append(summary, "Declining: "+trends(timeline.Declining))
+	}
+	a.summary = strings.Join(summary, "\n")
+
+	a.results = [][]string{append([]string{"Period", "Listens"}, timeline.Tags...)}
+	for j, p := range timeline.Periods {
+		row := []string{p.Label, strconv.FormatInt(p.Listens, 10)}
+		for i := range timeline.Tags {
+			row = append(row, strconv.FormatFloat(timeline.Weights[i][j], 'f', 2, 64))
+		}
+		a.results = append(a.results, row)
+	}
+	return timeline, a, nil
+}
+
+func genreTrendResults(timeline analysis.GenreTimeline) [][]string {
+	results := [][]string{{"Tag", "Peak", "Peak Weight", "Slope"}}
+	for _, t := range timeline.Trends {
+		results = append(results, []string{t.Tag, t.PeakPeriod, strconv.FormatFloat(t.PeakWeight, 'f', 2, 64), strconv.FormatFloat(t.Slope, 'f', 4, 64)})
+	}
+	return results
+}
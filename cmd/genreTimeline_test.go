@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGenreTimeline(t *testing.T) {
+	s, user := createTestStore(t)
+	ctx := context.Background()
+	s.SaveArtistTags(ctx, "Air", []string{"electronic", "french"}, []int{100, 50})
+	s.SaveArtistTags(ctx, "Blur", []string{"britpop", "rock"}, []int{100, 50})
+	var tracks []store.TrackImport
+	for i, l := range []struct {
+		month  int
+		artist string
+	}{{1, "Air"}, {2, "Air"}, {4, "Air"}, {5, "Blur"}} {
+		ts := time.Date(2020, time.Month(l.month), 1, i, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &GenreTimelineAnalyzer{Config: defaultGenreTimelineConfig()}
+	if err := analyzer.Configure(map[string]string{"granularity": "quarter", "tags": "2", "trends": "1"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	_, a, err := analyzer.analyze(ctx, s, user, time.Unix(0, 0), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	want := [][]string{
+		{"Period", "Listens", "electronic", "french"},
+		{"2020-Q1", "2", "1.00", "1.00"},
+		{"2020-Q2", "2", "0.50", "0.50"},
+	}
+	if !reflect.DeepEqual(a.results, want) {
+		t.Errorf("results = %v, want %v", a.results, want)
+	}
+	if a.summary != "Declining: electronic (-0.500/quarter)" {
+		t.Errorf("summary = %q, want electronic declining", a.summary)
+	}
+
+	email, err := analyzer.GetResults(ctx, s, user, time.Time{}, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	if !strings.Contains(email.BodyOverride, "<td>2020-Q2</td><td>2</td><td>0.50</td>") || !strings.Contains(email.BodyOverride, "<td>electronic</td><td>2020-Q1</td>") {
+		t.Errorf("GetResults() HTML missing the matrix or trends:\n%s", email.BodyOverride)
+	}
+
+	for _, params := range []map[string]string{{"granularity": "week"}, {"tag_scoring": "bm25"}} {
+		if err := analyzer.Configure(params); err == nil {
+			t.Errorf("Configure(%v) succeeded, want an error", params)
+		}
+	}
+
+	genreTimelineGranularity = "year"
+	genreTimelineTags = 1
+	genreTimelineFormat = "csv"
+	defer func() { genreTimelineFormat = "table" }()
+	var out bytes.Buffer
+	if err := printGenreTimeline(ctx, &out, s, user, []string{"2020"}); err != nil {
+		t.Fatalf("printGenreTimeline: %v", err)
+	}
+	if want := "Period,Listens,electronic\n2020,4,0.75\n"; out.String() != want {
+		t.Errorf("printGenreTimeline() = %q, want %q", out.String(), want)
+	}
+}
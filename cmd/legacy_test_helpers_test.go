@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"database/sql"
 	"path/filepath"
 	"testing"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
 )
 
 func createTestDb(t *testing.T) (*sql.DB, string) {
@@ -20,3 +23,16 @@ func createTestDb(t *testing.T) (*sql.DB, string) {
 
 	return db, dbPath
 }
+
+// createTestStore returns an in-memory store with a user, which is closed when the test ends.
+func createTestStore(t *testing.T) (*store.MemoryStore, string) {
+	t.Helper()
+	s := store.NewMemory()
+	t.Cleanup(func() { s.Close() })
+
+	user := "testuser"
+	if err := s.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return s, user
+}
@@ -17,13 +17,14 @@ package cmd
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"fmt"
 	"os"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/ademuri/last-fm-tools/internal/store"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -35,7 +36,9 @@ var newAlbumsCmd = &cobra.Command{
 	Long:  `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.`,
 	Args:  cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := printNewAlbums(viper.GetString("database"), newAlbumsNumber, args)
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printNewAlbums(cmd.Context(), db, newAlbumsNumber, args)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -90,12 +93,12 @@ func init() {
 	newAlbumsCmd.Flags().IntVarP(&newAlbumsNumber, "number", "n", 0, "number of results to return")
 }
 
-func printNewAlbums(dbPath string, numToReturn int, args []string) error {
+func printNewAlbums(ctx context.Context, db store.Store, numToReturn int, args []string) error {
 	start, end, err := parseDateRangeFromArgs(args)
 
 	config := AnalyserConfig{numToReturn, 0}
 	analyzer := &NewAlbumsAnalyzer{}
-	out, err := analyzer.SetConfig(config).GetResults(dbPath, viper.GetString("user"), start, end)
+	out, err := analyzer.SetConfig(config).GetResults(ctx, db, viper.GetString("user"), start, end)
 	if err != nil {
 		return err
 	}
@@ -103,31 +106,15 @@ func printNewAlbums(dbPath string, numToReturn int, args []string) error {
 	return nil
 }
 
-func (t *NewAlbumsAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
-	db, err := openDb(dbPath)
-	if err != nil {
-		err = fmt.Errorf("printNewAlbums: %w", err)
-		return
-	}
-
-	exists, err := dbExists(db)
-	if err != nil {
-		err = fmt.Errorf("printNewAlbums: %w", err)
-		return
-	}
-	if !exists {
-		err = fmt.Errorf("Database doesn't exist - run update first.")
-		return
-	}
-
+func (t *NewAlbumsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
 	out := new(bytes.Buffer)
 	var zeroTime time.Time
-	prevAlbums, err := getAlbumsForPeriod(db, user, zeroTime, start)
+	prevAlbums, err := getAlbumsForPeriod(ctx, db, user, zeroTime, start)
 	if err != nil {
 		err = fmt.Errorf("printNewAlbums: %w", err)
 		return
 	}
-	curAlbums, err := getAlbumsForPeriod(db, user, start, end)
+	curAlbums, err := getAlbumsForPeriod(ctx, db, user, start, end)
 	if err != nil {
 		err = fmt.Errorf("printNewAlbums: %w", err)
 		return
@@ -161,33 +148,15 @@ func (t *NewAlbumsAnalyzer) GetResults(dbPath string, user string, start time.Ti
 	return
 }
 
-func getAlbumsForPeriod(db *sql.DB, user string, start time.Time, end time.Time) (map[ArtistAlbum]int64, error) {
-	const countQueryString = `
-	SELECT Track.artist, Track.album, COUNT(Listen.id)
-	FROM Listen
-	INNER JOIN Track ON Track.id = Listen.track
-	WHERE user = ?
-	AND Listen.date BETWEEN ? AND ?
-	GROUP BY Track.artist, Track.album
-	;
-	`
-	albums := make(map[ArtistAlbum]int64)
-
-	countQuery, err := db.Query(countQueryString, user, start.Unix(), end.Unix())
+// getAlbumsForPeriod returns the plays of each album with start <= date <= end.
+func getAlbumsForPeriod(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (map[ArtistAlbum]int64, error) {
+	counts, err := db.GetTopAlbumsWithCount(ctx, user, start, end)
 	if err != nil {
-		return albums, fmt.Errorf("getAlbumsForPeriod: %w", err)
+		return nil, fmt.Errorf("getAlbumsForPeriod: %w", err)
 	}
-	defer countQuery.Close()
-
-	for countQuery.Next() {
-		var album ArtistAlbum
-		var count int64
-		err = countQuery.Scan(&album.Artist, &album.Album, &count)
-		if err != nil {
-			return albums, fmt.Errorf("getAlbumsForPeriod: %w", err)
-		}
-		albums[album] = count
+	albums := make(map[ArtistAlbum]int64)
+	for _, c := range counts {
+		albums[ArtistAlbum{c.Artist, c.Album}] = c.Count
 	}
-
 	return albums, nil
 }
@@ -17,13 +17,18 @@ limitations under the License.
 package cmd
 
 import (
-	"os"
+	"context"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
 )
 
 func TestPrintNewAlbumsDatabaseDoesntExist(t *testing.T) {
-	err := printNewAlbums(os.Getenv("TEST_TMPDIR")+"/invalid.db", 10, []string{"2020-05"})
+	err := withStore(filepath.Join(t.TempDir(), "invalid.db"), func(db store.Store) error {
+		return printNewAlbums(context.Background(), db, 10, []string{"2020-05"})
+	})
 	if err == nil {
 		t.Fatalf("printNewAlbums should have errored with no database")
 	}
@@ -17,13 +17,14 @@ package cmd
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"fmt"
 	"os"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/ademuri/last-fm-tools/internal/store"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -35,7 +36,9 @@ var newArtistsCmd = &cobra.Command{
 	Long:  `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.`,
 	Args:  cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := printNewArtists(viper.GetString("database"), newArtistsNumber, args)
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printNewArtists(cmd.Context(), db, newArtistsNumber, args)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -54,12 +57,12 @@ func init() {
 	newArtistsCmd.Flags().IntVarP(&newArtistsNumber, "number", "n", 0, "number of results to return")
 }
 
-func printNewArtists(dbPath string, numToReturn int, args []string) error {
+func printNewArtists(ctx context.Context, db store.Store, numToReturn int, args []string) error {
 	start, end, err := parseDateRangeFromArgs(args)
 
 	config := AnalyserConfig{numToReturn, 0}
 	analyzer := &NewArtistsAnalyzer{}
-	out, err := analyzer.SetConfig(config).GetResults(dbPath, viper.GetString("user"), start, end)
+	out, err := analyzer.SetConfig(config).GetResults(ctx, db, viper.GetString("user"), start, end)
 	if err != nil {
 		return err
 	}
@@ -98,41 +101,29 @@ func (t *NewArtistsAnalyzer) GetName() string {
 	return "New artists"
 }
 
-func (t *NewArtistsAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
-	db, err := openDb(dbPath)
-	if err != nil {
-		err = fmt.Errorf("printNewArtists: %w", err)
-		return
-	}
-
-	exists, err := dbExists(db)
-	if err != nil {
-		err = fmt.Errorf("printNewArtists: %w", err)
-		return
-	}
-	if !exists {
-		err = fmt.Errorf("Database doesn't exist - run update first.")
-		return
-	}
-
+func (t *NewArtistsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
 	out := new(bytes.Buffer)
 	var zeroTime time.Time
-	prevArtists, err := getArtistsForPeriod(db, user, zeroTime, start)
+	prevCounts, err := db.GetTopArtistsWithCount(ctx, user, zeroTime, start)
 	if err != nil {
 		err = fmt.Errorf("printNewArtists: %w", err)
 		return
 	}
-	curArtists, err := getArtistsForPeriod(db, user, start, end)
+	curCounts, err := db.GetTopArtistsWithCount(ctx, user, start, end)
 	if err != nil {
 		err = fmt.Errorf("printNewArtists: %w", err)
 		return
 	}
-	fmt.Fprintf(out, "Got %d prev artists, %d cur artists\n", len(prevArtists), len(curArtists))
+	fmt.Fprintf(out, "Got %d prev artists, %d cur artists\n", len(prevCounts), len(curCounts))
 
+	prevArtists := make(map[string]int64)
+	for _, c := range prevCounts {
+		prevArtists[c.Artist] = c.Count
+	}
 	counts := make([]ArtistCount, 0)
-	for artist, count := range curArtists {
-		if prevListens, ok := prevArtists[artist]; (!ok || prevListens < 5) && curArtists[artist] > 5 {
-			counts = append(counts, ArtistCount{artist, count})
+	for _, c := range curCounts {
+		if prevListens, ok := prevArtists[c.Artist]; (!ok || prevListens < 5) && c.Count > 5 {
+			counts = append(counts, ArtistCount{c.Artist, c.Count})
 		}
 	}
 	sort.Slice(counts, func(i, j int) bool {
@@ -155,34 +146,3 @@ func (t *NewArtistsAnalyzer) GetResults(dbPath string, user string, start time.T
 
 	return
 }
-
-func getArtistsForPeriod(db *sql.DB, user string, start time.Time, end time.Time) (map[string]int64, error) {
-	const countQueryString = `
-	SELECT Track.artist, COUNT(Listen.id)
-	FROM Listen
-	INNER JOIN Track ON Track.id = Listen.track
-	WHERE user = ?
-	AND Listen.date BETWEEN ? AND ?
-	GROUP BY Track.artist
-	;
-	`
-	artists := make(map[string]int64)
-
-	countQuery, err := db.Query(countQueryString, user, start.Unix(), end.Unix())
-	if err != nil {
-		return artists, fmt.Errorf("getArtistsForPeriod: %w", err)
-	}
-	defer countQuery.Close()
-
-	for countQuery.Next() {
-		var artist string
-		var count int64
-		err = countQuery.Scan(&artist, &count)
-		if err != nil {
-			return artists, fmt.Errorf("getArtistsForPeriod: %w", err)
-		}
-		artists[artist] = count
-	}
-
-	return artists, nil
-}
@@ -0,0 +1,166 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	personalTagAlbum string
+	personalTagMode  string
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manages personal tags for artists and albums",
+	Long: `Personal tags are your own tags for an artist or album. They're kept separately from the tags
+fetched from last.fm, so updates don't change them, and are used by every analysis. By default they
+supplement the fetched tags; use '--mode=replace' to use only the personal tags.`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <artist> [tags...]",
+	Short: "Adds personal tags to an artist or album",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return addPersonalTags(cmd.Context(), db, args[0], personalTagAlbum, args[1:], personalTagMode)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:   "remove <artist> [tags...]",
+	Short: "Removes personal tags from an artist or album, or all of them if no tags are given",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return removePersonalTags(cmd.Context(), os.Stdout, db, args[0], personalTagAlbum, args[1:])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list [artist]",
+	Short: "Lists personal tags, for every artist and album or just the artist's",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		artist := ""
+		if len(args) == 1 {
+			artist = args[0]
+		}
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return listPersonalTags(cmd.Context(), os.Stdout, db, artist)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRemoveCmd)
+	tagCmd.AddCommand(tagListCmd)
+
+	tagAddCmd.Flags().StringVar(&personalTagAlbum, "album", "", "Tag this album by the artist, rather than the artist")
+	tagAddCmd.Flags().StringVar(&personalTagMode, "mode", "", "Whether personal tags 'supplement' or 'replace' the fetched tags. Unchanged if unset, and 'supplement' for new tags.")
+	tagRemoveCmd.Flags().StringVar(&personalTagAlbum, "album", "", "Remove tags from this album by the artist, rather than the artist")
+}
+
+func addPersonalTags(ctx context.Context, db store.Store, artist, album string, tags []string, mode string) error {
+	var replace bool
+	switch mode {
+	case "":
+		if len(tags) == 0 {
+			return fmt.Errorf("no tags to add")
+		}
+	case "supplement":
+	case "replace":
+		replace = true
+	default:
+		return fmt.Errorf("invalid value for 'mode': %q, must be supplement or replace", mode)
+	}
+
+	if len(tags) > 0 {
+		if err := db.AddPersonalTags(ctx, artist, album, tags); err != nil {
+			return err
+		}
+	}
+	if mode != "" {
+		return db.SetPersonalTagsReplace(ctx, artist, album, replace)
+	}
+	return nil
+}
+
+func removePersonalTags(ctx context.Context, out io.Writer, db store.Store, artist, album string, tags []string) error {
+	removed, err := db.RemovePersonalTags(ctx, artist, album, tags)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("no matching personal tags found")
+	}
+	fmt.Fprintf(out, "Removed %d personal tags\n", removed)
+	return nil
+}
+
+func listPersonalTags(ctx context.Context, out io.Writer, db store.Store, artist string) error {
+	personal, err := db.GetPersonalTags(ctx)
+	if err != nil {
+		return err
+	}
+	table := tablewriter.NewWriter(out)
+	table.Header([]string{"Artist", "Album", "Mode", "Tags"})
+	rows := 0
+	for _, p := range personal {
+		if artist != "" && p.Artist != artist {
+			continue
+		}
+		mode := "supplement"
+		if p.Replace {
+			mode = "replace"
+		}
+		table.Append([]string{p.Artist, p.Album, mode, strings.Join(p.Tags, ", ")})
+		rows++
+	}
+	if rows == 0 {
+		fmt.Fprintln(out, "No personal tags.")
+		return nil
+	}
+	table.Render()
+	return nil
+}
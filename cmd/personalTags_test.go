@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/viper"
+)
+
+func TestPersonalTags(t *testing.T) {
+	ctx := context.Background()
+	s, user := createTestStore(t)
+	listened := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	tracks := []store.TrackImport{{Artist: "Neu!", Album: "Neu!", TrackName: "Hallogallo", DateUTS: fmt.Sprintf("%d", listened.Unix())}}
+	if err := s.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+	if err := s.SaveArtistTags(ctx, "Neu!", []string{"german", "electronic"}, []int{100, 50}); err != nil {
+		t.Fatalf("SaveArtistTags: %v", err)
+	}
+
+	if err := addPersonalTags(ctx, s, "Neu!", "", []string{"Krautrock"}, ""); err != nil {
+		t.Fatalf("addPersonalTags: %v", err)
+	}
+	if err := addPersonalTags(ctx, s, "Neu!", "", nil, "sometimes"); err == nil {
+		t.Errorf("addPersonalTags with an invalid mode succeeded")
+	}
+	if err := addPersonalTags(ctx, s, "Neu!", "Neu! 2", nil, "replace"); err == nil {
+		t.Errorf("setting the mode of an album without personal tags succeeded")
+	}
+
+	var out bytes.Buffer
+	if err := printTags(ctx, &out, s, "Neu!"); err != nil {
+		t.Fatalf("printTags: %v", err)
+	}
+	for _, row := range [][]string{
+		{"german", "last.fm", "100", "german"},
+		{"krautrock", "personal", "100", "krautrock"},
+	} {
+		if !containsRow(out.String(), row) {
+			t.Errorf("printTags output missing row %v:\n%s", row, out.String())
+		}
+	}
+
+	// Replacing the fetched tags leaves just the personal tag in the top-n tag column.
+	if err := addPersonalTags(ctx, s, "Neu!", "", nil, "replace"); err != nil {
+		t.Fatalf("addPersonalTags: %v", err)
+	}
+	viper.Set("user", user)
+	out.Reset()
+	if err := printTopN(ctx, &out, s, listened.AddDate(0, 0, -1), listened.AddDate(0, 0, 1), 10, 0, 0, 3); err != nil {
+		t.Fatalf("printTopN: %v", err)
+	}
+	if !strings.Contains(out.String(), "[krautrock]") {
+		t.Errorf("printTopN should only show the personal tag. Got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := listPersonalTags(ctx, &out, s, "Neu!"); err != nil {
+		t.Fatalf("listPersonalTags: %v", err)
+	}
+	if !containsRow(out.String(), []string{"Neu!", "replace", "krautrock"}) {
+		t.Errorf("listPersonalTags output missing tags:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := removePersonalTags(ctx, &out, s, "Neu!", "", nil); err != nil {
+		t.Fatalf("removePersonalTags: %v", err)
+	}
+	if err := removePersonalTags(ctx, &out, s, "Neu!", "", nil); err == nil {
+		t.Errorf("removing missing personal tags succeeded")
+	}
+	out.Reset()
+	if err := listPersonalTags(ctx, &out, s, ""); err != nil {
+		t.Fatalf("listPersonalTags: %v", err)
+	}
+	if out.String() != "No personal tags.\n" {
+		t.Errorf("listPersonalTags after removing = %q, want none", out.String())
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var playThroughsConfig = analysis.PlayThroughConfig{Gap: analysis.DefaultSessionGap}
+
+var playThroughsCmd = &cobra.Command{
+	Use:   "play-throughs [from] [to (optional)]",
+	Short: "Finds the albums the user most often plays all the way through",
+	Long: `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+A play-through is a run of consecutive listens from one album, in one session and without repeating
+a track, which covers at least --coverage of the album's known tracks. An album's known tracks are
+the ones which have been scrobbled, and track order isn't checked.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printPlayThroughs(cmd.Context(), db, args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(playThroughsCmd)
+
+	playThroughsCmd.Flags().IntVarP(&playThroughsConfig.Albums, "number", "n", 20, "number of albums to show")
+	playThroughsCmd.Flags().Float64Var(&playThroughsConfig.MinCoverage, "coverage", analysis.DefaultMinAlbumCoverage, "fraction of an album's known tracks a play-through must cover")
+	playThroughsCmd.Flags().IntVar(&playThroughsConfig.MinTracks, "min_tracks", analysis.DefaultMinAlbumTracks, "minimum number of known tracks for an album to count")
+}
+
+func printPlayThroughs(ctx context.Context, db store.Store, args []string) error {
+	start, end, err := parseDateRangeFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	analyzer := &PlayThroughsAnalyzer{Config: playThroughsConfig}
+	out, err := analyzer.GetResults(ctx, db, viper.GetString("user"), start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+type PlayThroughsAnalyzer struct {
+	Config analysis.PlayThroughConfig
+}
+
+func (t *PlayThroughsAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.Config.Albums = n
+	}
+	if val, ok := params["coverage"]; ok {
+		coverage, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'coverage': %v", err)
+		}
+		t.Config.MinCoverage = coverage
+	}
+	if val, ok := params["min_tracks"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'min_tracks': %v", err)
+		}
+		t.Config.MinTracks = n
+	}
+	return nil
+}
+
+func (t *PlayThroughsAnalyzer) GetName() string {
+	return "Album play-throughs"
+}
+
+func (t *PlayThroughsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (a Analysis, err error) {
+	stats, err := analysis.GetPlayThroughStats(ctx, db, user, start, end, t.Config)
+	if err != nil {
+		err = fmt.Errorf("printPlayThroughs: %w", err)
+		return
+	}
+
+	const dateFormat = "2006-01-02"
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Found %d album play-throughs from %s to %s\n",
+		len(stats.PlayThroughs), start.Format(dateFormat), end.Format(dateFormat))
+	if stats.Listens > 0 {
+		fmt.Fprintf(&summary, "%d of %d listens (%.0f%%) were part of a full album\n", stats.AlbumListens, stats.Listens, 100*stats.Share)
+	}
+	a.summary = summary.String()
+
+	a.results = [][]string{{"Artist", "Album", "Play-throughs", "Known Tracks", "Last Played"}}
+	for _, album := range stats.Albums {
+		a.results = append(a.results, []string{
+			album.Artist,
+			album.Album,
+			strconv.Itoa(album.PlayThroughs),
+			strconv.Itoa(album.KnownTracks),
+			album.LastPlayed.Format(dateFormat),
+		})
+	}
+	return
+}
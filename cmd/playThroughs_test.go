@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestPlayThroughsAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	base := time.Date(2020, 3, 1, 20, 0, 0, 0, time.UTC)
+	var tracks []store.TrackImport
+	add := func(minute int, album string, track int) {
+		ts := base.Add(time.Duration(minute) * time.Minute)
+		tracks = append(tracks, store.TrackImport{Artist: "Air", Album: album, TrackName: fmt.Sprintf("Track %d", track), DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	// Moon Safari is played through twice, then three of its tracks are played.
+	for i, minute := range []int{0, 100, 1000} {
+		for track := 1; track <= 4; track++ {
+			if minute == 1000 && track == 4 {
+				break
+			}
+			add(minute+4*track, "Moon Safari", track)
+		}
+		if i == 0 {
+			// A single in between.
+			add(50, "Kelly Watch the Stars", 1)
+		}
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &PlayThroughsAnalyzer{Config: analysis.PlayThroughConfig{Gap: analysis.DefaultSessionGap}}
+	if err := analyzer.Configure(map[string]string{"n": "5", "coverage": "1", "min_tracks": "4"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	got, err := analyzer.GetResults(context.Background(), s, user, base, base.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+
+	want := [][]string{
+		{"Artist", "Album", "Play-throughs", "Known Tracks", "Last Played"},
+		{"Air", "Moon Safari", "2", "4", "2020-03-01"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("GetResults() = %v, want %v", got.results, want)
+	}
+	for _, line := range []string{
+		"Found 2 album play-throughs",
+		"8 of 12 listens (67%) were part of a full album\n",
+	} {
+		if !strings.Contains(got.summary, line) {
+			t.Errorf("GetResults() summary missing %q. Got:\n%s", line, got.summary)
+		}
+	}
+}
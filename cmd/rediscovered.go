@@ -0,0 +1,196 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	rediscoveredDormancy int
+	rediscoveredMinPlays int64
+	rediscoveredResults  int
+)
+
+var rediscoveredCmd = &cobra.Command{
+	Use:   "rediscovered [from] [to (optional)]",
+	Short: "Shows artists and albums which came back after going unplayed for a long time",
+	Long: `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+Finds artists and albums which went unplayed for at least --dormancy days and then had at least
+--min-plays plays in the range, grouped into the same interest bands as 'forgotten' by their plays
+before the dormancy.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printRediscovered(cmd.Context(), os.Stdout, db, viper.GetString("user"), args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rediscoveredCmd)
+
+	rediscoveredCmd.Flags().IntVar(&rediscoveredDormancy, "dormancy", int(analysis.RediscoveryGap/(24*time.Hour)), "shortest gap between listens, in days, which counts as dormant")
+	rediscoveredCmd.Flags().Int64Var(&rediscoveredMinPlays, "min-plays", analysis.DefaultMinRediscoveredPlays, "fewest plays in the range after coming back")
+	rediscoveredCmd.Flags().IntVar(&rediscoveredResults, "results", 10, "max results shown per interest band")
+}
+
+func printRediscovered(ctx context.Context, out io.Writer, db store.Store, user string, args []string) error {
+	start, end, err := parseDateRangeFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	analyzer := &RediscoveredAnalyzer{Config: analysis.RediscoveredConfig{
+		MinDormancy:    time.Duration(rediscoveredDormancy) * 24 * time.Hour,
+		MinPlays:       rediscoveredMinPlays,
+		ResultsPerBand: rediscoveredResults,
+	}}
+	tables, err := analyzer.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		fmt.Fprintln(out, "Nothing rediscovered.")
+	}
+	for _, t := range tables {
+		fmt.Fprintf(out, "\n%s:\n", t.title)
+		fmt.Fprint(out, Analysis{results: t.results})
+	}
+	return nil
+}
+
+func defaultRediscoveredConfig() analysis.RediscoveredConfig {
+	return analysis.RediscoveredConfig{
+		MinDormancy:    analysis.RediscoveryGap,
+		MinPlays:       analysis.DefaultMinRediscoveredPlays,
+		ResultsPerBand: 10,
+	}
+}
+
+type RediscoveredAnalyzer struct {
+	Config analysis.RediscoveredConfig
+}
+
+func (t *RediscoveredAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["dormancy_days"]; ok {
+		days, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'dormancy_days': %v", err)
+		}
+		t.Config.MinDormancy = time.Duration(days) * 24 * time.Hour
+	}
+	if val, ok := params["min_plays"]; ok {
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'min_plays': %v", err)
+		}
+		t.Config.MinPlays = n
+	}
+	if val, ok := params["results"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'results': %v", err)
+		}
+		t.Config.ResultsPerBand = n
+	}
+	return nil
+}
+
+func (t *RediscoveredAnalyzer) GetName() string {
+	return "Rediscovered"
+}
+
+// GetResults returns HTML for emails, with a table for each interest band.
+func (t *RediscoveredAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
+	var a Analysis
+	tables, err := t.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return a, err
+	}
+
+	var sb strings.Builder
+	if len(tables) == 0 {
+		sb.WriteString("<div>Nothing rediscovered.</div>\n")
+	}
+	for _, table := range tables {
+		fmt.Fprintf(&sb, "<h4>%s</h4>", html.EscapeString(table.title))
+		writeHTMLTable(&sb, table.results)
+	}
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns a table of rediscovered artists, then albums, for each interest band with any.
+func (t *RediscoveredAnalyzer) analyze(ctx context.Context, db store.Store, user string, start, end time.Time) ([]titledTable, error) {
+	artists, err := analysis.GetRediscoveredArtists(ctx, db, user, start, end, t.Config)
+	if err != nil {
+		return nil, err
+	}
+	albums, err := analysis.GetRediscoveredAlbums(ctx, db, user, start, end, t.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []titledTable
+	for _, kind := range []struct {
+		name     string
+		results  map[string][]analysis.Rediscovery
+		isArtist bool
+	}{{"Artists", artists, true}, {"Albums", albums, false}} {
+		for _, band := range []string{analysis.BandObsession, analysis.BandStrong, analysis.BandModerate} {
+			items := kind.results[band]
+			if len(items) == 0 {
+				continue
+			}
+			header := []string{"Artist", "Plays", "Dormant", "Previous peak"}
+			if !kind.isArtist {
+				header = []string{"Artist", "Album", "Plays", "Dormant", "Previous peak"}
+			}
+			results := [][]string{header}
+			for _, r := range items {
+				row := []string{r.Artist}
+				if !kind.isArtist {
+					row = append(row, r.Album)
+				}
+				row = append(row,
+					strconv.FormatInt(r.Plays, 10),
+					fmt.Sprintf("%d days (since %s)", r.DormantDays, r.PreviousListen.Format("2006-01-02")),
+					fmt.Sprintf("%s (%d plays)", r.PeakMonth, r.PeakMonthPlays))
+				results = append(results, row)
+			}
+			title := fmt.Sprintf("Rediscovered %s: %s Interest (%d+ scrobbles)", strings.ToLower(kind.name), band, analysis.GetThreshold(band, kind.isArtist))
+			tables = append(tables, titledTable{title: title, results: results})
+		}
+	}
+	return tables, nil
+}
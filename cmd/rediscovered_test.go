@@ -0,0 +1,87 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestRediscoveredAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	add := func(ts time.Time, n int) {
+		for i := 0; i < n; i++ {
+			tracks = append(tracks, store.TrackImport{Artist: "Radiohead", Album: "Kid A", TrackName: "Idioteque", DateUTS: fmt.Sprintf("%d", ts.Add(time.Duration(i)*time.Minute).Unix())})
+		}
+	}
+	add(time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC), 20)
+	add(time.Date(2021, 3, 2, 12, 0, 0, 0, time.UTC), 4)
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &RediscoveredAnalyzer{Config: defaultRediscoveredConfig()}
+	if err := analyzer.Configure(map[string]string{"dormancy_days": "500", "min_plays": "4", "results": "5"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	tables, err := analyzer.analyze(context.Background(), s, user, start, end)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	var titles []string
+	for _, table := range tables {
+		titles = append(titles, table.title)
+	}
+	want := []string{
+		"Rediscovered artists: Moderate Interest (15+ scrobbles)",
+		"Rediscovered albums: Moderate Interest (10+ scrobbles)",
+	}
+	if !reflect.DeepEqual(titles, want) {
+		t.Fatalf("tables = %v, want %v", titles, want)
+	}
+	wantAlbums := [][]string{
+		{"Artist", "Album", "Plays", "Dormant", "Previous peak"},
+		{"Radiohead", "Kid A", "4", "731 days (since 2019-03-01)", "2019-03 (20 plays)"},
+	}
+	if !reflect.DeepEqual(tables[1].results, wantAlbums) {
+		t.Errorf("albums = %v, want %v", tables[1].results, wantAlbums)
+	}
+
+	// A longer dormancy than the gap finds nothing.
+	if err := analyzer.Configure(map[string]string{"dormancy_days": "800"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	a, err := analyzer.GetResults(context.Background(), s, user, start, end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	if !strings.Contains(a.BodyOverride, "Nothing rediscovered.") {
+		t.Errorf("GetResults = %q, want nothing rediscovered", a.BodyOverride)
+	}
+
+	if err := analyzer.Configure(map[string]string{"min_plays": "a few"}); err == nil {
+		t.Errorf("Configure with invalid min_plays succeeded")
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var searchNumber int
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Searches artists, albums and tracks by name",
+	Long: `Finds artists, albums and tracks whose names contain every word of the query, matching words
+by prefix and ignoring case and accents (so 'bjo' finds 'Björk'). Results are ordered by the
+user's play count.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := printSearch(cmd.Context(), os.Stdout, viper.GetString("database"), viper.GetString("user"), strings.Join(args, " "), searchNumber)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().IntVarP(&searchNumber, "number", "n", 20, "number of results to return")
+}
+
+func printSearch(ctx context.Context, out io.Writer, dbPath, user, query string, numToReturn int) error {
+	db, err := store.New(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	results, err := db.Search(ctx, user, query, numToReturn)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(out, "No matches for %q.\n", query)
+		return nil
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.Header([]string{"Type", "Name", "Artist", "Album", "Plays", "First Listen", "Last Listen", "Tags"})
+	for _, r := range results {
+		first, last := "", ""
+		if r.Plays > 0 {
+			first = r.FirstListen.Format("2006-01-02")
+			last = r.LastListen.Format("2006-01-02")
+		}
+		artist := r.Artist
+		if r.Kind == store.SearchKindArtist {
+			artist = ""
+		}
+		table.Append([]string{r.Kind, r.Name, artist, r.Album, strconv.FormatInt(r.Plays, 10), first, last, strings.Join(r.Tags, ", ")})
+	}
+	table.Render()
+	return nil
+}
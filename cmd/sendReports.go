@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -63,7 +64,7 @@ var sendReportsCmd = &cobra.Command{
 			Force:        force,
 			User:         viper.GetString("user"),
 		}
-		err := sendReports(config)
+		err := sendReports(cmd.Context(), config)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -81,12 +82,12 @@ func init() {
 	sendReportsCmd.Flags().BoolVarP(&force, "force", "f", false, "When true, send reports even if they've already been sent")
 }
 
-func sendReports(config SendReportsConfig) error {
+func sendReports(ctx context.Context, config SendReportsConfig) error {
 	db, err := createDatabase(config.DbPath)
 	if err != nil {
 		return err
 	}
-	reports, err := db.Query("SELECT name, user, email, sent, run_day, types, params, interval_days, next_run FROM Report")
+	reports, err := db.QueryContext(ctx, "SELECT name, user, email, sent, run_day, types, params, interval_days, next_run FROM Report")
 	if err != nil {
 		return fmt.Errorf("Querying reports: %w", err)
 	}
@@ -218,13 +219,16 @@ func sendReports(config SendReportsConfig) error {
 
 	errOccurred := false
 	for _, emailConfig := range emailConfigs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !config.DryRun {
 			updateConfig := UpdateConfig{
 				DbPath: config.DbPath,
 				User:   emailConfig.User,
 			}
 
-			err = updateDatabase(updateConfig)
+			err = updateDatabase(ctx, updateConfig)
 			if err != nil {
 				errOccurred = true
 				fmt.Printf("updateDatabase(%q): %w", emailConfig.User, err)
@@ -232,7 +236,7 @@ func sendReports(config SendReportsConfig) error {
 		}
 
 		fmt.Printf("Sending report (%q, %q)\n", emailConfig.User, emailConfig.ReportName)
-		err := sendEmail(emailConfig)
+		err := sendEmail(ctx, emailConfig)
 		if err != nil {
 			errOccurred = true
 			fmt.Printf("sendEmail: %w\n", err)
@@ -17,6 +17,7 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -46,12 +47,12 @@ func TestSendReports(t *testing.T) {
 		From:   "from@from.com",
 		DryRun: true,
 	}
-	err = sendReports(config)
+	err = sendReports(context.Background(), config)
 	if err != nil {
 		t.Fatalf("sendReports() error: %w", err)
 	}
 
-	err = sendReports(config)
+	err = sendReports(context.Background(), config)
 	if err != nil {
 		t.Fatalf("sendReports() error on second run: %w", err)
 	}
@@ -86,7 +87,7 @@ func TestSendReportsFilteringAndForce(t *testing.T) {
 	}
 	// We can't easily capture stdout to assert filtering, but we can rely on coverage or manual verification.
 	// However, we can verify it doesn't crash.
-	err = sendReports(config)
+	err = sendReports(context.Background(), config)
 	if err != nil {
 		t.Fatalf("sendReports() error with User filter: %w", err)
 	}
@@ -94,7 +95,7 @@ func TestSendReportsFilteringAndForce(t *testing.T) {
 	// Test Force flag
 	config.Force = true
 	config.User = "" // Clear user filter
-	err = sendReports(config)
+	err = sendReports(context.Background(), config)
 	if err != nil {
 		t.Fatalf("sendReports() error with Force=true: %w", err)
 	}
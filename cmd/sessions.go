@@ -0,0 +1,195 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	sessionsGap     time.Duration
+	sessionsLongest int
+	sessionsArtists int
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions [from] [to (optional)]",
+	Short: "Analyzes the user's listening sessions",
+	Long: `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+Listens are split into sessions wherever there's a gap of more than --gap between them. Reports the
+distribution of session lengths, listens per session, the longest sessions and which artists most
+often start and end sessions.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printSessions(cmd.Context(), db, args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+
+	sessionsCmd.Flags().DurationVar(&sessionsGap, "gap", analysis.DefaultSessionGap, "time without a listen which ends a session")
+	sessionsCmd.Flags().IntVarP(&sessionsLongest, "number", "n", 10, "number of longest sessions to show")
+	sessionsCmd.Flags().IntVar(&sessionsArtists, "artists", 5, "number of artists which most often start and end sessions to show")
+}
+
+func printSessions(ctx context.Context, db store.Store, args []string) error {
+	start, end, err := parseDateRangeFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	analyzer := &SessionsAnalyzer{Config: analysis.SessionConfig{Gap: sessionsGap, Longest: sessionsLongest, Artists: sessionsArtists}}
+	out, err := analyzer.GetResults(ctx, db, viper.GetString("user"), start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+type SessionsAnalyzer struct {
+	Config analysis.SessionConfig
+}
+
+func (t *SessionsAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["gap"]; ok {
+		gap, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'gap': %v", err)
+		}
+		t.Config.Gap = gap
+	}
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.Config.Longest = n
+	}
+	if val, ok := params["artists"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'artists': %v", err)
+		}
+		t.Config.Artists = n
+	}
+	return nil
+}
+
+func (t *SessionsAnalyzer) GetName() string {
+	return "Listening sessions"
+}
+
+func (t *SessionsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (a Analysis, err error) {
+	stats, err := analysis.GetSessionStats(ctx, db, user, start, end, t.Config)
+	if err != nil {
+		err = fmt.Errorf("printSessions: %w", err)
+		return
+	}
+
+	const dateFormat = "2006-01-02"
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Found %d sessions with %d listens from %s to %s (a session ends after %s without a listen)\n",
+		stats.Sessions, stats.Listens, start.Format(dateFormat), end.Format(dateFormat), formatSessionDuration(t.Config.Gap))
+	if stats.Sessions == 0 {
+		a.summary = summary.String()
+		return
+	}
+	fmt.Fprintf(&summary, "Session length: median %s, mean %s\n", formatSessionDuration(stats.MedianDuration), formatSessionDuration(stats.MeanDuration))
+	fmt.Fprintf(&summary, "Listens per session: median %.1f, mean %.1f\n", stats.MedianListens, stats.MeanListens)
+	for _, b := range stats.DurationBuckets {
+		var label string
+		switch {
+		case b.Min == 0:
+			label = "Under " + formatSessionDuration(b.Max)
+		case b.Max == 0:
+			label = formatSessionDuration(b.Min) + " or more"
+		default:
+			label = formatSessionDuration(b.Min) + " to " + formatSessionDuration(b.Max)
+		}
+		fmt.Fprintf(&summary, "%s: %d sessions (%.0f%%)\n", label, b.Sessions, 100*float64(b.Sessions)/float64(stats.Sessions))
+	}
+	writeSessionArtists(&summary, "Most often starts sessions", stats.Openers)
+	writeSessionArtists(&summary, "Most often ends sessions", stats.Closers)
+	a.summary = summary.String()
+
+	a.results = [][]string{{"Start", "Length", "Listens", "Artists"}}
+	for _, s := range stats.Longest {
+		a.results = append(a.results, []string{
+			s.Start().Format("2006-01-02 15:04"),
+			formatSessionDuration(s.Duration()),
+			strconv.Itoa(len(s.Listens)),
+			strings.Join(sessionTopArtists(s, 3), ", "),
+		})
+	}
+	return
+}
+
+// formatSessionDuration formats d to the minute, e.g. "45m" or "2h 5m".
+func formatSessionDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+func writeSessionArtists(out *strings.Builder, label string, artists []analysis.SessionArtist) {
+	if len(artists) == 0 {
+		return
+	}
+	var parts []string
+	for _, a := range artists {
+		parts = append(parts, fmt.Sprintf("%s (%d, %.0f%% of their sessions)", a.Artist, a.Sessions, 100*a.Rate))
+	}
+	fmt.Fprintf(out, "%s: %s\n", label, strings.Join(parts, ", "))
+}
+
+// sessionTopArtists returns the most played artists in the session.
+func sessionTopArtists(s analysis.Session, n int) []string {
+	counts := make(map[string]int)
+	var artists []string
+	for _, l := range s.Listens {
+		if counts[l.Artist] == 0 {
+			artists = append(artists, l.Artist)
+		}
+		counts[l.Artist]++
+	}
+	sort.SliceStable(artists, func(i, j int) bool { return counts[artists[i]] > counts[artists[j]] })
+	return artists[:min(len(artists), n)]
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestSessionsAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	base := time.Date(2020, 3, 1, 20, 0, 0, 0, time.UTC)
+	var tracks []store.TrackImport
+	for i, minute := range []int{0, 4, 8, 100, 104, 108, 112, 116, 170} {
+		artist := "Air"
+		if i >= 3 && i < 8 {
+			artist = "Blur"
+		}
+		ts := base.Add(time.Duration(minute) * time.Minute)
+		tracks = append(tracks, store.TrackImport{Artist: artist, Album: "Album", TrackName: fmt.Sprintf("Track %d", i), DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &SessionsAnalyzer{Config: analysis.SessionConfig{Gap: analysis.DefaultSessionGap}}
+	if err := analyzer.Configure(map[string]string{"gap": "1h", "n": "1", "artists": "1"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	got, err := analyzer.GetResults(context.Background(), s, user, base, base.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+
+	// With a 1 hour gap, the listens at 100-170 minutes are one session.
+	want := [][]string{
+		{"Start", "Length", "Listens", "Artists"},
+		{"2020-03-01 21:40", "1h 10m", "6", "Blur, Air"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("GetResults() = %v, want %v", got.results, want)
+	}
+	for _, line := range []string{
+		"Found 2 sessions with 9 listens",
+		"a session ends after 1h without a listen",
+		"Session length: median 39m, mean 39m\n",
+		"Under 15m: 1 sessions (50%)\n",
+		"1h to 2h: 1 sessions (50%)\n",
+		"Most often ends sessions: Air (2, 100% of their sessions)\n",
+	} {
+		if !strings.Contains(got.summary, line) {
+			t.Errorf("GetResults() summary missing %q. Got:\n%s", line, got.summary)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	streaksArtists  int
+	streaksTimezone string
+)
+
+var streaksCmd = &cobra.Command{
+	Use:   "streaks [year (optional)]",
+	Short: "Shows listening streaks and a calendar heatmap of daily listens",
+	Long: `Shows the longest and current runs of consecutive days with at least one listen, the artists
+listened to on the most consecutive days, and a calendar heatmap of daily listens. The heatmap
+covers the given year, or the last year if none is given. Streaks are as of the end of the year.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printStreaks(cmd.Context(), os.Stdout, db, viper.GetString("user"), args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(streaksCmd)
+
+	streaksCmd.Flags().IntVarP(&streaksArtists, "artists", "n", 10, "number of artists with the longest streaks to show")
+	streaksCmd.Flags().StringVar(&streaksTimezone, "timezone", "", "timezone which days start in (e.g. America/Los_Angeles), default is local time")
+}
+
+func printStreaks(ctx context.Context, out io.Writer, db store.Store, user string, args []string) error {
+	analyzer := &StreaksAnalyzer{Config: analysis.StreakConfig{Artists: streaksArtists}}
+	if err := analyzer.Configure(map[string]string{"timezone": streaksTimezone}); err != nil {
+		return err
+	}
+
+	now := time.Now().In(analyzer.location())
+	end := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if len(args) == 1 {
+		date, err := parseSingleDatestring(args[0])
+		if err != nil {
+			return err
+		}
+		if !date.Year {
+			return fmt.Errorf("Invalid year: %q", args[0])
+		}
+		end = time.Date(date.Date.Year()+1, 1, 1, 0, 0, 0, 0, now.Location())
+	}
+
+	stats, a, err := analyzer.analyze(ctx, db, user, end)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, a.summary)
+	fmt.Fprintln(out, renderHeatmapText(stats.Calendar))
+	if len(a.results) > 1 {
+		fmt.Fprint(out, Analysis{results: a.results})
+	}
+	return nil
+}
+
+type StreaksAnalyzer struct {
+	Config analysis.StreakConfig
+}
+
+func (t *StreaksAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["artists"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'artists': %v", err)
+		}
+		t.Config.Artists = n
+	}
+	if val, ok := params["timezone"]; ok && val != "" {
+		loc, err := time.LoadLocation(val)
+		if err != nil {
+			return fmt.Errorf("loading timezone %q: %w", val, err)
+		}
+		t.Config.Location = loc
+	}
+	return nil
+}
+
+func (t *StreaksAnalyzer) GetName() string {
+	return "Listening streaks"
+}
+
+func (t *StreaksAnalyzer) location() *time.Location {
+	if t.Config.Location == nil {
+		return time.Local
+	}
+	return t.Config.Location
+}
+
+// GetResults finds streaks as of end, with a heatmap of the year before it. The results are HTML
+// for emails.
+func (t *StreaksAnalyzer) GetResults(ctx context.Context, db store.Store, user string, _ time.Time, end time.Time) (Analysis, error) {
+	stats, a, err := t.analyze(ctx, db, user, end)
+	if err != nil {
+		return a, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div>%s</div>\n", strings.ReplaceAll(html.EscapeString(a.summary), "\n", "<br>\n"))
+	sb.WriteString(renderHeatmapHTML(stats.Calendar))
+	if len(a.results) > 1 {
+		sb.WriteString("<h3>Longest artist streaks</h3>")
+		writeHTMLTable(&sb, a.results)
+	}
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns the streak stats, with a text summary and a table of artist streaks.
+func (t *StreaksAnalyzer) analyze(ctx context.Context, db store.Store, user string, end time.Time) (stats analysis.StreakStats, a Analysis, err error) {
+	config := t.Config
+	config.Location = t.location()
+	stats, err = analysis.GetStreakStats(ctx, db, user, end, config)
+	if err != nil {
+		err = fmt.Errorf("printStreaks: %w", err)
+		return
+	}
+
+	var activeDays, listens int
+	for _, d := range stats.Calendar {
+		listens += d.Listens
+		if d.Listens > 0 {
+			activeDays++
+		}
+	}
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Longest streak: %s\n", formatStreak(stats.Longest))
+	fmt.Fprintf(&summary, "Current streak: %s\n", formatStreak(stats.Current))
+	if len(stats.Calendar) > 0 {
+		fmt.Fprintf(&summary, "%d listens on %d of %d days from %s to %s\n", listens, activeDays, len(stats.Calendar),
+			stats.Calendar[0].Day.Format("2006-01-02"), stats.Calendar[len(stats.Calendar)-1].Day.Format("2006-01-02"))
+	}
+	a.summary = summary.String()
+
+	a.results = [][]string{{"Artist", "Days", "From", "To"}}
+	for _, s := range stats.Artists {
+		a.results = append(a.results, []string{s.Artist, strconv.Itoa(s.Days), s.Start.Format("2006-01-02"), s.End().Format("2006-01-02")})
+	}
+	return
+}
+
+func formatStreak(s analysis.Streak) string {
+	switch s.Days {
+	case 0:
+		return "none"
+	case 1:
+		return fmt.Sprintf("1 day (%s)", s.Start.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%d days (%s to %s)", s.Days, s.Start.Format("2006-01-02"), s.End().Format("2006-01-02"))
+}
+
+// heatmapLevel scales a day's listens to 0 (none) to 4 (the busiest day).
+func heatmapLevel(listens, max int) int {
+	if listens == 0 || max == 0 {
+		return 0
+	}
+	return (4*listens + max - 1) / max
+}
+
+// heatmapWeeks arranges days into weeks running Monday to Sunday, like the weekly charts. Days
+// before the first day and after the last are nil.
+func heatmapWeeks(days []analysis.DayCount) (weeks [][7]*analysis.DayCount, max int) {
+	for i := range days {
+		d := &days[i]
+		weekday := (int(d.Day.Weekday()) + 6) % 7
+		if i == 0 || weekday == 0 {
+			weeks = append(weeks, [7]*analysis.DayCount{})
+		}
+		weeks[len(weeks)-1][weekday] = d
+		if d.Listens > max {
+			max = d.Listens
+		}
+	}
+	return
+}
+
+// heatmapMonths returns the label for each week which contains the first day of a month. The first
+// week is labelled too, unless the next month starts too soon after it for the labels to fit.
+func heatmapMonths(weeks [][7]*analysis.DayCount) []string {
+	labels := make([]string, len(weeks))
+	for i, week := range weeks {
+		for _, d := range week {
+			if d != nil && d.Day.Day() == 1 {
+				labels[i] = d.Day.Format("Jan")
+			}
+		}
+	}
+	if len(weeks) > 2 && labels[0] == "" && labels[1] == "" && labels[2] == "" {
+		for _, d := range weeks[0] {
+			if d != nil {
+				labels[0] = d.Day.Format("Jan")
+				break
+			}
+		}
+	}
+	return labels
+}
+
+var heatmapWeekdays = [7]string{"Mon", "", "Wed", "", "Fri", "", "Sun"}
+
+var heatmapChars = [5]string{"·", "░", "▒", "▓", "█"}
+
+// renderHeatmapText renders days as a GitHub-style calendar, with a column per week.
+func renderHeatmapText(days []analysis.DayCount) string {
+	weeks, max := heatmapWeeks(days)
+	var sb strings.Builder
+
+	// Each week is two characters wide, so month labels can overlap the next month's.
+	header := []rune(strings.Repeat(" ", 4+2*len(weeks)+2))
+	next := 0
+	for i, label := range heatmapMonths(weeks) {
+		col := 4 + 2*i
+		if label == "" || col < next {
+			continue
+		}
+		copy(header[col:], []rune(label))
+		next = col + len(label) + 1
+	}
+	sb.WriteString(strings.TrimRight(string(header), " ") + "\n")
+
+	for weekday, name := range heatmapWeekdays {
+		var row strings.Builder
+		fmt.Fprintf(&row, "%-4s", name)
+		for _, week := range weeks {
+			if d := week[weekday]; d != nil {
+				row.WriteString(heatmapChars[heatmapLevel(d.Listens, max)] + " ")
+			} else {
+				row.WriteString("  ")
+			}
+		}
+		sb.WriteString(strings.TrimRight(row.String(), " ") + "\n")
+	}
+	fmt.Fprintf(&sb, "    Less %s More\n", strings.Join(heatmapChars[:], " "))
+	return sb.String()
+}
+
+var heatmapColors = [5]string{"#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"}
+
+// renderHeatmapHTML renders days as a GitHub-style calendar table for emails. Styles are inline
+// to override the email's table borders.
+func renderHeatmapHTML(days []analysis.DayCount) string {
+	weeks, max := heatmapWeeks(days)
+	const cell = `border: none; padding: 0; width: 10px; height: 10px;`
+	var sb strings.Builder
+	sb.WriteString(`<table style="border: none; border-collapse: separate; border-spacing: 2px; font-size: 9px;">`)
+
+	// Each month label spans the weeks up to the next one, so that it doesn't widen its column.
+	sb.WriteString(`<tr><td style="border: none;"></td>`)
+	labels := heatmapMonths(weeks)
+	for i := 0; i < len(labels); {
+		span := 1
+		for i+span < len(labels) && labels[i+span] == "" {
+			span++
+		}
+		fmt.Fprintf(&sb, `<td style="border: none; padding: 0;" colspan="%d">%s</td>`, span, labels[i])
+		i += span
+	}
+	sb.WriteString("</tr>\n")
+
+	for weekday, name := range heatmapWeekdays {
+		fmt.Fprintf(&sb, `<tr><td style="border: none; padding: 0 2px 0 0;">%s</td>`, name)
+		for _, week := range weeks {
+			d := week[weekday]
+			if d == nil {
+				fmt.Fprintf(&sb, `<td style="%s"></td>`, cell)
+				continue
+			}
+			fmt.Fprintf(&sb, `<td style="%s background-color: %s;" title="%s: %d listens"></td>`,
+				cell, heatmapColors[heatmapLevel(d.Listens, max)], d.Day.Format("2006-01-02"), d.Listens)
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>\n")
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestStreaksAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	// Air on 2020-06-01 to 06-03, Blur on 06-03 and 06-04, then twice on 06-10.
+	for _, l := range []struct {
+		day, hour int
+		artist    string
+	}{{1, 12, "Air"}, {2, 12, "Air"}, {3, 12, "Air"}, {3, 13, "Blur"}, {4, 12, "Blur"}, {10, 12, "Blur"}, {10, 13, "Blur"}} {
+		ts := time.Date(2020, 6, l.day, l.hour, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &StreaksAnalyzer{}
+	if err := analyzer.Configure(map[string]string{"artists": "2", "timezone": "UTC"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats, got, err := analyzer.analyze(context.Background(), s, user, end)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	want := [][]string{
+		{"Artist", "Days", "From", "To"},
+		{"Air", "3", "2020-06-01", "2020-06-03"},
+		{"Blur", "2", "2020-06-03", "2020-06-04"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("results = %v, want %v", got.results, want)
+	}
+	for _, line := range []string{
+		"Longest streak: 4 days (2020-06-01 to 2020-06-04)\n",
+		"Current streak: none\n",
+		"7 listens on 5 of 366 days from 2020-01-01 to 2020-12-31\n",
+	} {
+		if !strings.Contains(got.summary, line) {
+			t.Errorf("summary missing %q. Got:\n%s", line, got.summary)
+		}
+	}
+
+	// 2020-01-01 was a Wednesday. June 3rd and 10th have the most listens.
+	text := renderHeatmapText(stats.Calendar)
+	lines := strings.Split(text, "\n")
+	if !strings.HasPrefix(lines[0], "    Jan") {
+		t.Errorf("heatmap header = %q, want it to start with Jan", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "Mon   · ") || !strings.HasPrefix(lines[3], "Wed ·") {
+		t.Errorf("heatmap doesn't start on a Wednesday:\n%s", text)
+	}
+	if strings.Count(text, "█") != 3 || strings.Count(text, "▒") != 4 {
+		t.Errorf("heatmap should have the 3rd and 10th at full level and the other days at half, plus the legend:\n%s", text)
+	}
+
+	a, err := analyzer.GetResults(context.Background(), s, user, time.Time{}, end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	for _, want := range []string{
+		`background-color: #216e39;" title="2020-06-10: 2 listens"`,
+		`background-color: #40c463;" title="2020-06-01: 1 listens"`,
+		`background-color: #ebedf0;" title="2020-06-05: 0 listens"`,
+		"<td>Air</td><td>3</td>",
+	} {
+		if !strings.Contains(a.BodyOverride, want) {
+			t.Errorf("GetResults() HTML missing %q", want)
+		}
+	}
+
+	var out bytes.Buffer
+	streaksTimezone = "UTC"
+	streaksArtists = 1
+	if err := printStreaks(context.Background(), &out, s, user, []string{"2020"}); err != nil {
+		t.Fatalf("printStreaks: %v", err)
+	}
+	if !strings.Contains(out.String(), "Longest streak: 4 days") || !strings.Contains(out.String(), "Less ·") {
+		t.Errorf("printStreaks() output missing summary or heatmap:\n%s", out.String())
+	}
+}
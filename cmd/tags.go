@@ -0,0 +1,197 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags <artist>",
+	Short: "Shows an artist's raw and canonical tags",
+	Long: `Shows the artist's tags as fetched from last.fm and their personal tags, next to their
+canonical form after the tag rules are applied. Tags which the rules drop are shown as '(dropped)',
+and fetched tags replaced by personal tags as '(replaced)'. Manage the rules with
+add-tag-rule, delete-tag-rule and list-tag-rules.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printTags(cmd.Context(), os.Stdout, db, args[0])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var addTagRuleCmd = &cobra.Command{
+	Use:   "add-tag-rule <synonym|block|regex> <pattern> [replacement]",
+	Short: "Adds a rule for curating tags",
+	Long: `Adds a rule which is applied to tags in every analysis:
+  synonym <tag> <canonical>: replaces the tag with the canonical tag, e.g. 'synonym hiphop "hip hop"'.
+  block <tag>: drops the tag, e.g. 'block "seen live"'.
+  regex <pattern> <replacement>: rewrites tags matching the regular expression, e.g.
+    'regex "^(.*) music$" "$1"'. Tags rewritten to nothing are dropped.
+Tags are lowercased, with hyphens and underscores treated as spaces, before the rules are applied.
+Regexes are applied in the order they were added, then synonyms. Blocked tags are dropped both
+before and after they're rewritten. Adding a rule
+for an existing pattern replaces it.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		rule := store.TagRule{Kind: store.TagRuleKind(args[0]), Pattern: args[1]}
+		if len(args) == 3 {
+			rule.Replacement = args[2]
+		}
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return addTagRule(cmd.Context(), db, rule)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var deleteTagRuleCmd = &cobra.Command{
+	Use:   "delete-tag-rule <synonym|block|regex> <pattern>",
+	Short: "Deletes a rule for curating tags",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return deleteTagRule(cmd.Context(), db, store.TagRuleKind(args[0]), args[1])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var listTagRulesCmd = &cobra.Command{
+	Use:   "list-tag-rules",
+	Short: "Lists the rules for curating tags",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return listTagRules(cmd.Context(), os.Stdout, db)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+	rootCmd.AddCommand(addTagRuleCmd)
+	rootCmd.AddCommand(deleteTagRuleCmd)
+	rootCmd.AddCommand(listTagRulesCmd)
+}
+
+func printTags(ctx context.Context, out io.Writer, db store.Store, artist string) error {
+	tags, err := db.GetArtistTagCounts(ctx, artist)
+	if err != nil {
+		return err
+	}
+	allPersonal, err := db.GetPersonalTags(ctx)
+	if err != nil {
+		return err
+	}
+	var personal store.PersonalTags
+	for _, p := range allPersonal {
+		if p.Artist == artist && p.Album == "" {
+			personal = p
+		}
+	}
+	if len(tags) == 0 && len(personal.Tags) == 0 {
+		fmt.Fprintf(out, "No tags for %q. Run update to fetch them.\n", artist)
+		return nil
+	}
+	rules, err := db.GetTagRules(ctx)
+	if err != nil {
+		return err
+	}
+	curator, err := store.NewTagCurator(rules)
+	if err != nil {
+		return err
+	}
+	canonical := func(tag string) string {
+		c, ok := curator.Canonical(artist, tag)
+		if !ok {
+			return "(dropped)"
+		}
+		return c
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.Header([]string{"Tag", "Source", "Count", "Canonical"})
+	for _, t := range tags {
+		c := canonical(t.Tag)
+		if personal.Replace {
+			c = "(replaced)"
+		}
+		table.Append([]string{t.Tag, "last.fm", strconv.Itoa(t.Count), c})
+	}
+	for _, tag := range personal.Tags {
+		table.Append([]string{tag, "personal", strconv.Itoa(store.PersonalTagCount), canonical(tag)})
+	}
+	table.Render()
+	return nil
+}
+
+func addTagRule(ctx context.Context, db store.Store, rule store.TagRule) error {
+	return db.AddTagRule(ctx, rule)
+}
+
+func deleteTagRule(ctx context.Context, db store.Store, kind store.TagRuleKind, pattern string) error {
+	deleted, err := db.DeleteTagRule(ctx, kind, pattern)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("no %s rule found for %q", kind, pattern)
+	}
+	return nil
+}
+
+func listTagRules(ctx context.Context, out io.Writer, db store.Store) error {
+	rules, err := db.GetTagRules(ctx)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Fprintln(out, "No tag rules.")
+		return nil
+	}
+	table := tablewriter.NewWriter(out)
+	table.Header([]string{"Kind", "Pattern", "Replacement"})
+	for _, r := range rules {
+		table.Append([]string{string(r.Kind), r.Pattern, r.Replacement})
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestTagRules(t *testing.T) {
+	ctx := context.Background()
+	s, _ := createTestStore(t)
+	if err := s.SaveArtistTags(ctx, "Slowdive", []string{"Shoegaze", "shoegazer", "seen live", "dream_pop"}, []int{100, 40, 20, 10}); err != nil {
+		t.Fatalf("SaveArtistTags: %v", err)
+	}
+
+	for _, rule := range []store.TagRule{
+		{Kind: store.TagRuleSynonym, Pattern: "shoegazer", Replacement: "shoegaze"},
+		{Kind: store.TagRuleBlock, Pattern: "seen live"},
+	} {
+		if err := addTagRule(ctx, s, rule); err != nil {
+			t.Fatalf("addTagRule(%+v): %v", rule, err)
+		}
+	}
+	if err := addTagRule(ctx, s, store.TagRule{Kind: "alias", Pattern: "x", Replacement: "y"}); err == nil {
+		t.Errorf("addTagRule with an invalid kind succeeded")
+	}
+
+	var out bytes.Buffer
+	if err := listTagRules(ctx, &out, s); err != nil {
+		t.Fatalf("listTagRules: %v", err)
+	}
+	if !strings.Contains(out.String(), "shoegazer") || !strings.Contains(out.String(), "seen live") {
+		t.Errorf("listTagRules output missing rules:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := printTags(ctx, &out, s, "Slowdive"); err != nil {
+		t.Fatalf("printTags: %v", err)
+	}
+	for _, row := range [][]string{
+		{"Shoegaze", "last.fm", "100", "shoegaze"},
+		{"shoegazer", "last.fm", "40", "shoegaze"},
+		{"seen live", "last.fm", "20", "(dropped)"},
+		{"dream_pop", "last.fm", "10", "dream pop"},
+	} {
+		if !containsRow(out.String(), row) {
+			t.Errorf("printTags output missing row %v:\n%s", row, out.String())
+		}
+	}
+
+	if err := deleteTagRule(ctx, s, store.TagRuleBlock, "Seen Live"); err != nil {
+		t.Fatalf("deleteTagRule: %v", err)
+	}
+	if err := deleteTagRule(ctx, s, store.TagRuleBlock, "seen live"); err == nil {
+		t.Errorf("deleting a missing rule succeeded")
+	}
+}
+
+// containsRow returns whether a line of the table has the cells in order.
+func containsRow(table string, cells []string) bool {
+	for _, line := range strings.Split(table, "\n") {
+		rest := line
+		found := true
+		for _, cell := range cells {
+			i := strings.Index(rest, " "+cell+" ")
+			if i < 0 {
+				found = false
+				break
+			}
+			rest = rest[i+len(cell)+1:]
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
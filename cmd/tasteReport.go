@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,12 +15,27 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+var (
+	tasteReportCurrentStart    string
+	tasteReportCurrentEnd      string
+	tasteReportHistoricalStart string
+	tasteReportHistoricalEnd   string
+	tasteReportArtists         int
+	tasteReportAlbums          int
+	tasteReportTags            int
+	tasteReportDriftTags       int
+	tasteReportMinTagCount     int
+	tasteReportMinTags         int
+	tasteReportScoring         string
+)
+
 var tasteReportCmd = &cobra.Command{
 	Use:   "taste-report",
 	Short: "Generates a comprehensive music taste report",
-	Long:  `Analyzes your listening history to generate a detailed YAML report of your music taste, history, and drift.`,
+	Long: `Analyzes your listening history to generate a detailed YAML report of your music taste, history, and drift.
+By default, the current period is the 18 months up to the latest listen, and the historical period is everything before it.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := runTasteReport()
+		err := runTasteReport(cmd.Context())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating report: %v\n", err)
 			os.Exit(1)
@@ -28,19 +45,56 @@ var tasteReportCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(tasteReportCmd)
+
+	defaults := analysis.DefaultTasteReportConfig()
+	tasteReportCmd.Flags().StringVar(&tasteReportCurrentStart, "current_start", "", "Start of the current period (e.g. 2023-01 or 18m), default is 18 months before the end")
+	tasteReportCmd.Flags().StringVar(&tasteReportCurrentEnd, "current_end", "", "End of the current period, including the whole year, month or day (e.g. 2024-06), default is the latest listen")
+	tasteReportCmd.Flags().StringVar(&tasteReportHistoricalStart, "historical_start", "", "Start of the historical period, default is the first listen")
+	tasteReportCmd.Flags().StringVar(&tasteReportHistoricalEnd, "historical_end", "", "End of the historical period, including the whole year, month or day, default is the start of the current period")
+	tasteReportCmd.Flags().IntVar(&tasteReportArtists, "artists", defaults.Artists, "Number of top artists in each period")
+	tasteReportCmd.Flags().IntVar(&tasteReportAlbums, "albums", defaults.Albums, "Number of top albums in the current period")
+	tasteReportCmd.Flags().IntVar(&tasteReportTags, "tags", defaults.Tags, "Number of top tags in each period")
+	tasteReportCmd.Flags().IntVar(&tasteReportDriftTags, "drift_tags", defaults.DriftTags, "Number of top tags from each period compared for taste drift")
+	tasteReportCmd.Flags().IntVar(&tasteReportMinTagCount, "min_tag_count", defaults.TagFilter.MinCount, "Minimum last.fm count for a tag to be used")
+	tasteReportCmd.Flags().StringVar(&tasteReportScoring, "tag_scoring", string(defaults.TagScoring), "How tags are weighted: binary (every tag gets the whole listen), count (shared by tag count) or tfidf (count, scaled down for tags common in the library)")
+	tasteReportCmd.Flags().IntVar(&tasteReportMinTags, "min_tags", defaults.TagFilter.MinTags, "Minimum number of tags an artist or album needs to be used for tag weights")
 }
 
-func runTasteReport() error {
+func runTasteReport(ctx context.Context) error {
 	dbPath := viper.GetString("database")
 	user := viper.GetString("user")
 
+	params := map[string]string{
+		"artists":       strconv.Itoa(tasteReportArtists),
+		"albums":        strconv.Itoa(tasteReportAlbums),
+		"tags":          strconv.Itoa(tasteReportTags),
+		"drift_tags":    strconv.Itoa(tasteReportDriftTags),
+		"min_tag_count": strconv.Itoa(tasteReportMinTagCount),
+		"min_tags":      strconv.Itoa(tasteReportMinTags),
+		"tag_scoring":   tasteReportScoring,
+	}
+	for name, val := range map[string]string{
+		"current_start":    tasteReportCurrentStart,
+		"current_end":      tasteReportCurrentEnd,
+		"historical_start": tasteReportHistoricalStart,
+		"historical_end":   tasteReportHistoricalEnd,
+	} {
+		if val != "" {
+			params[name] = val
+		}
+	}
+	analyzer := &TasteReportAnalyzer{Config: analysis.DefaultTasteReportConfig()}
+	if err := analyzer.Configure(params); err != nil {
+		return err
+	}
+
 	db, err := store.New(dbPath)
 	if err != nil {
 		return fmt.Errorf("opening database: %w", err)
 	}
 	defer db.Close()
 
-	report, err := analysis.GenerateReport(db, user)
+	report, err := analysis.GenerateReport(ctx, db, user, analyzer.Config)
 	if err != nil {
 		return fmt.Errorf("analyzing data: %w", err)
 	}
@@ -56,10 +110,64 @@ func runTasteReport() error {
 }
 
 type TasteReportAnalyzer struct {
+	Config analysis.TasteReportConfig
 }
 
 func (t *TasteReportAnalyzer) Configure(params map[string]string) error {
-	// No params for now
+	ints := map[string]*int{
+		"artists":       &t.Config.Artists,
+		"albums":        &t.Config.Albums,
+		"tags":          &t.Config.Tags,
+		"drift_tags":    &t.Config.DriftTags,
+		"min_tag_count": &t.Config.TagFilter.MinCount,
+		"min_tags":      &t.Config.TagFilter.MinTags,
+	}
+	for name, field := range ints {
+		if val, ok := params[name]; ok {
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid value for '%s': %v", name, err)
+			}
+			*field = n
+		}
+	}
+
+	if val, ok := params["tag_scoring"]; ok {
+		scoring, err := parseTagScoring(val)
+		if err != nil {
+			return err
+		}
+		t.Config.TagScoring = scoring
+	}
+
+	starts := map[string]*time.Time{
+		"current_start":    &t.Config.CurrentStart,
+		"historical_start": &t.Config.HistoricalStart,
+	}
+	for name, field := range starts {
+		if val, ok := params[name]; ok {
+			date, err := parseSingleDatestring(val)
+			if err != nil {
+				return fmt.Errorf("invalid value for '%s': %v", name, err)
+			}
+			*field = date.Date
+		}
+	}
+
+	// Periods include their ends, so ends include the whole year, month or day.
+	ends := map[string]*time.Time{
+		"current_end":    &t.Config.CurrentEnd,
+		"historical_end": &t.Config.HistoricalEnd,
+	}
+	for name, field := range ends {
+		if val, ok := params[name]; ok {
+			_, end, err := getImplicitDateRange(val)
+			if err != nil {
+				return fmt.Errorf("invalid value for '%s': %v", name, err)
+			}
+			*field = end.Add(-time.Second)
+		}
+	}
 	return nil
 }
 
@@ -67,15 +175,9 @@ func (t *TasteReportAnalyzer) GetName() string {
 	return "Music Taste Profile"
 }
 
-func (t *TasteReportAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (Analysis, error) {
+func (t *TasteReportAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
 	var a Analysis
-	db, err := store.New(dbPath)
-	if err != nil {
-		return a, fmt.Errorf("opening database: %w", err)
-	}
-	defer db.Close()
-
-	report, err := analysis.GenerateReport(db, user)
+	report, err := analysis.GenerateReport(ctx, db, user, t.Config)
 	if err != nil {
 		return a, fmt.Errorf("generating report: %w", err)
 	}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+)
+
+func TestTasteReportConfigure(t *testing.T) {
+	analyzer := &TasteReportAnalyzer{Config: analysis.DefaultTasteReportConfig()}
+	err := analyzer.Configure(map[string]string{
+		"current_start":    "2023-01",
+		"current_end":      "2024-06",
+		"historical_start": "2015",
+		"historical_end":   "2022-12-31",
+		"artists":          "10",
+		"min_tag_count":    "50",
+		"drift_tags":       "5",
+		"tag_scoring":      "tfidf",
+	})
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	want := analysis.DefaultTasteReportConfig()
+	want.CurrentStart = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	want.CurrentEnd = time.Date(2024, 6, 30, 23, 59, 59, 0, time.UTC)
+	want.HistoricalStart = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	want.HistoricalEnd = time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC)
+	want.Artists = 10
+	want.TagFilter.MinCount = 50
+	want.DriftTags = 5
+	want.TagScoring = analysis.TagScoringTFIDF
+	if !reflect.DeepEqual(analyzer.Config, want) {
+		t.Errorf("Config = %+v, want %+v", analyzer.Config, want)
+	}
+
+	for _, params := range []map[string]string{{"tags": "many"}, {"current_end": "June"}, {"tag_scoring": "bm25"}} {
+		if err := (&TasteReportAnalyzer{}).Configure(params); err == nil {
+			t.Errorf("Configure(%v) succeeded, want an error", params)
+		}
+	}
+}
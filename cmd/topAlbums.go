@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -33,7 +34,9 @@ var topAlbumsCmd = &cobra.Command{
 	Long:  `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.`,
 	Args:  cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := printTopAlbums(viper.GetString("database"), topAlbumsNumber, args)
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printTopAlbums(cmd.Context(), db, topAlbumsNumber, args)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -47,12 +50,12 @@ func init() {
 	topAlbumsCmd.Flags().IntVarP(&topAlbumsNumber, "number", "n", 10, "number of results to return")
 }
 
-func printTopAlbums(dbPath string, numToReturn int, args []string) error {
+func printTopAlbums(ctx context.Context, db store.Store, numToReturn int, args []string) error {
 	start, end, err := parseDateRangeFromArgs(args)
 
 	config := AnalyserConfig{numToReturn, 0}
 	analyzer := &TopAlbumsAnalyzer{}
-	out, err := analyzer.SetConfig(config).GetResults(dbPath, viper.GetString("user"), start, end)
+	out, err := analyzer.SetConfig(config).GetResults(ctx, db, viper.GetString("user"), start, end)
 	if err != nil {
 		return err
 	}
@@ -91,17 +94,10 @@ func (t *TopAlbumsAnalyzer) GetName() string {
 	return "Top albums"
 }
 
-func (t *TopAlbumsAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
+func (t *TopAlbumsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
 	analysis.results = make([][]string, 0)
 	
-	db, err := store.New(dbPath)
-	if err != nil {
-		err = fmt.Errorf("getTopAlbums: %w", err)
-		return
-	}
-	defer db.Close()
-
-	counts, err := db.GetTopAlbumsWithCount(user, start, end)
+	counts, err := db.GetTopAlbumsWithCount(ctx, user, start, end)
 	if err != nil {
 		err = fmt.Errorf("getTopAlbums: %w", err)
 		return
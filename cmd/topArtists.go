@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -33,7 +34,9 @@ var topArtistsCmd = &cobra.Command{
 	Long:  `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.`,
 	Args:  cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := printTopArtists(viper.GetString("database"), topArtistsNumber, args)
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printTopArtists(cmd.Context(), db, topArtistsNumber, args)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -47,12 +50,12 @@ func init() {
 	topArtistsCmd.Flags().IntVarP(&topArtistsNumber, "number", "n", 10, "number of results to return")
 }
 
-func printTopArtists(dbPath string, numToReturn int, args []string) error {
+func printTopArtists(ctx context.Context, db store.Store, numToReturn int, args []string) error {
 	start, end, err := parseDateRangeFromArgs(args)
 
 	config := AnalyserConfig{numToReturn, 0}
 	analyzer := &TopArtistsAnalyzer{}
-	out, err := analyzer.SetConfig(config).GetResults(dbPath, viper.GetString("user"), start, end)
+	out, err := analyzer.SetConfig(config).GetResults(ctx, db, viper.GetString("user"), start, end)
 	if err != nil {
 		return err
 	}
@@ -91,15 +94,8 @@ func (t *TopArtistsAnalyzer) GetName() string {
 	return "Top artists"
 }
 
-func (t *TopArtistsAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
-	db, err := store.New(dbPath)
-	if err != nil {
-		err = fmt.Errorf("printTopArtists: %w", err)
-		return
-	}
-	defer db.Close()
-
-	counts, err := db.GetTopArtistsWithCount(user, start, end)
+func (t *TopArtistsAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
+	counts, err := db.GetTopArtistsWithCount(ctx, user, start, end)
 	if err != nil {
 		err = fmt.Errorf("printTopArtists: %w", err)
 		return
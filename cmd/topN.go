@@ -16,14 +16,16 @@ limitations under the License.
 package cmd
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ademuri/last-fm-tools/internal/store"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -62,7 +64,9 @@ var topNCmd = &cobra.Command{
 		// So we might need to keep printTopN or make TopNAnalyzer support text output too?
 		// Or just stick with printTopN for CLI as they are quite different.
 		// I will keep printTopN for CLI usage as it writes to io.Writer and formatting is different (markdown-ish).
-		err = printTopN(os.Stdout, viper.GetString("database"), start, end, limitArtists, limitAlbums, limitTracks, limitTags)
+		err = withStore(viper.GetString("database"), func(db store.Store) error {
+			return printTopN(cmd.Context(), os.Stdout, db, start, end, limitArtists, limitAlbums, limitTracks, limitTags)
+		})
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -126,104 +130,75 @@ func (t *TopNAnalyzer) GetName() string {
 	return "Top N Report"
 }
 
-func (t *TopNAnalyzer) GetResults(dbPath string, user string, start time.Time, end time.Time) (Analysis, error) {
+func (t *TopNAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
 	var a Analysis
-	db, err := openDb(dbPath)
-	if err != nil {
-		return a, fmt.Errorf("openDb: %w", err)
-	}
-	defer db.Close()
-
 	var sb strings.Builder
 
 	// 1. Top Artists
 	if t.LimitArtists > 0 {
-		rows, err := db.Query(`
-		SELECT Track.artist, COUNT(Listen.id)
-		FROM Listen
-		INNER JOIN Track ON Track.id = Listen.track
-		WHERE user = ? AND Listen.date BETWEEN ? AND ?
-		GROUP BY Track.artist
-		ORDER BY COUNT(*) DESC
-		LIMIT ?`, user, start.Unix(), end.Unix(), t.LimitArtists)
+		artists, err := db.GetTopArtistsWithCount(ctx, user, start, end)
+		if err != nil {
+			return a, err
+		}
+
+		artistTags, err := getArtistTags(ctx, db, t.LimitTags)
 		if err != nil {
 			return a, err
 		}
-		defer rows.Close()
 
 		sb.WriteString(fmt.Sprintf("<h3>Top %d Artists</h3>", t.LimitArtists))
 		sb.WriteString("<table><thead><tr><th>Rank</th><th>Artist</th><th>Scrobbles</th><th>Tags</th></tr></thead><tbody>")
-		i := 1
-		for rows.Next() {
-			var name string
-			var count int64
-			if err := rows.Scan(&name, &count); err != nil {
-				return a, err
-			}
-			tags, _ := getArtistTags(db, name, t.LimitTags)
-			sb.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%d</td><td>%s</td></tr>", i, name, count, tags))
-			i++
+		for i, artist := range artists[:min(len(artists), t.LimitArtists)] {
+			sb.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%d</td><td>%s</td></tr>", i+1, artist.Artist, artist.Count, artistTags[artist.Artist]))
 		}
 		sb.WriteString("</tbody></table>")
-		rows.Close()
 	}
 
 	// 2. Top Albums
 	if t.LimitAlbums > 0 {
-		rows, err := db.Query(`
-		SELECT Track.artist, Track.album, COUNT(Listen.id)
-		FROM Listen
-		INNER JOIN Track ON Track.id = Listen.track
-		WHERE user = ? AND Listen.date BETWEEN ? AND ?
-		GROUP BY Track.artist, Track.album
-		ORDER BY COUNT(*) DESC
-		LIMIT ?`, user, start.Unix(), end.Unix(), t.LimitAlbums)
+		albums, err := db.GetTopAlbumsWithCount(ctx, user, start, end)
+		if err != nil {
+			return a, err
+		}
+
+		albumTags, err := getAlbumTags(ctx, db, t.LimitTags)
 		if err != nil {
 			return a, err
 		}
-		defer rows.Close()
 
 		sb.WriteString(fmt.Sprintf("<h3>Top %d Albums</h3>", t.LimitAlbums))
 		sb.WriteString("<table><thead><tr><th>Rank</th><th>Album</th><th>Artist</th><th>Scrobbles</th><th>Tags</th></tr></thead><tbody>")
-		i := 1
-		for rows.Next() {
-			var artist, album string
-			var count int64
-			if err := rows.Scan(&artist, &album, &count); err != nil {
-				return a, err
-			}
-			tags, _ := getAlbumTags(db, artist, album, t.LimitTags)
-			sb.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%s</td><td>%d</td><td>%s</td></tr>", i, album, artist, count, tags))
-			i++
+		for i, album := range albums[:min(len(albums), t.LimitAlbums)] {
+			tags := albumTags[store.AlbumKey{Artist: album.Artist, Name: album.Album}]
+			sb.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%s</td><td>%d</td><td>%s</td></tr>", i+1, album.Album, album.Artist, album.Count, tags))
+		}
+		sb.WriteString("</tbody></table>")
+	}
+
+	// 3. Top Tracks
+	if t.LimitTracks > 0 {
+		tracks, err := db.GetTopTracksWithCount(ctx, user, start, end)
+		if err != nil {
+			return a, err
+		}
+
+		sb.WriteString(fmt.Sprintf("<h3>Top %d Tracks</h3>", t.LimitTracks))
+		sb.WriteString("<table><thead><tr><th>Rank</th><th>Track</th><th>Artist</th><th>Scrobbles</th></tr></thead><tbody>")
+		for i, track := range tracks[:min(len(tracks), t.LimitTracks)] {
+			sb.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%s</td><td>%d</td></tr>", i+1, track.Track, track.Artist, track.Count))
 		}
 		sb.WriteString("</tbody></table>")
-		rows.Close()
 	}
 
 	a.BodyOverride = sb.String()
 	return a, nil
 }
 
-func printTopN(out io.Writer, dbPath string, start, end time.Time, limitArtists, limitAlbums, limitTracks, limitTags int) error {
+func printTopN(ctx context.Context, out io.Writer, db store.Store, start, end time.Time, limitArtists, limitAlbums, limitTracks, limitTags int) error {
 	user := viper.GetString("user")
 
-	db, err := openDb(dbPath)
-	if err != nil {
-		return fmt.Errorf("openDb: %w", err)
-	}
-	defer db.Close()
-
-	exists, err := dbExists(db)
-	if err != nil {
-		return fmt.Errorf("dbExists: %w", err)
-	}
-	if !exists {
-		return fmt.Errorf("Database doesn't exist - run update first.")
-	}
-
 	// 1. Total Scrobbles
-	var totalScrobbles int64
-	err = db.QueryRow("SELECT COUNT(id) FROM Listen WHERE user = ? AND date BETWEEN ? AND ?", user, start.Unix(), end.Unix()).Scan(&totalScrobbles)
+	totalScrobbles, err := db.GetTotalScrobblesInPeriod(ctx, user, start, end)
 	if err != nil {
 		return fmt.Errorf("counting total scrobbles: %w", err)
 	}
@@ -234,119 +209,60 @@ func printTopN(out io.Writer, dbPath string, start, end time.Time, limitArtists,
 
 	// 2. Top Artists
 	if limitArtists > 0 {
-		const artistQueryString = `
-		SELECT Track.artist, COUNT(Listen.id)
-		FROM Listen
-		INNER JOIN Track ON Track.id = Listen.track
-		WHERE user = ?
-		AND Listen.date BETWEEN ? AND ?
-		GROUP BY Track.artist
-		ORDER BY COUNT(*) DESC
-		LIMIT ?
-		;
-		`
-		artistRows, err := db.Query(artistQueryString, user, start.Unix(), end.Unix(), limitArtists)
+		artists, err := db.GetTopArtistsWithCount(ctx, user, start, end)
 		if err != nil {
 			return fmt.Errorf("querying artists: %w", err)
 		}
-		defer artistRows.Close()
 
-		fmt.Fprintf(out, "## Top %d Artists\n", limitArtists)
-		i := 1
-		for artistRows.Next() {
-			var name string
-			var count int64
-			if err := artistRows.Scan(&name, &count); err != nil {
-				return fmt.Errorf("scanning artist: %w", err)
-			}
-
-			tags, err := getArtistTags(db, name, limitTags)
-			if err != nil {
-				return fmt.Errorf("getting artist tags: %w", err)
-			}
+		artistTags, err := getArtistTags(ctx, db, limitTags)
+		if err != nil {
+			return fmt.Errorf("getting artist tags: %w", err)
+		}
 
-			if tags != "" {
-				fmt.Fprintf(out, "%d. %s (%d) - [%s]\n", i, name, count, tags)
+		fmt.Fprintf(out, "## Top %d Artists\n", limitArtists)
+		for i, artist := range artists[:min(len(artists), limitArtists)] {
+			if tags := artistTags[artist.Artist]; tags != "" {
+				fmt.Fprintf(out, "%d. %s (%d) - [%s]\n", i+1, artist.Artist, artist.Count, tags)
 			} else {
-				fmt.Fprintf(out, "%d. %s (%d)\n", i, name, count)
+				fmt.Fprintf(out, "%d. %s (%d)\n", i+1, artist.Artist, artist.Count)
 			}
-			i++
 		}
 		fmt.Fprintln(out)
 	}
 
 	// 3. Top Albums
 	if limitAlbums > 0 {
-		const albumQueryString = `
-		SELECT Track.artist, Track.album, COUNT(Listen.id)
-		FROM Listen
-		INNER JOIN Track ON Track.id = Listen.track
-		WHERE user = ?
-		AND Listen.date BETWEEN ? AND ?
-		GROUP BY Track.artist, Track.album
-		ORDER BY COUNT(*) DESC
-		LIMIT ?
-		;
-		`
-		albumRows, err := db.Query(albumQueryString, user, start.Unix(), end.Unix(), limitAlbums)
+		albums, err := db.GetTopAlbumsWithCount(ctx, user, start, end)
 		if err != nil {
 			return fmt.Errorf("querying albums: %w", err)
 		}
-		defer albumRows.Close()
-
-		fmt.Fprintf(out, "## Top %d Albums\n", limitAlbums)
-		i := 1
-		for albumRows.Next() {
-			var artist, album string
-			var count int64
-			if err := albumRows.Scan(&artist, &album, &count); err != nil {
-				return fmt.Errorf("scanning album: %w", err)
-			}
 
-			tags, err := getAlbumTags(db, artist, album, limitTags)
-			if err != nil {
-				return fmt.Errorf("getting album tags: %w", err)
-			}
+		albumTags, err := getAlbumTags(ctx, db, limitTags)
+		if err != nil {
+			return fmt.Errorf("getting album tags: %w", err)
+		}
 
-			if tags != "" {
-				fmt.Fprintf(out, "%d. %s - %s (%d) - [%s]\n", i, album, artist, count, tags)
+		fmt.Fprintf(out, "## Top %d Albums\n", limitAlbums)
+		for i, album := range albums[:min(len(albums), limitAlbums)] {
+			if tags := albumTags[store.AlbumKey{Artist: album.Artist, Name: album.Album}]; tags != "" {
+				fmt.Fprintf(out, "%d. %s - %s (%d) - [%s]\n", i+1, album.Album, album.Artist, album.Count, tags)
 			} else {
-				fmt.Fprintf(out, "%d. %s - %s (%d)\n", i, album, artist, count)
+				fmt.Fprintf(out, "%d. %s - %s (%d)\n", i+1, album.Album, album.Artist, album.Count)
 			}
-			i++
 		}
 		fmt.Fprintln(out)
 	}
 
 	// 4. Top Tracks
 	if limitTracks > 0 {
-		const trackQueryString = `
-		SELECT Track.name, Track.artist, COUNT(Listen.id)
-		FROM Listen
-		INNER JOIN Track ON Track.id = Listen.track
-		WHERE user = ?
-		AND Listen.date BETWEEN ? AND ?
-		GROUP BY Track.name, Track.artist
-		ORDER BY COUNT(*) DESC
-		LIMIT ?
-		;
-		`
-		trackRows, err := db.Query(trackQueryString, user, start.Unix(), end.Unix(), limitTracks)
+		tracks, err := db.GetTopTracksWithCount(ctx, user, start, end)
 		if err != nil {
 			return fmt.Errorf("querying tracks: %w", err)
 		}
-		defer trackRows.Close()
 
 		fmt.Fprintf(out, "## Top %d Tracks\n", limitTracks)
-		i := 1
-		for trackRows.Next() {
-			var name, artist string
-			var count int64
-			if err := trackRows.Scan(&name, &artist, &count); err != nil {
-				return fmt.Errorf("scanning track: %w", err)
-			}
-			fmt.Fprintf(out, "%d. %s - %s (%d)\n", i, name, artist, count)
-			i++
+		for i, track := range tracks[:min(len(tracks), limitTracks)] {
+			fmt.Fprintf(out, "%d. %s - %s (%d)\n", i+1, track.Track, track.Artist, track.Count)
 		}
 		fmt.Fprintln(out)
 	}
@@ -354,44 +270,57 @@ func printTopN(out io.Writer, dbPath string, start, end time.Time, limitArtists,
 	return nil
 }
 
-func getArtistTags(db *sql.DB, artist string, limit int) (string, error) {
+// getArtistTags returns each artist's top curated tags, comma-separated. The tags are loaded all at
+// once so that the tag rules and personal tags are only loaded once per report.
+func getArtistTags(ctx context.Context, db store.Store, limit int) (map[string]string, error) {
+	tags := make(map[string]string)
 	if limit <= 0 {
-		return "", nil
+		return tags, nil
 	}
-	rows, err := db.Query("SELECT tag FROM ArtistTag WHERE artist = ? ORDER BY count DESC LIMIT ?", artist, limit)
+	data, err := db.GetAllArtistTags(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer rows.Close()
-
-	var tags []string
-	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err != nil {
-			return "", err
-		}
-		tags = append(tags, tag)
+	byArtist := make(map[string][]store.TagCount)
+	for _, d := range data {
+		byArtist[d.Artist] = append(byArtist[d.Artist], store.TagCount{Tag: d.Tag, Count: d.Count})
+	}
+	for artist, counts := range byArtist {
+		tags[artist] = joinTopTags(counts, limit)
 	}
-	return strings.Join(tags, ", "), nil
+	return tags, nil
 }
 
-func getAlbumTags(db *sql.DB, artist, album string, limit int) (string, error) {
+// getAlbumTags returns each album's top curated tags, comma-separated.
+func getAlbumTags(ctx context.Context, db store.Store, limit int) (map[store.AlbumKey]string, error) {
+	tags := make(map[store.AlbumKey]string)
 	if limit <= 0 {
-		return "", nil
+		return tags, nil
 	}
-	rows, err := db.Query("SELECT tag FROM AlbumTag WHERE artist = ? AND album = ? ORDER BY count DESC LIMIT ?", artist, album, limit)
+	data, err := db.GetAllAlbumTags(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	byAlbum := make(map[store.AlbumKey][]store.TagCount)
+	for _, d := range data {
+		key := store.AlbumKey{Artist: d.Artist, Name: d.Album}
+		byAlbum[key] = append(byAlbum[key], store.TagCount{Tag: d.Tag, Count: d.Count})
 	}
-	defer rows.Close()
+	for key, counts := range byAlbum {
+		tags[key] = joinTopTags(counts, limit)
+	}
+	return tags, nil
+}
 
-	var tags []string
-	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err != nil {
-			return "", err
-		}
-		tags = append(tags, tag)
+// joinTopTags returns the limit tags with the highest counts, comma-separated. The tags are in name
+// order, so ties stay in name order.
+func joinTopTags(counts []store.TagCount, limit int) string {
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Count > counts[j].Count
+	})
+	var names []string
+	for _, c := range counts[:min(len(counts), limit)] {
+		names = append(names, c.Tag)
 	}
-	return strings.Join(tags, ", "), nil
+	return strings.Join(names, ", ")
 }
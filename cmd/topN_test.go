@@ -18,10 +18,13 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ademuri/last-fm-tools/internal/store"
 	"github.com/spf13/viper"
 )
 
@@ -77,6 +80,12 @@ track := "Come Together"
 
 	tx.Commit()
 
+	s, err := store.New(dbPath)
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	defer s.Close()
+
 	// Configure limits
 	limitArtists = 10
 	limitAlbums = 10
@@ -89,7 +98,7 @@ track := "Come Together"
 	startTime := time.Now().AddDate(0, -2, 0)
 	endTime := time.Now()
 
-	err = printTopN(&out, dbPath, startTime, endTime, limitArtists, limitAlbums, limitTracks, limitTags)
+	err = printTopN(context.Background(), &out, s, startTime, endTime, limitArtists, limitAlbums, limitTracks, limitTags)
 	if err != nil {
 		t.Fatalf("printTopN failed: %v", err)
 	}
@@ -113,3 +122,44 @@ track := "Come Together"
 		t.Errorf("Output missing expected album tags %q. Got:\n%s", expectedAlbumTags, output)
 	}
 }
+
+func TestTopNAnalyzerTracks(t *testing.T) {
+	db, user := createTestStore(t)
+	listenDate := time.Now().AddDate(0, -1, 0).Unix()
+	var tracks []store.TrackImport
+	add := func(album, track string, date int64) {
+		tracks = append(tracks, store.TrackImport{Artist: "Radiohead", Album: album, TrackName: track, DateUTS: strconv.FormatInt(date, 10)})
+	}
+	add("OK Computer", "Airbag", listenDate)
+	add("OK Computer OKNOTOK", "Airbag", listenDate+1)
+	// Tied tracks are ordered by name.
+	add("OK Computer", "Lucky", listenDate+2)
+	add("OK Computer", "Karma Police", listenDate+3)
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &TopNAnalyzer{}
+	if err := analyzer.Configure(map[string]string{"tracks": "5"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	a, err := analyzer.GetResults(context.Background(), db, user, time.Now().AddDate(0, -2, 0), time.Now())
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	want := "<h3>Top 5 Tracks</h3><table><thead><tr><th>Rank</th><th>Track</th><th>Artist</th><th>Scrobbles</th></tr></thead><tbody><tr><td>1</td><td>Airbag</td><td>Radiohead</td><td>2</td></tr><tr><td>2</td><td>Karma Police</td><td>Radiohead</td><td>1</td></tr><tr><td>3</td><td>Lucky</td><td>Radiohead</td><td>1</td></tr></tbody></table>"
+	if !strings.Contains(a.BodyOverride, want) {
+		t.Errorf("GetResults() missing tracks section %q. Got:\n%s", want, a.BodyOverride)
+	}
+
+	if err := analyzer.Configure(map[string]string{"tracks": "0"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	a, err = analyzer.GetResults(context.Background(), db, user, time.Now().AddDate(0, -2, 0), time.Now())
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	if strings.Contains(a.BodyOverride, "Tracks") {
+		t.Errorf("GetResults() with tracks=0 should not include tracks. Got:\n%s", a.BodyOverride)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var topTracksNumber int
+var topTracksCmd = &cobra.Command{
+	Use:   "top-tracks [from] [to (optional)]",
+	Short: "Gets the user's top tracks",
+	Long: `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+Listens of the same track on different albums (e.g. a single and the album) are counted together.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printTopTracks(cmd.Context(), db, topTracksNumber, args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(topTracksCmd)
+
+	topTracksCmd.Flags().IntVarP(&topTracksNumber, "number", "n", 10, "number of results to return")
+}
+
+func printTopTracks(ctx context.Context, db store.Store, numToReturn int, args []string) error {
+	start, end, err := parseDateRangeFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	config := AnalyserConfig{numToReturn, 0}
+	analyzer := &TopTracksAnalyzer{}
+	out, err := analyzer.SetConfig(config).GetResults(ctx, db, viper.GetString("user"), start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+type TopTracksAnalyzer struct {
+	Config AnalyserConfig
+}
+
+func (t *TopTracksAnalyzer) SetConfig(config AnalyserConfig) *TopTracksAnalyzer {
+	t.Config = config
+	return t
+}
+
+func (t *TopTracksAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.Config.NumToReturn = n
+	}
+	if val, ok := params["min"]; ok {
+		min, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'min': %v", err)
+		}
+		t.Config.FilterThreshold = min
+	}
+	return nil
+}
+
+func (t *TopTracksAnalyzer) GetName() string {
+	return "Top tracks"
+}
+
+func (t *TopTracksAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (analysis Analysis, err error) {
+	counts, err := db.GetTopTracksWithCount(ctx, user, start, end)
+	if err != nil {
+		err = fmt.Errorf("printTopTracks: %w", err)
+		return
+	}
+
+	numTracks := 0
+	var numListens int64 = 0
+	analysis.results = [][]string{{"Track", "Artist", "Listens"}}
+
+	for _, tpc := range counts {
+		numTracks += 1
+		listens := tpc.Count
+
+		if (t.Config.NumToReturn == 0 || numTracks <= t.Config.NumToReturn) && (t.Config.FilterThreshold == 0 || listens > t.Config.FilterThreshold) {
+			analysis.results = append(analysis.results, []string{tpc.Track, tpc.Artist, strconv.FormatInt(listens, 10)})
+		}
+
+		numListens += listens
+	}
+
+	const dateFormat = "2006-01-02"
+	analysis.summary = fmt.Sprintf("Found %d tracks and %d listens from %s to %s\n",
+		numTracks, numListens, start.Format(dateFormat), end.Format(dateFormat))
+
+	return
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestTopTracksAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	err := s.AddRecentTracks(context.Background(), user, []store.TrackImport{
+		{Artist: "Radiohead", Album: "OK Computer", TrackName: "Airbag", DateUTS: "1600000000"},
+		{Artist: "Radiohead", Album: "OK Computer OKNOTOK", TrackName: "Airbag", DateUTS: "1600000100"},
+		{Artist: "Radiohead", Album: "OK Computer", TrackName: "Lucky", DateUTS: "1600000200"},
+		{Artist: "Portishead", Album: "Dummy", TrackName: "Roads", DateUTS: "1600000300"},
+		{Artist: "Portishead", Album: "Dummy", TrackName: "Roads", DateUTS: "1600000400"},
+		{Artist: "Portishead", Album: "Dummy", TrackName: "Roads", DateUTS: "1600000500"},
+	})
+	if err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	start, end := time.Unix(1600000000, 0), time.Unix(1600001000, 0)
+	analyzer := &TopTracksAnalyzer{}
+	if err := analyzer.Configure(map[string]string{"n": "2"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	got, err := analyzer.GetResults(context.Background(), s, user, start, end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	want := [][]string{
+		{"Track", "Artist", "Listens"},
+		{"Roads", "Portishead", "3"},
+		{"Airbag", "Radiohead", "2"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("GetResults(n=2) = %v, want %v", got.results, want)
+	}
+
+	if err := analyzer.Configure(map[string]string{"n": "0", "min": "2"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	got, err = analyzer.GetResults(context.Background(), s, user, start, end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	want = [][]string{
+		{"Track", "Artist", "Listens"},
+		{"Roads", "Portishead", "3"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("GetResults(min=2) = %v, want %v", got.results, want)
+	}
+}
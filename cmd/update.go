@@ -70,7 +70,7 @@ var updateCmd = &cobra.Command{
 			TagUpdateInterval: interval,
 		}
 
-		err = updateDatabase(config)
+		err = updateDatabase(cmd.Context(), config)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -94,7 +94,7 @@ func init() {
 	viper.BindPFlag("tag-update-interval", updateCmd.Flags().Lookup("tag-update-interval"))
 }
 
-func updateDatabase(config UpdateConfig) error {
+func updateDatabase(ctx context.Context, config UpdateConfig) error {
 	var after time.Time
 	var err error
 	if len(config.After) > 0 {
@@ -114,12 +114,12 @@ func updateDatabase(config UpdateConfig) error {
 	lastfmClient := lastfm.New(lastFmApiKey, lastFmSecret)
 	lastfmClient.SetUserAgent("last-fm-tools/1.0")
 
-	err = db.CreateUser(user)
+	err = db.CreateUser(ctx, user)
 	if err != nil {
 		return fmt.Errorf("creating user: %w", err)
 	}
 
-	lastUpdated, err := db.GetLastUpdated(user)
+	lastUpdated, err := db.GetLastUpdated(ctx, user)
 	if err != nil {
 		return err
 	}
@@ -131,7 +131,7 @@ func updateDatabase(config UpdateConfig) error {
 	fmt.Printf("User data was last updated: %s\n", lastUpdated.Format("2006-01-02"))
 
 	// Session Key
-	sessionKey, err := db.GetSessionKey(user)
+	sessionKey, err := db.GetSessionKey(ctx, user)
 	if err != nil {
 		return err
 	}
@@ -140,93 +140,89 @@ func updateDatabase(config UpdateConfig) error {
 		fmt.Printf("Using session key for user %q\n", user)
 	}
 
-	latestListen, err := db.GetLatestListen(user)
+	latestListen, err := db.GetLatestListen(ctx, user)
 	if err != nil {
 		return fmt.Errorf("getting latest listen: %w", err)
 	}
 	fmt.Printf("Latest local listening data is from: %s\n", latestListen.Format("2006-01-02"))
 
 	fmt.Printf("Updating database for %q\n", user)
+	// Pages are fetched newest first. Saving them as they arrive would leave a gap in the history if
+	// the update stopped part way, which later incremental updates wouldn't fill, so they're saved
+	// oldest first instead: an interrupted update leaves the history complete up to its newest listen,
+	// and the next update carries on from there. The window is fixed when the update starts, so that
+	// listens scrobbled meanwhile don't shift the pages.
+	params := lastfm.P{"limit": 200, "user": user, "to": now.Unix()}
+	switch {
+	case !after.IsZero():
+		params["from"] = after.Unix()
+	case !config.Force && !latestListen.IsZero():
+		params["from"] = latestListen.AddDate(0, 0, -7).Unix()
+	}
 	limiter := rate.NewLimiter(rate.Every(1*time.Second), 1)
-	page := 1 // First page is 1
-	pages := 0
-	for {
-		var recentTracks lastfm.UserGetRecentTracks
-		err := retry.Do(
-			func() error {
-				var err error
-				recentTracks, err = lastfmClient.User.GetRecentTracks(lastfm.P{
-					"limit": 200,
-					"page":  page,
-					"user":  user,
-				})
+	first, err := fetchRecentTracks(ctx, lastfmClient, params, 1)
+	if err != nil {
+		return err
+	}
+	saved := 0
+	for page := first.TotalPages; page >= 1; page-- {
+		recentTracks := first
+		if page > 1 {
+			if err := limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("fetching recent tracks: %w", err)
+			}
+			recentTracks, err = fetchRecentTracks(ctx, lastfmClient, params, page)
+			if err != nil {
 				return err
-			},
-			retry.RetryIf(func(err error) bool {
-				if lerr, ok := err.(*lastfm.LastfmError); ok {
-					if lerr.Code/100 == 5 {
-						fmt.Printf("last.fm errored, retrying: %w", lerr)
-						return true
-					}
-					return false
-				}
-				return false
-			}),
-		)
-		if err != nil {
-			return fmt.Errorf("fetching recent tracks: %w", err)
-		}
-
-		if pages == 0 {
-			pages = recentTracks.TotalPages
+			}
 		}
 
-		// Convert to store.TrackImport
-		var tracksToImport []store.TrackImport
+		var listens []store.TrackImport
 		for _, t := range recentTracks.Tracks {
-			tracksToImport = append(tracksToImport, store.TrackImport{
+			// The track being played now has no date yet.
+			if t.NowPlaying == "true" {
+				continue
+			}
+			listens = append(listens, store.TrackImport{
 				Artist:    t.Artist.Name,
 				Album:     t.Album.Name,
 				TrackName: t.Name,
 				DateUTS:   t.Date.Uts,
 			})
 		}
-
-		err = db.AddRecentTracks(user, tracksToImport)
-		if err != nil {
-			return fmt.Errorf("inserting recent tracks (page %d): %w", page, err)
+		if len(listens) == 0 {
+			continue
 		}
+		if err := db.AddRecentTracks(ctx, user, listens); err != nil {
+			return fmt.Errorf("inserting recent tracks: %w", err)
+		}
+		saved += len(listens)
 
-		oldestDateUts, err := strconv.ParseInt(recentTracks.Tracks[len(recentTracks.Tracks)-1].Date.Uts, 10, 64)
+		oldestDateUts, err := strconv.ParseInt(listens[len(listens)-1].DateUTS, 10, 64)
 		if err != nil {
 			return fmt.Errorf("parsing date: %w", err)
 		}
-		oldestDate := time.Unix(oldestDateUts, 0)
-
-		fmt.Printf("Downloaded page %v of %v (oldest: %s)\n", page, pages, oldestDate.Format("2006-01-02"))
-		page += 1
-
-		if !after.IsZero() && oldestDate.Before(after) {
-			break
-		}
-		if page > pages {
-			break
-		}
-		if !config.Force && !latestListen.IsZero() && oldestDate.Before(latestListen.AddDate(0, 0, -7)) {
-			fmt.Println("Refreshed back to existing data")
-			break
-		}
+		fmt.Printf("Saved page %v of %v (oldest: %s)\n", page, first.TotalPages, time.Unix(oldestDateUts, 0).Format("2006-01-02"))
+	}
+	fmt.Printf("Saved %d listens\n", saved)
 
-		limiter.Wait(context.Background())
+	weeks, err := db.UpdateWeeklyCharts(ctx, user, now)
+	if err != nil {
+		return fmt.Errorf("updating weekly charts: %w", err)
+	}
+	if weeks > 0 {
+		fmt.Printf("Updated weekly charts for %d weeks\n", weeks)
 	}
 
+	// Tags are saved one artist or album at a time, so an interrupted update keeps the tags fetched
+	// so far and fetches the rest next time.
 	fmt.Println("Updating tags...")
-	err = updateTags(db, lastfmClient, config.TagUpdateInterval)
+	err = updateTags(ctx, db, lastfmClient, config.TagUpdateInterval)
 	if err != nil {
 		return err
 	}
 
-	err = db.SetLastUpdated(user, now)
+	err = db.SetLastUpdated(ctx, user, now)
 	if err != nil {
 		return err
 	}
@@ -234,15 +230,46 @@ func updateDatabase(config UpdateConfig) error {
 	return nil
 }
 
-func updateTags(db *store.Store, lastfmClient *lastfm.Api, interval time.Duration) error {
+// fetchRecentTracks fetches a page of the user's recent tracks, retrying last.fm server errors.
+func fetchRecentTracks(ctx context.Context, lastfmClient *lastfm.Api, params lastfm.P, page int) (lastfm.UserGetRecentTracks, error) {
+	pageParams := lastfm.P{"page": page}
+	for k, v := range params {
+		pageParams[k] = v
+	}
+	var recentTracks lastfm.UserGetRecentTracks
+	err := retry.Do(
+		func() error {
+			var err error
+			recentTracks, err = lastfmClient.User.GetRecentTracks(pageParams)
+			return err
+		},
+		retry.RetryIf(func(err error) bool {
+			if lerr, ok := err.(*lastfm.LastfmError); ok {
+				if lerr.Code/100 == 5 {
+					fmt.Printf("last.fm errored, retrying: %w", lerr)
+					return true
+				}
+				return false
+			}
+			return false
+		}),
+		retry.Context(ctx),
+	)
+	if err != nil {
+		return recentTracks, fmt.Errorf("fetching recent tracks: %w", err)
+	}
+	return recentTracks, nil
+}
+
+func updateTags(ctx context.Context, db store.Store, lastfmClient *lastfm.Api, interval time.Duration) error {
 	limiter := rate.NewLimiter(rate.Every(1*time.Second), 1)
 
-	err := updateArtistTags(db, lastfmClient, limiter, interval)
+	err := updateArtistTags(ctx, db, lastfmClient, limiter, interval)
 	if err != nil {
 		return fmt.Errorf("updateArtistTags: %w", err)
 	}
 
-	err = updateAlbumTags(db, lastfmClient, limiter, interval)
+	err = updateAlbumTags(ctx, db, lastfmClient, limiter, interval)
 	if err != nil {
 		return fmt.Errorf("updateAlbumTags: %w", err)
 	}
@@ -250,8 +277,8 @@ func updateTags(db *store.Store, lastfmClient *lastfm.Api, interval time.Duratio
 	return nil
 }
 
-func updateArtistTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter, interval time.Duration) error {
-	artists, err := db.GetArtistsNeedingTagUpdate(interval)
+func updateArtistTags(ctx context.Context, db store.Store, client *lastfm.Api, limiter *rate.Limiter, interval time.Duration) error {
+	artists, err := db.GetArtistsNeedingTagUpdate(ctx, interval)
 	if err != nil {
 		return err
 	}
@@ -260,7 +287,9 @@ func updateArtistTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter
 
 	for i, artist := range artists {
 		fmt.Printf("[%d/%d] Fetching tags for artist: %s\n", i+1, len(artists), artist)
-		limiter.Wait(context.Background())
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
 
 		var topTags lastfm.ArtistGetTopTags
 		err := retry.Do(
@@ -281,8 +310,12 @@ func updateArtistTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter
 				}
 				return false
 			}),
+			retry.Context(ctx),
 		)
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			fmt.Printf("Error fetching tags for artist %s: %v\n", artist, err)
 			continue
 		}
@@ -295,7 +328,7 @@ func updateArtistTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter
 			counts = append(counts, c)
 		}
 
-		if err := db.SaveArtistTags(artist, tags, counts); err != nil {
+		if err := db.SaveArtistTags(ctx, artist, tags, counts); err != nil {
 			return fmt.Errorf("saving tags for artist %s: %w", artist, err)
 		}
 	}
@@ -303,8 +336,8 @@ func updateArtistTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter
 	return nil
 }
 
-func updateAlbumTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter, interval time.Duration) error {
-	albums, err := db.GetAlbumsNeedingTagUpdate(interval)
+func updateAlbumTags(ctx context.Context, db store.Store, client *lastfm.Api, limiter *rate.Limiter, interval time.Duration) error {
+	albums, err := db.GetAlbumsNeedingTagUpdate(ctx, interval)
 	if err != nil {
 		return err
 	}
@@ -313,7 +346,9 @@ func updateAlbumTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter,
 
 	for i, alb := range albums {
 		fmt.Printf("[%d/%d] Fetching tags for album: %s - %s\n", i+1, len(albums), alb.Artist, alb.Name)
-		limiter.Wait(context.Background())
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
 
 		var topTags lastfm.AlbumGetTopTags
 		err := retry.Do(
@@ -335,8 +370,12 @@ func updateAlbumTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter,
 				}
 				return false
 			}),
+			retry.Context(ctx),
 		)
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			fmt.Printf("Error fetching tags for album %s - %s: %v\n", alb.Artist, alb.Name, err)
 			continue
 		}
@@ -349,7 +388,7 @@ func updateAlbumTags(db *store.Store, client *lastfm.Api, limiter *rate.Limiter,
 			counts = append(counts, c)
 		}
 
-		if err := db.SaveAlbumTags(alb.Artist, alb.Name, tags, counts); err != nil {
+		if err := db.SaveAlbumTags(ctx, alb.Artist, alb.Name, tags, counts); err != nil {
 			return fmt.Errorf("saving tags for album %s - %s: %w", alb.Artist, alb.Name, err)
 		}
 	}
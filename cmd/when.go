@@ -0,0 +1,251 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	whenArtists  int
+	whenTags     int
+	whenTimezone string
+	whenScoring  string
+)
+
+var whenCmd = &cobra.Command{
+	Use:   "when [from] [to (optional)]",
+	Short: "Shows when the user listens, and what they listen to at different times",
+	Long: `Uses the specified date or date range. Date strings look like 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd'.
+Shows a heatmap of listens for each hour of the week, and the top artists and tags for each part
+of the week: weekday morning commutes (05-09), work (09-17) and evenings (17-22), weekends (05-22)
+and late nights (22-05). Tags are weighted like the taste report.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printWhen(cmd.Context(), os.Stdout, db, viper.GetString("user"), args)
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whenCmd)
+
+	whenCmd.Flags().IntVarP(&whenArtists, "artists", "n", 3, "number of top artists to show for each part of the week")
+	whenCmd.Flags().IntVar(&whenTags, "tags", 3, "number of top tags to show for each part of the week")
+	whenCmd.Flags().StringVar(&whenScoring, "tag_scoring", string(analysis.TagScoringBinary), "how tags are weighted: binary (every tag gets the whole listen), count (shared by tag count) or tfidf (count, scaled down for tags common in the library)")
+	whenCmd.Flags().StringVar(&whenTimezone, "timezone", "", "timezone to bucket listens in (e.g. America/Los_Angeles), default is local time")
+}
+
+func printWhen(ctx context.Context, out io.Writer, db store.Store, user string, args []string) error {
+	start, end, err := parseDateRangeFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	analyzer := &WhenAnalyzer{Config: analysis.WhenConfig{Artists: whenArtists, Tags: whenTags}}
+	if err := analyzer.Configure(map[string]string{"timezone": whenTimezone, "tag_scoring": whenScoring}); err != nil {
+		return err
+	}
+	stats, a, err := analyzer.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, a.summary)
+	fmt.Fprintln(out, renderHourHeatmapText(stats.Hours))
+	fmt.Fprint(out, Analysis{results: a.results})
+	return nil
+}
+
+type WhenAnalyzer struct {
+	Config analysis.WhenConfig
+}
+
+func (t *WhenAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["artists"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'artists': %v", err)
+		}
+		t.Config.Artists = n
+	}
+	if val, ok := params["tags"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'tags': %v", err)
+		}
+		t.Config.Tags = n
+	}
+	if val, ok := params["tag_scoring"]; ok {
+		scoring, err := parseTagScoring(val)
+		if err != nil {
+			return err
+		}
+		t.Config.TagScoring = scoring
+	}
+	if val, ok := params["timezone"]; ok && val != "" {
+		loc, err := time.LoadLocation(val)
+		if err != nil {
+			return fmt.Errorf("loading timezone %q: %w", val, err)
+		}
+		t.Config.Location = loc
+	}
+	return nil
+}
+
+func (t *WhenAnalyzer) GetName() string {
+	return "When you listen"
+}
+
+// GetResults returns HTML for emails, with the hour of the week heatmap.
+func (t *WhenAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
+	stats, a, err := t.analyze(ctx, db, user, start, end)
+	if err != nil {
+		return a, err
+	}
+	if stats.Listens == 0 {
+		a.results = a.results[:1]
+		return a, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<div>%s</div>\n", strings.ReplaceAll(html.EscapeString(a.summary), "\n", "<br>\n"))
+	sb.WriteString(renderHourHeatmapHTML(stats.Hours))
+	writeHTMLTable(&sb, a.results)
+	a.BodyOverride = sb.String()
+	return a, nil
+}
+
+// analyze returns the stats, with a text summary and a table of the dayparts.
+func (t *WhenAnalyzer) analyze(ctx context.Context, db store.Store, user string, start, end time.Time) (stats analysis.WhenStats, a Analysis, err error) {
+	config := t.Config
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	stats, err = analysis.GetWhenStats(ctx, db, user, start, end, config)
+	if err != nil {
+		err = fmt.Errorf("printWhen: %w", err)
+		return
+	}
+
+	const dateFormat = "2006-01-02"
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%d listens from %s to %s (times in %s)\n", stats.Listens, start.Format(dateFormat), end.Format(dateFormat), config.Location)
+	var distinctive []string
+	for _, p := range stats.Dayparts {
+		if p.Distinctive != "" {
+			distinctive = append(distinctive, fmt.Sprintf("%s during %s", p.Distinctive, strings.ToLower(p.Daypart.Name)))
+		}
+	}
+	if len(distinctive) > 0 {
+		fmt.Fprintf(&summary, "Stands out: %s\n", strings.Join(distinctive, ", "))
+	}
+	a.summary = summary.String()
+
+	a.results = [][]string{{"Part of the Week", "Listens", "Share", "Top Artists", "Top Tags"}}
+	for _, p := range stats.Dayparts {
+		var artists, tags []string
+		for _, artist := range p.Artists {
+			artists = append(artists, artist.Artist)
+		}
+		for _, tag := range p.Tags {
+			tags = append(tags, tag.Tag)
+		}
+		a.results = append(a.results, []string{
+			p.Daypart.Name,
+			strconv.Itoa(p.Listens),
+			fmt.Sprintf("%.0f%%", 100*p.Share),
+			strings.Join(artists, ", "),
+			strings.Join(tags, ", "),
+		})
+	}
+	return
+}
+
+var hourHeatmapDays = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+func hourHeatmapMax(hours [7][24]int) (max int) {
+	for _, day := range hours {
+		for _, n := range day {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	return
+}
+
+// renderHourHeatmapText renders listens for each hour of the week, with a row per day.
+func renderHourHeatmapText(hours [7][24]int) string {
+	max := hourHeatmapMax(hours)
+	var sb strings.Builder
+	sb.WriteString("    ")
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&sb, "%-6s", fmt.Sprintf("%02d", hour))
+	}
+	sb.WriteString("\n")
+	for day, name := range hourHeatmapDays {
+		fmt.Fprintf(&sb, "%-4s", name)
+		for hour, n := range hours[day] {
+			if hour > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(heatmapChars[heatmapLevel(n, max)])
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "    Less %s More\n", strings.Join(heatmapChars[:], " "))
+	return sb.String()
+}
+
+// renderHourHeatmapHTML renders listens for each hour of the week as a table for emails.
+func renderHourHeatmapHTML(hours [7][24]int) string {
+	max := hourHeatmapMax(hours)
+	const cell = `border: none; padding: 0; width: 14px; height: 14px;`
+	var sb strings.Builder
+	sb.WriteString(`<table style="border: none; border-collapse: separate; border-spacing: 2px; font-size: 9px;">`)
+	sb.WriteString(`<tr><td style="border: none;"></td>`)
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&sb, `<td style="border: none; padding: 0;" colspan="3">%02d</td>`, hour)
+	}
+	sb.WriteString("</tr>\n")
+	for day, name := range hourHeatmapDays {
+		fmt.Fprintf(&sb, `<tr><td style="border: none; padding: 0 2px 0 0;">%s</td>`, name)
+		for hour, n := range hours[day] {
+			fmt.Fprintf(&sb, `<td style="%s background-color: %s;" title="%s %02d:00: %d listens"></td>`,
+				cell, heatmapColors[heatmapLevel(n, max)], name, hour, n)
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>\n")
+	return sb.String()
+}
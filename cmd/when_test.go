@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestWhenAnalyzer(t *testing.T) {
+	s, user := createTestStore(t)
+	ctx := context.Background()
+	if err := s.SaveArtistTags(ctx, "Eno", []string{"ambient", "electronic"}, []int{100, 60}); err != nil {
+		t.Fatalf("SaveArtistTags: %v", err)
+	}
+	if err := s.SaveArtistTags(ctx, "Ramones", []string{"punk", "rock"}, []int{100, 80}); err != nil {
+		t.Fatalf("SaveArtistTags: %v", err)
+	}
+	// 2020-01-06 was a Monday. Eno during work hours, and the Ramones on Friday night.
+	monday := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	var tracks []store.TrackImport
+	for _, l := range []struct {
+		day, hour int
+		artist    string
+	}{{0, 10, "Eno"}, {1, 10, "Eno"}, {2, 11, "Eno"}, {4, 23, "Ramones"}} {
+		ts := monday.AddDate(0, 0, l.day).Add(time.Duration(l.hour) * time.Hour)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: "Album", TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	analyzer := &WhenAnalyzer{}
+	if err := analyzer.Configure(map[string]string{"artists": "1", "tags": "2", "timezone": "UTC"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	end := monday.AddDate(0, 0, 7)
+	stats, got, err := analyzer.analyze(ctx, s, user, monday, end)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	want := [][]string{
+		{"Part of the Week", "Listens", "Share", "Top Artists", "Top Tags"},
+		{"Morning commute", "0", "0%", "", ""},
+		{"Work", "3", "75%", "Eno", "ambient, electronic"},
+		{"Evening", "0", "0%", "", ""},
+		{"Weekend", "0", "0%", "", ""},
+		{"Late night", "1", "25%", "Ramones", "punk, rock"},
+	}
+	if !reflect.DeepEqual(got.results, want) {
+		t.Errorf("results = %v, want %v", got.results, want)
+	}
+	if line := "Stands out: ambient during work, punk during late night\n"; !strings.Contains(got.summary, line) {
+		t.Errorf("summary missing %q. Got:\n%s", line, got.summary)
+	}
+
+	text := renderHourHeatmapText(stats.Hours)
+	lines := strings.Split(text, "\n")
+	if want := "Mon · · · · · · · · · · █ · "; !strings.HasPrefix(lines[1], want) {
+		t.Errorf("heatmap Monday = %q, want prefix %q", lines[1], want)
+	}
+	if !strings.HasSuffix(lines[5], " █") {
+		t.Errorf("heatmap Friday = %q, want a listen at 23:00", lines[5])
+	}
+
+	a, err := analyzer.GetResults(ctx, s, user, monday, end)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	for _, want := range []string{
+		`background-color: #216e39;" title="Fri 23:00: 1 listens"`,
+		"<td>Work</td><td>3</td><td>75%</td><td>Eno</td>",
+	} {
+		if !strings.Contains(a.BodyOverride, want) {
+			t.Errorf("GetResults() HTML missing %q", want)
+		}
+	}
+
+	var out bytes.Buffer
+	whenTimezone = "UTC"
+	if err := printWhen(ctx, &out, s, user, []string{"2020-01-06", "2020-01-13"}); err != nil {
+		t.Fatalf("printWhen: %v", err)
+	}
+	if !strings.Contains(out.String(), "Stands out:") || !strings.Contains(out.String(), "Less ·") {
+		t.Errorf("printWhen() output missing summary or heatmap:\n%s", out.String())
+	}
+}
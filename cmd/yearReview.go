@@ -0,0 +1,218 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	yearReviewTop     int
+	yearReviewFormat  string
+	yearReviewScoring string
+)
+
+var yearReviewCmd = &cobra.Command{
+	Use:   "year-review <year>",
+	Short: "Generates a summary of a year of listening",
+	Long: `Summarises a year of listening: total scrobbles compared to the previous year, top artists,
+albums and tracks with the month each peaked, new artists, the biggest rediscovery, the busiest day,
+a monthly breakdown and the tags which rose and fell the most. Years are in UTC.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := withStore(viper.GetString("database"), func(db store.Store) error {
+			return printYearReview(cmd.Context(), os.Stdout, db, viper.GetString("user"), args[0])
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(yearReviewCmd)
+
+	yearReviewCmd.Flags().IntVarP(&yearReviewTop, "number", "n", 10, "number of top artists, albums and tracks to show")
+	yearReviewCmd.Flags().StringVar(&yearReviewScoring, "tag_scoring", string(analysis.TagScoringBinary), "how tags are weighted: binary (every tag gets the whole listen), count (shared by tag count) or tfidf (count, scaled down for tags common in the library)")
+	yearReviewCmd.Flags().StringVar(&yearReviewFormat, "format", "yaml", "output format: yaml, json or html")
+}
+
+func printYearReview(ctx context.Context, out io.Writer, db store.Store, user, yearArg string) error {
+	analyzer := &YearReviewAnalyzer{Config: defaultYearReviewConfig()}
+	if err := analyzer.Configure(map[string]string{"year": yearArg, "n": strconv.Itoa(yearReviewTop), "tag_scoring": yearReviewScoring}); err != nil {
+		return err
+	}
+
+	review, err := analyzer.generate(ctx, db, user, analyzer.Year)
+	if err != nil {
+		return fmt.Errorf("printYearReview: %w", err)
+	}
+
+	switch yearReviewFormat {
+	case "yaml":
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		err = encoder.Encode(review)
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(review)
+	case "html":
+		_, err = fmt.Fprintln(out, renderYearReviewHTML(review))
+	default:
+		return fmt.Errorf("invalid format %q, must be yaml, json or html", yearReviewFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("encoding review: %w", err)
+	}
+	return nil
+}
+
+func defaultYearReviewConfig() analysis.YearReviewConfig {
+	return analysis.YearReviewConfig{Top: 10, NewArtists: 5, TagShifts: 5, TagScoring: analysis.TagScoringBinary}
+}
+
+type YearReviewAnalyzer struct {
+	Config analysis.YearReviewConfig
+	// The year to review. If unset, it's the year the email's period starts in.
+	Year int
+}
+
+func (t *YearReviewAnalyzer) Configure(params map[string]string) error {
+	if val, ok := params["year"]; ok {
+		year, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'year': %v", err)
+		}
+		t.Year = year
+	}
+	if val, ok := params["n"]; ok {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid value for 'n': %v", err)
+		}
+		t.Config.Top = n
+	}
+	if val, ok := params["tag_scoring"]; ok {
+		scoring, err := parseTagScoring(val)
+		if err != nil {
+			return err
+		}
+		t.Config.TagScoring = scoring
+	}
+	return nil
+}
+
+func (t *YearReviewAnalyzer) GetName() string {
+	return "Year in review"
+}
+
+func (t *YearReviewAnalyzer) generate(ctx context.Context, db store.Store, user string, year int) (*analysis.YearReview, error) {
+	return analysis.GenerateYearReview(ctx, db, user, year, t.Config)
+}
+
+// GetResults reviews the configured year, or the year start is in, which makes a report run every
+// January with the default period of the previous month review the previous year.
+func (t *YearReviewAnalyzer) GetResults(ctx context.Context, db store.Store, user string, start time.Time, end time.Time) (Analysis, error) {
+	var a Analysis
+	year := t.Year
+	if year == 0 {
+		year = start.Year()
+	}
+	review, err := t.generate(ctx, db, user, year)
+	if err != nil {
+		return a, fmt.Errorf("generating year review: %w", err)
+	}
+	a.BodyOverride = renderYearReviewHTML(review)
+	return a, nil
+}
+
+func renderYearReviewHTML(review *analysis.YearReview) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<h3>%d in review</h3>\n", review.Year)
+	fmt.Fprintf(&sb, "<p><strong>%d scrobbles</strong> of %d artists", review.TotalScrobbles, review.TotalArtists)
+	if review.PreviousYearScrobbles > 0 {
+		fmt.Fprintf(&sb, ", %+.0f%% compared to %d (%d scrobbles)", review.ChangeVsPreviousYear*100, review.Year-1, review.PreviousYearScrobbles)
+	}
+	sb.WriteString(".</p>\n")
+	if d := review.BusiestDay; d != nil {
+		fmt.Fprintf(&sb, "<p><strong>Busiest day:</strong> %s, with %d scrobbles.</p>\n", d.Date, d.Scrobbles)
+	}
+	if r := review.BiggestRediscovery; r != nil {
+		fmt.Fprintf(&sb, "<p><strong>Biggest rediscovery:</strong> %s, with %d scrobbles after not being played since %s.</p>\n",
+			html.EscapeString(r.Artist), r.Scrobbles, r.PreviousListen)
+	}
+
+	topTable := func(title string, entries []analysis.YearTopEntry, nameHeader string) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "<h3>%s</h3>\n", title)
+		header := []string{"Artist", "Scrobbles", "Peak Month"}
+		if nameHeader != "" {
+			header = []string{"Artist", nameHeader, "Scrobbles", "Peak Month"}
+		}
+		results := [][]string{header}
+		for _, e := range entries {
+			row := []string{e.Artist}
+			if nameHeader != "" {
+				row = append(row, e.Name)
+			}
+			results = append(results, append(row, strconv.FormatInt(e.Scrobbles, 10), e.PeakMonth))
+		}
+		writeHTMLTable(&sb, results)
+	}
+	topTable("Top artists", review.TopArtists, "")
+	topTable("Top albums", review.TopAlbums, "Album")
+	topTable("Top tracks", review.TopTracks, "Track")
+	topTable(fmt.Sprintf("New artists (%d discovered)", review.NewArtists.Count), review.NewArtists.Top, "")
+
+	sb.WriteString("<h3>Months</h3>\n")
+	months := [][]string{{"Month", "Scrobbles", "Top Artist"}}
+	for _, m := range review.Months {
+		months = append(months, []string{m.Month, strconv.FormatInt(m.Scrobbles, 10), m.TopArtist})
+	}
+	writeHTMLTable(&sb, months)
+
+	shifts := func(title string, tags []analysis.YearTagShift) {
+		if len(tags) == 0 {
+			return
+		}
+		var names []string
+		for _, t := range tags {
+			names = append(names, fmt.Sprintf("%s (%.2f → %.2f)", t.Tag, t.PreviousWeight, t.Weight))
+		}
+		fmt.Fprintf(&sb, "<p><strong>%s:</strong> %s</p>\n", title, html.EscapeString(strings.Join(names, ", ")))
+	}
+	shifts("Rising tags", review.TagShifts.Rising)
+	shifts("Falling tags", review.TagShifts.Falling)
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/analysis"
+	"github.com/ademuri/last-fm-tools/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYearReview(t *testing.T) {
+	s, user := createTestStore(t)
+	var tracks []store.TrackImport
+	for _, l := range []struct {
+		month, day int
+		artist     string
+	}{{12, 1, "Air"}, {3, 2, "Air"}, {3, 2, "Blur"}, {3, 3, "Blur"}, {8, 1, "Blur"}} {
+		year := 2020
+		if l.month == 12 {
+			year = 2019
+		}
+		ts := time.Date(year, time.Month(l.month), l.day, len(l.artist)+10, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: "Album", TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	// Reports sent in January cover December, and so review the previous year.
+	analyzer := &YearReviewAnalyzer{Config: defaultYearReviewConfig()}
+	start := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	a, err := analyzer.GetResults(context.Background(), s, user, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	for _, want := range []string{
+		"<h3>2020 in review</h3>",
+		"<strong>4 scrobbles</strong> of 2 artists, +300% compared to 2019 (1 scrobbles)",
+		"<strong>Busiest day:</strong> 2020-03-02, with 2 scrobbles.",
+		"<td>Blur</td><td>3</td><td>March</td>",
+		"<h3>New artists (1 discovered)</h3>",
+		"<td>August</td><td>1</td><td>Blur</td>",
+	} {
+		if !strings.Contains(a.BodyOverride, want) {
+			t.Errorf("GetResults() HTML missing %q:\n%s", want, a.BodyOverride)
+		}
+	}
+
+	yearReviewTop = 1
+	defer func() { yearReviewFormat = "yaml" }()
+	for _, format := range []string{"yaml", "json"} {
+		yearReviewFormat = format
+		var out bytes.Buffer
+		if err := printYearReview(context.Background(), &out, s, user, "2020"); err != nil {
+			t.Fatalf("printYearReview(%s): %v", format, err)
+		}
+		var review analysis.YearReview
+		if format == "yaml" {
+			err = yaml.Unmarshal(out.Bytes(), &review)
+		} else {
+			err = json.Unmarshal(out.Bytes(), &review)
+		}
+		if err != nil {
+			t.Fatalf("decoding %s: %v\n%s", format, err, out.String())
+		}
+		if review.Year != 2020 || review.TotalScrobbles != 4 || len(review.TopArtists) != 1 || review.TopArtists[0].Artist != "Blur" {
+			t.Errorf("printYearReview(%s) = %+v, want 4 scrobbles in 2020 with Blur on top", format, review)
+		}
+	}
+
+	yearReviewFormat = "xml"
+	if err := printYearReview(context.Background(), &bytes.Buffer{}, s, user, "2020"); err == nil {
+		t.Errorf("printYearReview() with an invalid format succeeded, want an error")
+	}
+}
@@ -0,0 +1,182 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+type AlbumHistoryConfig struct {
+	// Months start at midnight in Location.
+	Location *time.Location
+	// How sessions and play-throughs are found. PlayThrough.Albums is unused.
+	PlayThrough PlayThroughConfig
+	// Number of tags to return.
+	Tags int
+	// Number of albums played alongside the album to return.
+	Related int
+}
+
+// AlbumHistory is everything about the user's listening to one album. The zero AlbumHistory, with
+// no plays, means the album was never listened to.
+type AlbumHistory struct {
+	// The names as they were scrobbled, which may differ in case from the names asked for.
+	Artist string
+	Album  string
+	// The first listen is when the album was discovered.
+	FirstListen time.Time
+	LastListen  time.Time
+	Plays       int64
+	// How the album ranks by plays among the artist's albums, 1 for the most played.
+	ArtistRank   int
+	ArtistAlbums int
+	// Plays of each of the album's known tracks, most played first. Tracks which have been
+	// scrobbled by any user are known, so some may have no plays.
+	Tracks []NamePlays
+	// Plays in every month from the first listen to the last.
+	Months []MonthPlays
+	// Estimated full listens of the album, oldest first.
+	PlayThroughs []PlayThrough
+	Tags         []string
+	// The number of listening sessions which included the album.
+	Sessions int
+	// Other albums played in the most of those sessions.
+	Related []RelatedAlbum
+}
+
+// RelatedAlbum is an album played in the same sessions as another.
+type RelatedAlbum struct {
+	Artist string
+	Album  string
+	// The number of sessions with both albums.
+	Sessions int
+}
+
+// resolveAlbum returns the album named in the listens, matching the names exactly if possible, or
+// otherwise ignoring case, picking the most played spelling.
+func resolveAlbum(listens []store.TrackListen, artist, album string) (key store.AlbumKey) {
+	plays := make(map[store.AlbumKey]int)
+	for _, l := range listens {
+		if l.Album == "" {
+			continue
+		}
+		if l.Artist == artist && l.Album == album {
+			return store.AlbumKey{Artist: artist, Name: album}
+		}
+		if strings.EqualFold(l.Artist, artist) && strings.EqualFold(l.Album, album) {
+			plays[store.AlbumKey{Artist: l.Artist, Name: l.Album}]++
+		}
+	}
+	for k, n := range plays {
+		if key.Name == "" || n > plays[key] || (n == plays[key] && (k.Artist < key.Artist || (k.Artist == key.Artist && k.Name < key.Name))) {
+			key = k
+		}
+	}
+	return key
+}
+
+// GetAlbumHistory gathers the user's history with the album from their listens before end.
+func GetAlbumHistory(ctx context.Context, db store.Store, user, artist, album string, end time.Time, config AlbumHistoryConfig) (history AlbumHistory, err error) {
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	listens, err := db.GetListenHistory(ctx, user, time.Unix(0, 0), end)
+	if err != nil {
+		return history, err
+	}
+	key := resolveAlbum(listens, artist, album)
+	if key.Name == "" {
+		return history, nil
+	}
+	history.Artist, history.Album = key.Artist, key.Name
+
+	known, err := db.GetAlbumTracks(ctx, key.Artist, key.Name)
+	if err != nil {
+		return history, err
+	}
+	tracks := make(map[string]int64)
+	for _, track := range known {
+		tracks[track] = 0
+	}
+	months := make(map[time.Time]int64)
+	related := make(map[store.AlbumKey]int)
+	for _, s := range SplitSessions(listens, config.PlayThrough.Gap) {
+		others := make(map[store.AlbumKey]bool)
+		included := false
+		for _, l := range s.Listens {
+			k := store.AlbumKey{Artist: l.Artist, Name: l.Album}
+			if k != key {
+				if l.Album != "" {
+					others[k] = true
+				}
+				continue
+			}
+			included = true
+			if history.Plays == 0 {
+				history.FirstListen = l.Time.In(loc)
+			}
+			history.LastListen = l.Time.In(loc)
+			history.Plays++
+			tracks[l.Track]++
+			months[startOfMonth(l.Time, loc)]++
+		}
+		if !included {
+			continue
+		}
+		history.Sessions++
+		for k := range others {
+			related[k]++
+		}
+		for _, run := range albumRuns(s.Listens) {
+			if run[0].Artist != key.Artist || run[0].Album != key.Name || !config.PlayThrough.isPlayThrough(len(run), len(known)) {
+				continue
+			}
+			history.PlayThroughs = append(history.PlayThroughs, PlayThrough{
+				Artist:      key.Artist,
+				Album:       key.Name,
+				Start:       run[0].Time,
+				Tracks:      len(run),
+				KnownTracks: len(known),
+			})
+		}
+	}
+
+	history.Tracks = topNamePlays(tracks, len(tracks))
+	history.Months = monthlyPlays(months, history.FirstListen, history.LastListen, loc)
+	for k, n := range related {
+		history.Related = append(history.Related, RelatedAlbum{Artist: k.Artist, Album: k.Name, Sessions: n})
+	}
+	sort.Slice(history.Related, func(i, j int) bool {
+		a, b := history.Related[i], history.Related[j]
+		if a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		if a.Artist != b.Artist {
+			return a.Artist < b.Artist
+		}
+		return a.Album < b.Album
+	})
+	history.Related = history.Related[:min(len(history.Related), config.Related)]
+
+	// A negative limit returns all of the artist's albums. The range includes its end.
+	albums, err := db.GetTopAlbumsForArtist(ctx, user, key.Artist, time.Unix(0, 0), end.Add(-time.Second), -1)
+	if err != nil {
+		return history, err
+	}
+	history.ArtistRank = 1
+	history.ArtistAlbums = len(albums)
+	for _, a := range albums {
+		if int64(a.Count) > history.Plays {
+			history.ArtistRank++
+		}
+	}
+
+	if history.Tags, err = db.GetTopTagsForAlbum(ctx, key.Artist, key.Name, config.Tags); err != nil {
+		return history, err
+	}
+	return history, nil
+}
@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGetAlbumHistory(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+	db.CreateUser(ctx, "friend")
+	db.SaveAlbumTags(ctx, "Radiohead", "OK Computer", []string{"alternative", "90s", "rock"}, []int{100, 60, 50})
+
+	add := func(user string, listens []struct{ date, artist, album, track string }) {
+		var tracks []store.TrackImport
+		for _, l := range listens {
+			ts, err := time.Parse(time.RFC3339, l.date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.album, TrackName: l.track, DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+		if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+			t.Fatalf("AddRecentTracks: %v", err)
+		}
+	}
+	// Another user's scrobble makes Lucky a known track of the album.
+	add("friend", []struct{ date, artist, album, track string }{
+		{"2020-01-01T10:00:00Z", "Radiohead", "OK Computer", "Lucky"},
+	})
+	add(user, []struct{ date, artist, album, track string }{
+		// Four of the five known tracks, then Kid A in the same session.
+		{"2020-01-10T10:00:00Z", "Radiohead", "OK Computer", "Airbag"},
+		{"2020-01-10T10:05:00Z", "Radiohead", "OK Computer", "Paranoid Android"},
+		{"2020-01-10T10:10:00Z", "Radiohead", "OK Computer", "Subterranean Homesick Alien"},
+		{"2020-01-10T10:15:00Z", "Radiohead", "OK Computer", "Exit Music"},
+		{"2020-01-10T10:20:00Z", "Radiohead", "Kid A", "Idioteque"},
+		{"2020-03-01T12:00:00Z", "Radiohead", "OK Computer", "Airbag"},
+		{"2020-03-01T12:05:00Z", "Low", "Things We Lost in the Fire", "Sunflower"},
+		// Kid A alone isn't played alongside OK Computer.
+		{"2020-03-02T12:00:00Z", "Radiohead", "Kid A", "Idioteque"},
+		{"2020-04-01T12:00:00Z", "Radiohead", "OK Computer", "Airbag"},
+		{"2020-04-01T12:05:00Z", "Radiohead", "Kid A", "Idioteque"},
+	})
+
+	config := AlbumHistoryConfig{
+		Location:    time.UTC,
+		PlayThrough: PlayThroughConfig{Gap: DefaultSessionGap, MinCoverage: DefaultMinAlbumCoverage, MinTracks: DefaultMinAlbumTracks},
+		Tags:        2,
+		Related:     5,
+	}
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The names are matched ignoring case.
+	history, err := GetAlbumHistory(ctx, db, user, "radiohead", "ok computer", end, config)
+	if err != nil {
+		t.Fatalf("GetAlbumHistory: %v", err)
+	}
+
+	if history.Artist != "Radiohead" || history.Album != "OK Computer" || history.Plays != 6 {
+		t.Errorf("album = %q by %q with %d plays, want OK Computer by Radiohead with 6", history.Album, history.Artist, history.Plays)
+	}
+	if got := history.FirstListen.Format("2006-01-02"); got != "2020-01-10" {
+		t.Errorf("FirstListen = %s, want 2020-01-10", got)
+	}
+	if history.ArtistRank != 1 || history.ArtistAlbums != 2 {
+		t.Errorf("rank = %d of %d, want 1 of 2", history.ArtistRank, history.ArtistAlbums)
+	}
+	wantTracks := []NamePlays{{"Airbag", 3}, {"Exit Music", 1}, {"Paranoid Android", 1}, {"Subterranean Homesick Alien", 1}, {"Lucky", 0}}
+	if !reflect.DeepEqual(history.Tracks, wantTracks) {
+		t.Errorf("Tracks = %v, want %v", history.Tracks, wantTracks)
+	}
+	var months []int64
+	for _, m := range history.Months {
+		months = append(months, m.Plays)
+	}
+	if want := []int64{4, 0, 1, 1}; !reflect.DeepEqual(months, want) {
+		t.Errorf("Months = %v, want %v", months, want)
+	}
+	if len(history.PlayThroughs) != 1 || history.PlayThroughs[0].Tracks != 4 || history.PlayThroughs[0].KnownTracks != 5 {
+		t.Errorf("PlayThroughs = %+v, want one of 4 of 5 tracks", history.PlayThroughs)
+	}
+	if want := []string{"alternative", "90s"}; !reflect.DeepEqual(history.Tags, want) {
+		t.Errorf("Tags = %v, want %v", history.Tags, want)
+	}
+	if history.Sessions != 3 {
+		t.Errorf("Sessions = %d, want 3", history.Sessions)
+	}
+	wantRelated := []RelatedAlbum{{"Radiohead", "Kid A", 2}, {"Low", "Things We Lost in the Fire", 1}}
+	if !reflect.DeepEqual(history.Related, wantRelated) {
+		t.Errorf("Related = %v, want %v", history.Related, wantRelated)
+	}
+
+	history, err = GetAlbumHistory(ctx, db, user, "Radiohead", "Amnesiac", end, config)
+	if err != nil {
+		t.Fatalf("GetAlbumHistory: %v", err)
+	}
+	if history.Plays != 0 {
+		t.Errorf("GetAlbumHistory for an unknown album = %+v, want no plays", history)
+	}
+}
@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"regexp"
@@ -11,35 +12,103 @@ import (
 	"github.com/ademuri/last-fm-tools/internal/store"
 )
 
+// TagFilter controls which of an artist or album's tags are used for weighting.
+type TagFilter struct {
+	// Tags with a lower count are dropped.
+	MinCount int
+	// Artists and albums with fewer tags left after filtering are ignored.
+	MinTags int
+}
+
+var DefaultTagFilter = TagFilter{MinCount: 25, MinTags: 2}
+
+// TagScoring is how a listen's weight is shared between the tags of its artist and album.
+type TagScoring string
+
+const (
+	// Each tag gets the whole listen. This is the default.
+	TagScoringBinary TagScoring = "binary"
+	// Tags get shares of the listen in proportion to their counts.
+	TagScoringCount TagScoring = "count"
+	// Tags get count-proportional shares of the listen, scaled by their inverse document frequency
+	// across the albums in the user's library, so that tags like "rock" which are on everything
+	// count for less.
+	TagScoringTFIDF TagScoring = "tfidf"
+)
+
+type TasteReportConfig struct {
+	// The current period, including both ends. If CurrentEnd is zero it's the latest listen, and if
+	// CurrentStart is zero it's CurrentMonths before CurrentEnd.
+	CurrentStart  time.Time
+	CurrentEnd    time.Time
+	CurrentMonths int
+	// The historical period, including both ends. If HistoricalStart is zero it's the first listen,
+	// and if HistoricalEnd is zero it's the start of the current period.
+	HistoricalStart time.Time
+	HistoricalEnd   time.Time
+	// Number of top artists, albums and tags to return for each period.
+	Artists int
+	Albums  int
+	Tags    int
+	// Number of top tags from each period which are compared for taste drift.
+	DriftTags  int
+	TagFilter  TagFilter
+	TagScoring TagScoring
+}
+
+// DefaultTasteReportConfig compares the last 18 months against everything before them.
+func DefaultTasteReportConfig() TasteReportConfig {
+	return TasteReportConfig{
+		CurrentMonths: 18,
+		Artists:       30,
+		Albums:        20,
+		Tags:          40,
+		DriftTags:     20,
+		TagFilter:     DefaultTagFilter,
+		TagScoring:    TagScoringBinary,
+	}
+}
+
 // GenerateReport creates a comprehensive music taste report.
-func GenerateReport(db *store.Store, user string) (*Report, error) {
+func GenerateReport(ctx context.Context, db store.Store, user string, config TasteReportConfig) (*Report, error) {
 	// 1. Determine Periods
-	latestListen, err := db.GetLatestListen(user)
-	if err != nil {
-		// If no listens, default to now
-		latestListen = time.Now()
+	currentEnd := config.CurrentEnd
+	if currentEnd.IsZero() {
+		latestListen, err := db.GetLatestListen(ctx, user)
+		if err != nil {
+			// If no listens, default to now
+			latestListen = time.Now()
+		}
+		currentEnd = latestListen
+	}
+
+	currentStart := config.CurrentStart
+	if currentStart.IsZero() {
+		currentStart = currentEnd.AddDate(0, -config.CurrentMonths, 0)
 	}
 
-	// Current Period: Last 18 months
-	currentEnd := latestListen
-	currentStart := currentEnd.AddDate(0, -18, 0)
-	
 	// Historical Period: Everything before current start
-	firstListen, err := db.GetFirstListen(user)
-	if err != nil {
-		firstListen = currentStart // Fallback
+	historicalStart := config.HistoricalStart
+	if historicalStart.IsZero() {
+		firstListen, err := db.GetFirstListen(ctx, user)
+		if err != nil {
+			firstListen = currentStart // Fallback
+		}
+		historicalStart = firstListen
+	}
+	historicalEnd := config.HistoricalEnd
+	if historicalEnd.IsZero() {
+		historicalEnd = currentStart
 	}
-	historicalStart := firstListen
-	historicalEnd := currentStart
 
 	report := &Report{}
 
 	// 2. Metadata
-	totalScrobbles, err := db.GetTotalScrobbles(user)
+	totalScrobbles, err := db.GetTotalScrobbles(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("getting total scrobbles: %w", err)
 	}
-	totalArtists, err := db.GetTotalArtists(user)
+	totalArtists, err := db.GetTotalArtists(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("getting total artists: %w", err)
 	}
@@ -53,7 +122,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 	}
 
 	// 3. Current Taste
-	currentArtistCounts, err := db.GetTopArtists(user, currentStart, currentEnd, 30)
+	currentArtistCounts, err := db.GetTopArtists(ctx, user, currentStart, currentEnd, config.Artists)
 	if err != nil {
 		return nil, fmt.Errorf("current artists: %w", err)
 	}
@@ -65,7 +134,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 			Scrobbles: a.Scrobbles,
 		}
 		
-		albumCounts, err := db.GetTopAlbumsForArtist(user, a.Name, currentStart, currentEnd, 3)
+		albumCounts, err := db.GetTopAlbumsForArtist(ctx, user, a.Name, currentStart, currentEnd, 3)
 		if err != nil {
 			return nil, err
 		}
@@ -75,7 +144,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 		}
 		stat.TopAlbums = albums
 
-		tags, err := db.GetTopTagsForArtist(a.Name, 3)
+		tags, err := db.GetTopTagsForArtist(ctx, a.Name, 3)
 		if err != nil {
 			return nil, err
 		}
@@ -84,7 +153,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 		currentArtists = append(currentArtists, stat)
 	}
 
-	currentAlbumCounts, err := db.GetTopAlbums(user, currentStart, currentEnd, 20)
+	currentAlbumCounts, err := db.GetTopAlbums(ctx, user, currentStart, currentEnd, config.Albums)
 	if err != nil {
 		return nil, fmt.Errorf("current albums: %w", err)
 	}
@@ -96,7 +165,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 			Artist:    a.Artist,
 			Scrobbles: a.Scrobbles,
 		}
-		tags, err := db.GetTopTagsForAlbum(a.Artist, a.Title, 3)
+		tags, err := db.GetTopTagsForAlbum(ctx, a.Artist, a.Title, 3)
 		if err != nil {
 			return nil, err
 		}
@@ -104,7 +173,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 		currentAlbums = append(currentAlbums, stat)
 	}
 
-	currentTags, err := getTopTagsWeighted(db, user, currentStart, currentEnd, 40)
+	currentTags, err := getTopTagsWeighted(ctx, db, user, currentStart, currentEnd, config.Tags, config.TagFilter, config.TagScoring)
 	if err != nil {
 		return nil, fmt.Errorf("current tags: %w", err)
 	}
@@ -116,7 +185,7 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 	}
 
 	// 4. Historical Baseline
-	historicalArtistCounts, err := db.GetTopArtists(user, historicalStart, historicalEnd, 30)
+	historicalArtistCounts, err := db.GetTopArtists(ctx, user, historicalStart, historicalEnd, config.Artists)
 	if err != nil {
 		return nil, fmt.Errorf("historical artists: %w", err)
 	}
@@ -128,19 +197,19 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 			Scrobbles: a.Scrobbles,
 		}
 		
-		years, err := db.GetPeakYears(user, a.Name)
+		years, err := db.GetPeakYears(ctx, user, a.Name)
 		if err != nil {
 			return nil, err
 		}
 		stat.PeakYears = years
 
-		count, err := db.GetArtistListenCount(user, a.Name, currentStart, currentEnd)
+		count, err := db.GetArtistListenCount(ctx, user, a.Name, currentStart, currentEnd)
 		if err != nil {
 			return nil, err
 		}
 		stat.InCurrentTaste = count > 0
 
-		tags, err := db.GetTopTagsForArtist(a.Name, 3)
+		tags, err := db.GetTopTagsForArtist(ctx, a.Name, 3)
 		if err != nil {
 			return nil, err
 		}
@@ -151,14 +220,14 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 
 	// Annotate Current Artists with "In Historical"
 	for i := range report.CurrentTaste.TopArtists {
-		count, err := db.GetArtistListenCount(user, report.CurrentTaste.TopArtists[i].Name, historicalStart, historicalEnd)
+		count, err := db.GetArtistListenCount(ctx, user, report.CurrentTaste.TopArtists[i].Name, historicalStart, historicalEnd)
 		if err != nil {
 			return nil, err
 		}
 		report.CurrentTaste.TopArtists[i].InHistoricalBaseline = count > 0
 	}
 
-	historicalTags, err := getTopTagsWeighted(db, user, historicalStart, historicalEnd, 40)
+	historicalTags, err := getTopTagsWeighted(ctx, db, user, historicalStart, historicalEnd, config.Tags, config.TagFilter, config.TagScoring)
 	if err != nil {
 		return nil, fmt.Errorf("historical tags: %w", err)
 	}
@@ -169,29 +238,29 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 	}
 
 	// 5. Taste Drift
-	declined, emerged := calculateDrift(historicalTags, currentTags)
+	declined, emerged := calculateDrift(historicalTags, currentTags, config.DriftTags)
 	report.TasteDrift = TasteDrift{
 		DeclinedTags: declined,
 		EmergedTags:  emerged,
 	}
 
 	// 6. Listening Patterns
-	lp, err := calculateListeningPatterns(db, user, currentStart, currentEnd)
+	lp, err := calculateListeningPatterns(ctx, db, user, currentStart, currentEnd)
 	if err != nil {
 		return nil, fmt.Errorf("listening patterns: %w", err)
 	}
 	report.ListeningPatterns = lp
 
-	avgTracksPerAlbum, err := db.GetAverageTracksPerAlbum(user, currentStart, currentEnd)
+	playThroughs, err := GetPlayThroughStats(ctx, db, user, currentStart, currentEnd.Add(time.Second), PlayThroughConfig{
+		Gap:         DefaultSessionGap,
+		MinCoverage: DefaultMinAlbumCoverage,
+		MinTracks:   DefaultMinAlbumTracks,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("getting avg tracks per album: %w", err)
-	}
-
-	if avgTracksPerAlbum >= 3.0 {
-		report.Metadata.ListeningStyle = "album-oriented"
-	} else {
-		report.Metadata.ListeningStyle = "track-oriented"
+		return nil, fmt.Errorf("album play-throughs: %w", err)
 	}
+	report.Metadata.AlbumPlayThroughs = len(playThroughs.PlayThroughs)
+	report.Metadata.FullAlbumListeningShare = math.Round(playThroughs.Share*100) / 100
 
 	return report, nil
 }
@@ -200,10 +269,18 @@ func GenerateReport(db *store.Store, user string) (*Report, error) {
 
 var yearRegex = regexp.MustCompile(`^\d{4}$`)
 
-func filterTags(tags []string, counts []int) []string {
+func filterTags(tags []string, counts []int, minCount int) []string {
+	validTags, _ := filterTagCounts(tags, counts, minCount)
+	return validTags
+}
+
+// filterTagCounts normalizes tags and drops ones which are rare, years or too short, returning the
+// remaining tags with their counts.
+func filterTagCounts(tags []string, counts []int, minCount int) ([]string, []int) {
 	validTags := []string{}
+	validCounts := []int{}
 	for i, t := range tags {
-		if counts[i] < 25 {
+		if counts[i] < minCount {
 			continue
 		}
 		
@@ -221,18 +298,77 @@ func filterTags(tags []string, counts []int) []string {
 		}
 
 		validTags = append(validTags, normalized)
+		validCounts = append(validCounts, counts[i])
 	}
-	return validTags
+	return validTags, validCounts
 }
 
-func getTopTagsWeighted(db *store.Store, user string, start, end time.Time, limit int) ([]TagStat, error) {
-	// 1. Fetch all Artist Tags
-	artistTagData, err := db.GetAllArtistTags()
+func getTopTagsWeighted(ctx context.Context, db store.Store, user string, start, end time.Time, limit int, filter TagFilter, scoring TagScoring) ([]TagStat, error) {
+	tags, err := loadTagIndex(ctx, db, user, filter, scoring)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listens in the period, by album
+	listenCounts, err := db.GetAlbumListenCounts(ctx, user, start, end)
 	if err != nil {
 		return nil, err
 	}
 
-	artistTagsMap := make(map[string][]string)
+	totalScrobblesPeriod, err := db.GetTotalScrobblesInPeriod(ctx, user, start, end)
+	if err != nil {
+		totalScrobblesPeriod = 1 // Fallback
+	}
+	if totalScrobblesPeriod == 0 {
+		totalScrobblesPeriod = 1
+	}
+
+	return tags.weight(listenCounts, totalScrobblesPeriod, limit), nil
+}
+
+// tagIndex holds the tags used for weighting: the filtered tags of each artist and album with
+// enough of them, and how to score them.
+type tagIndex struct {
+	artists map[string][]tagCount
+	albums  map[store.AlbumKey][]tagCount
+	scoring TagScoring
+	// The inverse document frequency of each tag across the user's library, for TF-IDF scoring.
+	idf map[string]float64
+}
+
+type tagCount struct {
+	tag   string
+	count int
+}
+
+func loadTagIndex(ctx context.Context, db store.Store, user string, filter TagFilter, scoring TagScoring) (tagIndex, error) {
+	switch scoring {
+	case "", TagScoringBinary, TagScoringCount, TagScoringTFIDF:
+	default:
+		return tagIndex{}, fmt.Errorf("invalid tag scoring %q, must be binary, count or tfidf", scoring)
+	}
+
+	// Personal tags are the user's own choice, so they skip the filter.
+	personalData, err := db.GetPersonalTags(ctx)
+	if err != nil {
+		return tagIndex{}, err
+	}
+	personal := make(map[store.AlbumKey]map[string]bool)
+	for _, p := range personalData {
+		key := store.AlbumKey{Artist: p.Artist, Name: p.Album}
+		personal[key] = make(map[string]bool)
+		for _, tag := range p.Tags {
+			personal[key][tag] = true
+		}
+	}
+
+	// 1. Fetch all Artist Tags
+	artistTagData, err := db.GetAllArtistTags(ctx)
+	if err != nil {
+		return tagIndex{}, err
+	}
+
+	artistTagsMap := make(map[string][]tagCount)
 	
 	// Group by artist
 	var currentArtist string
@@ -242,8 +378,8 @@ func getTopTagsWeighted(db *store.Store, user string, start, end time.Time, limi
 	for _, d := range artistTagData {
 		if d.Artist != currentArtist {
 			if currentArtist != "" {
-				valid := filterTags(currentTags, currentCounts)
-				if len(valid) >= 2 {
+				valid := filterTagIndex(currentTags, currentCounts, filter, personal[store.AlbumKey{Artist: currentArtist}])
+				if len(valid) > 0 {
 					artistTagsMap[currentArtist] = valid
 				}
 			}
@@ -255,33 +391,30 @@ func getTopTagsWeighted(db *store.Store, user string, start, end time.Time, limi
 		currentCounts = append(currentCounts, d.Count)
 	}
 	if currentArtist != "" {
-		valid := filterTags(currentTags, currentCounts)
-		if len(valid) >= 2 {
+		valid := filterTagIndex(currentTags, currentCounts, filter, personal[store.AlbumKey{Artist: currentArtist}])
+		if len(valid) > 0 {
 			artistTagsMap[currentArtist] = valid
 		}
 	}
 
 	// 2. Fetch all Album Tags
-	albumTagData, err := db.GetAllAlbumTags()
+	albumTagData, err := db.GetAllAlbumTags(ctx)
 	if err != nil {
-		return nil, err
+		return tagIndex{}, err
 	}
 	
-	type albumKey struct {
-		artist, album string
-	}
-	albumTagsMap := make(map[albumKey][]string)
+	albumTagsMap := make(map[store.AlbumKey][]tagCount)
 	
-	var currentAlbumKey albumKey
+	var currentAlbumKey store.AlbumKey
 	currentTags = []string{}
 	currentCounts = []int{}
 	
 	for _, d := range albumTagData {
-		key := albumKey{d.Artist, d.Album}
+		key := store.AlbumKey{Artist: d.Artist, Name: d.Album}
 		if key != currentAlbumKey {
-			if currentAlbumKey.artist != "" {
-				valid := filterTags(currentTags, currentCounts)
-				if len(valid) >= 2 {
+			if currentAlbumKey.Artist != "" {
+				valid := filterTagIndex(currentTags, currentCounts, filter, personal[currentAlbumKey])
+				if len(valid) > 0 {
 					albumTagsMap[currentAlbumKey] = valid
 				}
 			}
@@ -292,78 +425,138 @@ func getTopTagsWeighted(db *store.Store, user string, start, end time.Time, limi
 		currentTags = append(currentTags, d.Tag)
 		currentCounts = append(currentCounts, d.Count)
 	}
-	if currentAlbumKey.artist != "" {
-		valid := filterTags(currentTags, currentCounts)
-		if len(valid) >= 2 {
+	if currentAlbumKey.Artist != "" {
+		valid := filterTagIndex(currentTags, currentCounts, filter, personal[currentAlbumKey])
+		if len(valid) > 0 {
 			albumTagsMap[currentAlbumKey] = valid
 		}
 	}
 
-	// 3. Iterate Listens and Accumulate Weights
-	listenCounts, err := db.GetAlbumListenCounts(user, start, end)
-	if err != nil {
-		return nil, err
+	index := tagIndex{artists: artistTagsMap, albums: albumTagsMap, scoring: scoring}
+	if scoring == TagScoringTFIDF {
+		// Each album in the user's library is a document.
+		library, err := db.GetAlbumListenCounts(ctx, user, time.Unix(0, 0), time.Now())
+		if err != nil {
+			return tagIndex{}, err
+		}
+		docs := 0
+		docFreq := make(map[string]int)
+		for _, l := range library {
+			tags := index.tags(l.Artist, l.Title)
+			if len(tags) == 0 {
+				continue
+			}
+			docs++
+			for tag := range tags {
+				docFreq[tag]++
+			}
+		}
+		// Smoothed, so that tags of every album still count for something.
+		index.idf = make(map[string]float64)
+		for tag, df := range docFreq {
+			index.idf[tag] = math.Log(float64(1+docs)/float64(1+df)) + 1
+		}
+	}
+	return index, nil
+}
+
+// filterTagIndex filters tags, returning none if fewer than filter.MinTags are left. Personal tags
+// are always kept, and an artist or album with any is never dropped for having too few tags.
+func filterTagIndex(tags []string, counts []int, filter TagFilter, personal map[string]bool) []tagCount {
+	var result []tagCount
+	var fetched []string
+	var fetchedCounts []int
+	for i, tag := range tags {
+		if personal[tag] {
+			result = append(result, tagCount{tag: tag, count: counts[i]})
+			continue
+		}
+		fetched = append(fetched, tag)
+		fetchedCounts = append(fetchedCounts, counts[i])
+	}
+	valid, validCounts := filterTagCounts(fetched, fetchedCounts, filter.MinCount)
+	if len(result) == 0 && len(valid) < filter.MinTags {
+		return nil
 	}
+	for i, tag := range valid {
+		result = append(result, tagCount{tag: tag, count: validCounts[i]})
+	}
+	return result
+}
+
+// tags returns the tags of an album and its artist, with the higher count for tags which are on
+// both.
+func (t tagIndex) tags(artist, album string) map[string]int {
+	tags := make(map[string]int)
+	for _, tc := range t.artists[artist] {
+		tags[tc.tag] = max(tags[tc.tag], tc.count)
+	}
+	for _, tc := range t.albums[store.AlbumKey{Artist: artist, Name: album}] {
+		tags[tc.tag] = max(tags[tc.tag], tc.count)
+	}
+	return tags
+}
 
-	globalTagCounts := make(map[string]int64)
-	var totalWeight int64
+// weight returns the top tags for the album listen counts, with weights relative to total listens.
+// With binary scoring each listen counts once towards each tag of its artist or album, so weights
+// are the fraction of listens with the tag. Count scoring splits each listen between its tags in
+// proportion to their counts, and TF-IDF scoring scales those shares by how rare the tags are in
+// the user's library.
+func (t tagIndex) weight(listenCounts []store.AlbumScrobbleCount, total int64, limit int) []TagStat {
+	globalTagCounts := make(map[string]float64)
 
 	for _, l := range listenCounts {
-		count := l.Scrobbles
-		
-		uniqueTags := make(map[string]bool)
-		
-		// Add artist tags
-		if tags, ok := artistTagsMap[l.Artist]; ok {
-			for _, t := range tags {
-				uniqueTags[t] = true
-			}
+		count := float64(l.Scrobbles)
+		tags := t.tags(l.Artist, l.Title)
+
+		var tagTotal int
+		for _, c := range tags {
+			tagTotal += c
 		}
-		
-		// Add album tags
-		if tags, ok := albumTagsMap[albumKey{l.Artist, l.Title}]; ok {
-			for _, t := range tags {
-				uniqueTags[t] = true
+
+		for tag, c := range tags {
+			switch t.scoring {
+			case TagScoringCount, TagScoringTFIDF:
+				share := 1 / float64(len(tags))
+				if tagTotal > 0 {
+					share = float64(c) / float64(tagTotal)
+				}
+				if t.scoring == TagScoringTFIDF {
+					share *= t.idf[tag]
+				}
+				globalTagCounts[tag] += count * share
+			default:
+				globalTagCounts[tag] += count
 			}
 		}
-		
-		for t := range uniqueTags {
-			globalTagCounts[t] += count
-			totalWeight += count
-		}
 	}
 
-	// 4. Convert to TagStat and Sort
+	// Convert to TagStat and Sort
 	var stats []TagStat
 	for tag, weight := range globalTagCounts {
-		stats = append(stats, TagStat{Tag: tag, Weight: float64(weight)})
+		stats = append(stats, TagStat{Tag: tag, Weight: weight})
 	}
 	
 	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].Weight > stats[j].Weight
+		if stats[i].Weight != stats[j].Weight {
+			return stats[i].Weight > stats[j].Weight
+		}
+		return stats[i].Tag < stats[j].Tag
 	})
 	
 	if len(stats) > limit {
 		stats = stats[:limit]
 	}
 
-	totalScrobblesPeriod, err := db.GetTotalScrobblesInPeriod(user, start, end)
-	if err != nil {
-		totalScrobblesPeriod = 1 // Fallback
-	}
-	if totalScrobblesPeriod == 0 {
-		totalScrobblesPeriod = 1
-	}
-
 	for i := range stats {
-		stats[i].Weight = stats[i].Weight / float64(totalScrobblesPeriod)
+		stats[i].Weight = stats[i].Weight / float64(total)
 		stats[i].Weight = math.Round(stats[i].Weight*100) / 100
 	}
 
-	return stats, nil
+	return stats
 }
 
-func calculateDrift(histTags, currTags []TagStat) ([]DriftTag, []DriftTag) {
+func calculateDrift(histTags, currTags []TagStat, top int) ([]DriftTag, []DriftTag) {
 	histMap := make(map[string]float64)
 	for _, t := range histTags {
 		histMap[t.Tag] = t.Weight
@@ -373,18 +566,18 @@ func calculateDrift(histTags, currTags []TagStat) ([]DriftTag, []DriftTag) {
 		currMap[t.Tag] = t.Weight
 	}
 
-	histTop20 := histTags
-	if len(histTop20) > 20 {
-		histTop20 = histTop20[:20]
+	histTop := histTags
+	if len(histTop) > top {
+		histTop = histTop[:top]
 	}
 
-	currTop20 := currTags
-	if len(currTop20) > 20 {
-		currTop20 = currTop20[:20]
+	currTop := currTags
+	if len(currTop) > top {
+		currTop = currTop[:top]
 	}
 	
 	var declined []DriftTag
-	for _, h := range histTop20 {
+	for _, h := range histTop {
 		if _, exists := currMap[h.Tag]; !exists {
 			declined = append(declined, DriftTag{
 				Tag:              h.Tag,
@@ -395,7 +588,7 @@ func calculateDrift(histTags, currTags []TagStat) ([]DriftTag, []DriftTag) {
 	}
 
 	var emerged []DriftTag
-	for _, c := range currTop20 {
+	for _, c := range currTop {
 		if _, exists := histMap[c.Tag]; !exists {
 			emerged = append(emerged, DriftTag{
 				Tag:              c.Tag,
@@ -408,10 +601,10 @@ func calculateDrift(histTags, currTags []TagStat) ([]DriftTag, []DriftTag) {
 	return declined, emerged
 }
 
-func calculateListeningPatterns(db *store.Store, user string, start, end time.Time) (ListeningPatterns, error) {
+func calculateListeningPatterns(ctx context.Context, db store.Store, user string, start, end time.Time) (ListeningPatterns, error) {
 	lp := ListeningPatterns{}
 	
-	stats, err := db.GetArtistAlbumStats(user, start, end)
+	stats, err := db.GetArtistAlbumStats(ctx, user, start, end)
 	if err != nil {
 		return lp, err
 	}
@@ -457,14 +650,14 @@ func calculateListeningPatterns(db *store.Store, user string, start, end time.Ti
 	lp.AllAlbumsPerArtistMedian, lp.AllAlbumsPerArtistAverage = calcStats(allCounts)
 	lp.Top100ArtistsAlbumsMedian, lp.Top100ArtistsAlbumsAverage = calcStats(top100Counts)
 
-	count, err := db.GetNewArtistsCount(user, time.Now().AddDate(-1, 0, 0))
+	count, err := db.GetNewArtistsCount(ctx, user, time.Now().AddDate(-1, 0, 0))
 	if err != nil {
 		return lp, err
 	}
 	lp.NewArtistsInLast12Month = count
 
-	totalS, _ := db.GetTotalScrobbles(user)
-	totalA, _ := db.GetTotalArtists(user)
+	totalS, _ := db.GetTotalScrobbles(ctx, user)
+	totalA, _ := db.GetTotalArtists(ctx, user)
 	if totalS > 0 {
 		ratio := float64(totalS - int64(totalA)) / float64(totalS)
 		lp.RepeatListeningRatio = math.Round(ratio*100) / 100
@@ -1,8 +1,10 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -10,7 +12,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func setupTestDB(t *testing.T) *store.Store {
+func setupTestDB(t *testing.T) *store.SQLiteStore {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
 	s, err := store.New(dbPath)
 	if err != nil {
@@ -24,7 +26,7 @@ func TestGenerateReport(t *testing.T) {
 	defer db.Close()
 
 	user := "testuser"
-	db.CreateUser(user)
+	db.CreateUser(context.Background(), user)
 
 	now := time.Now()
 	longAgo := now.AddDate(-2, 0, 0)
@@ -39,7 +41,7 @@ func TestGenerateReport(t *testing.T) {
 				DateUTS:   fmt.Sprintf("%d", ts.Unix()),
 			},
 		}
-		if err := db.AddRecentTracks(user, tracks); err != nil {
+		if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
 			t.Fatalf("failed to add listen: %v", err)
 		}
 	}
@@ -57,20 +59,15 @@ func TestGenerateReport(t *testing.T) {
 	// Album C1 (Artist C)
 	addListen("Artist C", "Album C1", "Track 3", now.Add(-600*time.Second))
 
-	// Add more tracks to A1 to trigger "album-oriented" (Avg tracks >= 3)
-	// Currently A1: 4 tracks. B1: 1. C1: 1. Avg = (4+1+1)/3 = 2.0.
-	// Need more.
+	// More tracks for A1, which aren't played in the same run as the others.
 	addListen("Artist A", "Album A1", "Track 7", now.Add(-700*time.Second))
 	addListen("Artist A", "Album A1", "Track 8", now.Add(-800*time.Second))
-	// A1 now 6 tracks. Avg = (6+1+1)/3 = 2.66.
 
-	// Add Album A2 (Artist A) with 4 tracks
+	// Album A2 (Artist A) is played through.
 	addListen("Artist A", "Album A2", "T1", now.Add(-1000*time.Second))
 	addListen("Artist A", "Album A2", "T2", now.Add(-1100*time.Second))
 	addListen("Artist A", "Album A2", "T3", now.Add(-1200*time.Second))
 	addListen("Artist A", "Album A2", "T4", now.Add(-1300*time.Second))
-	
-	// Albums: A1 (6), B1 (1), C1 (1), A2 (4). Avg = (6+1+1+4)/4 = 3.0.
 
 	// Historical listens
 	for i := 0; i < 5; i++ {
@@ -78,12 +75,12 @@ func TestGenerateReport(t *testing.T) {
 	}
 
 	// Tags
-	db.SaveArtistTags("Artist A", []string{"Rock", "Indie"}, []int{100, 80})
-	db.SaveArtistTags("Artist B", []string{"Rock", "Alternative"}, []int{50, 30})
+	db.SaveArtistTags(context.Background(), "Artist A", []string{"Rock", "Indie"}, []int{100, 80})
+	db.SaveArtistTags(context.Background(), "Artist B", []string{"Rock", "Alternative"}, []int{50, 30})
 	
-	db.SaveAlbumTags("Artist A", "Album A1", []string{"Pop", "Cool"}, []int{60, 40})
+	db.SaveAlbumTags(context.Background(), "Artist A", "Album A1", []string{"Pop", "Cool"}, []int{60, 40})
 
-	report, err := GenerateReport(db, user)
+	report, err := GenerateReport(context.Background(), db, user, DefaultTasteReportConfig())
 	if err != nil {
 		t.Fatalf("GenerateReport failed: %v", err)
 	}
@@ -93,8 +90,12 @@ func TestGenerateReport(t *testing.T) {
 		t.Errorf("expected 17 scrobbles, got %d", report.Metadata.TotalScrobbles)
 	}
 
-	if report.Metadata.ListeningStyle != "album-oriented" {
-		t.Errorf("expected album-oriented style, got %s", report.Metadata.ListeningStyle)
+	// A2 is played through. A1's last run only covers 4 of its 6 tracks.
+	if report.Metadata.AlbumPlayThroughs != 1 {
+		t.Errorf("expected 1 album play-through, got %d", report.Metadata.AlbumPlayThroughs)
+	}
+	if report.Metadata.FullAlbumListeningShare != 0.33 {
+		t.Errorf("expected full album listening share 0.33, got %v", report.Metadata.FullAlbumListeningShare)
 	}
 
 	if len(report.CurrentTaste.TopArtists) == 0 {
@@ -130,7 +131,7 @@ func TestFilterTags(t *testing.T) {
 	tags := []string{"Valid1", "Valid2", "1999", "Tiny", "LowWeight", "With-Hyphen", "With_Underscore"}
 	counts := []int{100, 50, 100, 100, 10, 100, 100}
 	
-	filtered := filterTags(tags, counts)
+	filtered := filterTags(tags, counts, DefaultTagFilter.MinCount)
 	
 	expected := []string{"valid1", "valid2", "tiny", "with hyphen", "with underscore"}
 	
@@ -155,7 +156,7 @@ func TestCalculateDrift(t *testing.T) {
 		{Tag: "techno", Weight: 0.6}, 
 	}
 	
-	declined, emerged := calculateDrift(hist, curr)
+	declined, emerged := calculateDrift(hist, curr, 20)
 	
 	if len(declined) != 1 || declined[0].Tag != "jazz" {
 		t.Errorf("expected 'jazz' to decline")
@@ -164,4 +165,189 @@ func TestCalculateDrift(t *testing.T) {
 	if len(emerged) != 1 || emerged[0].Tag != "techno" {
 		t.Errorf("expected 'techno' to emerge")
 	}
+
+	// Only the top tag of each period is compared.
+	declined, emerged = calculateDrift(hist, curr, 1)
+	if len(declined) != 0 || len(emerged) != 0 {
+		t.Errorf("expected no drift in the top tag, got declined %v and emerged %v", declined, emerged)
+	}
+}
+
+func TestGenerateReportMemoryStore(t *testing.T) {
+	db := store.NewMemory()
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	now := time.Now()
+	var tracks []store.TrackImport
+	for i := 0; i < 3; i++ {
+		tracks = append(tracks, store.TrackImport{Artist: "Artist A", Album: "Album A1", TrackName: fmt.Sprintf("Track %d", i), DateUTS: fmt.Sprintf("%d", now.Add(time.Duration(-i)*time.Hour).Unix())})
+	}
+	tracks = append(tracks, store.TrackImport{Artist: "Artist B", Album: "Album B1", TrackName: "Track", DateUTS: fmt.Sprintf("%d", now.AddDate(-2, 0, 0).Unix())})
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	report, err := GenerateReport(context.Background(), db, user, DefaultTasteReportConfig())
+	if err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+	if report.Metadata.TotalScrobbles != 4 {
+		t.Errorf("expected 4 scrobbles, got %d", report.Metadata.TotalScrobbles)
+	}
+	if len(report.CurrentTaste.TopArtists) != 1 || report.CurrentTaste.TopArtists[0].Name != "Artist A" {
+		t.Errorf("expected only Artist A in current taste, got %v", report.CurrentTaste.TopArtists)
+	}
+}
+
+func TestGenerateReportConfig(t *testing.T) {
+	db := store.NewMemory()
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+	db.SaveArtistTags(context.Background(), "Artist A", []string{"rock", "indie", "pop"}, []int{100, 30, 20})
+
+	var tracks []store.TrackImport
+	for i, artist := range []string{"Artist A", "Artist A", "Artist B", "Artist C", "Artist C"} {
+		ts := time.Date(2020, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: artist, Album: artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	config := DefaultTasteReportConfig()
+	config.CurrentStart = time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)
+	config.CurrentEnd = time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC)
+	config.HistoricalStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	config.HistoricalEnd = time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	config.Artists = 1
+	config.TagFilter = TagFilter{MinCount: 30, MinTags: 2}
+	report, err := GenerateReport(context.Background(), db, user, config)
+	if err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+
+	if got := report.Metadata.CurrentPeriod; got != "2020-01-03 to 2020-01-04" {
+		t.Errorf("CurrentPeriod = %q, want 2020-01-03 to 2020-01-04", got)
+	}
+	if got := report.Metadata.HistoricalPeriod; got != "2020-01-01 to 2020-01-02" {
+		t.Errorf("HistoricalPeriod = %q, want 2020-01-01 to 2020-01-02", got)
+	}
+	// Both periods include their ends, so the current period has one listen each of B and C.
+	if len(report.CurrentTaste.TopArtists) != 1 || report.CurrentTaste.TopArtists[0].Name != "Artist B" {
+		t.Errorf("expected Artist B to top the current period, got %v", report.CurrentTaste.TopArtists)
+	}
+	if len(report.HistoricalBaseline.TopArtists) != 1 || report.HistoricalBaseline.TopArtists[0].Name != "Artist A" {
+		t.Errorf("expected Artist A to top the historical period, got %v", report.HistoricalBaseline.TopArtists)
+	}
+	// Pop is below the minimum count.
+	want := []TagStat{{Tag: "indie", Weight: 1}, {Tag: "rock", Weight: 1}}
+	if !reflect.DeepEqual(report.HistoricalBaseline.TopTags, want) {
+		t.Errorf("historical tags = %v, want %v", report.HistoricalBaseline.TopTags, want)
+	}
+}
+
+func TestTagScoring(t *testing.T) {
+	db := store.NewMemory()
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+
+	// Every artist is tagged rock, but it's never their main tag.
+	artists := []struct {
+		name    string
+		tag     string
+		rock    int
+		listens int
+	}{
+		{"Slowdive", "shoegaze", 30, 3},
+		{"Ride", "shoegaze", 30, 2},
+		{"Oasis", "britpop", 40, 2},
+		{"Blur", "britpop", 30, 1},
+		{"Pavement", "indie", 50, 2},
+	}
+	var tracks []store.TrackImport
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, a := range artists {
+		db.SaveArtistTags(ctx, a.name, []string{a.tag, "rock"}, []int{100, a.rock})
+		for i := 0; i < a.listens; i++ {
+			ts := start.Add(time.Duration(len(tracks)) * time.Hour)
+			tracks = append(tracks, store.TrackImport{Artist: a.name, Album: a.name, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	for _, tc := range []struct {
+		scoring TagScoring
+		want    []TagStat
+	}{
+		// Rock is on every listen.
+		{TagScoringBinary, []TagStat{{"rock", 1}, {"shoegaze", 0.5}, {"britpop", 0.3}, {"indie", 0.2}}},
+		// Rock only gets a small share of each listen.
+		{TagScoringCount, []TagStat{{"shoegaze", 0.38}, {"rock", 0.26}, {"britpop", 0.22}, {"indie", 0.13}}},
+		// Rock is on every album in the library, and indie is only on one.
+		{TagScoringTFIDF, []TagStat{{"shoegaze", 0.65}, {"britpop", 0.37}, {"indie", 0.28}, {"rock", 0.26}}},
+	} {
+		got, err := getTopTagsWeighted(ctx, db, user, start, start.AddDate(0, 0, 1), 10, DefaultTagFilter, tc.scoring)
+		if err != nil {
+			t.Fatalf("getTopTagsWeighted(%s): %v", tc.scoring, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("getTopTagsWeighted(%s) = %v, want %v", tc.scoring, got, tc.want)
+		}
+	}
+
+	if _, err := getTopTagsWeighted(ctx, db, user, start, start.AddDate(0, 0, 1), 10, DefaultTagFilter, "bm25"); err == nil {
+		t.Errorf("getTopTagsWeighted() with an unknown scoring succeeded, want an error")
+	}
+}
+
+func TestPersonalTagsSkipFilter(t *testing.T) {
+	db := store.NewMemory()
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tracks []store.TrackImport
+	for i, artist := range []string{"Can", "Faust"} {
+		ts := start.Add(time.Duration(i) * time.Hour)
+		tracks = append(tracks, store.TrackImport{Artist: artist, Album: artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+	// Faust's fetched tags are too few and too rare to pass the filter.
+	db.SaveArtistTags(ctx, "Can", []string{"krautrock", "experimental"}, []int{100, 80})
+	db.SaveArtistTags(ctx, "Faust", []string{"noise"}, []int{10})
+
+	got, err := getTopTagsWeighted(ctx, db, user, start, start.AddDate(0, 0, 1), 10, DefaultTagFilter, TagScoringBinary)
+	if err != nil {
+		t.Fatalf("getTopTagsWeighted: %v", err)
+	}
+	want := []TagStat{{"experimental", 0.5}, {"krautrock", 0.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getTopTagsWeighted = %v, want %v", got, want)
+	}
+
+	// A single personal tag is enough to keep Faust, and replaces Can's fetched tags.
+	if err := db.AddPersonalTags(ctx, "Faust", "", []string{"kraut"}); err != nil {
+		t.Fatalf("AddPersonalTags: %v", err)
+	}
+	if err := db.AddPersonalTags(ctx, "Can", "", []string{"kraut"}); err != nil {
+		t.Fatalf("AddPersonalTags: %v", err)
+	}
+	if err := db.SetPersonalTagsReplace(ctx, "Can", "", true); err != nil {
+		t.Fatalf("SetPersonalTagsReplace: %v", err)
+	}
+	got, err = getTopTagsWeighted(ctx, db, user, start, start.AddDate(0, 0, 1), 10, DefaultTagFilter, TagScoringBinary)
+	if err != nil {
+		t.Fatalf("getTopTagsWeighted: %v", err)
+	}
+	want = []TagStat{{"kraut", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getTopTagsWeighted with personal tags = %v, want %v", got, want)
+	}
 }
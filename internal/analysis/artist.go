@@ -0,0 +1,203 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// DefaultDormancyGap is how long an artist must go unplayed for the gap to count as dormant.
+const DefaultDormancyGap = 180 * 24 * time.Hour
+
+type ArtistHistoryConfig struct {
+	// Days, months and years start at midnight in Location.
+	Location *time.Location
+	// Number of top albums and tracks to return.
+	Top int
+	// Number of tags to return.
+	Tags int
+	// The shortest gap between listens which counts as dormant.
+	MinGap time.Duration
+}
+
+// ArtistHistory is everything about the user's listening to one artist. The zero ArtistHistory, with
+// no plays, means the artist was never listened to.
+type ArtistHistory struct {
+	// The artist's name as it was scrobbled, which may differ in case from the name asked for.
+	Artist      string
+	FirstListen time.Time
+	LastListen  time.Time
+	Plays       int64
+	// The shortest run of years holding most of the plays, like "2008-2011".
+	PeakYears string
+	// Plays in every month from the first listen to the last.
+	Months    []MonthPlays
+	TopAlbums []NamePlays
+	TopTracks []NamePlays
+	Tags      []string
+	// How the artist ranked among all artists in each year they were listened to, oldest first.
+	Years         []ArtistYear
+	LongestStreak Streak
+	// Gaps between listens of at least MinGap, oldest first.
+	Gaps []DormancyGap
+}
+
+type MonthPlays struct {
+	// Midnight at the start of the month.
+	Month time.Time
+	Plays int64
+}
+
+type NamePlays struct {
+	Name  string
+	Plays int64
+}
+
+type ArtistYear struct {
+	Year  int
+	Plays int64
+	// 1 for the most played artist. Artists with the same plays share a rank.
+	Rank int
+	// The number of artists listened to in the year.
+	Artists int
+}
+
+// DormancyGap is a gap between two listens to an artist.
+type DormancyGap struct {
+	// The last listen before the gap and the first after it.
+	From time.Time
+	To   time.Time
+}
+
+// Days is the number of whole days in the gap.
+func (g DormancyGap) Days() int {
+	return int(g.To.Sub(g.From) / (24 * time.Hour))
+}
+
+func startOfMonth(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// monthlyPlays returns the plays in every month from first to last, given plays by start of month.
+func monthlyPlays(plays map[time.Time]int64, first, last time.Time, loc *time.Location) []MonthPlays {
+	var months []MonthPlays
+	for m := startOfMonth(first, loc); !m.After(last); m = m.AddDate(0, 1, 0) {
+		months = append(months, MonthPlays{Month: m, Plays: plays[m]})
+	}
+	return months
+}
+
+// resolveArtist returns the artist named in the listens, matching name exactly if possible, or
+// otherwise ignoring case, picking the most played spelling.
+func resolveArtist(listens []store.TrackListen, name string) string {
+	plays := make(map[string]int)
+	for _, l := range listens {
+		if l.Artist == name {
+			return name
+		}
+		if strings.EqualFold(l.Artist, name) {
+			plays[l.Artist]++
+		}
+	}
+	best := ""
+	for artist, n := range plays {
+		if best == "" || n > plays[best] || (n == plays[best] && artist < best) {
+			best = artist
+		}
+	}
+	return best
+}
+
+func topNamePlays(counts map[string]int64, limit int) []NamePlays {
+	var top []NamePlays
+	for name, n := range counts {
+		top = append(top, NamePlays{Name: name, Plays: n})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Plays != top[j].Plays {
+			return top[i].Plays > top[j].Plays
+		}
+		return top[i].Name < top[j].Name
+	})
+	return top[:min(len(top), limit)]
+}
+
+// GetArtistHistory gathers the user's history with the artist from their listens before end.
+func GetArtistHistory(ctx context.Context, db store.Store, user, artist string, end time.Time, config ArtistHistoryConfig) (history ArtistHistory, err error) {
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	listens, err := db.GetListenHistory(ctx, user, time.Unix(0, 0), end)
+	if err != nil {
+		return history, err
+	}
+	artist = resolveArtist(listens, artist)
+	if artist == "" {
+		return history, nil
+	}
+	history.Artist = artist
+
+	// Plays of every artist by year, for ranking.
+	yearPlays := make(map[int]map[string]int64)
+	albums := make(map[string]int64)
+	tracks := make(map[string]int64)
+	months := make(map[time.Time]int64)
+	var times []time.Time
+	for _, l := range listens {
+		t := l.Time.In(loc)
+		if yearPlays[t.Year()] == nil {
+			yearPlays[t.Year()] = make(map[string]int64)
+		}
+		yearPlays[t.Year()][l.Artist]++
+		if l.Artist != artist {
+			continue
+		}
+
+		if config.MinGap > 0 && len(times) > 0 && t.Sub(times[len(times)-1]) >= config.MinGap {
+			history.Gaps = append(history.Gaps, DormancyGap{From: times[len(times)-1], To: t})
+		}
+		times = append(times, t)
+		if l.Album != "" {
+			albums[l.Album]++
+		}
+		tracks[l.Track]++
+		months[startOfMonth(t, loc)]++
+	}
+	history.Plays = int64(len(times))
+	history.FirstListen = times[0]
+	history.LastListen = times[len(times)-1]
+	history.TopAlbums = topNamePlays(albums, config.Top)
+	history.TopTracks = topNamePlays(tracks, config.Top)
+
+	history.Months = monthlyPlays(months, history.FirstListen, history.LastListen, loc)
+
+	for year, plays := range yearPlays {
+		n := plays[artist]
+		if n == 0 {
+			continue
+		}
+		rank := 1
+		for _, other := range plays {
+			if other > n {
+				rank++
+			}
+		}
+		history.Years = append(history.Years, ArtistYear{Year: year, Plays: n, Rank: rank, Artists: len(plays)})
+	}
+	sort.Slice(history.Years, func(i, j int) bool { return history.Years[i].Year < history.Years[j].Year })
+
+	history.LongestStreak = LongestStreak(DailyCounts(times, history.FirstListen, history.LastListen.Add(time.Nanosecond), loc))
+
+	if history.PeakYears, err = db.GetPeakYears(ctx, user, artist); err != nil {
+		return history, err
+	}
+	if history.Tags, err = db.GetTopTagsForArtist(ctx, artist, config.Tags); err != nil {
+		return history, err
+	}
+	return history, nil
+}
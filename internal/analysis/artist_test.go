@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGetArtistHistory(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+	db.SaveArtistTags(ctx, "Radiohead", []string{"alternative", "rock", "electronic"}, []int{100, 80, 40})
+
+	listens := []struct {
+		date   string
+		artist string
+		album  string
+		track  string
+	}{
+		{"2019-01-05T10:00:00Z", "Radiohead", "OK Computer", "Airbag"},
+		{"2019-01-06T10:00:00Z", "Radiohead", "OK Computer", "Airbag"},
+		{"2019-01-07T10:00:00Z", "Radiohead", "OK Computer", "Airbag"},
+		{"2019-03-10T10:00:00Z", "Radiohead", "Kid A", "Idioteque"},
+		// Dormant for most of a year.
+		{"2020-02-01T10:00:00Z", "Radiohead", "OK Computer", "Lucky"},
+		{"2020-02-02T10:00:00Z", "Radiohead", "Kid A", "Idioteque"},
+	}
+	for i := 0; i < 5; i++ {
+		listens = append(listens, struct {
+			date   string
+			artist string
+			album  string
+			track  string
+		}{fmt.Sprintf("2019-06-%02dT10:00:00Z", i+1), "Muse", "Absolution", "Hysteria"})
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts, err := time.Parse(time.RFC3339, l.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.album, TrackName: l.track, DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	config := ArtistHistoryConfig{Location: time.UTC, Top: 2, Tags: 2, MinGap: DefaultDormancyGap}
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The name is matched ignoring case.
+	history, err := GetArtistHistory(ctx, db, user, "radiohead", end, config)
+	if err != nil {
+		t.Fatalf("GetArtistHistory: %v", err)
+	}
+
+	if history.Artist != "Radiohead" || history.Plays != 6 {
+		t.Errorf("artist = %q with %d plays, want Radiohead with 6", history.Artist, history.Plays)
+	}
+	if got := history.FirstListen.Format("2006-01-02"); got != "2019-01-05" {
+		t.Errorf("FirstListen = %s, want 2019-01-05", got)
+	}
+	if got := history.LastListen.Format("2006-01-02"); got != "2020-02-02" {
+		t.Errorf("LastListen = %s, want 2020-02-02", got)
+	}
+	if history.PeakYears != "2019" {
+		t.Errorf("PeakYears = %q, want 2019", history.PeakYears)
+	}
+	if want := []string{"alternative", "rock"}; !reflect.DeepEqual(history.Tags, want) {
+		t.Errorf("Tags = %v, want %v", history.Tags, want)
+	}
+	if want := []NamePlays{{"OK Computer", 4}, {"Kid A", 2}}; !reflect.DeepEqual(history.TopAlbums, want) {
+		t.Errorf("TopAlbums = %v, want %v", history.TopAlbums, want)
+	}
+	if want := []NamePlays{{"Airbag", 3}, {"Idioteque", 2}}; !reflect.DeepEqual(history.TopTracks, want) {
+		t.Errorf("TopTracks = %v, want %v", history.TopTracks, want)
+	}
+
+	// Every month from January 2019 to February 2020.
+	if len(history.Months) != 14 {
+		t.Fatalf("len(Months) = %d, want 14", len(history.Months))
+	}
+	for i, want := range map[int]int64{0: 3, 1: 0, 2: 1, 13: 2} {
+		if history.Months[i].Plays != want {
+			t.Errorf("Months[%d] = %+v, want %d plays", i, history.Months[i], want)
+		}
+	}
+
+	// Muse was more played in 2019.
+	wantYears := []ArtistYear{{Year: 2019, Plays: 4, Rank: 2, Artists: 2}, {Year: 2020, Plays: 2, Rank: 1, Artists: 1}}
+	if !reflect.DeepEqual(history.Years, wantYears) {
+		t.Errorf("Years = %+v, want %+v", history.Years, wantYears)
+	}
+	if history.LongestStreak.Days != 3 || history.LongestStreak.Start.Format("2006-01-02") != "2019-01-05" {
+		t.Errorf("LongestStreak = %+v, want 3 days from 2019-01-05", history.LongestStreak)
+	}
+	if len(history.Gaps) != 1 || history.Gaps[0].From.Format("2006-01-02") != "2019-03-10" || history.Gaps[0].Days() != 328 {
+		t.Errorf("Gaps = %+v, want one of 328 days from 2019-03-10", history.Gaps)
+	}
+
+	history, err = GetArtistHistory(ctx, db, user, "Blur", end, config)
+	if err != nil {
+		t.Fatalf("GetArtistHistory: %v", err)
+	}
+	if history.Plays != 0 {
+		t.Errorf("GetArtistHistory for an unknown artist = %+v, want no plays", history)
+	}
+}
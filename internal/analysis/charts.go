@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+const week = 7 * 24 * time.Hour
+
+// ChartRecord summarises an artist, album or track's history in the weekly charts. Name is the album
+// or track name, and empty for artists.
+type ChartRecord struct {
+	Artist string
+	Name   string
+	// Highest position reached, and the number of weeks spent there.
+	Peak        int
+	WeeksAtPeak int
+	WeeksAtTop  int
+	// Number of weeks on the chart, in total and in the longest run of consecutive weeks.
+	WeeksOnChart int
+	LongestRun   int
+	FirstWeek    time.Time
+	LastWeek     time.Time
+	// Total listens in the weeks it was on the chart.
+	Listens int64
+}
+
+// ChartPoint is an entry's position in one week's chart. Rank is 0 for weeks it was off the chart.
+type ChartPoint struct {
+	Week  time.Time
+	Rank  int
+	Count int64
+}
+
+// ChartRecords summarises the chart history of every entry in charts, as returned by
+// store.GetWeeklyCharts. Only the top chartSize positions count as being on the chart, or all of them
+// if chartSize is 0. Records are ordered by weeks at #1, then peak, then weeks on the chart.
+func ChartRecords(charts []store.ChartEntry, chartSize int) []ChartRecord {
+	records := make(map[store.AlbumKey]*ChartRecord)
+	runs := make(map[store.AlbumKey]int)
+	var order []store.AlbumKey
+	for _, e := range charts {
+		if chartSize > 0 && e.Rank > chartSize {
+			continue
+		}
+		key := store.AlbumKey{Artist: e.Artist, Name: e.Name}
+		r, ok := records[key]
+		if !ok {
+			r = &ChartRecord{Artist: e.Artist, Name: e.Name, FirstWeek: e.Week}
+			records[key] = r
+			order = append(order, key)
+		}
+
+		switch {
+		case r.Peak == 0 || e.Rank < r.Peak:
+			r.Peak = e.Rank
+			r.WeeksAtPeak = 1
+		case e.Rank == r.Peak:
+			r.WeeksAtPeak++
+		}
+		if e.Rank == 1 {
+			r.WeeksAtTop++
+		}
+
+		// Charts are in week order, so a run continues if this entry was on last week's chart.
+		if r.WeeksOnChart == 0 || !e.Week.Equal(r.LastWeek.Add(week)) {
+			runs[key] = 0
+		}
+		runs[key]++
+		if runs[key] > r.LongestRun {
+			r.LongestRun = runs[key]
+		}
+		r.WeeksOnChart++
+		r.LastWeek = e.Week
+		r.Listens += e.Count
+	}
+
+	var results []ChartRecord
+	for _, key := range order {
+		results = append(results, *records[key])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].WeeksAtTop != results[j].WeeksAtTop {
+			return results[i].WeeksAtTop > results[j].WeeksAtTop
+		}
+		if results[i].Peak != results[j].Peak {
+			return results[i].Peak < results[j].Peak
+		}
+		return results[i].WeeksOnChart > results[j].WeeksOnChart
+	})
+	return results
+}
+
+// ChartTrajectory returns an entry's position in every week from its first to its last week on the
+// chart, including the weeks in between when it was off the chart.
+func ChartTrajectory(charts []store.ChartEntry, artist, name string, chartSize int) []ChartPoint {
+	var points []ChartPoint
+	for _, e := range charts {
+		if e.Artist != artist || e.Name != name || (chartSize > 0 && e.Rank > chartSize) {
+			continue
+		}
+		if len(points) > 0 {
+			for w := points[len(points)-1].Week.Add(week); w.Before(e.Week); w = w.Add(week) {
+				points = append(points, ChartPoint{Week: w})
+			}
+		}
+		points = append(points, ChartPoint{Week: e.Week, Rank: e.Rank, Count: e.Count})
+	}
+	return points
+}
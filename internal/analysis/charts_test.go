@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestChartRecords(t *testing.T) {
+	w := func(n int) time.Time {
+		return time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 7*n)
+	}
+	charts := []store.ChartEntry{
+		{Week: w(0), Rank: 1, Artist: "A", Count: 10},
+		{Week: w(0), Rank: 2, Artist: "B", Count: 5},
+		{Week: w(0), Rank: 3, Artist: "C", Count: 1},
+		{Week: w(1), Rank: 1, Artist: "B", Count: 8},
+		{Week: w(1), Rank: 2, Artist: "A", Count: 4},
+		{Week: w(2), Rank: 1, Artist: "B", Count: 9},
+		{Week: w(3), Rank: 1, Artist: "A", Count: 3},
+		{Week: w(3), Rank: 2, Artist: "B", Count: 2},
+	}
+
+	got := ChartRecords(charts, 2)
+	want := []ChartRecord{
+		{Artist: "B", Peak: 1, WeeksAtPeak: 2, WeeksAtTop: 2, WeeksOnChart: 4, LongestRun: 4, FirstWeek: w(0), LastWeek: w(3), Listens: 24},
+		{Artist: "A", Peak: 1, WeeksAtPeak: 2, WeeksAtTop: 2, WeeksOnChart: 3, LongestRun: 2, FirstWeek: w(0), LastWeek: w(3), Listens: 17},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChartRecords() = %+v, want %+v", got, want)
+	}
+
+	gotPoints := ChartTrajectory(charts, "A", "", 0)
+	wantPoints := []ChartPoint{{w(0), 1, 10}, {w(1), 2, 4}, {Week: w(2)}, {w(3), 1, 3}}
+	if !reflect.DeepEqual(gotPoints, wantPoints) {
+		t.Errorf("ChartTrajectory() = %+v, want %+v", gotPoints, wantPoints)
+	}
+}
@@ -0,0 +1,191 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// DefaultRetentionOffsets are the months after an artist's first month at which retention is
+// measured.
+var DefaultRetentionOffsets = []int{1, 3, 6, 12}
+
+// DefaultMinCohortPlays is the default number of plays in an artist's first CohortClassMonths
+// months for them to be classified.
+const DefaultMinCohortPlays = 3
+
+// CohortClassMonths is how many months after an artist's first month are watched before they're
+// classified.
+const CohortClassMonths = 12
+
+// How artists are classified by their first CohortClassMonths months after their first month.
+const (
+	// Played in their first month and never again.
+	ClassOneMonthWonder = "one-month wonder"
+	// Played most in a month at least slowBurnMonths after their first, and not a staple.
+	ClassSlowBurn = "slow burn"
+	// Played in at least half of the months.
+	ClassStaple = "staple"
+)
+
+// CohortClasses are the classes, in the order they're shown.
+var CohortClasses = []string{ClassStaple, ClassSlowBurn, ClassOneMonthWonder}
+
+const slowBurnMonths = 3
+
+type CohortConfig struct {
+	// Months after the first month at which retention is measured.
+	Offsets []int
+	// The fewest plays in an artist's first CohortClassMonths months for them to be classified.
+	MinPlays        int64
+	ArtistsPerClass int
+}
+
+// CohortRetention is how many of a cohort's artists were played Months after the cohort's month.
+// Observed is false if that month hasn't finished yet, in which case Retained and Share are 0.
+type CohortRetention struct {
+	Months   int     `json:"months"`
+	Retained int     `json:"retained"`
+	Share    float64 `json:"share"`
+	Observed bool    `json:"observed"`
+}
+
+// Cohort is the artists first listened to in a month, which starts in UTC.
+type Cohort struct {
+	Start     time.Time         `json:"start"`
+	Month     string            `json:"month"`
+	Artists   int               `json:"artists"`
+	Retention []CohortRetention `json:"retention"`
+}
+
+// CohortArtist is a classified artist, with their plays from their first month to
+// CohortClassMonths later.
+type CohortArtist struct {
+	Artist          string `json:"artist"`
+	Cohort          string `json:"cohort"`
+	Plays           int64  `json:"plays"`
+	FirstMonthPlays int64  `json:"first_month_plays"`
+	// Months after the first month with plays.
+	ActiveMonths int    `json:"active_months"`
+	PeakMonth    string `json:"peak_month"`
+	Class        string `json:"class"`
+}
+
+type Cohorts struct {
+	Cohorts []Cohort `json:"cohorts"`
+	// The number of artists in each class, and the ones with the most plays.
+	ClassCounts map[string]int            `json:"class_counts"`
+	Artists     map[string][]CohortArtist `json:"artists"`
+}
+
+// GetCohorts groups artists by the month of their first listen, for months with start <= month <
+// end, and measures how many of each cohort were still played in later months, counting listens up
+// to end. Artists whose first CohortClassMonths months have finished by end are also classified.
+func GetCohorts(ctx context.Context, db store.Store, user string, start, end time.Time, config CohortConfig) (cohorts Cohorts, err error) {
+	counts, err := db.GetArtistMonthlyCounts(ctx, user, time.Unix(0, 0), end)
+	if err != nil {
+		return cohorts, fmt.Errorf("getting monthly counts: %w", err)
+	}
+
+	// Plays by month for each artist. The counts are ordered by artist and month, so the first
+	// month of each artist is their first listen.
+	months := make(map[string]map[time.Time]int64)
+	firstMonths := make(map[string]time.Time)
+	for _, c := range counts {
+		if months[c.Artist] == nil {
+			months[c.Artist] = make(map[time.Time]int64)
+			firstMonths[c.Artist] = c.Month
+		}
+		months[c.Artist][c.Month] = c.Count
+	}
+
+	// A month is observed once it's finished.
+	observed := func(month time.Time) bool {
+		return !month.AddDate(0, 1, 0).After(end)
+	}
+
+	byCohort := make(map[time.Time][]string)
+	for artist, first := range firstMonths {
+		if !first.Before(startOfMonth(start, time.UTC)) {
+			byCohort[first] = append(byCohort[first], artist)
+		}
+	}
+	for month, artists := range byCohort {
+		cohort := Cohort{Start: month, Month: month.Format("2006-01"), Artists: len(artists)}
+		for _, offset := range config.Offsets {
+			later := month.AddDate(0, offset, 0)
+			r := CohortRetention{Months: offset, Observed: observed(later)}
+			if r.Observed {
+				for _, artist := range artists {
+					if months[artist][later] > 0 {
+						r.Retained++
+					}
+				}
+				r.Share = roundTo(float64(r.Retained)/float64(len(artists)), 4)
+			}
+			cohort.Retention = append(cohort.Retention, r)
+		}
+		cohorts.Cohorts = append(cohorts.Cohorts, cohort)
+	}
+	sort.Slice(cohorts.Cohorts, func(i, j int) bool { return cohorts.Cohorts[i].Start.Before(cohorts.Cohorts[j].Start) })
+
+	cohorts.ClassCounts = make(map[string]int)
+	cohorts.Artists = make(map[string][]CohortArtist)
+	for _, c := range cohorts.Cohorts {
+		if !observed(c.Start.AddDate(0, CohortClassMonths, 0)) {
+			continue
+		}
+		for _, artist := range byCohort[c.Start] {
+			a := classifyCohortArtist(artist, c.Start, months[artist])
+			if a.Plays < config.MinPlays || a.Class == "" {
+				continue
+			}
+			cohorts.ClassCounts[a.Class]++
+			cohorts.Artists[a.Class] = append(cohorts.Artists[a.Class], a)
+		}
+	}
+	for class, artists := range cohorts.Artists {
+		sort.Slice(artists, func(i, j int) bool {
+			if artists[i].Plays != artists[j].Plays {
+				return artists[i].Plays > artists[j].Plays
+			}
+			return artists[i].Artist < artists[j].Artist
+		})
+		cohorts.Artists[class] = artists[:min(len(artists), config.ArtistsPerClass)]
+	}
+	return cohorts, nil
+}
+
+// classifyCohortArtist classifies an artist by their plays from their first month to
+// CohortClassMonths later. The class is empty if none fits.
+func classifyCohortArtist(artist string, first time.Time, plays map[time.Time]int64) CohortArtist {
+	a := CohortArtist{Artist: artist, Cohort: first.Format("2006-01"), FirstMonthPlays: plays[first]}
+	var peak time.Time
+	var peakPlays int64
+	for i := 0; i <= CohortClassMonths; i++ {
+		month := first.AddDate(0, i, 0)
+		n := plays[month]
+		a.Plays += n
+		if n > 0 && i > 0 {
+			a.ActiveMonths++
+		}
+		// The earliest month wins a tie.
+		if n > peakPlays {
+			peak, peakPlays = month, n
+		}
+	}
+	a.PeakMonth = peak.Format("2006-01")
+
+	switch {
+	case a.ActiveMonths*2 >= CohortClassMonths:
+		a.Class = ClassStaple
+	case !peak.Before(first.AddDate(0, slowBurnMonths, 0)):
+		a.Class = ClassSlowBurn
+	case a.ActiveMonths == 0:
+		a.Class = ClassOneMonthWonder
+	}
+	return a
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGetCohorts(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+
+	month := func(year, month int) time.Time { return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC) }
+	var tracks []store.TrackImport
+	add := func(artist string, m time.Time, plays int) {
+		for i := 0; i < plays; i++ {
+			ts := m.Add(time.Duration(i+1) * time.Hour)
+			tracks = append(tracks, store.TrackImport{Artist: artist, Album: artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+		}
+	}
+	// The January 2019 cohort: Staple is played every month, Burn mostly months later, Wonder only in
+	// the first month, Few too little to classify, and Fade mostly in the first month but not only.
+	add("Staple", month(2019, 1), 3)
+	for m := 2; m <= 12; m++ {
+		add("Staple", month(2019, m), 1)
+	}
+	add("Burn", month(2019, 1), 1)
+	add("Burn", month(2019, 6), 5)
+	add("Wonder", month(2019, 1), 4)
+	add("Few", month(2019, 1), 2)
+	add("Fade", month(2019, 1), 3)
+	add("Fade", month(2019, 2), 1)
+	// The December 2020 cohort is too new to classify or to measure beyond a month.
+	add("Late", month(2020, 12), 5)
+	add("Late", month(2021, 1), 5)
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	config := CohortConfig{Offsets: DefaultRetentionOffsets, MinPlays: DefaultMinCohortPlays, ArtistsPerClass: 10}
+	end := month(2021, 3)
+	cohorts, err := GetCohorts(ctx, db, user, time.Unix(0, 0), end, config)
+	if err != nil {
+		t.Fatalf("GetCohorts: %v", err)
+	}
+
+	wantCohorts := []Cohort{
+		{Start: month(2019, 1), Month: "2019-01", Artists: 5, Retention: []CohortRetention{
+			{Months: 1, Retained: 2, Share: 0.4, Observed: true},
+			{Months: 3, Retained: 1, Share: 0.2, Observed: true},
+			{Months: 6, Retained: 1, Share: 0.2, Observed: true},
+			{Months: 12, Retained: 0, Share: 0, Observed: true},
+		}},
+		{Start: month(2020, 12), Month: "2020-12", Artists: 1, Retention: []CohortRetention{
+			{Months: 1, Retained: 1, Share: 1, Observed: true},
+			{Months: 3}, {Months: 6}, {Months: 12},
+		}},
+	}
+	if !reflect.DeepEqual(cohorts.Cohorts, wantCohorts) {
+		t.Errorf("Cohorts = %+v\nwant %+v", cohorts.Cohorts, wantCohorts)
+	}
+
+	wantArtists := map[string][]CohortArtist{
+		ClassStaple:         {{Artist: "Staple", Cohort: "2019-01", Plays: 14, FirstMonthPlays: 3, ActiveMonths: 11, PeakMonth: "2019-01", Class: ClassStaple}},
+		ClassSlowBurn:       {{Artist: "Burn", Cohort: "2019-01", Plays: 6, FirstMonthPlays: 1, ActiveMonths: 1, PeakMonth: "2019-06", Class: ClassSlowBurn}},
+		ClassOneMonthWonder: {{Artist: "Wonder", Cohort: "2019-01", Plays: 4, FirstMonthPlays: 4, ActiveMonths: 0, PeakMonth: "2019-01", Class: ClassOneMonthWonder}},
+	}
+	if !reflect.DeepEqual(cohorts.Artists, wantArtists) {
+		t.Errorf("Artists = %+v\nwant %+v", cohorts.Artists, wantArtists)
+	}
+	wantCounts := map[string]int{ClassStaple: 1, ClassSlowBurn: 1, ClassOneMonthWonder: 1}
+	if !reflect.DeepEqual(cohorts.ClassCounts, wantCounts) {
+		t.Errorf("ClassCounts = %v, want %v", cohorts.ClassCounts, wantCounts)
+	}
+
+	// Only cohorts from start on are shown, but earlier listens still decide who is new.
+	cohorts, err = GetCohorts(ctx, db, user, month(2019, 6), end, config)
+	if err != nil {
+		t.Fatalf("GetCohorts: %v", err)
+	}
+	if len(cohorts.Cohorts) != 1 || cohorts.Cohorts[0].Month != "2020-12" {
+		t.Errorf("Cohorts = %+v, want only 2020-12", cohorts.Cohorts)
+	}
+}
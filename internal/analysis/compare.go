@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// Period is a date range, with both ends inclusive like the store's queries.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+type CompareConfig struct {
+	Current  Period
+	Previous Period
+	// Albums compares albums instead of artists.
+	Albums bool
+	// Number of entries in each chart, default is all of them. New entries and drop-outs are
+	// relative to this.
+	ChartSize int
+	// Number of risers and fallers to return.
+	Movers int
+}
+
+// RankChange is an artist or album's position in the current and previous charts. A rank of 0
+// means it wasn't played in that period.
+type RankChange struct {
+	Artist    string
+	Album     string
+	Rank      int
+	Count     int64
+	PrevRank  int
+	PrevCount int64
+}
+
+// Delta is how many places the entry rose (positive) or fell (negative). It's 0 unless the entry
+// was played in both periods.
+func (r RankChange) Delta() int {
+	if r.Rank == 0 || r.PrevRank == 0 {
+		return 0
+	}
+	return r.PrevRank - r.Rank
+}
+
+type Comparison struct {
+	// The current chart, in rank order.
+	Chart []RankChange
+	// Entries in the current chart that weren't in the previous one, in rank order.
+	NewEntries []RankChange
+	// Entries in the previous chart that aren't in the current one, in previous rank order.
+	DropOuts []RankChange
+	// Entries in the current chart that rose the most, biggest rise first.
+	Risers []RankChange
+	// Entries in the previous chart that fell the most, biggest fall first.
+	Fallers []RankChange
+}
+
+// Compare ranks the user's artists or albums in two periods and works out how they moved. It counts
+// listens rather than summing the stored weekly charts, which keep only each week's top entries and
+// whose Monday weeks don't line up with the periods.
+func Compare(ctx context.Context, db store.Store, user string, config CompareConfig) (Comparison, error) {
+	current, err := rankedCounts(ctx, db, user, config.Current, config.Albums)
+	if err != nil {
+		return Comparison{}, fmt.Errorf("current period: %w", err)
+	}
+	previous, err := rankedCounts(ctx, db, user, config.Previous, config.Albums)
+	if err != nil {
+		return Comparison{}, fmt.Errorf("previous period: %w", err)
+	}
+	return compareRankings(current, previous, config.ChartSize, config.Movers), nil
+}
+
+type rankedCount struct {
+	key   store.AlbumKey
+	rank  int
+	count int64
+}
+
+// rankedCounts returns the play counts for the period in descending order, with ties sharing a
+// rank (e.g. 1, 2, 2, 4) so that the order ties happen to be returned in doesn't count as
+// movement.
+func rankedCounts(ctx context.Context, db store.Store, user string, period Period, albums bool) ([]rankedCount, error) {
+	var counts []rankedCount
+	if albums {
+		albumCounts, err := db.GetTopAlbumsWithCount(ctx, user, period.Start, period.End)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range albumCounts {
+			counts = append(counts, rankedCount{key: store.AlbumKey{Artist: c.Artist, Name: c.Album}, count: c.Count})
+		}
+	} else {
+		artistCounts, err := db.GetTopArtistsWithCount(ctx, user, period.Start, period.End)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range artistCounts {
+			counts = append(counts, rankedCount{key: store.AlbumKey{Artist: c.Artist}, count: c.Count})
+		}
+	}
+
+	for i := range counts {
+		if i > 0 && counts[i].count == counts[i-1].count {
+			counts[i].rank = counts[i-1].rank
+		} else {
+			counts[i].rank = i + 1
+		}
+	}
+	return counts, nil
+}
+
+func compareRankings(current, previous []rankedCount, chartSize, movers int) (c Comparison) {
+	inChart := func(rank int) bool {
+		return rank != 0 && (chartSize == 0 || rank <= chartSize)
+	}
+
+	prevByKey := make(map[store.AlbumKey]rankedCount)
+	for _, p := range previous {
+		prevByKey[p.key] = p
+	}
+	curByKey := make(map[store.AlbumKey]rankedCount)
+	for _, cur := range current {
+		curByKey[cur.key] = cur
+	}
+
+	var fallers []RankChange
+	for _, cur := range current {
+		if !inChart(cur.rank) {
+			break
+		}
+		prev := prevByKey[cur.key]
+		change := RankChange{Artist: cur.key.Artist, Album: cur.key.Name, Rank: cur.rank, Count: cur.count, PrevRank: prev.rank, PrevCount: prev.count}
+		c.Chart = append(c.Chart, change)
+		if !inChart(prev.rank) {
+			c.NewEntries = append(c.NewEntries, change)
+		}
+		if change.Delta() > 0 {
+			c.Risers = append(c.Risers, change)
+		}
+	}
+	for _, prev := range previous {
+		if !inChart(prev.rank) {
+			break
+		}
+		cur := curByKey[prev.key]
+		change := RankChange{Artist: prev.key.Artist, Album: prev.key.Name, Rank: cur.rank, Count: cur.count, PrevRank: prev.rank, PrevCount: prev.count}
+		if !inChart(cur.rank) {
+			c.DropOuts = append(c.DropOuts, change)
+		}
+		if change.Delta() < 0 {
+			fallers = append(fallers, change)
+		}
+	}
+
+	sort.SliceStable(c.Risers, func(i, j int) bool {
+		return c.Risers[i].Delta() > c.Risers[j].Delta()
+	})
+	sort.SliceStable(fallers, func(i, j int) bool {
+		return fallers[i].Delta() < fallers[j].Delta()
+	})
+	if len(c.Risers) > movers {
+		c.Risers = c.Risers[:movers]
+	}
+	if len(fallers) > movers {
+		fallers = fallers[:movers]
+	}
+	c.Fallers = fallers
+	return c
+}
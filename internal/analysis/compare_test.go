@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestCompare(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	previous := Period{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)}
+	current := Period{time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC)}
+	addListens := func(period Period, counts map[string]int) {
+		var tracks []store.TrackImport
+		for artist, count := range counts {
+			for i := 0; i < count; i++ {
+				ts := period.Start.Add(time.Duration(i) * time.Hour)
+				tracks = append(tracks, store.TrackImport{Artist: artist, Album: artist + " album", TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+			}
+		}
+		if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+			t.Fatalf("AddRecentTracks: %v", err)
+		}
+	}
+	addListens(previous, map[string]int{"A": 10, "B": 8, "C": 6, "D": 4, "E": 2})
+	addListens(current, map[string]int{"B": 12, "E": 9, "A": 7, "F": 5, "C": 1})
+
+	got, err := Compare(context.Background(), db, user, CompareConfig{Current: current, Previous: previous, ChartSize: 3, Movers: 2})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+
+	b := RankChange{Artist: "B", Rank: 1, Count: 12, PrevRank: 2, PrevCount: 8}
+	e := RankChange{Artist: "E", Rank: 2, Count: 9, PrevRank: 5, PrevCount: 2}
+	a := RankChange{Artist: "A", Rank: 3, Count: 7, PrevRank: 1, PrevCount: 10}
+	c := RankChange{Artist: "C", Rank: 5, Count: 1, PrevRank: 3, PrevCount: 6}
+	want := Comparison{
+		Chart:      []RankChange{b, e, a},
+		NewEntries: []RankChange{e},
+		DropOuts:   []RankChange{c},
+		Risers:     []RankChange{e, b},
+		Fallers:    []RankChange{a, c},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() = %+v, want %+v", got, want)
+	}
+
+	got, err = Compare(context.Background(), db, user, CompareConfig{Current: current, Previous: previous, Albums: true, ChartSize: 1, Movers: 1})
+	if err != nil {
+		t.Fatalf("Compare(albums): %v", err)
+	}
+	want = Comparison{
+		Chart:      []RankChange{{Artist: "B", Album: "B album", Rank: 1, Count: 12, PrevRank: 2, PrevCount: 8}},
+		NewEntries: []RankChange{{Artist: "B", Album: "B album", Rank: 1, Count: 12, PrevRank: 2, PrevCount: 8}},
+		DropOuts:   []RankChange{{Artist: "A", Album: "A album", Rank: 3, Count: 7, PrevRank: 1, PrevCount: 10}},
+		Risers:     []RankChange{{Artist: "B", Album: "B album", Rank: 1, Count: 12, PrevRank: 2, PrevCount: 8}},
+		Fallers:    []RankChange{{Artist: "A", Album: "A album", Rank: 3, Count: 7, PrevRank: 1, PrevCount: 10}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare(albums) = %+v, want %+v", got, want)
+	}
+}
+
+func TestRankedCountsTies(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+	var tracks []store.TrackImport
+	for i, artist := range []string{"A", "A", "B", "C", "C", "D"} {
+		tracks = append(tracks, store.TrackImport{Artist: artist, Album: "", TrackName: "Track", DateUTS: fmt.Sprintf("%d", 1600000000+i)})
+	}
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	counts, err := rankedCounts(context.Background(), db, user, Period{time.Unix(1600000000, 0), time.Unix(1600001000, 0)}, false)
+	if err != nil {
+		t.Fatalf("rankedCounts: %v", err)
+	}
+	var ranks []int
+	for _, c := range counts {
+		ranks = append(ranks, c.rank)
+	}
+	if want := []int{1, 1, 3, 3}; !reflect.DeepEqual(ranks, want) {
+		t.Errorf("rankedCounts() ranks = %v, want %v", ranks, want)
+	}
+}
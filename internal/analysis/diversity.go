@@ -0,0 +1,219 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// DefaultFreshWindow is how long after an artist is first listened to that their plays count as
+// fresh.
+const DefaultFreshWindow = 90 * 24 * time.Hour
+
+type DiversityConfig struct {
+	// The length of each period: GranularityMonth, GranularityQuarter or GranularityYear. Periods
+	// start in UTC.
+	Granularity string
+	// Plays of an artist within FreshWindow of their first listen are fresh, and the rest familiar.
+	FreshWindow time.Duration
+	// Number of top artists whose share of the plays is measured.
+	TopArtists int
+}
+
+// DiversityPeriod is how the plays in a period were spread across artists.
+type DiversityPeriod struct {
+	Start   time.Time `json:"start"`
+	Label   string    `json:"label"`
+	Listens int64     `json:"listens"`
+	Artists int       `json:"artists"`
+	// Shannon entropy of the plays across artists, in bits. Higher is more diverse.
+	Entropy float64 `json:"entropy"`
+	// Gini coefficient of the plays across artists: 0 when every artist has the same plays, and
+	// approaching 1 as a few artists take all of them.
+	Gini float64 `json:"gini"`
+	// Share of the plays from the top TopArtists artists.
+	TopShare           float64 `json:"top_share"`
+	ArtistsPerThousand float64 `json:"artists_per_thousand"`
+	// Share of the plays which were fresh.
+	FreshShare float64 `json:"fresh_share"`
+}
+
+// DiversityTrend is the change in one of the metrics per period, fitted by least squares over
+// periods with listens.
+type DiversityTrend struct {
+	Metric string  `json:"metric"`
+	Slope  float64 `json:"slope"`
+}
+
+type Diversity struct {
+	Periods []DiversityPeriod `json:"periods"`
+	Trends  []DiversityTrend  `json:"trends"`
+}
+
+// Names of the metrics in DiversityTrend.
+const (
+	MetricEntropy            = "entropy"
+	MetricGini               = "gini"
+	MetricTopShare           = "top_share"
+	MetricArtistsPerThousand = "artists_per_thousand"
+	MetricFreshShare         = "fresh_share"
+)
+
+// shannonEntropy returns the entropy in bits of the distribution given by counts.
+func shannonEntropy(counts []int64) float64 {
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	var entropy float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// gini returns the Gini coefficient of counts, or 0 if there are none.
+func gini(counts []int64) float64 {
+	sorted := append([]int64(nil), counts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total, weighted float64
+	for i, c := range sorted {
+		total += float64(c)
+		weighted += float64(i+1) * float64(c)
+	}
+	if total == 0 {
+		return 0
+	}
+	n := float64(len(sorted))
+	return 2*weighted/(n*total) - (n+1)/n
+}
+
+func roundTo(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
+
+// GetDiversity measures how diverse the user's listening was in each period from the first to the
+// last listen with start <= date < end.
+func GetDiversity(ctx context.Context, db store.Store, user string, start, end time.Time, config DiversityConfig) (diversity Diversity, err error) {
+	switch config.Granularity {
+	case GranularityMonth, GranularityQuarter, GranularityYear:
+	default:
+		return diversity, fmt.Errorf("invalid granularity %q, must be month, quarter or year", config.Granularity)
+	}
+
+	// Every artist's first listen, for telling fresh plays from familiar ones.
+	stats, err := db.GetArtistListenStats(ctx, user, time.Unix(0, 0), end)
+	if err != nil {
+		return diversity, err
+	}
+	firstListens := make(map[string]time.Time)
+	var first, last time.Time
+	for _, s := range stats {
+		firstListens[s.Artist] = s.FirstListen
+		if first.IsZero() || s.FirstListen.Before(first) {
+			first = s.FirstListen
+		}
+		if s.LastListen.After(last) {
+			last = s.LastListen
+		}
+	}
+	if len(stats) == 0 || last.Before(start) {
+		return diversity, nil
+	}
+	if first.Before(start) {
+		first = start
+	}
+
+	for p := periodStart(first, config.Granularity); !p.After(last); p = nextPeriod(p, config.Granularity) {
+		pStart, pEnd := p, nextPeriod(p, config.Granularity)
+		if pStart.Before(start) {
+			pStart = start
+		}
+		if pEnd.After(end) {
+			pEnd = end
+		}
+		period, err := diversityPeriod(ctx, db, user, pStart, pEnd, firstListens, config)
+		if err != nil {
+			return diversity, fmt.Errorf("%s: %w", periodLabel(p, config.Granularity), err)
+		}
+		period.Start = p
+		period.Label = periodLabel(p, config.Granularity)
+		diversity.Periods = append(diversity.Periods, period)
+	}
+
+	metrics := []struct {
+		name  string
+		value func(DiversityPeriod) float64
+	}{
+		{MetricEntropy, func(p DiversityPeriod) float64 { return p.Entropy }},
+		{MetricGini, func(p DiversityPeriod) float64 { return p.Gini }},
+		{MetricTopShare, func(p DiversityPeriod) float64 { return p.TopShare }},
+		{MetricArtistsPerThousand, func(p DiversityPeriod) float64 { return p.ArtistsPerThousand }},
+		{MetricFreshShare, func(p DiversityPeriod) float64 { return p.FreshShare }},
+	}
+	for _, m := range metrics {
+		var xs, ys []float64
+		for j, p := range diversity.Periods {
+			if p.Listens > 0 {
+				xs = append(xs, float64(j))
+				ys = append(ys, m.value(p))
+			}
+		}
+		diversity.Trends = append(diversity.Trends, DiversityTrend{Metric: m.name, Slope: roundTo(slope(xs, ys), 4)})
+	}
+	return diversity, nil
+}
+
+// diversityPeriod measures the plays with start <= date < end.
+func diversityPeriod(ctx context.Context, db store.Store, user string, start, end time.Time, firstListens map[string]time.Time, config DiversityConfig) (period DiversityPeriod, err error) {
+	// The range of GetTopArtistsWithCount includes its end. Artists are ordered by plays.
+	artists, err := db.GetTopArtistsWithCount(ctx, user, start, end.Add(-time.Second))
+	if err != nil {
+		return period, err
+	}
+	if len(artists) == 0 {
+		return period, nil
+	}
+
+	counts := make([]int64, len(artists))
+	var top, fresh int64
+	for i, a := range artists {
+		counts[i] = a.Count
+		period.Listens += a.Count
+		if i < config.TopArtists {
+			top += a.Count
+		}
+
+		// Only artists first listened to within FreshWindow before the period ends have fresh plays
+		// in it.
+		freshEnd := firstListens[a.Artist].Add(config.FreshWindow)
+		if !freshEnd.After(start) {
+			continue
+		}
+		if freshEnd.After(end) {
+			freshEnd = end
+		}
+		n, err := db.GetArtistListenCount(ctx, user, a.Artist, start, freshEnd.Add(-time.Second))
+		if err != nil {
+			return period, err
+		}
+		fresh += n
+	}
+
+	period.Artists = len(artists)
+	period.Entropy = roundTo(shannonEntropy(counts), 4)
+	period.Gini = roundTo(gini(counts), 4)
+	period.TopShare = roundTo(float64(top)/float64(period.Listens), 4)
+	period.ArtistsPerThousand = roundTo(float64(period.Artists)*1000/float64(period.Listens), 2)
+	period.FreshShare = roundTo(float64(fresh)/float64(period.Listens), 4)
+	return period, nil
+}
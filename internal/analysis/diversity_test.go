@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGiniAndEntropy(t *testing.T) {
+	tests := []struct {
+		counts  []int64
+		gini    float64
+		entropy float64
+	}{
+		{nil, 0, 0},
+		{[]int64{5}, 0, 0},
+		{[]int64{3, 3, 3, 3}, 0, 2},
+		{[]int64{1, 0, 0, 0}, 0.75, 0},
+		{[]int64{3, 1}, 0.25, 0.8113},
+	}
+	for _, tt := range tests {
+		if got := gini(tt.counts); math.Abs(got-tt.gini) > 1e-9 {
+			t.Errorf("gini(%v) = %v, want %v", tt.counts, got, tt.gini)
+		}
+		if got := roundTo(shannonEntropy(tt.counts), 4); got != tt.entropy {
+			t.Errorf("shannonEntropy(%v) = %v, want %v", tt.counts, got, tt.entropy)
+		}
+	}
+}
+
+func TestGetDiversity(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+
+	// January: two plays each of A and B, both new. February: three of A, one of C, which is new.
+	// March is empty. April: four of A, which is no longer fresh.
+	listens := []struct {
+		month, day int
+		artist     string
+	}{
+		{1, 1, "A"}, {1, 2, "A"}, {1, 3, "B"}, {1, 4, "B"},
+		{2, 1, "A"}, {2, 2, "A"}, {2, 3, "A"}, {2, 4, "C"},
+		{4, 1, "A"}, {4, 2, "A"}, {4, 3, "A"}, {4, 4, "A"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts := time.Date(2020, time.Month(l.month), l.day, 12, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	config := DiversityConfig{Granularity: GranularityMonth, FreshWindow: 60 * 24 * time.Hour, TopArtists: 1}
+	diversity, err := GetDiversity(ctx, db, user, time.Unix(0, 0), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), config)
+	if err != nil {
+		t.Fatalf("GetDiversity: %v", err)
+	}
+
+	month := func(m int) time.Time { return time.Date(2020, time.Month(m), 1, 0, 0, 0, 0, time.UTC) }
+	want := []DiversityPeriod{
+		{Start: month(1), Label: "2020-01", Listens: 4, Artists: 2, Entropy: 1, Gini: 0, TopShare: 0.5, ArtistsPerThousand: 500, FreshShare: 1},
+		{Start: month(2), Label: "2020-02", Listens: 4, Artists: 2, Entropy: 0.8113, Gini: 0.25, TopShare: 0.75, ArtistsPerThousand: 500, FreshShare: 1},
+		{Start: month(3), Label: "2020-03"},
+		{Start: month(4), Label: "2020-04", Listens: 4, Artists: 1, Entropy: 0, Gini: 0, TopShare: 1, ArtistsPerThousand: 250, FreshShare: 0},
+	}
+	if !reflect.DeepEqual(diversity.Periods, want) {
+		t.Errorf("Periods = %+v\nwant %+v", diversity.Periods, want)
+	}
+
+	trends := make(map[string]float64)
+	for _, tr := range diversity.Trends {
+		trends[tr.Metric] = tr.Slope
+	}
+	if trends[MetricEntropy] >= 0 || trends[MetricFreshShare] >= 0 || trends[MetricTopShare] <= 0 {
+		t.Errorf("Trends = %+v, want falling entropy and fresh share and rising top share", diversity.Trends)
+	}
+
+	// A fresh play is only counted while it's within the window of the artist's first listen.
+	config.FreshWindow = 33 * 24 * time.Hour
+	diversity, err = GetDiversity(ctx, db, user, month(2), month(3), config)
+	if err != nil {
+		t.Fatalf("GetDiversity: %v", err)
+	}
+	if len(diversity.Periods) != 1 || diversity.Periods[0].FreshShare != 0.75 {
+		t.Errorf("Periods = %+v, want one period with a fresh share of 0.75", diversity.Periods)
+	}
+
+	if _, err := GetDiversity(ctx, db, user, month(1), month(2), DiversityConfig{Granularity: "week"}); err == nil {
+		t.Errorf("GetDiversity with granularity week: want error")
+	}
+}
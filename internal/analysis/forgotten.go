@@ -1,7 +1,9 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"sort"
 	"time"
 
@@ -15,8 +17,17 @@ type ForgottenConfig struct {
 	FirstListenBefore  time.Time
 	MinArtistScrobbles int
 	MinAlbumScrobbles  int
+	MinTrackScrobbles  int
 	ResultsPerBand     int
-	SortBy             string // "dormancy" or "listens"
+	SortBy             string // SortDormancy, SortListens or SortScore
+	// How the interest bands are chosen, BandModeFixed if unset.
+	BandMode string
+	// Thresholds for BandModeFixed. Unset thresholds use the defaults.
+	ArtistBands BandThresholds
+	AlbumBands  BandThresholds
+	TrackBands  BandThresholds
+	// Percentiles for BandModePercentile, DefaultBandPercentiles if unset.
+	Percentiles BandPercentiles
 }
 
 type ForgottenArtist struct {
@@ -26,6 +37,8 @@ type ForgottenArtist struct {
 	LastListen     time.Time
 	DaysSinceLast  int
 	Band           string
+	// Only set when sorting by SortScore.
+	Score float64
 }
 
 type ForgottenAlbum struct {
@@ -36,6 +49,20 @@ type ForgottenAlbum struct {
 	LastListen     time.Time
 	DaysSinceLast  int
 	Band           string
+	// Only set when sorting by SortScore.
+	Score float64
+}
+
+type ForgottenTrack struct {
+	Artist         string
+	Track          string
+	TotalScrobbles int64
+	FirstListen    time.Time
+	LastListen     time.Time
+	DaysSinceLast  int
+	Band           string
+	// Only set when sorting by SortScore.
+	Score float64
 }
 
 const (
@@ -52,74 +79,355 @@ const (
 	ThresholdAlbumObsession = 60
 	ThresholdAlbumStrong    = 30
 	ThresholdAlbumModerate  = 10
+
+	// Track Thresholds
+	ThresholdTrackObsession = 40
+	ThresholdTrackStrong    = 20
+	ThresholdTrackModerate  = 8
+
+	// BandModeFixed uses the configured thresholds. BandModePercentile derives them from the
+	// distribution of the user's scrobbles per artist, album or track, so that heavy and light listeners
+	// get comparable bands.
+	BandModeFixed      = "fixed"
+	BandModePercentile = "percentile"
+
+	SortDormancy = "dormancy"
+	SortListens  = "listens"
+	// SortScore sorts by the rediscovery score, see rediscoveryScore.
+	SortScore = "score"
 )
 
-// GetThreshold returns the minimum scrobbles for a given band and type (artist/album).
-func GetThreshold(band string, isArtist bool) int {
-	if isArtist {
-		switch band {
-		case BandObsession:
-			return ThresholdArtistObsession
-		case BandStrong:
-			return ThresholdArtistStrong
-		case BandModerate:
-			return ThresholdArtistModerate
-		}
-	} else {
-		switch band {
-		case BandObsession:
-			return ThresholdAlbumObsession
-		case BandStrong:
-			return ThresholdAlbumStrong
-		case BandModerate:
-			return ThresholdAlbumModerate
-		}
+// BandThresholds are the minimum scrobbles for each interest band.
+type BandThresholds struct {
+	Obsession int64
+	Strong    int64
+	Moderate  int64
+}
+
+// BandPercentiles are the percentiles, from 0 to 100, of the user's scrobbles per artist, album or
+// track at which each interest band starts.
+type BandPercentiles struct {
+	Obsession float64
+	Strong    float64
+	Moderate  float64
+}
+
+var (
+	DefaultArtistBands     = BandThresholds{ThresholdArtistObsession, ThresholdArtistStrong, ThresholdArtistModerate}
+	DefaultAlbumBands      = BandThresholds{ThresholdAlbumObsession, ThresholdAlbumStrong, ThresholdAlbumModerate}
+	DefaultTrackBands      = BandThresholds{ThresholdTrackObsession, ThresholdTrackStrong, ThresholdTrackModerate}
+	DefaultBandPercentiles = BandPercentiles{99, 95, 85}
+)
+
+// Band returns the band for an artist or album with the scrobbles, or "" if it's in none.
+func (b BandThresholds) Band(scrobbles int64) string {
+	switch {
+	case scrobbles >= b.Obsession:
+		return BandObsession
+	case scrobbles >= b.Strong:
+		return BandStrong
+	case scrobbles >= b.Moderate:
+		return BandModerate
+	}
+	return ""
+}
+
+// Threshold returns the minimum scrobbles for the band.
+func (b BandThresholds) Threshold(band string) int64 {
+	switch band {
+	case BandObsession:
+		return b.Obsession
+	case BandStrong:
+		return b.Strong
+	case BandModerate:
+		return b.Moderate
 	}
 	return 0
 }
 
-func determineBand(scrobbles int64, isArtist bool) string {
-	if isArtist {
-		if scrobbles >= ThresholdArtistObsession {
-			return BandObsession
+func (b BandThresholds) validate() error {
+	if b.Moderate < 1 || b.Strong < b.Moderate || b.Obsession < b.Strong {
+		return fmt.Errorf("invalid band thresholds %d, %d, %d: must be positive and decreasing from obsession to moderate", b.Obsession, b.Strong, b.Moderate)
+	}
+	return nil
+}
+
+func (p BandPercentiles) validate() error {
+	if p.Moderate <= 0 || p.Strong < p.Moderate || p.Obsession < p.Strong || p.Obsession > 100 {
+		return fmt.Errorf("invalid band percentiles %g, %g, %g: must be between 0 and 100 and decreasing from obsession to moderate", p.Obsession, p.Strong, p.Moderate)
+	}
+	return nil
+}
+
+// percentileBands returns the thresholds at the percentiles of counts, using the nearest rank. Every
+// threshold is at least 1.
+func percentileBands(counts []int64, p BandPercentiles) BandThresholds {
+	sorted := append([]int64(nil), counts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(percentile float64) int64 {
+		if len(sorted) == 0 {
+			return 1
+		}
+		rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+		return max(sorted[max(rank, 1)-1], 1)
+	}
+	return BandThresholds{Obsession: at(p.Obsession), Strong: at(p.Strong), Moderate: at(p.Moderate)}
+}
+
+// ResolveForgottenBands returns cfg with ArtistBands, AlbumBands and TrackBands set to the
+// thresholds its BandMode gives, and BandMode set to BandModeFixed.
+func ResolveForgottenBands(ctx context.Context, db store.Store, user string, cfg ForgottenConfig) (ForgottenConfig, error) {
+	switch cfg.BandMode {
+	case "", BandModeFixed:
+		if cfg.ArtistBands == (BandThresholds{}) {
+			cfg.ArtistBands = DefaultArtistBands
+		}
+		if cfg.AlbumBands == (BandThresholds{}) {
+			cfg.AlbumBands = DefaultAlbumBands
 		}
-		if scrobbles >= ThresholdArtistStrong {
-			return BandStrong
+		if cfg.TrackBands == (BandThresholds{}) {
+			cfg.TrackBands = DefaultTrackBands
 		}
-		if scrobbles >= ThresholdArtistModerate {
-			return BandModerate
+	case BandModePercentile:
+		if cfg.Percentiles == (BandPercentiles{}) {
+			cfg.Percentiles = DefaultBandPercentiles
 		}
-	} else {
-		if scrobbles >= ThresholdAlbumObsession {
-			return BandObsession
+		if err := cfg.Percentiles.validate(); err != nil {
+			return cfg, err
 		}
-		if scrobbles >= ThresholdAlbumStrong {
-			return BandStrong
+		// Every artist, album and track the user has listened to.
+		all := store.ForgottenQueryOptions{LastListenBefore: math.MaxInt64, FirstListenBefore: math.MaxInt64}
+		artists, err := db.GetForgottenArtists(ctx, user, all)
+		if err != nil {
+			return cfg, fmt.Errorf("getting artist scrobbles: %w", err)
 		}
-		if scrobbles >= ThresholdAlbumModerate {
-			return BandModerate
+		var counts []int64
+		for _, a := range artists {
+			counts = append(counts, a.TotalScrobbles)
 		}
+		cfg.ArtistBands = percentileBands(counts, cfg.Percentiles)
+
+		albums, err := db.GetForgottenAlbums(ctx, user, all)
+		if err != nil {
+			return cfg, fmt.Errorf("getting album scrobbles: %w", err)
+		}
+		counts = nil
+		for _, a := range albums {
+			counts = append(counts, a.TotalScrobbles)
+		}
+		cfg.AlbumBands = percentileBands(counts, cfg.Percentiles)
+
+		tracks, err := db.GetForgottenTracks(ctx, user, all)
+		if err != nil {
+			return cfg, fmt.Errorf("getting track scrobbles: %w", err)
+		}
+		counts = nil
+		for _, t := range tracks {
+			counts = append(counts, t.TotalScrobbles)
+		}
+		cfg.TrackBands = percentileBands(counts, cfg.Percentiles)
+	default:
+		return cfg, fmt.Errorf("invalid band mode %q, must be fixed or percentile", cfg.BandMode)
+	}
+	if err := cfg.ArtistBands.validate(); err != nil {
+		return cfg, fmt.Errorf("artist bands: %w", err)
+	}
+	if err := cfg.AlbumBands.validate(); err != nil {
+		return cfg, fmt.Errorf("album bands: %w", err)
+	}
+	if err := cfg.TrackBands.validate(); err != nil {
+		return cfg, fmt.Errorf("track bands: %w", err)
+	}
+	cfg.BandMode = BandModeFixed
+	return cfg, nil
+}
+
+// peakMonths returns the start of the month, in UTC, with the most listens for each key, which is the
+// artist, album or track listened to. Ties go to the earliest month.
+func peakMonths(listens []store.TrackListen, key func(store.TrackListen) store.AlbumKey) map[store.AlbumKey]time.Time {
+	months := make(map[store.AlbumKey]map[time.Time]int64)
+	for _, l := range listens {
+		k := key(l)
+		if months[k] == nil {
+			months[k] = make(map[time.Time]int64)
+		}
+		months[k][startOfMonth(l.Time, time.UTC)]++
+	}
+	peaks := make(map[store.AlbumKey]time.Time)
+	for key, counts := range months {
+		var peak time.Time
+		for month, n := range counts {
+			if n > counts[peak] || (n == counts[peak] && month.Before(peak)) {
+				peak = month
+			}
+		}
+		peaks[key] = peak
+	}
+	return peaks
+}
+
+// forgottenFeedback hides the items the user has given feedback on.
+type forgottenFeedback map[store.FeedbackKind]map[store.AlbumKey]store.ForgottenFeedback
+
+func loadForgottenFeedback(ctx context.Context, db store.Store, user string) (forgottenFeedback, error) {
+	all, err := db.GetForgottenFeedback(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("getting forgotten feedback: %w", err)
+	}
+	feedback := make(forgottenFeedback)
+	for _, f := range all {
+		if feedback[f.Kind] == nil {
+			feedback[f.Kind] = make(map[store.AlbumKey]store.ForgottenFeedback)
+		}
+		feedback[f.Kind][store.AlbumKey{Artist: f.Artist, Name: f.Name}] = f
+	}
+	return feedback, nil
+}
+
+// hidden returns whether the item is left out of the results. Ignored items are always hidden, and
+// snoozed items until their snooze ends. Revisited items count as listened to when they were
+// marked, so they're hidden until that's before LastListenBefore.
+func (f forgottenFeedback) hidden(kind store.FeedbackKind, artist, name string, cfg ForgottenConfig, now time.Time) bool {
+	feedback, ok := f[kind][store.AlbumKey{Artist: artist, Name: name}]
+	if !ok {
+		return false
+	}
+	switch feedback.State {
+	case store.FeedbackIgnored:
+		return true
+	case store.FeedbackSnoozed:
+		return now.Before(feedback.Until)
+	case store.FeedbackRevisited:
+		return feedback.Marked.After(cfg.LastListenBefore)
+	}
+	return false
+}
+
+// rediscoveryScore is historical intensity × dormancy × recency of peak. Intensity is the scrobbles
+// as a multiple of the moderate band's threshold, dormancy is the years since the last listen, and
+// recency is 1 / (1 + years since the peak month). Music that was played heavily, dropped soon after
+// its peak and then left for a long time scores highest.
+func rediscoveryScore(scrobbles int64, bands BandThresholds, last, peak, now time.Time) float64 {
+	const year = 365 * 24 * time.Hour
+	intensity := float64(scrobbles) / float64(bands.Moderate)
+	dormancy := float64(now.Sub(last)) / float64(year)
+	recency := 1 / (1 + math.Max(float64(now.Sub(peak))/float64(year), 0))
+	return math.Round(intensity*dormancy*recency*100) / 100
+}
+
+// GetThreshold returns the minimum scrobbles for a given band and type (artist/album).
+func GetThreshold(band string, isArtist bool) int {
+	if isArtist {
+		return int(DefaultArtistBands.Threshold(band))
+	}
+	return int(DefaultAlbumBands.Threshold(band))
+}
+
+func determineBand(scrobbles int64, isArtist bool) string {
+	if isArtist {
+		return DefaultArtistBands.Band(scrobbles)
+	}
+	return DefaultAlbumBands.Band(scrobbles)
+}
+
+// ForgottenReport is the forgotten artists, albums and tracks in each band.
+type ForgottenReport struct {
+	// Config is the report's config with the bands resolved, for the band headings.
+	Config  ForgottenConfig
+	Artists map[string][]ForgottenArtist
+	Albums  map[string][]ForgottenAlbum
+	Tracks  map[string][]ForgottenTrack
+}
+
+// GetForgotten returns the forgotten artists, albums and tracks. The bands, feedback and listen
+// history are loaded once for all three.
+func GetForgotten(ctx context.Context, db store.Store, user string, cfg ForgottenConfig, now time.Time) (ForgottenReport, error) {
+	var report ForgottenReport
+	q, err := newForgottenQuery(ctx, db, user, cfg, now)
+	if err != nil {
+		return report, err
+	}
+	report.Config = q.cfg
+	if report.Artists, err = q.artists(ctx, db, user); err != nil {
+		return report, err
+	}
+	if report.Albums, err = q.albums(ctx, db, user); err != nil {
+		return report, err
+	}
+	if report.Tracks, err = q.tracks(ctx, db, user); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// forgottenQuery is what finding forgotten artists, albums and tracks shares: the resolved bands, the
+// feedback and, when sorting by score, the listen history.
+type forgottenQuery struct {
+	cfg ForgottenConfig
+	// Whether the bands came from percentiles, which can start below the configured minimums.
+	percentile bool
+	feedback   forgottenFeedback
+	listens    []store.TrackListen
+	now        time.Time
+}
+
+func newForgottenQuery(ctx context.Context, db store.Store, user string, cfg ForgottenConfig, now time.Time) (*forgottenQuery, error) {
+	q := &forgottenQuery{percentile: cfg.BandMode == BandModePercentile, now: now}
+	var err error
+	if q.cfg, err = ResolveForgottenBands(ctx, db, user, cfg); err != nil {
+		return nil, err
+	}
+	if q.feedback, err = loadForgottenFeedback(ctx, db, user); err != nil {
+		return nil, err
+	}
+	if cfg.SortBy == SortScore {
+		if q.listens, err = db.GetListenHistory(ctx, user, time.Unix(0, 0), now); err != nil {
+			return nil, fmt.Errorf("getting listens: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// options returns the store query options for items with at least min scrobbles. In percentile mode
+// min is lowered to the moderate band's threshold, to keep the lower bands from being empty.
+func (q *forgottenQuery) options(min int, bands BandThresholds) store.ForgottenQueryOptions {
+	if q.percentile && bands.Moderate < int64(min) {
+		min = int(bands.Moderate)
+	}
+	return store.ForgottenQueryOptions{
+		MinScrobbles:      min,
+		LastListenAfter:   q.cfg.LastListenAfter.Unix(),
+		LastListenBefore:  q.cfg.LastListenBefore.Unix(),
+		FirstListenAfter:  q.cfg.FirstListenAfter.Unix(),
+		FirstListenBefore: q.cfg.FirstListenBefore.Unix(),
 	}
-	return ""
 }
 
-func GetForgottenArtists(db *store.Store, user string, cfg ForgottenConfig, now time.Time) (map[string][]ForgottenArtist, error) {
-	opts := store.ForgottenQueryOptions{
-		MinScrobbles:      cfg.MinArtistScrobbles,
-		LastListenAfter:   cfg.LastListenAfter.Unix(),
-		LastListenBefore:  cfg.LastListenBefore.Unix(),
-		FirstListenAfter:  cfg.FirstListenAfter.Unix(),
-		FirstListenBefore: cfg.FirstListenBefore.Unix(),
+func GetForgottenArtists(ctx context.Context, db store.Store, user string, cfg ForgottenConfig, now time.Time) (map[string][]ForgottenArtist, error) {
+	q, err := newForgottenQuery(ctx, db, user, cfg, now)
+	if err != nil {
+		return nil, err
 	}
+	return q.artists(ctx, db, user)
+}
 
-	stats, err := db.GetForgottenArtists(user, opts)
+func (q *forgottenQuery) artists(ctx context.Context, db store.Store, user string) (map[string][]ForgottenArtist, error) {
+	cfg, feedback, now := q.cfg, q.feedback, q.now
+	stats, err := db.GetForgottenArtists(ctx, user, q.options(cfg.MinArtistScrobbles, cfg.ArtistBands))
 	if err != nil {
 		return nil, fmt.Errorf("getting forgotten artists: %w", err)
 	}
+	var peaks map[store.AlbumKey]time.Time
+	if cfg.SortBy == SortScore {
+		peaks = peakMonths(q.listens, func(l store.TrackListen) store.AlbumKey { return store.AlbumKey{Artist: l.Artist} })
+	}
 
 	results := make(map[string][]ForgottenArtist)
 
 	for _, s := range stats {
+		if feedback.hidden(store.FeedbackArtist, s.Artist, "", cfg, now) {
+			continue
+		}
 		a := ForgottenArtist{
 			Artist:         s.Artist,
 			TotalScrobbles: s.TotalScrobbles,
@@ -128,10 +436,13 @@ func GetForgottenArtists(db *store.Store, user string, cfg ForgottenConfig, now
 			DaysSinceLast:  int(now.Sub(s.LastListen).Hours() / 24),
 		}
 
-		a.Band = determineBand(a.TotalScrobbles, true)
+		a.Band = cfg.ArtistBands.Band(a.TotalScrobbles)
 		if a.Band == "" {
 			continue
 		}
+		if peaks != nil {
+			a.Score = rediscoveryScore(a.TotalScrobbles, cfg.ArtistBands, a.LastListen, peaks[store.AlbumKey{Artist: a.Artist}], now)
+		}
 
 		results[a.Band] = append(results[a.Band], a)
 	}
@@ -146,23 +457,31 @@ func GetForgottenArtists(db *store.Store, user string, cfg ForgottenConfig, now
 	return results, nil
 }
 
-func GetForgottenAlbums(db *store.Store, user string, cfg ForgottenConfig, now time.Time) (map[string][]ForgottenAlbum, error) {
-	opts := store.ForgottenQueryOptions{
-		MinScrobbles:      cfg.MinAlbumScrobbles,
-		LastListenAfter:   cfg.LastListenAfter.Unix(),
-		LastListenBefore:  cfg.LastListenBefore.Unix(),
-		FirstListenAfter:  cfg.FirstListenAfter.Unix(),
-		FirstListenBefore: cfg.FirstListenBefore.Unix(),
+func GetForgottenAlbums(ctx context.Context, db store.Store, user string, cfg ForgottenConfig, now time.Time) (map[string][]ForgottenAlbum, error) {
+	q, err := newForgottenQuery(ctx, db, user, cfg, now)
+	if err != nil {
+		return nil, err
 	}
+	return q.albums(ctx, db, user)
+}
 
-	stats, err := db.GetForgottenAlbums(user, opts)
+func (q *forgottenQuery) albums(ctx context.Context, db store.Store, user string) (map[string][]ForgottenAlbum, error) {
+	cfg, feedback, now := q.cfg, q.feedback, q.now
+	stats, err := db.GetForgottenAlbums(ctx, user, q.options(cfg.MinAlbumScrobbles, cfg.AlbumBands))
 	if err != nil {
 		return nil, fmt.Errorf("getting forgotten albums: %w", err)
 	}
+	var peaks map[store.AlbumKey]time.Time
+	if cfg.SortBy == SortScore {
+		peaks = peakMonths(q.listens, func(l store.TrackListen) store.AlbumKey { return store.AlbumKey{Artist: l.Artist, Name: l.Album} })
+	}
 
 	results := make(map[string][]ForgottenAlbum)
 
 	for _, s := range stats {
+		if feedback.hidden(store.FeedbackAlbum, s.Artist, s.Album, cfg, now) {
+			continue
+		}
 		a := ForgottenAlbum{
 			Artist:         s.Artist,
 			Album:          s.Album,
@@ -172,10 +491,13 @@ func GetForgottenAlbums(db *store.Store, user string, cfg ForgottenConfig, now t
 			DaysSinceLast:  int(now.Sub(s.LastListen).Hours() / 24),
 		}
 
-		a.Band = determineBand(a.TotalScrobbles, false)
+		a.Band = cfg.AlbumBands.Band(a.TotalScrobbles)
 		if a.Band == "" {
 			continue
 		}
+		if peaks != nil {
+			a.Score = rediscoveryScore(a.TotalScrobbles, cfg.AlbumBands, a.LastListen, peaks[store.AlbumKey{Artist: a.Artist, Name: a.Album}], now)
+		}
 
 		results[a.Band] = append(results[a.Band], a)
 	}
@@ -190,10 +512,68 @@ func GetForgottenAlbums(db *store.Store, user string, cfg ForgottenConfig, now t
 	return results, nil
 }
 
+func GetForgottenTracks(ctx context.Context, db store.Store, user string, cfg ForgottenConfig, now time.Time) (map[string][]ForgottenTrack, error) {
+	q, err := newForgottenQuery(ctx, db, user, cfg, now)
+	if err != nil {
+		return nil, err
+	}
+	return q.tracks(ctx, db, user)
+}
+
+func (q *forgottenQuery) tracks(ctx context.Context, db store.Store, user string) (map[string][]ForgottenTrack, error) {
+	cfg, feedback, now := q.cfg, q.feedback, q.now
+	stats, err := db.GetForgottenTracks(ctx, user, q.options(cfg.MinTrackScrobbles, cfg.TrackBands))
+	if err != nil {
+		return nil, fmt.Errorf("getting forgotten tracks: %w", err)
+	}
+	var peaks map[store.AlbumKey]time.Time
+	if cfg.SortBy == SortScore {
+		peaks = peakMonths(q.listens, func(l store.TrackListen) store.AlbumKey { return store.AlbumKey{Artist: l.Artist, Name: l.Track} })
+	}
+
+	results := make(map[string][]ForgottenTrack)
+
+	for _, s := range stats {
+		if feedback.hidden(store.FeedbackTrack, s.Artist, s.Track, cfg, now) {
+			continue
+		}
+		t := ForgottenTrack{
+			Artist:         s.Artist,
+			Track:          s.Track,
+			TotalScrobbles: s.TotalScrobbles,
+			FirstListen:    s.FirstListen,
+			LastListen:     s.LastListen,
+			DaysSinceLast:  int(now.Sub(s.LastListen).Hours() / 24),
+		}
+
+		t.Band = cfg.TrackBands.Band(t.TotalScrobbles)
+		if t.Band == "" {
+			continue
+		}
+		if peaks != nil {
+			t.Score = rediscoveryScore(t.TotalScrobbles, cfg.TrackBands, t.LastListen, peaks[store.AlbumKey{Artist: t.Artist, Name: t.Track}], now)
+		}
+
+		results[t.Band] = append(results[t.Band], t)
+	}
+
+	for band := range results {
+		sortTracks(results[band], cfg.SortBy)
+		if len(results[band]) > cfg.ResultsPerBand {
+			results[band] = results[band][:cfg.ResultsPerBand]
+		}
+	}
+
+	return results, nil
+}
+
 func sortArtists(artists []ForgottenArtist, sortBy string) {
 	sort.Slice(artists, func(i, j int) bool {
-		if sortBy == "listens" {
+		switch sortBy {
+		case SortListens:
 			return artists[i].TotalScrobbles > artists[j].TotalScrobbles
+		case SortScore:
+			return artists[i].Score > artists[j].Score
 		}
 		// Default to dormancy (longest dormancy first)
 		return artists[i].DaysSinceLast > artists[j].DaysSinceLast
@@ -202,9 +582,24 @@ func sortArtists(artists []ForgottenArtist, sortBy string) {
 
 func sortAlbums(albums []ForgottenAlbum, sortBy string) {
 	sort.Slice(albums, func(i, j int) bool {
-		if sortBy == "listens" {
+		switch sortBy {
+		case SortListens:
 			return albums[i].TotalScrobbles > albums[j].TotalScrobbles
+		case SortScore:
+			return albums[i].Score > albums[j].Score
 		}
 		return albums[i].DaysSinceLast > albums[j].DaysSinceLast
 	})
-}
\ No newline at end of file
+}
+
+func sortTracks(tracks []ForgottenTrack, sortBy string) {
+	sort.Slice(tracks, func(i, j int) bool {
+		switch sortBy {
+		case SortListens:
+			return tracks[i].TotalScrobbles > tracks[j].TotalScrobbles
+		case SortScore:
+			return tracks[i].Score > tracks[j].Score
+		}
+		return tracks[i].DaysSinceLast > tracks[j].DaysSinceLast
+	})
+}
@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -9,7 +10,7 @@ import (
 )
 
 // Better helper that handles everything
-func setupArtistAndListens(t *testing.T, db *store.Store, user string, id int, artist, album string, count int, lastListen time.Time) {
+func setupArtistAndListens(t *testing.T, db *store.SQLiteStore, user string, id int, artist, album string, count int, lastListen time.Time) {
 	t.Helper()
 	
 	// We use AddRecentTracks to insert data.
@@ -28,7 +29,7 @@ func setupArtistAndListens(t *testing.T, db *store.Store, user string, id int, a
 	}
 	
 	// AddRecentTracks batches.
-	if err := db.AddRecentTracks(user, tracks); err != nil {
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
 		t.Fatalf("AddRecentTracks failed: %v", err)
 	}
 	
@@ -41,7 +42,7 @@ func TestGetForgottenArtists(t *testing.T) {
 	defer db.Close()
 
 	user := "testuser"
-	db.CreateUser(user) // Ensure user exists
+	db.CreateUser(context.Background(), user) // Ensure user exists
 
 	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 	twoYearsAgo := now.AddDate(-2, 0, 0)
@@ -62,7 +63,7 @@ func TestGetForgottenArtists(t *testing.T) {
 		SortBy:             "dormancy",
 	}
 
-	results, err := GetForgottenArtists(db, user, config, now)
+	results, err := GetForgottenArtists(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenArtists failed: %v", err)
 	}
@@ -89,7 +90,7 @@ func TestGetForgottenAlbums(t *testing.T) {
 	defer db.Close()
 
 	user := "testuser"
-	db.CreateUser(user)
+	db.CreateUser(context.Background(), user)
 
 	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 	twoYearsAgo := now.AddDate(-2, 0, 0)
@@ -109,7 +110,7 @@ func TestGetForgottenAlbums(t *testing.T) {
 		SortBy:             "dormancy",
 	}
 
-	results, err := GetForgottenAlbums(db, user, config, now)
+	results, err := GetForgottenAlbums(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenAlbums failed: %v", err)
 	}
@@ -132,7 +133,7 @@ func TestGetForgottenArtistsWithDateRange(t *testing.T) {
 	defer db.Close()
 
 	user := "testuser"
-	db.CreateUser(user)
+	db.CreateUser(context.Background(), user)
 
 	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 
@@ -152,7 +153,7 @@ func TestGetForgottenArtistsWithDateRange(t *testing.T) {
 		SortBy:             "dormancy",
 	}
 
-	results, err := GetForgottenArtists(db, user, config, now)
+	results, err := GetForgottenArtists(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenArtists failed: %v", err)
 	}
@@ -169,7 +170,7 @@ func TestGetForgottenArtistsWithFirstListenDateRange(t *testing.T) {
 	defer db.Close()
 
 	user := "testuser"
-	db.CreateUser(user)
+	db.CreateUser(context.Background(), user)
 
 	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 
@@ -177,14 +178,14 @@ func TestGetForgottenArtistsWithFirstListenDateRange(t *testing.T) {
 	setupArtistAndListens(t, db, user, 1, "Artist A", "A1", ThresholdArtistObsession, now.AddDate(-2, 0, 0))
 	// Add one extra listen 10 years ago manually via AddRecentTracks
 	ts := now.AddDate(-10, 0, 0)
-	db.AddRecentTracks(user, []store.TrackImport{{
+	db.AddRecentTracks(context.Background(), user, []store.TrackImport{{
 		Artist: "Artist A", Album: "A1", TrackName: "Old Track", DateUTS: fmt.Sprintf("%d", ts.Unix()),
 	}})
 
 	// Artist B: First listen 3 years ago, last listen 2 years ago
 	setupArtistAndListens(t, db, user, 2, "Artist B", "B1", ThresholdArtistObsession, now.AddDate(-2, 0, 0))
 	ts = now.AddDate(-3, 0, 0)
-	db.AddRecentTracks(user, []store.TrackImport{{
+	db.AddRecentTracks(context.Background(), user, []store.TrackImport{{
 		Artist: "Artist B", Album: "B1", TrackName: "Old Track B", DateUTS: fmt.Sprintf("%d", ts.Unix()),
 	}})
 
@@ -198,7 +199,7 @@ func TestGetForgottenArtistsWithFirstListenDateRange(t *testing.T) {
 		SortBy:             "dormancy",
 	}
 
-	results, err := GetForgottenArtists(db, user, config, now)
+	results, err := GetForgottenArtists(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenArtists failed: %v", err)
 	}
@@ -215,7 +216,7 @@ func TestSortingAndLimits(t *testing.T) {
 	defer db.Close()
 
 	user := "testuser"
-	db.CreateUser(user)
+	db.CreateUser(context.Background(), user)
 
 	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 
@@ -241,7 +242,7 @@ func TestSortingAndLimits(t *testing.T) {
 		SortBy:             "dormancy",
 	}
 
-	results, err := GetForgottenArtists(db, user, config, now)
+	results, err := GetForgottenArtists(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenArtists failed: %v", err)
 	}
@@ -256,7 +257,7 @@ func TestSortingAndLimits(t *testing.T) {
 
 	// 2. Sort by Listens: Most scrobbles first
 	config.SortBy = "listens"
-	results, err = GetForgottenArtists(db, user, config, now)
+	results, err = GetForgottenArtists(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenArtists failed: %v", err)
 	}
@@ -267,7 +268,7 @@ func TestSortingAndLimits(t *testing.T) {
 
 	// 3. Limits
 	config.ResultsPerBand = 2
-	results, err = GetForgottenArtists(db, user, config, now)
+	results, err = GetForgottenArtists(context.Background(), db, user, config, now)
 	if err != nil {
 		t.Fatalf("GetForgottenArtists failed: %v", err)
 	}
@@ -280,3 +281,183 @@ func TestSortingAndLimits(t *testing.T) {
 		t.Errorf("Limit failed. Got: %s, %s", list[0].Artist, list[1].Artist)
 	}
 }
+
+func TestSortByScore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Artist A: 60 scrobbles, peak and last listen 3 years ago
+	setupArtistAndListens(t, db, user, 1, "Artist A", "A1", 60, now.AddDate(-3, 0, 0))
+	// Artist B: 80 scrobbles, peak and last listen 7 months ago
+	setupArtistAndListens(t, db, user, 2, "Artist B", "B1", 80, now.AddDate(0, -7, 0))
+	// Artist C: 45 scrobbles, peak 13 years ago but last listen 8 years ago
+	setupArtistAndListens(t, db, user, 3, "Artist C", "C1", 40, now.AddDate(-13, 0, 0))
+	setupArtistAndListens(t, db, user, 3, "Artist C", "C1", 5, now.AddDate(-8, 0, 0))
+
+	// Custom thresholds put everyone in the moderate band.
+	config := ForgottenConfig{
+		LastListenAfter:    time.Unix(0, 0),
+		LastListenBefore:   now.AddDate(0, 0, -90),
+		FirstListenAfter:   time.Unix(0, 0),
+		FirstListenBefore:  now.AddDate(1, 0, 0),
+		MinArtistScrobbles: 10,
+		ResultsPerBand:     10,
+		SortBy:             SortScore,
+		ArtistBands:        BandThresholds{Obsession: 1000, Strong: 500, Moderate: 15},
+	}
+
+	results, err := GetForgottenArtists(context.Background(), db, user, config, now)
+	if err != nil {
+		t.Fatalf("GetForgottenArtists failed: %v", err)
+	}
+	list := results[BandModerate]
+	if len(results) != 1 || len(list) != 3 {
+		t.Fatalf("Expected 3 moderate artists, got %+v", results)
+	}
+	// Scores are roughly A 3.0, B 1.96 and C 1.71, unlike either the dormancy or listens order.
+	if list[0].Artist != "Artist A" || list[1].Artist != "Artist B" || list[2].Artist != "Artist C" {
+		t.Errorf("Score sort failed. Got: %s (%g), %s (%g), %s (%g)", list[0].Artist, list[0].Score, list[1].Artist, list[1].Score, list[2].Artist, list[2].Score)
+	}
+}
+
+func TestPercentileBands(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	// Ten artists with 1 to 10 scrobbles, each on a single album.
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 10; i++ {
+		setupArtistAndListens(t, db, user, i, fmt.Sprintf("Artist %d", i), "Album", i, now.AddDate(-1, 0, i))
+	}
+
+	config := ForgottenConfig{BandMode: BandModePercentile, Percentiles: BandPercentiles{Obsession: 90, Strong: 50, Moderate: 20}}
+	resolved, err := ResolveForgottenBands(context.Background(), db, user, config)
+	if err != nil {
+		t.Fatalf("ResolveForgottenBands failed: %v", err)
+	}
+	want := BandThresholds{Obsession: 9, Strong: 5, Moderate: 2}
+	if resolved.ArtistBands != want || resolved.AlbumBands != want {
+		t.Errorf("Got artist bands %+v and album bands %+v, want %+v", resolved.ArtistBands, resolved.AlbumBands, want)
+	}
+	if resolved.BandMode != BandModeFixed {
+		t.Errorf("Expected resolved band mode to be fixed, got %q", resolved.BandMode)
+	}
+
+	// The bands start below the minimum scrobbles, which mustn't hide the lower bands.
+	config.LastListenAfter = time.Unix(0, 0)
+	config.LastListenBefore = now
+	config.FirstListenAfter = time.Unix(0, 0)
+	config.FirstListenBefore = now
+	config.MinArtistScrobbles = 10
+	config.ResultsPerBand = 10
+	artists, err := GetForgottenArtists(context.Background(), db, user, config, now)
+	if err != nil {
+		t.Fatalf("GetForgottenArtists failed: %v", err)
+	}
+	if len(artists[BandObsession]) != 2 || len(artists[BandStrong]) != 4 || len(artists[BandModerate]) != 3 {
+		t.Errorf("Expected 2 obsession, 4 strong and 3 moderate artists, got %+v", artists)
+	}
+
+	// Unset fixed thresholds use the defaults.
+	resolved, err = ResolveForgottenBands(context.Background(), db, user, ForgottenConfig{AlbumBands: BandThresholds{30, 20, 10}})
+	if err != nil {
+		t.Fatalf("ResolveForgottenBands failed: %v", err)
+	}
+	if resolved.ArtistBands != DefaultArtistBands || resolved.AlbumBands != (BandThresholds{30, 20, 10}) {
+		t.Errorf("Unexpected fixed bands: %+v, %+v", resolved.ArtistBands, resolved.AlbumBands)
+	}
+
+	for _, bad := range []ForgottenConfig{
+		{BandMode: "quantile"},
+		{ArtistBands: BandThresholds{10, 20, 30}},
+		{BandMode: BandModePercentile, Percentiles: BandPercentiles{Obsession: 150, Strong: 90, Moderate: 50}},
+	} {
+		if _, err := ResolveForgottenBands(context.Background(), db, user, bad); err == nil {
+			t.Errorf("ResolveForgottenBands(%+v) succeeded", bad)
+		}
+	}
+
+	if got := percentileBands(nil, DefaultBandPercentiles); got != (BandThresholds{1, 1, 1}) {
+		t.Errorf("percentileBands with no scrobbles = %+v, want all 1", got)
+	}
+}
+
+func TestForgottenTracksAndFeedback(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	twoYearsAgo := now.AddDate(-2, 0, 0)
+
+	setupArtistAndListens(t, db, user, 1, "Artist A", "A1", 50, twoYearsAgo)
+	setupArtistAndListens(t, db, user, 2, "Artist B", "B1", 20, twoYearsAgo)
+	setupArtistAndListens(t, db, user, 3, "Artist C", "C1", 20, twoYearsAgo)
+
+	for _, f := range []store.ForgottenFeedback{
+		{Kind: store.FeedbackArtist, Artist: "Artist A", State: store.FeedbackIgnored, Marked: now.AddDate(-1, 0, 0)},
+		{Kind: store.FeedbackAlbum, Artist: "Artist B", Name: "B1", State: store.FeedbackSnoozed, Marked: now.AddDate(0, -1, 0), Until: now.AddDate(0, 1, 0)},
+		// The snooze has ended.
+		{Kind: store.FeedbackAlbum, Artist: "Artist C", Name: "C1", State: store.FeedbackSnoozed, Marked: now.AddDate(0, -3, 0), Until: now.AddDate(0, 0, -1)},
+		// Revisited recently, so it isn't forgotten.
+		{Kind: store.FeedbackTrack, Artist: "Artist B", Name: "Track Artist B B1", State: store.FeedbackRevisited, Marked: now.AddDate(0, 0, -10)},
+		// Revisited long enough ago to be forgotten again.
+		{Kind: store.FeedbackTrack, Artist: "Artist C", Name: "Track Artist C C1", State: store.FeedbackRevisited, Marked: now.AddDate(0, 0, -100)},
+	} {
+		if err := db.SetForgottenFeedback(context.Background(), user, f); err != nil {
+			t.Fatalf("SetForgottenFeedback failed: %v", err)
+		}
+	}
+
+	config := ForgottenConfig{
+		LastListenAfter:    time.Unix(0, 0),
+		LastListenBefore:   now.AddDate(0, 0, -90),
+		FirstListenAfter:   time.Unix(0, 0),
+		FirstListenBefore:  now.AddDate(1, 0, 0),
+		MinArtistScrobbles: 10,
+		MinAlbumScrobbles:  5,
+		MinTrackScrobbles:  5,
+		ResultsPerBand:     10,
+		SortBy:             SortDormancy,
+	}
+
+	artists, err := GetForgottenArtists(context.Background(), db, user, config, now)
+	if err != nil {
+		t.Fatalf("GetForgottenArtists failed: %v", err)
+	}
+	if len(artists[BandStrong]) != 0 || len(artists[BandModerate]) != 2 {
+		t.Errorf("Expected the ignored Artist A to be hidden, got %+v", artists)
+	}
+
+	albums, err := GetForgottenAlbums(context.Background(), db, user, config, now)
+	if err != nil {
+		t.Fatalf("GetForgottenAlbums failed: %v", err)
+	}
+	if len(albums[BandStrong]) != 1 || albums[BandStrong][0].Album != "A1" {
+		t.Errorf("Expected A1 as strong, since only its artist is ignored, got %+v", albums[BandStrong])
+	}
+	if len(albums[BandModerate]) != 1 || albums[BandModerate][0].Album != "C1" {
+		t.Errorf("Expected only C1 as moderate, with B1 snoozed, got %+v", albums[BandModerate])
+	}
+
+	tracks, err := GetForgottenTracks(context.Background(), db, user, config, now)
+	if err != nil {
+		t.Fatalf("GetForgottenTracks failed: %v", err)
+	}
+	if len(tracks[BandObsession]) != 1 || tracks[BandObsession][0].Artist != "Artist A" {
+		t.Errorf("Expected Artist A's track as an obsession, got %+v", tracks[BandObsession])
+	}
+	if len(tracks[BandStrong]) != 1 || tracks[BandStrong][0].Track != "Track Artist C C1" {
+		t.Errorf("Expected only Artist C's track as strong, got %+v", tracks[BandStrong])
+	}
+}
@@ -0,0 +1,171 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+const (
+	// DefaultMinAlbumCoverage is the default fraction of an album's known tracks which a run must
+	// cover to count as a play-through.
+	DefaultMinAlbumCoverage = 0.8
+	// DefaultMinAlbumTracks is the default minimum number of known tracks for an album to be
+	// played through, so that singles and EPs don't count.
+	DefaultMinAlbumTracks = 4
+)
+
+type PlayThroughConfig struct {
+	// Inactivity gap which ends a run, as for sessions.
+	Gap         time.Duration
+	MinCoverage float64
+	MinTracks   int
+	// Number of most played-through albums to return.
+	Albums int
+}
+
+// isPlayThrough returns whether a run covering tracks of an album's known tracks is a play-through.
+func (c PlayThroughConfig) isPlayThrough(tracks, known int) bool {
+	return known >= c.MinTracks && float64(tracks) >= c.MinCoverage*float64(known)
+}
+
+// PlayThrough is a run of consecutive listens from one album which covered most of its known
+// tracks.
+type PlayThrough struct {
+	Artist      string
+	Album       string
+	Start       time.Time
+	Tracks      int
+	KnownTracks int
+}
+
+// AlbumPlayThroughs is how often an album was played through.
+type AlbumPlayThroughs struct {
+	Artist       string
+	Album        string
+	PlayThroughs int
+	KnownTracks  int
+	LastPlayed   time.Time
+}
+
+type PlayThroughStats struct {
+	Listens int
+	// Listens which were part of a play-through, and their share of all listens.
+	AlbumListens int
+	Share        float64
+	// Play-throughs, oldest first.
+	PlayThroughs []PlayThrough
+	// Albums with the most play-throughs, most first.
+	Albums []AlbumPlayThroughs
+}
+
+// GetPlayThroughStats finds the user's album play-throughs with start <= date < end.
+//
+// last.fm doesn't give track numbers, so an album's known tracks are the ones which have been
+// scrobbled, and track order isn't checked: a run is any listens from the same album, in the same
+// session, without a track repeating. A run is a play-through if the album has at least
+// MinTracks known tracks and the run covers at least MinCoverage of them.
+func GetPlayThroughStats(ctx context.Context, db store.Store, user string, start, end time.Time, config PlayThroughConfig) (PlayThroughStats, error) {
+	sessions, err := GetSessions(ctx, db, user, start, end, config.Gap)
+	if err != nil {
+		return PlayThroughStats{}, err
+	}
+
+	var stats PlayThroughStats
+	knownTracks := make(map[store.AlbumKey]int)
+	for _, s := range sessions {
+		stats.Listens += len(s.Listens)
+		for _, run := range albumRuns(s.Listens) {
+			key := store.AlbumKey{Artist: run[0].Artist, Name: run[0].Album}
+			known, ok := knownTracks[key]
+			if !ok {
+				tracks, err := db.GetAlbumTracks(ctx, key.Artist, key.Name)
+				if err != nil {
+					return PlayThroughStats{}, err
+				}
+				known = len(tracks)
+				knownTracks[key] = known
+			}
+			if !config.isPlayThrough(len(run), known) {
+				continue
+			}
+			stats.PlayThroughs = append(stats.PlayThroughs, PlayThrough{
+				Artist:      key.Artist,
+				Album:       key.Name,
+				Start:       run[0].Time,
+				Tracks:      len(run),
+				KnownTracks: known,
+			})
+			stats.AlbumListens += len(run)
+		}
+	}
+	if stats.Listens > 0 {
+		stats.Share = float64(stats.AlbumListens) / float64(stats.Listens)
+	}
+	stats.Albums = topPlayThroughAlbums(stats.PlayThroughs, config.Albums)
+	return stats, nil
+}
+
+// albumRuns splits a session into runs of consecutive listens from the same album, starting a new
+// run when a track repeats. Runs are all distinct tracks, so their length is also the number of
+// tracks they cover. Listens without an album aren't part of any run.
+func albumRuns(listens []store.TrackListen) [][]store.TrackListen {
+	var runs [][]store.TrackListen
+	var run []store.TrackListen
+	played := make(map[string]bool)
+	for _, l := range listens {
+		if len(run) == 0 || l.Artist != run[0].Artist || l.Album != run[0].Album || played[l.Track] {
+			if len(run) > 0 {
+				runs = append(runs, run)
+			}
+			run = nil
+			played = make(map[string]bool)
+		}
+		if l.Album == "" {
+			continue
+		}
+		run = append(run, l)
+		played[l.Track] = true
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func topPlayThroughAlbums(playThroughs []PlayThrough, limit int) []AlbumPlayThroughs {
+	albums := make(map[store.AlbumKey]*AlbumPlayThroughs)
+	for _, p := range playThroughs {
+		key := store.AlbumKey{Artist: p.Artist, Name: p.Album}
+		a, ok := albums[key]
+		if !ok {
+			a = &AlbumPlayThroughs{Artist: p.Artist, Album: p.Album, KnownTracks: p.KnownTracks}
+			albums[key] = a
+		}
+		a.PlayThroughs++
+		a.LastPlayed = p.Start
+	}
+
+	var results []AlbumPlayThroughs
+	for _, a := range albums {
+		results = append(results, *a)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].PlayThroughs != results[j].PlayThroughs {
+			return results[i].PlayThroughs > results[j].PlayThroughs
+		}
+		if !results[i].LastPlayed.Equal(results[j].LastPlayed) {
+			return results[i].LastPlayed.After(results[j].LastPlayed)
+		}
+		if results[i].Artist != results[j].Artist {
+			return results[i].Artist < results[j].Artist
+		}
+		return results[i].Album < results[j].Album
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
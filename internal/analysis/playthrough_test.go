@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestPlayThroughs(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	base := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Minutes after base, and the album and track listened to. Album "Long" has 5 known tracks,
+	// "Short" has 3 and "Other" has 4.
+	listens := []struct {
+		minute int
+		album  string
+		track  string
+	}{
+		// A play-through of Long out of order, covering 4 of 5 tracks.
+		{0, "Long", "3"}, {4, "Long", "1"}, {8, "Long", "2"}, {12, "Long", "4"},
+		// A repeated track splits the run, so neither half covers enough of Long.
+		{16, "Long", "1"}, {20, "Long", "2"}, {24, "Long", "5"}, {28, "Long", "2"}, {32, "Long", "3"},
+		// Short is played through, but has too few tracks to count.
+		{36, "Short", "1"}, {40, "Short", "2"}, {44, "Short", "3"},
+		// A session gap splits the run.
+		{48, "Other", "1"}, {52, "Other", "2"}, {53, "Other", "3"}, {90, "Other", "4"},
+		// A full play-through of Long.
+		{200, "Long", "1"}, {204, "Long", "2"}, {208, "Long", "3"}, {212, "Long", "4"}, {216, "Long", "5"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts := base.Add(time.Duration(l.minute) * time.Minute)
+		tracks = append(tracks, store.TrackImport{Artist: "A", Album: l.album, TrackName: l.track, DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	config := PlayThroughConfig{Gap: DefaultSessionGap, MinCoverage: DefaultMinAlbumCoverage, MinTracks: DefaultMinAlbumTracks}
+	stats, err := GetPlayThroughStats(context.Background(), db, user, base, base.AddDate(0, 0, 1), config)
+	if err != nil {
+		t.Fatalf("GetPlayThroughStats: %v", err)
+	}
+
+	// The store returns local times.
+	for i := range stats.PlayThroughs {
+		stats.PlayThroughs[i].Start = stats.PlayThroughs[i].Start.UTC()
+	}
+	for i := range stats.Albums {
+		stats.Albums[i].LastPlayed = stats.Albums[i].LastPlayed.UTC()
+	}
+	want := []PlayThrough{
+		{Artist: "A", Album: "Long", Start: base, Tracks: 4, KnownTracks: 5},
+		{Artist: "A", Album: "Long", Start: base.Add(200 * time.Minute), Tracks: 5, KnownTracks: 5},
+	}
+	if !reflect.DeepEqual(stats.PlayThroughs, want) {
+		t.Errorf("PlayThroughs = %+v, want %+v", stats.PlayThroughs, want)
+	}
+	if stats.Listens != len(listens) || stats.AlbumListens != 9 {
+		t.Errorf("Listens = %d, AlbumListens = %d, want %d, 9", stats.Listens, stats.AlbumListens, len(listens))
+	}
+	if want := 9.0 / float64(len(listens)); stats.Share != want {
+		t.Errorf("Share = %v, want %v", stats.Share, want)
+	}
+	wantAlbums := []AlbumPlayThroughs{
+		{Artist: "A", Album: "Long", PlayThroughs: 2, KnownTracks: 5, LastPlayed: base.Add(200 * time.Minute)},
+	}
+	if !reflect.DeepEqual(stats.Albums, wantAlbums) {
+		t.Errorf("Albums = %+v, want %+v", stats.Albums, wantAlbums)
+	}
+}
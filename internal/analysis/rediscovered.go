@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// DefaultMinRediscoveredPlays is the default number of plays after a dormancy for an artist or album
+// to count as rediscovered.
+const DefaultMinRediscoveredPlays = 3
+
+type RediscoveredConfig struct {
+	// The shortest gap between listens which counts as dormant.
+	MinDormancy time.Duration
+	// The fewest plays in the period, from the first listen after the dormancy on.
+	MinPlays       int64
+	ResultsPerBand int
+}
+
+// Rediscovery is an artist or album which came back after going unplayed for a long time. Album is
+// empty for artists.
+type Rediscovery struct {
+	Artist string
+	Album  string
+	// Plays in the period from the first listen after the dormancy on.
+	Plays int64
+	// Plays before the dormancy, which decide the interest band.
+	PreviousScrobbles int64
+	// The last listen before the dormancy and the first after it.
+	PreviousListen time.Time
+	Rediscovered   time.Time
+	DormantDays    int
+	// The month with the most plays before the dormancy, like "2012-03", the earliest if there's a
+	// tie. Months start in UTC.
+	PeakMonth      string
+	PeakMonthPlays int64
+	Band           string
+}
+
+// rediscoveryCounts follows one artist or album through the listens.
+type rediscoveryCounts struct {
+	last   time.Time
+	before int64
+	months map[time.Time]int64
+	found  *Rediscovery
+}
+
+// GetRediscoveredArtists returns artists listened to with start <= date < end after a dormancy of at
+// least MinDormancy, by interest band.
+func GetRediscoveredArtists(ctx context.Context, db store.Store, user string, start, end time.Time, cfg RediscoveredConfig) (map[string][]Rediscovery, error) {
+	return getRediscoveries(ctx, db, user, start, end, cfg, true)
+}
+
+// GetRediscoveredAlbums is like GetRediscoveredArtists, for albums.
+func GetRediscoveredAlbums(ctx context.Context, db store.Store, user string, start, end time.Time, cfg RediscoveredConfig) (map[string][]Rediscovery, error) {
+	return getRediscoveries(ctx, db, user, start, end, cfg, false)
+}
+
+func getRediscoveries(ctx context.Context, db store.Store, user string, start, end time.Time, cfg RediscoveredConfig, isArtist bool) (map[string][]Rediscovery, error) {
+	listens, err := db.GetListenHistory(ctx, user, time.Unix(0, 0), end)
+	if err != nil {
+		return nil, fmt.Errorf("getting listens: %w", err)
+	}
+
+	counts := make(map[store.AlbumKey]*rediscoveryCounts)
+	for _, l := range listens {
+		key := store.AlbumKey{Artist: l.Artist}
+		if !isArtist {
+			if l.Album == "" {
+				continue
+			}
+			key.Name = l.Album
+		}
+		c := counts[key]
+		if c == nil {
+			c = &rediscoveryCounts{months: make(map[time.Time]int64)}
+			counts[key] = c
+		}
+
+		if c.found != nil {
+			c.found.Plays++
+			continue
+		}
+		if c.before > 0 && !l.Time.Before(start) && l.Time.Sub(c.last) >= cfg.MinDormancy {
+			c.found = &Rediscovery{
+				Artist:         key.Artist,
+				Album:          key.Name,
+				Plays:          1,
+				PreviousListen: c.last,
+				Rediscovered:   l.Time,
+				DormantDays:    int(l.Time.Sub(c.last) / (24 * time.Hour)),
+			}
+			continue
+		}
+		c.last = l.Time
+		c.before++
+		c.months[startOfMonth(l.Time, time.UTC)]++
+	}
+
+	results := make(map[string][]Rediscovery)
+	for _, c := range counts {
+		r := c.found
+		if r == nil || r.Plays < cfg.MinPlays {
+			continue
+		}
+		r.PreviousScrobbles = c.before
+		r.Band = determineBand(c.before, isArtist)
+		if r.Band == "" {
+			continue
+		}
+		var peak time.Time
+		for month, n := range c.months {
+			if n > r.PeakMonthPlays || (n == r.PeakMonthPlays && month.Before(peak)) {
+				peak, r.PeakMonthPlays = month, n
+			}
+		}
+		r.PeakMonth = peak.Format("2006-01")
+		results[r.Band] = append(results[r.Band], *r)
+	}
+
+	for band, items := range results {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].Plays != items[j].Plays {
+				return items[i].Plays > items[j].Plays
+			}
+			if items[i].DormantDays != items[j].DormantDays {
+				return items[i].DormantDays > items[j].DormantDays
+			}
+			if items[i].Artist != items[j].Artist {
+				return items[i].Artist < items[j].Artist
+			}
+			return items[i].Album < items[j].Album
+		})
+		results[band] = items[:min(len(items), cfg.ResultsPerBand)]
+	}
+	return results, nil
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetRediscovered(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := "testuser"
+	if err := db.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	back := start.AddDate(0, 0, 10)
+	dormant := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	setupArtistAndListens(t, db, user, 1, "Artist A", "Album A1", ThresholdArtistObsession, dormant)
+	setupArtistAndListens(t, db, user, 1, "Artist A", "Album A1", 5, back)
+	// Listened to recently, so not dormant.
+	setupArtistAndListens(t, db, user, 2, "Artist B", "Album B1", ThresholdArtistModerate, start.AddDate(0, 0, -20))
+	setupArtistAndListens(t, db, user, 2, "Artist B", "Album B1", 5, back)
+	// Too few plays since coming back.
+	setupArtistAndListens(t, db, user, 3, "Artist C", "Album C1", ThresholdArtistModerate, dormant)
+	setupArtistAndListens(t, db, user, 3, "Artist C", "Album C1", 1, back)
+	// Too few plays before the dormancy for any band.
+	setupArtistAndListens(t, db, user, 4, "Artist D", "Album D1", 5, dormant)
+	setupArtistAndListens(t, db, user, 4, "Artist D", "Album D1", 5, back)
+	// Comes back after the period.
+	setupArtistAndListens(t, db, user, 5, "Artist E", "Album E1", ThresholdArtistStrong, dormant)
+	setupArtistAndListens(t, db, user, 5, "Artist E", "Album E1", 5, end.AddDate(0, 0, 1))
+
+	cfg := RediscoveredConfig{MinDormancy: RediscoveryGap, MinPlays: DefaultMinRediscoveredPlays, ResultsPerBand: 10}
+	artists, err := GetRediscoveredArtists(context.Background(), db, user, start, end, cfg)
+	if err != nil {
+		t.Fatalf("GetRediscoveredArtists: %v", err)
+	}
+	if len(artists) != 1 || len(artists[BandObsession]) != 1 {
+		t.Fatalf("Expected only Artist A as an obsession, got %+v", artists)
+	}
+	a := artists[BandObsession][0]
+	if a.Artist != "Artist A" || a.Plays != 5 || a.PreviousScrobbles != ThresholdArtistObsession {
+		t.Errorf("Unexpected rediscovery %+v", a)
+	}
+	if !a.PreviousListen.Equal(dormant) {
+		t.Errorf("PreviousListen = %v, want %v", a.PreviousListen, dormant)
+	}
+	if want := int(back.Add(-4*time.Minute).Sub(dormant) / (24 * time.Hour)); a.DormantDays != want {
+		t.Errorf("DormantDays = %d, want %d", a.DormantDays, want)
+	}
+	if a.PeakMonth != "2020-06" || a.PeakMonthPlays != ThresholdArtistObsession {
+		t.Errorf("Peak = %s with %d plays, want 2020-06 with %d", a.PeakMonth, a.PeakMonthPlays, ThresholdArtistObsession)
+	}
+
+	albums, err := GetRediscoveredAlbums(context.Background(), db, user, start, end, cfg)
+	if err != nil {
+		t.Fatalf("GetRediscoveredAlbums: %v", err)
+	}
+	if len(albums[BandObsession]) != 1 || albums[BandObsession][0].Album != "Album A1" {
+		t.Errorf("Expected Album A1 as an obsession, got %+v", albums)
+	}
+	// Album C1 has enough plays for the moderate album band, but too few since coming back.
+	if len(albums) != 1 {
+		t.Errorf("Expected only one band, got %+v", albums)
+	}
+}
@@ -0,0 +1,189 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// DefaultSessionGap is the default inactivity gap which ends a listening session.
+const DefaultSessionGap = 30 * time.Minute
+
+// Session is a run of listens with no gap between consecutive listens longer than the session gap.
+type Session struct {
+	Listens []store.TrackListen
+}
+
+func (s Session) Start() time.Time {
+	return s.Listens[0].Time
+}
+
+// Duration is the time from the first listen to the last. Listens are timestamped when the track
+// started, so this doesn't include the last track.
+func (s Session) Duration() time.Duration {
+	return s.Listens[len(s.Listens)-1].Time.Sub(s.Listens[0].Time)
+}
+
+// SplitSessions splits listens, oldest first, into sessions wherever consecutive listens are more
+// than gap apart.
+func SplitSessions(listens []store.TrackListen, gap time.Duration) []Session {
+	var sessions []Session
+	for i, l := range listens {
+		if i == 0 || l.Time.Sub(listens[i-1].Time) > gap {
+			sessions = append(sessions, Session{})
+		}
+		last := &sessions[len(sessions)-1]
+		last.Listens = append(last.Listens, l)
+	}
+	return sessions
+}
+
+// GetSessions splits the user's listens with start <= date < end into sessions. Sessions which span
+// start or end are cut there.
+func GetSessions(ctx context.Context, db store.Store, user string, start, end time.Time, gap time.Duration) ([]Session, error) {
+	listens, err := db.GetListenHistory(ctx, user, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return SplitSessions(listens, gap), nil
+}
+
+type SessionConfig struct {
+	Gap time.Duration
+	// Number of longest sessions to return.
+	Longest int
+	// Number of artists which most often start and end sessions to return.
+	Artists int
+}
+
+// SessionBucket is the number of sessions with a duration in [Min, Max). Max is 0 for the last
+// bucket.
+type SessionBucket struct {
+	Min      time.Duration
+	Max      time.Duration
+	Sessions int
+}
+
+var sessionBucketBounds = []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 4 * time.Hour}
+
+// SessionArtist is how often an artist starts or ends a session. Rate is the fraction of the
+// sessions the artist was played in that they started or ended.
+type SessionArtist struct {
+	Artist   string
+	Sessions int
+	Rate     float64
+}
+
+type SessionStats struct {
+	Sessions        int
+	Listens         int
+	MeanDuration    time.Duration
+	MedianDuration  time.Duration
+	MeanListens     float64
+	MedianListens   float64
+	DurationBuckets []SessionBucket
+	// Longest sessions, longest first.
+	Longest []Session
+	// Artists which most often start and end sessions. Sessions with a single listen aren't counted.
+	Openers []SessionArtist
+	Closers []SessionArtist
+}
+
+// GetSessionStats summarises the user's listening sessions between start and end.
+func GetSessionStats(ctx context.Context, db store.Store, user string, start, end time.Time, config SessionConfig) (SessionStats, error) {
+	sessions, err := GetSessions(ctx, db, user, start, end, config.Gap)
+	if err != nil {
+		return SessionStats{}, err
+	}
+	return sessionStats(sessions, config), nil
+}
+
+func sessionStats(sessions []Session, config SessionConfig) (stats SessionStats) {
+	stats.Sessions = len(sessions)
+	for i, bound := range sessionBucketBounds {
+		var lower time.Duration
+		if i > 0 {
+			lower = sessionBucketBounds[i-1]
+		}
+		stats.DurationBuckets = append(stats.DurationBuckets, SessionBucket{Min: lower, Max: bound})
+	}
+	stats.DurationBuckets = append(stats.DurationBuckets, SessionBucket{Min: sessionBucketBounds[len(sessionBucketBounds)-1]})
+	if len(sessions) == 0 {
+		return
+	}
+
+	var durations []float64
+	var lengths []float64
+	var totalDuration time.Duration
+	played := make(map[string]int)
+	opened := make(map[string]int)
+	closed := make(map[string]int)
+	for _, s := range sessions {
+		d := s.Duration()
+		totalDuration += d
+		durations = append(durations, float64(d))
+		lengths = append(lengths, float64(len(s.Listens)))
+		stats.Listens += len(s.Listens)
+
+		bucket := sort.Search(len(sessionBucketBounds), func(i int) bool { return d < sessionBucketBounds[i] })
+		stats.DurationBuckets[bucket].Sessions++
+
+		if len(s.Listens) < 2 {
+			continue
+		}
+		artists := make(map[string]bool)
+		for _, l := range s.Listens {
+			artists[l.Artist] = true
+		}
+		for artist := range artists {
+			played[artist]++
+		}
+		opened[s.Listens[0].Artist]++
+		closed[s.Listens[len(s.Listens)-1].Artist]++
+	}
+
+	stats.MeanDuration = totalDuration / time.Duration(len(sessions))
+	stats.MedianDuration = time.Duration(median(durations))
+	stats.MeanListens = float64(stats.Listens) / float64(len(sessions))
+	stats.MedianListens = median(lengths)
+
+	longest := make([]Session, len(sessions))
+	copy(longest, sessions)
+	sort.SliceStable(longest, func(i, j int) bool {
+		return longest[i].Duration() > longest[j].Duration()
+	})
+	stats.Longest = longest[:min(len(longest), config.Longest)]
+
+	stats.Openers = topSessionArtists(opened, played, config.Artists)
+	stats.Closers = topSessionArtists(closed, played, config.Artists)
+	return
+}
+
+func topSessionArtists(counts map[string]int, played map[string]int, limit int) []SessionArtist {
+	var artists []SessionArtist
+	for artist, count := range counts {
+		artists = append(artists, SessionArtist{Artist: artist, Sessions: count, Rate: float64(count) / float64(played[artist])})
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if artists[i].Sessions != artists[j].Sessions {
+			return artists[i].Sessions > artists[j].Sessions
+		}
+		return artists[i].Artist < artists[j].Artist
+	})
+	return artists[:min(len(artists), limit)]
+}
+
+// median returns the median of values, which it sorts.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
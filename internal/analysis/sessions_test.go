@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestSessions(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	base := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Minutes after base, and the artist listened to.
+	listens := []struct {
+		minute int
+		artist string
+	}{
+		// 0-20 minutes: A opens, B closes.
+		{0, "A"}, {10, "B"}, {20, "B"},
+		// A gap of 31 minutes ends a session, but gaps of exactly 30 minutes don't: 51-171 minutes.
+		{51, "A"}, {81, "C"}, {111, "C"}, {141, "C"}, {171, "C"},
+		// A single listen.
+		{300, "D"},
+		// 400-404 minutes.
+		{400, "A"}, {404, "B"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts := base.Add(time.Duration(l.minute) * time.Minute)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: "", TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	sessions, err := GetSessions(context.Background(), db, user, base, base.AddDate(0, 0, 1), DefaultSessionGap)
+	if err != nil {
+		t.Fatalf("GetSessions: %v", err)
+	}
+	var lengths []int
+	for _, s := range sessions {
+		lengths = append(lengths, len(s.Listens))
+	}
+	if want := []int{3, 5, 1, 2}; !reflect.DeepEqual(lengths, want) {
+		t.Fatalf("GetSessions() lengths = %v, want %v", lengths, want)
+	}
+
+	stats, err := GetSessionStats(context.Background(), db, user, base, base.AddDate(0, 0, 1), SessionConfig{Gap: DefaultSessionGap, Longest: 1, Artists: 2})
+	if err != nil {
+		t.Fatalf("GetSessionStats: %v", err)
+	}
+	if stats.Sessions != 4 || stats.Listens != 11 {
+		t.Errorf("GetSessionStats() = %d sessions and %d listens, want 4 and 11", stats.Sessions, stats.Listens)
+	}
+	// Durations are 20m, 120m, 0 and 4m.
+	if want := 36 * time.Minute; stats.MeanDuration != want {
+		t.Errorf("MeanDuration = %v, want %v", stats.MeanDuration, want)
+	}
+	if want := 12 * time.Minute; stats.MedianDuration != want {
+		t.Errorf("MedianDuration = %v, want %v", stats.MedianDuration, want)
+	}
+	if stats.MedianListens != 2.5 {
+		t.Errorf("MedianListens = %v, want 2.5", stats.MedianListens)
+	}
+	var buckets []int
+	for _, b := range stats.DurationBuckets {
+		buckets = append(buckets, b.Sessions)
+	}
+	if want := []int{2, 1, 0, 0, 1, 0}; !reflect.DeepEqual(buckets, want) {
+		t.Errorf("DurationBuckets = %v, want %v", buckets, want)
+	}
+	if len(stats.Longest) != 1 || stats.Longest[0].Duration() != 2*time.Hour {
+		t.Errorf("Longest = %+v, want the 2 hour session", stats.Longest)
+	}
+	if want := []SessionArtist{{"A", 3, 1}}; !reflect.DeepEqual(stats.Openers[:1], want) {
+		t.Errorf("Openers = %+v, want A first", stats.Openers)
+	}
+	if want := []SessionArtist{{"B", 2, 1}, {"C", 1, 1}}; !reflect.DeepEqual(stats.Closers, want) {
+		t.Errorf("Closers = %+v, want %+v", stats.Closers, want)
+	}
+}
@@ -0,0 +1,187 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// DayCount is the number of listens on a day. Day is midnight at the start of the day.
+type DayCount struct {
+	Day     time.Time
+	Listens int
+}
+
+// Streak is a run of consecutive days with at least one listen. The zero Streak is no streak.
+type Streak struct {
+	Start time.Time
+	Days  int
+}
+
+// End is the last day of the streak.
+func (s Streak) End() time.Time {
+	return s.Start.AddDate(0, 0, s.Days-1)
+}
+
+// ArtistStreak is an artist's longest run of consecutive days with a listen to them.
+type ArtistStreak struct {
+	Artist string
+	Streak
+}
+
+type StreakConfig struct {
+	// Days start at midnight in Location.
+	Location *time.Location
+	// Number of artists with the longest streaks to return.
+	Artists int
+}
+
+type StreakStats struct {
+	Longest Streak
+	// The streak which includes the last day, or the day before it if there are no listens on the
+	// last day yet.
+	Current Streak
+	// Artists with the longest streaks, longest first.
+	Artists []ArtistStreak
+	// Listens per day for the year up to end, for a calendar heatmap.
+	Calendar []DayCount
+}
+
+// startOfDay returns midnight at the start of t's day in loc.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// lastDay returns the start of the last day in loc which begins before end.
+func lastDay(end time.Time, loc *time.Location) time.Time {
+	return startOfDay(end.Add(-time.Nanosecond), loc)
+}
+
+// DailyCounts buckets listens into days in loc, returning a count for every day which overlaps
+// [start, end), including days without listens.
+func DailyCounts(listens []time.Time, start, end time.Time, loc *time.Location) []DayCount {
+	first, last := startOfDay(start, loc), lastDay(end, loc).AddDate(0, 0, 1)
+	index := make(map[time.Time]int)
+	var days []DayCount
+	for d := first; d.Before(last); d = d.AddDate(0, 0, 1) {
+		index[d] = len(days)
+		days = append(days, DayCount{Day: d})
+	}
+	for _, l := range listens {
+		if i, ok := index[startOfDay(l, loc)]; ok {
+			days[i].Listens++
+		}
+	}
+	return days
+}
+
+// LongestStreak returns the earliest of the longest runs of days with listens.
+func LongestStreak(days []DayCount) (longest Streak) {
+	var run Streak
+	for _, d := range days {
+		if d.Listens == 0 {
+			run = Streak{}
+			continue
+		}
+		if run.Days == 0 {
+			run.Start = d.Day
+		}
+		run.Days++
+		if run.Days > longest.Days {
+			longest = run
+		}
+	}
+	return
+}
+
+// CurrentStreak returns the run of days with listens which ends on the last day. The last day may
+// still be in progress, so if it has no listens the run ending the day before counts.
+func CurrentStreak(days []DayCount) (current Streak) {
+	end := len(days) - 1
+	if end >= 0 && days[end].Listens == 0 {
+		end--
+	}
+	for i := end; i >= 0 && days[i].Listens > 0; i-- {
+		current = Streak{Start: days[i].Day, Days: current.Days + 1}
+	}
+	return
+}
+
+// ArtistStreaks returns each artist's longest streak, longest first, keeping the top limit.
+func ArtistStreaks(listens []store.TrackListen, loc *time.Location, limit int) []ArtistStreak {
+	type run struct {
+		last    time.Time
+		current Streak
+		longest Streak
+	}
+	runs := make(map[string]*run)
+	for _, l := range listens {
+		day := startOfDay(l.Time, loc)
+		r, ok := runs[l.Artist]
+		if !ok {
+			r = &run{}
+			runs[l.Artist] = r
+		}
+		switch {
+		case r.current.Days > 0 && day.Equal(r.last):
+			continue
+		case r.current.Days > 0 && day.Equal(r.last.AddDate(0, 0, 1)):
+			r.current.Days++
+		default:
+			r.current = Streak{Start: day, Days: 1}
+		}
+		r.last = day
+		if r.current.Days > r.longest.Days {
+			r.longest = r.current
+		}
+	}
+
+	var streaks []ArtistStreak
+	for artist, r := range runs {
+		streaks = append(streaks, ArtistStreak{Artist: artist, Streak: r.longest})
+	}
+	sort.Slice(streaks, func(i, j int) bool {
+		if streaks[i].Days != streaks[j].Days {
+			return streaks[i].Days > streaks[j].Days
+		}
+		if !streaks[i].Start.Equal(streaks[j].Start) {
+			return streaks[i].Start.After(streaks[j].Start)
+		}
+		return streaks[i].Artist < streaks[j].Artist
+	})
+	return streaks[:min(len(streaks), limit)]
+}
+
+// GetStreakStats finds the user's listening streaks over all of their listens before end, and
+// their daily listens for the year before end.
+func GetStreakStats(ctx context.Context, db store.Store, user string, end time.Time, config StreakConfig) (stats StreakStats, err error) {
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	listens, err := db.GetListensInRange(ctx, user, time.Unix(0, 0), end)
+	if err != nil {
+		return stats, err
+	}
+	calendarStart := lastDay(end, loc).AddDate(-1, 0, 1)
+	if len(listens) == 0 {
+		stats.Calendar = DailyCounts(nil, calendarStart, end, loc)
+		return stats, nil
+	}
+	first := listens[0]
+
+	days := DailyCounts(listens, first, end, loc)
+	stats.Longest = LongestStreak(days)
+	stats.Current = CurrentStreak(days)
+
+	history, err := db.GetListenHistory(ctx, user, first, end)
+	if err != nil {
+		return stats, err
+	}
+	stats.Artists = ArtistStreaks(history, loc, config.Artists)
+	stats.Calendar = DailyCounts(listens, calendarStart, end, loc)
+	return stats, nil
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestStreaks(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	db.CreateUser(context.Background(), user)
+
+	day := func(d int) time.Time {
+		return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	// Days in January 2020 with listens, and the artist listened to.
+	listens := []struct {
+		day    int
+		hour   int
+		artist string
+	}{
+		// A three day streak of A, with B on the first two days.
+		{1, 10, "A"}, {1, 23, "B"}, {2, 0, "B"}, {2, 12, "A"}, {3, 9, "A"},
+		// A four day streak, with C on the last three.
+		{10, 8, "A"}, {11, 8, "C"}, {12, 8, "C"}, {12, 20, "C"}, {13, 8, "C"},
+		// The current streak ends on the 19th, with no listens on the 20th yet.
+		{18, 8, "B"}, {19, 8, "B"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts := day(l.day).Add(time.Duration(l.hour) * time.Hour)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	stats, err := GetStreakStats(context.Background(), db, user, day(21), StreakConfig{Location: time.UTC, Artists: 2})
+	if err != nil {
+		t.Fatalf("GetStreakStats: %v", err)
+	}
+	if !stats.Longest.Start.Equal(day(10)) || stats.Longest.Days != 4 {
+		t.Errorf("Longest = %+v, want 4 days from %v", stats.Longest, day(10))
+	}
+	if !stats.Longest.End().Equal(day(13)) {
+		t.Errorf("Longest.End() = %v, want %v", stats.Longest.End(), day(13))
+	}
+	if !stats.Current.Start.Equal(day(18)) || stats.Current.Days != 2 {
+		t.Errorf("Current = %+v, want 2 days from %v", stats.Current, day(18))
+	}
+
+	// A and C both have three day streaks, and C's is more recent.
+	if len(stats.Artists) != 2 {
+		t.Fatalf("Artists = %+v, want 2", stats.Artists)
+	}
+	for i, want := range []struct {
+		artist string
+		start  time.Time
+	}{{"C", day(11)}, {"A", day(1)}} {
+		got := stats.Artists[i]
+		if got.Artist != want.artist || !got.Start.Equal(want.start) || got.Days != 3 {
+			t.Errorf("Artists[%d] = %+v, want %s for 3 days from %v", i, got, want.artist, want.start)
+		}
+	}
+
+	// The calendar covers the year up to 2020-01-20.
+	if len(stats.Calendar) != 365 {
+		t.Fatalf("len(Calendar) = %d, want 365", len(stats.Calendar))
+	}
+	if first := stats.Calendar[0].Day; !first.Equal(time.Date(2019, 1, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Calendar starts %v, want 2019-01-21", first)
+	}
+	if last := stats.Calendar[len(stats.Calendar)-1]; !last.Day.Equal(day(20)) || last.Listens != 0 {
+		t.Errorf("Calendar ends %+v, want 2020-01-20 with no listens", last)
+	}
+	if got := stats.Calendar[len(stats.Calendar)-9]; !got.Day.Equal(day(12)) || got.Listens != 2 {
+		t.Errorf("Calendar entry = %+v, want 2020-01-12 with 2 listens", got)
+	}
+}
+
+func TestStreaksWithoutListens(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+	db.CreateUser(context.Background(), "testuser")
+
+	stats, err := GetStreakStats(context.Background(), db, "testuser", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), StreakConfig{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("GetStreakStats: %v", err)
+	}
+	if stats.Longest.Days != 0 || stats.Current.Days != 0 || len(stats.Calendar) != 366 {
+		t.Errorf("GetStreakStats() = %+v, want no streaks and a 366 day calendar", stats)
+	}
+}
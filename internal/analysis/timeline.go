@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// Granularities for genre timelines.
+const (
+	GranularityMonth   = "month"
+	GranularityQuarter = "quarter"
+	GranularityYear    = "year"
+)
+
+type GenreTimelineConfig struct {
+	// The length of each period: GranularityMonth, GranularityQuarter or GranularityYear. Periods
+	// start in UTC.
+	Granularity string
+	// Number of tags in the timeline, chosen by their weight over the whole range.
+	Tags int
+	// Number of emerging and declining tags to return.
+	Trends     int
+	TagFilter  TagFilter
+	TagScoring TagScoring
+}
+
+type TimelinePeriod struct {
+	Start   time.Time `json:"start"`
+	Label   string    `json:"label"`
+	Listens int64     `json:"listens"`
+}
+
+// TagTrend is how a tag's weight changed over the timeline.
+type TagTrend struct {
+	Tag string `json:"tag"`
+	// The period the tag had its highest weight in, the earliest if there's a tie.
+	PeakPeriod string  `json:"peak_period"`
+	PeakWeight float64 `json:"peak_weight"`
+	// The change in weight per period, fitted by least squares over periods with listens.
+	Slope float64 `json:"slope"`
+}
+
+type GenreTimeline struct {
+	Periods []TimelinePeriod `json:"periods"`
+	Tags    []string         `json:"tags"`
+	// Weights[i][j] is the weight of Tags[i] in Periods[j], weighted like the taste report.
+	Weights [][]float64 `json:"weights"`
+	// Trends for each of Tags, in the same order.
+	Trends []TagTrend `json:"trends"`
+	// The tags with the most positive and negative slopes.
+	Emerging  []TagTrend `json:"emerging"`
+	Declining []TagTrend `json:"declining"`
+}
+
+// periodStart returns the start of the period containing t.
+func periodStart(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	switch granularity {
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case GranularityQuarter:
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func nextPeriod(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	case GranularityQuarter:
+		return start.AddDate(0, 3, 0)
+	default:
+		return start.AddDate(1, 0, 0)
+	}
+}
+
+func periodLabel(start time.Time, granularity string) string {
+	switch granularity {
+	case GranularityMonth:
+		return start.Format("2006-01")
+	case GranularityQuarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (start.Month()+2)/3)
+	default:
+		return start.Format("2006")
+	}
+}
+
+// GetGenreTimeline weights the user's tags in each period from the first to the last listen with
+// start <= date < end.
+func GetGenreTimeline(ctx context.Context, db store.Store, user string, start, end time.Time, config GenreTimelineConfig) (timeline GenreTimeline, err error) {
+	switch config.Granularity {
+	case GranularityMonth, GranularityQuarter, GranularityYear:
+	default:
+		return timeline, fmt.Errorf("invalid granularity %q, must be month, quarter or year", config.Granularity)
+	}
+
+	listens, err := db.GetListenHistory(ctx, user, start, end)
+	if err != nil {
+		return timeline, err
+	}
+	if len(listens) == 0 {
+		return timeline, nil
+	}
+	tags, err := loadTagIndex(ctx, db, user, config.TagFilter, config.TagScoring)
+	if err != nil {
+		return timeline, err
+	}
+
+	// Listens are in time order.
+	first := periodStart(listens[0].Time, config.Granularity)
+	last := periodStart(listens[len(listens)-1].Time, config.Granularity)
+	index := make(map[time.Time]int)
+	for p := first; !p.After(last); p = nextPeriod(p, config.Granularity) {
+		index[p] = len(timeline.Periods)
+		timeline.Periods = append(timeline.Periods, TimelinePeriod{Start: p, Label: periodLabel(p, config.Granularity)})
+	}
+
+	albums := make([]map[store.AlbumKey]int64, len(timeline.Periods))
+	for i := range albums {
+		albums[i] = make(map[store.AlbumKey]int64)
+	}
+	allAlbums := make(map[store.AlbumKey]int64)
+	for _, l := range listens {
+		i := index[periodStart(l.Time, config.Granularity)]
+		timeline.Periods[i].Listens++
+		albums[i][store.AlbumKey{Artist: l.Artist, Name: l.Album}]++
+		allAlbums[store.AlbumKey{Artist: l.Artist, Name: l.Album}]++
+	}
+
+	for _, t := range tags.weight(albumCounts(allAlbums), int64(len(listens)), config.Tags) {
+		timeline.Tags = append(timeline.Tags, t.Tag)
+	}
+	timeline.Weights = make([][]float64, len(timeline.Tags))
+	for i := range timeline.Weights {
+		timeline.Weights[i] = make([]float64, len(timeline.Periods))
+	}
+	rows := make(map[string]int)
+	for i, tag := range timeline.Tags {
+		rows[tag] = i
+	}
+	for j, p := range timeline.Periods {
+		if p.Listens == 0 {
+			continue
+		}
+		for _, t := range tags.weight(albumCounts(albums[j]), p.Listens, math.MaxInt) {
+			if i, ok := rows[t.Tag]; ok {
+				timeline.Weights[i][j] = t.Weight
+			}
+		}
+	}
+
+	for i, tag := range timeline.Tags {
+		trend := TagTrend{Tag: tag}
+		var xs, ys []float64
+		for j, p := range timeline.Periods {
+			w := timeline.Weights[i][j]
+			if w > trend.PeakWeight {
+				trend.PeakWeight = w
+				trend.PeakPeriod = p.Label
+			}
+			if p.Listens > 0 {
+				xs = append(xs, float64(j))
+				ys = append(ys, w)
+			}
+		}
+		trend.Slope = math.Round(slope(xs, ys)*10000) / 10000
+		timeline.Trends = append(timeline.Trends, trend)
+	}
+
+	for _, t := range timeline.Trends {
+		switch {
+		case t.Slope > 0:
+			timeline.Emerging = append(timeline.Emerging, t)
+		case t.Slope < 0:
+			timeline.Declining = append(timeline.Declining, t)
+		}
+	}
+	sort.SliceStable(timeline.Emerging, func(i, j int) bool {
+		return timeline.Emerging[i].Slope > timeline.Emerging[j].Slope
+	})
+	sort.SliceStable(timeline.Declining, func(i, j int) bool {
+		return timeline.Declining[i].Slope < timeline.Declining[j].Slope
+	})
+	timeline.Emerging = timeline.Emerging[:min(len(timeline.Emerging), config.Trends)]
+	timeline.Declining = timeline.Declining[:min(len(timeline.Declining), config.Trends)]
+	return timeline, nil
+}
+
+// slope returns the least squares slope of ys against xs, or 0 if there are fewer than two points.
+func slope(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var cov, varX float64
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		varX += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	return cov / varX
+}
@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGenreTimeline(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+	db.SaveArtistTags(ctx, "Eno", []string{"ambient", "electronic"}, []int{100, 60})
+	db.SaveArtistTags(ctx, "Ramones", []string{"punk", "rock"}, []int{100, 80})
+
+	// Mostly Eno in 2018, nothing in 2019, and mostly Ramones in 2020.
+	listens := []struct {
+		year, month int
+		artist      string
+	}{
+		{2018, 1, "Eno"}, {2018, 2, "Eno"}, {2018, 3, "Eno"}, {2018, 4, "Ramones"},
+		{2020, 1, "Eno"}, {2020, 2, "Ramones"}, {2020, 5, "Ramones"}, {2020, 6, "Ramones"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts := time.Date(l.year, time.Month(l.month), 1, 12, 0, 0, 0, time.UTC)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	config := GenreTimelineConfig{Granularity: GranularityYear, Tags: 3, Trends: 1, TagFilter: DefaultTagFilter}
+	timeline, err := GetGenreTimeline(ctx, db, user, time.Unix(0, 0), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), config)
+	if err != nil {
+		t.Fatalf("GetGenreTimeline: %v", err)
+	}
+
+	var labels []string
+	var counts []int64
+	for _, p := range timeline.Periods {
+		labels = append(labels, p.Label)
+		counts = append(counts, p.Listens)
+	}
+	if want := []string{"2018", "2019", "2020"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("periods = %v, want %v", labels, want)
+	}
+	if want := []int64{4, 0, 4}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("listens = %v, want %v", counts, want)
+	}
+
+	// All tags have the same weight overall, so the top three are alphabetical.
+	if want := []string{"ambient", "electronic", "punk"}; !reflect.DeepEqual(timeline.Tags, want) {
+		t.Errorf("Tags = %v, want %v", timeline.Tags, want)
+	}
+	wantWeights := [][]float64{{0.75, 0, 0.25}, {0.75, 0, 0.25}, {0.25, 0, 0.75}}
+	if !reflect.DeepEqual(timeline.Weights, wantWeights) {
+		t.Errorf("Weights = %v, want %v", timeline.Weights, wantWeights)
+	}
+
+	// The empty year isn't counted towards slopes.
+	wantTrends := []TagTrend{
+		{Tag: "ambient", PeakPeriod: "2018", PeakWeight: 0.75, Slope: -0.25},
+		{Tag: "electronic", PeakPeriod: "2018", PeakWeight: 0.75, Slope: -0.25},
+		{Tag: "punk", PeakPeriod: "2020", PeakWeight: 0.75, Slope: 0.25},
+	}
+	if !reflect.DeepEqual(timeline.Trends, wantTrends) {
+		t.Errorf("Trends = %+v, want %+v", timeline.Trends, wantTrends)
+	}
+	if !reflect.DeepEqual(timeline.Emerging, wantTrends[2:]) || !reflect.DeepEqual(timeline.Declining, wantTrends[:1]) {
+		t.Errorf("Emerging = %+v and Declining = %+v, want punk and ambient", timeline.Emerging, timeline.Declining)
+	}
+
+	config.Granularity = GranularityQuarter
+	timeline, err = GetGenreTimeline(ctx, db, user, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), config)
+	if err != nil {
+		t.Fatalf("GetGenreTimeline: %v", err)
+	}
+	labels = nil
+	for _, p := range timeline.Periods {
+		labels = append(labels, p.Label)
+	}
+	if want := []string{"2020-Q1", "2020-Q2"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("quarters = %v, want %v", labels, want)
+	}
+
+	config.Granularity = "week"
+	if _, err := GetGenreTimeline(ctx, db, user, time.Unix(0, 0), time.Now(), config); err == nil {
+		t.Errorf("GetGenreTimeline() with weeks succeeded, want an error")
+	}
+}
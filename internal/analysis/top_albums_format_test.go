@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"testing"
@@ -24,7 +25,7 @@ func TestTopAlbumsFormat(t *testing.T) {
 	album := "Test Album"
 	
 	// Insert Data
-	db.CreateUser(user)
+	db.CreateUser(context.Background(), user)
 	
 	now := time.Now()
 	// 5 listens
@@ -37,10 +38,10 @@ func TestTopAlbumsFormat(t *testing.T) {
 			DateUTS:   fmt.Sprintf("%d", now.Unix()-int64(i)),
 		})
 	}
-	db.AddRecentTracks(user, tracks)
+	db.AddRecentTracks(context.Background(), user, tracks)
 
 	// Test GetTopAlbumsForArtist (Direct store call)
-	albumsData, err := db.GetTopAlbumsForArtist(user, artist, now.AddDate(0, -1, 0), now.AddDate(0, 1, 0), 10)
+	albumsData, err := db.GetTopAlbumsForArtist(context.Background(), user, artist, now.AddDate(0, -1, 0), now.AddDate(0, 1, 0), 10)
 	if err != nil {
 		t.Fatalf("GetTopAlbumsForArtist failed: %v", err)
 	}
@@ -57,7 +58,7 @@ func TestTopAlbumsFormat(t *testing.T) {
 	}
 	
 	// Integration Test via GenerateReport
-	report, err := GenerateReport(db, user)
+	report, err := GenerateReport(context.Background(), db, user, DefaultTasteReportConfig())
 	if err != nil {
 		t.Fatalf("GenerateReport failed: %v", err)
 	}
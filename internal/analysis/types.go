@@ -13,7 +13,9 @@ type ProfileMetadata struct {
 	GeneratedDate    string `yaml:"generated_date"`
 	TotalScrobbles   int64  `yaml:"total_scrobbles"`
 	TotalArtists     int    `yaml:"total_artists"`
-	ListeningStyle   string `yaml:"listening_style"`
+	// Album play-throughs in the current period, and the share of listens which were part of one.
+	AlbumPlayThroughs       int     `yaml:"album_play_throughs"`
+	FullAlbumListeningShare float64 `yaml:"full_album_listening_share"`
 	CurrentPeriod    string `yaml:"current_period"`
 	HistoricalPeriod string `yaml:"historical_period"`
 }
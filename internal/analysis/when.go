@@ -0,0 +1,169 @@
+package analysis
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// Daypart is a recurring part of the week, covering hours [StartHour, EndHour) on its days. EndHour
+// is less than StartHour for dayparts which run past midnight.
+type Daypart struct {
+	Name      string
+	Days      []time.Weekday
+	StartHour int
+	EndHour   int
+}
+
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// Dayparts split the week into parts with different listening habits. Every hour of the week is in
+// exactly one of them.
+var Dayparts = []Daypart{
+	{Name: "Morning commute", Days: weekdays, StartHour: 5, EndHour: 9},
+	{Name: "Work", Days: weekdays, StartHour: 9, EndHour: 17},
+	{Name: "Evening", Days: weekdays, StartHour: 17, EndHour: 22},
+	{Name: "Weekend", Days: []time.Weekday{time.Saturday, time.Sunday}, StartHour: 5, EndHour: 22},
+	{Name: "Late night", Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}, StartHour: 22, EndHour: 5},
+}
+
+// Contains returns whether the daypart includes the hour of the day.
+func (d Daypart) Contains(day time.Weekday, hour int) bool {
+	for _, dd := range d.Days {
+		if dd != day {
+			continue
+		}
+		if d.StartHour < d.EndHour {
+			return hour >= d.StartHour && hour < d.EndHour
+		}
+		return hour >= d.StartHour || hour < d.EndHour
+	}
+	return false
+}
+
+// daypartOf returns the index in Dayparts of the daypart containing the hour.
+func daypartOf(day time.Weekday, hour int) int {
+	for i, d := range Dayparts {
+		if d.Contains(day, hour) {
+			return i
+		}
+	}
+	return -1
+}
+
+type WhenConfig struct {
+	// Hours are in Location.
+	Location *time.Location
+	// Number of top artists and tags to return for each daypart.
+	Artists int
+	Tags    int
+	// How tags are weighted, binary if unset.
+	TagScoring TagScoring
+}
+
+// DaypartProfile is what was listened to during a daypart.
+type DaypartProfile struct {
+	Daypart Daypart
+	Listens int
+	// Share of all listens.
+	Share   float64
+	Artists []store.ArtistPlayCount
+	// Top tags, weighted like the taste report.
+	Tags []TagStat
+	// The tag which is most over-represented in the daypart compared to all listening, if any.
+	Distinctive string
+}
+
+type WhenStats struct {
+	Listens int
+	// Listens in each hour of the week, with days starting on Monday.
+	Hours    [7][24]int
+	Dayparts []DaypartProfile
+}
+
+// GetWhenStats buckets the user's listens with start <= date < end into hours of the week and
+// dayparts, and finds what they listen to during each daypart.
+func GetWhenStats(ctx context.Context, db store.Store, user string, start, end time.Time, config WhenConfig) (stats WhenStats, err error) {
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	listens, err := db.GetListenHistory(ctx, user, start, end)
+	if err != nil {
+		return stats, err
+	}
+	tags, err := loadTagIndex(ctx, db, user, DefaultTagFilter, config.TagScoring)
+	if err != nil {
+		return stats, err
+	}
+
+	type counts struct {
+		artists map[string]int64
+		albums  map[store.AlbumKey]int64
+		all     int
+	}
+	parts := make([]counts, len(Dayparts))
+	for i := range parts {
+		parts[i] = counts{artists: make(map[string]int64), albums: make(map[store.AlbumKey]int64)}
+	}
+	allAlbums := make(map[store.AlbumKey]int64)
+	for _, l := range listens {
+		t := l.Time.In(loc)
+		stats.Hours[(int(t.Weekday())+6)%7][t.Hour()]++
+		p := &parts[daypartOf(t.Weekday(), t.Hour())]
+		p.all++
+		p.artists[l.Artist]++
+		p.albums[store.AlbumKey{Artist: l.Artist, Name: l.Album}]++
+		allAlbums[store.AlbumKey{Artist: l.Artist, Name: l.Album}]++
+	}
+	stats.Listens = len(listens)
+
+	// Weights for every tag, to compare dayparts against.
+	overall := make(map[string]float64)
+	for _, t := range tags.weight(albumCounts(allAlbums), int64(max(stats.Listens, 1)), math.MaxInt) {
+		overall[t.Tag] = t.Weight
+	}
+
+	for i, d := range Dayparts {
+		p := parts[i]
+		profile := DaypartProfile{Daypart: d, Listens: p.all}
+		if stats.Listens > 0 {
+			profile.Share = float64(p.all) / float64(stats.Listens)
+		}
+		for artist, count := range p.artists {
+			profile.Artists = append(profile.Artists, store.ArtistPlayCount{Artist: artist, Count: count})
+		}
+		sort.Slice(profile.Artists, func(i, j int) bool {
+			if profile.Artists[i].Count != profile.Artists[j].Count {
+				return profile.Artists[i].Count > profile.Artists[j].Count
+			}
+			return profile.Artists[i].Artist < profile.Artists[j].Artist
+		})
+		profile.Artists = profile.Artists[:min(len(profile.Artists), config.Artists)]
+
+		if p.all > 0 {
+			all := tags.weight(albumCounts(p.albums), int64(p.all), math.MaxInt)
+			var lift float64
+			for _, t := range all {
+				if diff := t.Weight - overall[t.Tag]; diff > lift {
+					lift = diff
+					profile.Distinctive = t.Tag
+				}
+			}
+			profile.Tags = all[:min(len(all), config.Tags)]
+		}
+		stats.Dayparts = append(stats.Dayparts, profile)
+	}
+	return stats, nil
+}
+
+func albumCounts(counts map[store.AlbumKey]int64) []store.AlbumScrobbleCount {
+	var albums []store.AlbumScrobbleCount
+	for key, count := range counts {
+		albums = append(albums, store.AlbumScrobbleCount{Artist: key.Artist, Title: key.Name, Scrobbles: count})
+	}
+	return albums
+}
@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestDayparts(t *testing.T) {
+	// Every hour of the week is in exactly one daypart.
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		for hour := 0; hour < 24; hour++ {
+			var in []string
+			for _, d := range Dayparts {
+				if d.Contains(day, hour) {
+					in = append(in, d.Name)
+				}
+			}
+			if len(in) != 1 {
+				t.Errorf("%s %02d:00 is in dayparts %v, want exactly one", day, hour, in)
+			}
+		}
+	}
+}
+
+func TestWhen(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+	db.SaveArtistTags(ctx, "Eno", []string{"ambient", "electronic"}, []int{100, 60})
+	db.SaveArtistTags(ctx, "Ramones", []string{"punk", "rock"}, []int{100, 80})
+
+	// 2020-01-06 was a Monday.
+	monday := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	listens := []struct {
+		day, hour int
+		artist    string
+	}{
+		{0, 7, "Ramones"},
+		{0, 10, "Eno"}, {1, 11, "Eno"}, {2, 14, "Eno"},
+		// Friday night, into Saturday morning.
+		{4, 23, "Ramones"}, {5, 1, "Ramones"},
+		{5, 15, "Ramones"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts := monday.AddDate(0, 0, l.day).Add(time.Duration(l.hour) * time.Hour)
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.artist, TrackName: "Track", DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	stats, err := GetWhenStats(ctx, db, user, monday, monday.AddDate(0, 0, 7), WhenConfig{Location: time.UTC, Artists: 1, Tags: 1})
+	if err != nil {
+		t.Fatalf("GetWhenStats: %v", err)
+	}
+	if stats.Listens != 7 {
+		t.Errorf("Listens = %d, want 7", stats.Listens)
+	}
+	if stats.Hours[0][10] != 1 || stats.Hours[4][23] != 1 || stats.Hours[5][1] != 1 {
+		t.Errorf("Hours has Mon 10:00 = %d, Fri 23:00 = %d, Sat 01:00 = %d, want 1 each",
+			stats.Hours[0][10], stats.Hours[4][23], stats.Hours[5][1])
+	}
+
+	type profile struct {
+		name        string
+		listens     int
+		artists     []store.ArtistPlayCount
+		tags        []TagStat
+		distinctive string
+	}
+	var got []profile
+	for _, p := range stats.Dayparts {
+		got = append(got, profile{p.Daypart.Name, p.Listens, p.Artists, p.Tags, p.Distinctive})
+	}
+	want := []profile{
+		{"Morning commute", 1, []store.ArtistPlayCount{{Artist: "Ramones", Count: 1}}, []TagStat{{Tag: "punk", Weight: 1}}, "punk"},
+		{"Work", 3, []store.ArtistPlayCount{{Artist: "Eno", Count: 3}}, []TagStat{{Tag: "ambient", Weight: 1}}, "ambient"},
+		{"Evening", 0, nil, nil, ""},
+		{"Weekend", 1, []store.ArtistPlayCount{{Artist: "Ramones", Count: 1}}, []TagStat{{Tag: "punk", Weight: 1}}, "punk"},
+		{"Late night", 2, []store.ArtistPlayCount{{Artist: "Ramones", Count: 2}}, []TagStat{{Tag: "punk", Weight: 1}}, "punk"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Dayparts = %+v, want %+v", got, want)
+	}
+}
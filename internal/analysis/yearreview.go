@@ -0,0 +1,298 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+// RediscoveryGap is how long an artist must have gone unplayed for listening to them again to count
+// as a rediscovery.
+const RediscoveryGap = 365 * 24 * time.Hour
+
+type YearReviewConfig struct {
+	// Number of top artists, albums and tracks to return.
+	Top int
+	// Number of new artists to return.
+	NewArtists int
+	// Number of rising and falling tags to return.
+	TagShifts int
+	// How tags are weighted, binary if unset.
+	TagScoring TagScoring
+}
+
+// YearReview is an annual summary of a user's listening. Years run from January 1st 00:00 UTC.
+type YearReview struct {
+	Year                  int    `yaml:"year" json:"year"`
+	GeneratedDate         string `yaml:"generated_date" json:"generated_date"`
+	TotalScrobbles        int64  `yaml:"total_scrobbles" json:"total_scrobbles"`
+	PreviousYearScrobbles int64  `yaml:"previous_year_scrobbles" json:"previous_year_scrobbles"`
+	// Change in scrobbles as a fraction of the previous year's, or 0 if there were none.
+	ChangeVsPreviousYear float64          `yaml:"change_vs_previous_year" json:"change_vs_previous_year"`
+	TotalArtists         int              `yaml:"total_artists" json:"total_artists"`
+	TopArtists           []YearTopEntry   `yaml:"top_artists" json:"top_artists"`
+	TopAlbums            []YearTopEntry   `yaml:"top_albums" json:"top_albums"`
+	TopTracks            []YearTopEntry   `yaml:"top_tracks" json:"top_tracks"`
+	NewArtists           YearNewArtists   `yaml:"new_artists" json:"new_artists"`
+	BiggestRediscovery   *YearRediscovery `yaml:"biggest_rediscovery,omitempty" json:"biggest_rediscovery,omitempty"`
+	BusiestDay           *YearDay         `yaml:"busiest_day,omitempty" json:"busiest_day,omitempty"`
+	Months               []YearMonth      `yaml:"months" json:"months"`
+	TagShifts            YearTagShifts    `yaml:"tag_shifts" json:"tag_shifts"`
+}
+
+// YearTopEntry is a top artist, album or track. Name is the album or track name, and empty for
+// artists.
+type YearTopEntry struct {
+	Artist    string `yaml:"artist" json:"artist"`
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Scrobbles int64  `yaml:"scrobbles" json:"scrobbles"`
+	// The month with the most scrobbles, the earliest if there's a tie.
+	PeakMonth string `yaml:"peak_month" json:"peak_month"`
+}
+
+// YearNewArtists are the artists first listened to during the year.
+type YearNewArtists struct {
+	Count int            `yaml:"count" json:"count"`
+	Top   []YearTopEntry `yaml:"top" json:"top"`
+}
+
+// YearRediscovery is the most played artist of the year who hadn't been listened to for at least
+// RediscoveryGap before it.
+type YearRediscovery struct {
+	Artist    string `yaml:"artist" json:"artist"`
+	Scrobbles int64  `yaml:"scrobbles" json:"scrobbles"`
+	// The last listen before the gap, and the first after it.
+	PreviousListen string `yaml:"previous_listen" json:"previous_listen"`
+	Rediscovered   string `yaml:"rediscovered" json:"rediscovered"`
+}
+
+type YearDay struct {
+	Date      string `yaml:"date" json:"date"`
+	Scrobbles int64  `yaml:"scrobbles" json:"scrobbles"`
+}
+
+type YearMonth struct {
+	Month     string `yaml:"month" json:"month"`
+	Scrobbles int64  `yaml:"scrobbles" json:"scrobbles"`
+	TopArtist string `yaml:"top_artist,omitempty" json:"top_artist,omitempty"`
+}
+
+type YearTagShifts struct {
+	Rising  []YearTagShift `yaml:"rising" json:"rising"`
+	Falling []YearTagShift `yaml:"falling" json:"falling"`
+}
+
+// YearTagShift is a tag's weight this year and the previous year, weighted like the taste report.
+type YearTagShift struct {
+	Tag            string  `yaml:"tag" json:"tag"`
+	PreviousWeight float64 `yaml:"previous_weight" json:"previous_weight"`
+	Weight         float64 `yaml:"weight" json:"weight"`
+}
+
+// yearCounts counts an artist, album or track's listens in total and by month.
+type yearCounts struct {
+	total  int64
+	months [12]int64
+}
+
+func (c *yearCounts) add(t time.Time) {
+	c.total++
+	c.months[t.Month()-1]++
+}
+
+func (c yearCounts) peakMonth() string {
+	peak := 0
+	for i, n := range c.months {
+		if n > c.months[peak] {
+			peak = i
+		}
+	}
+	return time.Month(peak + 1).String()
+}
+
+// topYearEntries returns the entries with the most listens, breaking ties by artist and name.
+func topYearEntries(counts map[store.AlbumKey]*yearCounts, limit int) []YearTopEntry {
+	var entries []YearTopEntry
+	for key, c := range counts {
+		entries = append(entries, YearTopEntry{Artist: key.Artist, Name: key.Name, Scrobbles: c.total, PeakMonth: c.peakMonth()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Scrobbles != entries[j].Scrobbles {
+			return entries[i].Scrobbles > entries[j].Scrobbles
+		}
+		if entries[i].Artist != entries[j].Artist {
+			return entries[i].Artist < entries[j].Artist
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries[:min(len(entries), limit)]
+}
+
+// GenerateYearReview summarises the user's listening in the year. Like Compare, it counts listens
+// rather than reading the weekly charts, since chart weeks straddle the new year.
+func GenerateYearReview(ctx context.Context, db store.Store, user string, year int, config YearReviewConfig) (*YearReview, error) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	prevStart := start.AddDate(-1, 0, 0)
+
+	review := &YearReview{Year: year, GeneratedDate: time.Now().Format("2006-01-02")}
+
+	listens, err := db.GetListenHistory(ctx, user, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("listens: %w", err)
+	}
+	review.TotalScrobbles = int64(len(listens))
+	prevListens, err := db.GetListensInRange(ctx, user, prevStart, start)
+	if err != nil {
+		return nil, fmt.Errorf("previous year listens: %w", err)
+	}
+	review.PreviousYearScrobbles = int64(len(prevListens))
+	if review.PreviousYearScrobbles > 0 {
+		change := float64(review.TotalScrobbles-review.PreviousYearScrobbles) / float64(review.PreviousYearScrobbles)
+		review.ChangeVsPreviousYear = math.Round(change*100) / 100
+	}
+
+	artists := make(map[store.AlbumKey]*yearCounts)
+	albums := make(map[store.AlbumKey]*yearCounts)
+	tracks := make(map[store.AlbumKey]*yearCounts)
+	firstListens := make(map[string]time.Time)
+	days := make(map[string]int64)
+	monthArtists := make([]map[string]int64, 12)
+	for i := range monthArtists {
+		monthArtists[i] = make(map[string]int64)
+	}
+	count := func(counts map[store.AlbumKey]*yearCounts, key store.AlbumKey, t time.Time) {
+		if counts[key] == nil {
+			counts[key] = &yearCounts{}
+		}
+		counts[key].add(t)
+	}
+	for _, l := range listens {
+		t := l.Time.UTC()
+		count(artists, store.AlbumKey{Artist: l.Artist}, t)
+		if l.Album != "" {
+			count(albums, store.AlbumKey{Artist: l.Artist, Name: l.Album}, t)
+		}
+		count(tracks, store.AlbumKey{Artist: l.Artist, Name: l.Track}, t)
+		if _, ok := firstListens[l.Artist]; !ok {
+			firstListens[l.Artist] = t
+		}
+		days[t.Format("2006-01-02")]++
+		monthArtists[t.Month()-1][l.Artist]++
+	}
+	review.TotalArtists = len(artists)
+	review.TopArtists = topYearEntries(artists, config.Top)
+	review.TopAlbums = topYearEntries(albums, config.Top)
+	review.TopTracks = topYearEntries(tracks, config.Top)
+
+	// Artists listened to before the year are either rediscoveries or old favourites, and the rest
+	// are new.
+	before, err := db.GetArtistListenStats(ctx, user, time.Unix(0, 0), start)
+	if err != nil {
+		return nil, fmt.Errorf("earlier listens: %w", err)
+	}
+	lastListens := make(map[string]time.Time)
+	for _, a := range before {
+		lastListens[a.Artist] = a.LastListen.UTC()
+	}
+	newArtists := make(map[store.AlbumKey]*yearCounts)
+	for key, c := range artists {
+		last, ok := lastListens[key.Artist]
+		if !ok {
+			newArtists[key] = c
+			continue
+		}
+		first := firstListens[key.Artist]
+		if first.Sub(last) < RediscoveryGap {
+			continue
+		}
+		r := review.BiggestRediscovery
+		if r == nil || c.total > r.Scrobbles || (c.total == r.Scrobbles && key.Artist < r.Artist) {
+			review.BiggestRediscovery = &YearRediscovery{
+				Artist:         key.Artist,
+				Scrobbles:      c.total,
+				PreviousListen: last.Format("2006-01-02"),
+				Rediscovered:   first.Format("2006-01-02"),
+			}
+		}
+	}
+	review.NewArtists = YearNewArtists{Count: len(newArtists), Top: topYearEntries(newArtists, config.NewArtists)}
+
+	for day, n := range days {
+		if b := review.BusiestDay; b == nil || n > b.Scrobbles || (n == b.Scrobbles && day < b.Date) {
+			review.BusiestDay = &YearDay{Date: day, Scrobbles: n}
+		}
+	}
+
+	for i, counts := range monthArtists {
+		month := YearMonth{Month: time.Month(i + 1).String()}
+		var top int64
+		for artist, n := range counts {
+			month.Scrobbles += n
+			if n > top || (n == top && artist < month.TopArtist) {
+				top = n
+				month.TopArtist = artist
+			}
+		}
+		review.Months = append(review.Months, month)
+	}
+
+	shifts, err := tagShifts(ctx, db, user, prevStart, start, end, config)
+	if err != nil {
+		return nil, fmt.Errorf("tag shifts: %w", err)
+	}
+	review.TagShifts = shifts
+	return review, nil
+}
+
+// tagShifts compares the top tags of the year [start, end) with the previous year, returning the
+// tags whose weight rose and fell the most.
+func tagShifts(ctx context.Context, db store.Store, user string, prevStart, start, end time.Time, config YearReviewConfig) (shifts YearTagShifts, err error) {
+	// getTopTagsWeighted's range includes its end.
+	current, err := getTopTagsWeighted(ctx, db, user, start, end.Add(-time.Second), 40, DefaultTagFilter, config.TagScoring)
+	if err != nil {
+		return shifts, err
+	}
+	previous, err := getTopTagsWeighted(ctx, db, user, prevStart, start.Add(-time.Second), 40, DefaultTagFilter, config.TagScoring)
+	if err != nil {
+		return shifts, err
+	}
+
+	weights := make(map[string]*YearTagShift)
+	for _, t := range previous {
+		weights[t.Tag] = &YearTagShift{Tag: t.Tag, PreviousWeight: t.Weight}
+	}
+	for _, t := range current {
+		if weights[t.Tag] == nil {
+			weights[t.Tag] = &YearTagShift{Tag: t.Tag}
+		}
+		weights[t.Tag].Weight = t.Weight
+	}
+	for _, s := range weights {
+		switch {
+		case s.Weight > s.PreviousWeight:
+			shifts.Rising = append(shifts.Rising, *s)
+		case s.Weight < s.PreviousWeight:
+			shifts.Falling = append(shifts.Falling, *s)
+		}
+	}
+	bySize := func(tags []YearTagShift) {
+		sort.Slice(tags, func(i, j int) bool {
+			di := math.Abs(tags[i].Weight - tags[i].PreviousWeight)
+			dj := math.Abs(tags[j].Weight - tags[j].PreviousWeight)
+			if di != dj {
+				return di > dj
+			}
+			return tags[i].Tag < tags[j].Tag
+		})
+	}
+	bySize(shifts.Rising)
+	bySize(shifts.Falling)
+	shifts.Rising = shifts.Rising[:min(len(shifts.Rising), config.TagShifts)]
+	shifts.Falling = shifts.Falling[:min(len(shifts.Falling), config.TagShifts)]
+	return shifts, nil
+}
@@ -0,0 +1,138 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ademuri/last-fm-tools/internal/store"
+)
+
+func TestGenerateYearReview(t *testing.T) {
+	db := store.NewMemory()
+	defer db.Close()
+
+	user := "testuser"
+	ctx := context.Background()
+	db.CreateUser(ctx, user)
+	db.SaveArtistTags(ctx, "Eno", []string{"ambient", "electronic"}, []int{100, 60})
+	db.SaveArtistTags(ctx, "Ramones", []string{"punk", "rock"}, []int{100, 80})
+	db.SaveArtistTags(ctx, "Low", []string{"slowcore", "indie"}, []int{100, 60})
+
+	listens := []struct {
+		date   string
+		artist string
+		album  string
+		track  string
+	}{
+		// Ramones were last listened to more than a year before 2020, and Eno in 2019.
+		{"2018-05-01T10:00:00Z", "Ramones", "Ramones", "Blitzkrieg Bop"},
+		{"2019-03-01T10:00:00Z", "Eno", "Apollo", "An Ending"},
+		{"2019-03-01T11:00:00Z", "Eno", "Apollo", "An Ending"},
+
+		{"2020-01-05T10:00:00Z", "Eno", "Apollo", "An Ending"},
+		{"2020-03-10T10:00:00Z", "Ramones", "Ramones", "Blitzkrieg Bop"},
+		{"2020-03-10T11:00:00Z", "Ramones", "Ramones", "Judy Is a Punk"},
+		{"2020-03-10T12:00:00Z", "Ramones", "Ramones", "Blitzkrieg Bop"},
+		{"2020-07-01T10:00:00Z", "Low", "Things We Lost in the Fire", "Sunflower"},
+		{"2020-07-02T10:00:00Z", "Low", "Things We Lost in the Fire", "Sunflower"},
+		{"2020-12-31T23:00:00Z", "Ramones", "", "Sheena Is a Punk Rocker"},
+		// Outside of the year.
+		{"2021-01-01T00:00:00Z", "Eno", "Apollo", "An Ending"},
+	}
+	var tracks []store.TrackImport
+	for _, l := range listens {
+		ts, err := time.Parse(time.RFC3339, l.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracks = append(tracks, store.TrackImport{Artist: l.artist, Album: l.album, TrackName: l.track, DateUTS: fmt.Sprintf("%d", ts.Unix())})
+	}
+	if err := db.AddRecentTracks(ctx, user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	review, err := GenerateYearReview(ctx, db, user, 2020, YearReviewConfig{Top: 2, NewArtists: 5, TagShifts: 2})
+	if err != nil {
+		t.Fatalf("GenerateYearReview: %v", err)
+	}
+
+	if review.TotalScrobbles != 7 || review.PreviousYearScrobbles != 2 || review.ChangeVsPreviousYear != 2.5 {
+		t.Errorf("scrobbles = %d vs %d (%v), want 7 vs 2 (2.5)", review.TotalScrobbles, review.PreviousYearScrobbles, review.ChangeVsPreviousYear)
+	}
+	if review.TotalArtists != 3 {
+		t.Errorf("TotalArtists = %d, want 3", review.TotalArtists)
+	}
+
+	wantArtists := []YearTopEntry{
+		{Artist: "Ramones", Scrobbles: 4, PeakMonth: "March"},
+		{Artist: "Low", Scrobbles: 2, PeakMonth: "July"},
+	}
+	if !reflect.DeepEqual(review.TopArtists, wantArtists) {
+		t.Errorf("TopArtists = %+v, want %+v", review.TopArtists, wantArtists)
+	}
+	// Listens without an album aren't counted towards albums.
+	wantAlbums := []YearTopEntry{
+		{Artist: "Ramones", Name: "Ramones", Scrobbles: 3, PeakMonth: "March"},
+		{Artist: "Low", Name: "Things We Lost in the Fire", Scrobbles: 2, PeakMonth: "July"},
+	}
+	if !reflect.DeepEqual(review.TopAlbums, wantAlbums) {
+		t.Errorf("TopAlbums = %+v, want %+v", review.TopAlbums, wantAlbums)
+	}
+	wantTracks := []YearTopEntry{
+		{Artist: "Low", Name: "Sunflower", Scrobbles: 2, PeakMonth: "July"},
+		{Artist: "Ramones", Name: "Blitzkrieg Bop", Scrobbles: 2, PeakMonth: "March"},
+	}
+	if !reflect.DeepEqual(review.TopTracks, wantTracks) {
+		t.Errorf("TopTracks = %+v, want %+v", review.TopTracks, wantTracks)
+	}
+
+	wantNew := YearNewArtists{Count: 1, Top: []YearTopEntry{{Artist: "Low", Scrobbles: 2, PeakMonth: "July"}}}
+	if !reflect.DeepEqual(review.NewArtists, wantNew) {
+		t.Errorf("NewArtists = %+v, want %+v", review.NewArtists, wantNew)
+	}
+	wantRediscovery := &YearRediscovery{Artist: "Ramones", Scrobbles: 4, PreviousListen: "2018-05-01", Rediscovered: "2020-03-10"}
+	if !reflect.DeepEqual(review.BiggestRediscovery, wantRediscovery) {
+		t.Errorf("BiggestRediscovery = %+v, want %+v", review.BiggestRediscovery, wantRediscovery)
+	}
+	if want := (&YearDay{Date: "2020-03-10", Scrobbles: 3}); !reflect.DeepEqual(review.BusiestDay, want) {
+		t.Errorf("BusiestDay = %+v, want %+v", review.BusiestDay, want)
+	}
+
+	if len(review.Months) != 12 {
+		t.Fatalf("len(Months) = %d, want 12", len(review.Months))
+	}
+	for _, want := range []YearMonth{
+		{Month: "January", Scrobbles: 1, TopArtist: "Eno"},
+		{Month: "February"},
+		{Month: "March", Scrobbles: 3, TopArtist: "Ramones"},
+		{Month: "December", Scrobbles: 1, TopArtist: "Ramones"},
+	} {
+		var got YearMonth
+		for _, m := range review.Months {
+			if m.Month == want.Month {
+				got = m
+			}
+		}
+		if got != want {
+			t.Errorf("month = %+v, want %+v", got, want)
+		}
+	}
+
+	// 2019 was all Eno. Slowcore and indie rose too, but by less.
+	wantShifts := YearTagShifts{
+		Rising: []YearTagShift{
+			{Tag: "punk", Weight: 0.57},
+			{Tag: "rock", Weight: 0.57},
+		},
+		Falling: []YearTagShift{
+			{Tag: "ambient", PreviousWeight: 1, Weight: 0.14},
+			{Tag: "electronic", PreviousWeight: 1, Weight: 0.14},
+		},
+	}
+	if !reflect.DeepEqual(review.TagShifts, wantShifts) {
+		t.Errorf("TagShifts = %+v, want %+v", review.TagShifts, wantShifts)
+	}
+}
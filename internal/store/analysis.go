@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -21,29 +22,29 @@ type TagCount struct {
 	Count int
 }
 
-func (s *Store) GetTotalScrobbles(user string) (int64, error) {
+func (s *SQLiteStore) GetTotalScrobbles(ctx context.Context, user string) (int64, error) {
 	var count int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM Listen WHERE user = ?", user).Scan(&count)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Listen WHERE user = ?", user).Scan(&count)
 	return count, err
 }
 
-func (s *Store) GetTotalArtists(user string) (int, error) {
+func (s *SQLiteStore) GetTotalArtists(ctx context.Context, user string) (int, error) {
 	var count int
 	query := `SELECT COUNT(DISTINCT t.artist) FROM Listen l JOIN Track t ON l.track = t.id WHERE l.user = ?`
-	err := s.db.QueryRow(query, user).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, user).Scan(&count)
 	return count, err
 }
 
-func (s *Store) GetFirstListen(user string) (time.Time, error) {
+func (s *SQLiteStore) GetFirstListen(ctx context.Context, user string) (time.Time, error) {
 	var date int64
-	err := s.db.QueryRow("SELECT MIN(date) FROM Listen WHERE user = ?", user).Scan(&date)
+	err := s.db.QueryRowContext(ctx, "SELECT MIN(date) FROM Listen WHERE user = ?", user).Scan(&date)
 	if err != nil {
 		return time.Time{}, err
 	}
 	return time.Unix(date, 0), nil
 }
 
-func (s *Store) GetTopArtists(user string, start, end time.Time, limit int) ([]ArtistScrobbleCount, error) {
+func (s *SQLiteStore) GetTopArtists(ctx context.Context, user string, start, end time.Time, limit int) ([]ArtistScrobbleCount, error) {
 	query := `
 		SELECT t.artist, COUNT(*) as scrobbles
 		FROM Listen l
@@ -53,7 +54,7 @@ func (s *Store) GetTopArtists(user string, start, end time.Time, limit int) ([]A
 		ORDER BY scrobbles DESC
 		LIMIT ?
 	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix(), limit)
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix(), limit)
 	if err != nil {
 		return nil, fmt.Errorf("querying top artists: %w", err)
 	}
@@ -70,7 +71,7 @@ func (s *Store) GetTopArtists(user string, start, end time.Time, limit int) ([]A
 	return artists, rows.Err()
 }
 
-func (s *Store) GetTopAlbumsForArtist(user, artist string, start, end time.Time, limit int) ([]TagCount, error) {
+func (s *SQLiteStore) GetTopAlbumsForArtist(ctx context.Context, user, artist string, start, end time.Time, limit int) ([]TagCount, error) {
 	// Reusing TagCount struct for Name/Count pair
 	query := `
 		SELECT t.album, COUNT(*) as scrobbles
@@ -81,7 +82,7 @@ func (s *Store) GetTopAlbumsForArtist(user, artist string, start, end time.Time,
 		ORDER BY scrobbles DESC
 		LIMIT ?
 	`
-	rows, err := s.db.Query(query, user, artist, start.Unix(), end.Unix(), limit)
+	rows, err := s.db.QueryContext(ctx, query, user, artist, start.Unix(), end.Unix(), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -98,26 +99,21 @@ func (s *Store) GetTopAlbumsForArtist(user, artist string, start, end time.Time,
 	return albums, rows.Err()
 }
 
-func (s *Store) GetTopTagsForArtist(artist string, limit int) ([]string, error) {
-	query := `SELECT tag FROM ArtistTag WHERE artist = ? ORDER BY count DESC LIMIT ?`
-	rows, err := s.db.Query(query, artist, limit)
+// GetTopTagsForArtist returns artist's top tags, including personal tags, after curation by the tag
+// rules.
+func (s *SQLiteStore) GetTopTagsForArtist(ctx context.Context, artist string, limit int) ([]string, error) {
+	layer, err := s.tagLayer(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var tags []string
-	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err != nil {
-			return nil, err
-		}
-		tags = append(tags, tag)
+	tags, err := s.GetArtistTagCounts(ctx, artist)
+	if err != nil {
+		return nil, err
 	}
-	return tags, rows.Err()
+	return tagNames(layer.artistTags(artist, tags), limit), nil
 }
 
-func (s *Store) GetTopAlbums(user string, start, end time.Time, limit int) ([]AlbumScrobbleCount, error) {
+func (s *SQLiteStore) GetTopAlbums(ctx context.Context, user string, start, end time.Time, limit int) ([]AlbumScrobbleCount, error) {
 	query := `
 		SELECT t.album, t.artist, COUNT(*) as scrobbles
 		FROM Listen l
@@ -127,7 +123,7 @@ func (s *Store) GetTopAlbums(user string, start, end time.Time, limit int) ([]Al
 		ORDER BY scrobbles DESC
 		LIMIT ?
 	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix(), limit)
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix(), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -144,26 +140,34 @@ func (s *Store) GetTopAlbums(user string, start, end time.Time, limit int) ([]Al
 	return albums, rows.Err()
 }
 
-func (s *Store) GetTopTagsForAlbum(artist, album string, limit int) ([]string, error) {
-	query := `SELECT tag FROM AlbumTag WHERE artist = ? AND album = ? ORDER BY count DESC LIMIT ?`
-	rows, err := s.db.Query(query, artist, album, limit)
+// GetTopTagsForAlbum returns the album's top tags, including personal tags, after curation by the
+// tag rules.
+func (s *SQLiteStore) GetTopTagsForAlbum(ctx context.Context, artist, album string, limit int) ([]string, error) {
+	layer, err := s.tagLayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT tag, count FROM AlbumTag WHERE artist = ? AND album = ?", artist, album)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	
-	var tags []string
+
+	var tags []TagCount
 	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err != nil {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
 			return nil, err
 		}
-		tags = append(tags, tag)
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return tags, rows.Err()
+	return tagNames(layer.albumTags(artist, album, tags), limit), nil
 }
 
-func (s *Store) GetArtistListenCount(user, artist string, start, end time.Time) (int64, error) {
+func (s *SQLiteStore) GetArtistListenCount(ctx context.Context, user, artist string, start, end time.Time) (int64, error) {
 	query := `
 		SELECT COUNT(*) 
 		FROM Listen l
@@ -171,13 +175,13 @@ func (s *Store) GetArtistListenCount(user, artist string, start, end time.Time)
 		WHERE l.user = ? AND t.artist = ? AND l.date BETWEEN ? AND ?
 	`
 	var count int64
-	err := s.db.QueryRow(query, user, artist, start.Unix(), end.Unix()).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, user, artist, start.Unix(), end.Unix()).Scan(&count)
 	return count, err
 }
 
 // GetPeakYears returns the start and end year of the peak listening period.
 // Returns "year" or "start-end".
-func (s *Store) GetPeakYears(user, artist string) (string, error) {
+func (s *SQLiteStore) GetPeakYears(ctx context.Context, user, artist string) (string, error) {
 	query := `
 		SELECT strftime('%Y', datetime(date, 'unixepoch')) as year, COUNT(*)
 		FROM Listen l
@@ -186,16 +190,12 @@ func (s *Store) GetPeakYears(user, artist string) (string, error) {
 		GROUP BY year
 		ORDER BY year
 	`
-	rows, err := s.db.Query(query, user, artist)
+	rows, err := s.db.QueryContext(ctx, query, user, artist)
 	if err != nil {
 		return "", err
 	}
 	defer rows.Close()
 
-	type yearCount struct {
-		year  string
-		count int
-	}
 	var counts []yearCount
 	var total int
 	for rows.Next() {
@@ -211,12 +211,18 @@ func (s *Store) GetPeakYears(user, artist string) (string, error) {
 		return "", nil
 	}
 
-	// Logic moved from analysis.go to here (or keep it there? It's logic, not just data access).
-	// But it requires iterating the result set. 
-	// I'll keep the calculation logic here to encapsulate the raw SQL/iteration.
-	
+	return peakYears(counts, total), nil
+}
+
+type yearCount struct {
+	year  string
+	count int
+}
+
+// peakYears finds the shortest run of consecutive years holding 80% of total listens.
+func peakYears(counts []yearCount, total int) string {
 	target := int(float64(total) * 0.8)
-	
+
 	bestStart, bestEnd := -1, -1
 	minLen := 999999
 
@@ -238,43 +244,32 @@ func (s *Store) GetPeakYears(user, artist string) (string, error) {
 
 	if bestStart != -1 {
 		if bestStart == bestEnd {
-			return counts[bestStart].year, nil
+			return counts[bestStart].year
 		}
-		return fmt.Sprintf("%s-%s", counts[bestStart].year, counts[bestEnd].year), nil
+		return fmt.Sprintf("%s-%s", counts[bestStart].year, counts[bestEnd].year)
 	}
 
-	return "Unknown", nil
+	return "Unknown"
 }
 
-func (s *Store) GetAverageTracksPerAlbum(user string, start, end time.Time) (float64, error) {
-	query := `
-		SELECT COUNT(DISTINCT t.id)
-		FROM Listen l
-		JOIN Track t ON l.track = t.id
-		WHERE l.user = ? AND l.date BETWEEN ? AND ? AND t.album != ''
-		GROUP BY t.artist, t.album
-	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix())
+// GetAlbumTracks returns the names of the album's tracks which have been scrobbled, in name order.
+// Track listings aren't fetched from last.fm, so these are the album's known tracks.
+func (s *SQLiteStore) GetAlbumTracks(ctx context.Context, artist, album string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT name FROM Track WHERE artist = ? AND album = ? ORDER BY name", artist, album)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("querying album tracks: %w", err)
 	}
 	defer rows.Close()
 
-	var countSum int64
-	var albumCount int64
+	var tracks []string
 	for rows.Next() {
-		var c int64
-		if err := rows.Scan(&c); err != nil {
-			return 0, err
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
 		}
-		countSum += c
-		albumCount++
+		tracks = append(tracks, name)
 	}
-
-	if albumCount == 0 {
-		return 0, nil
-	}
-	return float64(countSum) / float64(albumCount), nil
+	return tracks, rows.Err()
 }
 
 // Support for Tag Weighting
@@ -292,8 +287,14 @@ type AlbumTagData struct {
 	Count  int
 }
 
-func (s *Store) GetAllArtistTags() ([]ArtistTagData, error) {
-	rows, err := s.db.Query("SELECT artist, tag, count FROM ArtistTag")
+// GetAllArtistTags returns every artist's tags, including personal tags, after curation by the tag
+// rules, ordered by artist.
+func (s *SQLiteStore) GetAllArtistTags(ctx context.Context) ([]ArtistTagData, error) {
+	layer, err := s.tagLayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT artist, tag, count FROM ArtistTag")
 	if err != nil {
 		return nil, err
 	}
@@ -307,11 +308,20 @@ func (s *Store) GetAllArtistTags() ([]ArtistTagData, error) {
 		}
 		data = append(data, d)
 	}
-	return data, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return layer.allArtistTags(data), nil
 }
 
-func (s *Store) GetAllAlbumTags() ([]AlbumTagData, error) {
-	rows, err := s.db.Query("SELECT artist, album, tag, count FROM AlbumTag ORDER BY artist, album")
+// GetAllAlbumTags returns every album's tags, including personal tags, after curation by the tag
+// rules, ordered by artist and album.
+func (s *SQLiteStore) GetAllAlbumTags(ctx context.Context) ([]AlbumTagData, error) {
+	layer, err := s.tagLayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT artist, album, tag, count FROM AlbumTag")
 	if err != nil {
 		return nil, err
 	}
@@ -325,10 +335,13 @@ func (s *Store) GetAllAlbumTags() ([]AlbumTagData, error) {
 		}
 		data = append(data, d)
 	}
-	return data, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return layer.allAlbumTags(data), nil
 }
 
-func (s *Store) GetAlbumListenCounts(user string, start, end time.Time) ([]AlbumScrobbleCount, error) {
+func (s *SQLiteStore) GetAlbumListenCounts(ctx context.Context, user string, start, end time.Time) ([]AlbumScrobbleCount, error) {
 	// Reusing AlbumScrobbleCount
 	query := `
 		SELECT t.artist, t.album, COUNT(*)
@@ -337,7 +350,7 @@ func (s *Store) GetAlbumListenCounts(user string, start, end time.Time) ([]Album
 		WHERE l.user = ? AND l.date BETWEEN ? AND ?
 		GROUP BY t.artist, t.album
 	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix())
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix())
 	if err != nil {
 		return nil, err
 	}
@@ -354,7 +367,13 @@ func (s *Store) GetAlbumListenCounts(user string, start, end time.Time) ([]Album
 	return counts, rows.Err()
 }
 
-func (s *Store) GetArtistAlbumStats(user string, start, end time.Time) ([]struct{Artist string; AlbumCount float64; ListenCount int64}, error) {
+type ArtistAlbumStats struct {
+	Artist      string
+	AlbumCount  float64
+	ListenCount int64
+}
+
+func (s *SQLiteStore) GetArtistAlbumStats(ctx context.Context, user string, start, end time.Time) ([]ArtistAlbumStats, error) {
 	query := `
 		SELECT t.artist, COUNT(DISTINCT t.album) as album_count, COUNT(*) as listen_count
 		FROM Listen l
@@ -363,15 +382,15 @@ func (s *Store) GetArtistAlbumStats(user string, start, end time.Time) ([]struct
 		GROUP BY t.artist
 		ORDER BY listen_count DESC
 	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix())
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix())
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	
-	var stats []struct{Artist string; AlbumCount float64; ListenCount int64}
+	var stats []ArtistAlbumStats
 	for rows.Next() {
-		var s struct{Artist string; AlbumCount float64; ListenCount int64}
+		var s ArtistAlbumStats
 		if err := rows.Scan(&s.Artist, &s.AlbumCount, &s.ListenCount); err != nil {
 			return nil, err
 		}
@@ -380,7 +399,7 @@ func (s *Store) GetArtistAlbumStats(user string, start, end time.Time) ([]struct
 	return stats, rows.Err()
 }
 
-func (s *Store) GetNewArtistsCount(user string, since time.Time) (int, error) {
+func (s *SQLiteStore) GetNewArtistsCount(ctx context.Context, user string, since time.Time) (int, error) {
 	query := `
 		SELECT COUNT(*) FROM (
 			SELECT t.artist, MIN(l.date) as first_listen
@@ -391,13 +410,13 @@ func (s *Store) GetNewArtistsCount(user string, since time.Time) (int, error) {
 		)
 	`
 	var count int
-	err := s.db.QueryRow(query, user, since.Unix()).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, user, since.Unix()).Scan(&count)
 	return count, err
 }
 
-func (s *Store) GetTotalScrobblesInPeriod(user string, start, end time.Time) (int64, error) {
+func (s *SQLiteStore) GetTotalScrobblesInPeriod(ctx context.Context, user string, start, end time.Time) (int64, error) {
 	var count int64
-	err := s.db.QueryRow("SELECT COUNT(*) FROM Listen WHERE user = ? AND date BETWEEN ? AND ?", user, start.Unix(), end.Unix()).Scan(&count)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Listen WHERE user = ? AND date BETWEEN ? AND ?", user, start.Unix(), end.Unix()).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
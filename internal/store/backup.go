@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// backupPagesPerStep is how many pages are copied before releasing the source database lock, so that
+// a backup of a large database doesn't block writers for its whole duration.
+const backupPagesPerStep = 1024
+
+// Backup writes a consistent snapshot of the live database to destPath using SQLite's online backup
+// API. destPath must not be in use by another connection; any existing contents are overwritten.
+func (s *SQLiteStore) Backup(ctx context.Context, destPath string) error {
+	dest, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return fmt.Errorf("opening backup destination: %w", err)
+	}
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			destSQLite, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("backup destination is not a SQLite connection")
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("database is not a SQLite connection")
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("starting backup: %w", err)
+			}
+			for {
+				if err := ctx.Err(); err != nil {
+					backup.Close()
+					return err
+				}
+				done, err := backup.Step(backupPagesPerStep)
+				if err != nil {
+					backup.Close()
+					return fmt.Errorf("copying pages: %w", err)
+				}
+				if done {
+					break
+				}
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("finishing backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// CheckCompatibility verifies that the database at dbPath is intact and has every table and column
+// this version expects, after the additive migrations New applies. Missing indexes are not an
+// error, since they don't affect correctness.
+func CheckCompatibility(ctx context.Context, dbPath string) error {
+	// Check before New, which would otherwise create the tables in an empty file.
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		db.Close()
+		return fmt.Errorf("checking integrity: %w", err)
+	}
+	exists, err := dbExists(db)
+	db.Close()
+	if err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	if !exists {
+		return fmt.Errorf("not a last-fm-tools database")
+	}
+
+	s, err := New(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer s.Close()
+
+	missing, err := missingSchemaObjects(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	var incompatible []string
+	for _, o := range missing {
+		if o.kind != "index" {
+			incompatible = append(incompatible, o.String())
+		}
+	}
+	if len(incompatible) > 0 {
+		return fmt.Errorf("schema is missing %s", strings.Join(incompatible, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChartKind is what a weekly chart ranks.
+type ChartKind string
+
+const (
+	ChartArtists ChartKind = "artist"
+	ChartAlbums  ChartKind = "album"
+	// Tracks are charted by artist and name, so plays of a track on different albums count
+	// together.
+	ChartTracks ChartKind = "track"
+)
+
+var chartKinds = []ChartKind{ChartArtists, ChartAlbums, ChartTracks}
+
+// WeeklyChartSize is the number of entries stored in each weekly chart.
+const WeeklyChartSize = 100
+
+const chartWeek = 7 * 24 * time.Hour
+
+// WeekStart returns the start of the chart week containing t. Chart weeks run from Monday 00:00 UTC.
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// ChartEntry is a position in a weekly chart. Name is the album or track name, and empty for
+// artists. Entries with the same count share a rank.
+type ChartEntry struct {
+	Week   time.Time
+	Rank   int
+	Artist string
+	Name   string
+	Count  int64
+}
+
+// chartFingerprint identifies the listens a week's charts were computed from, so that charts are
+// recomputed when listens are added to or removed from the week.
+type chartFingerprint struct {
+	listens      int64
+	lastListenID int64
+}
+
+// rankChart sorts counts for one chart and assigns ranks, keeping the top WeeklyChartSize.
+func rankChart(entries []ChartEntry) []ChartEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		if entries[i].Artist != entries[j].Artist {
+			return entries[i].Artist < entries[j].Artist
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	entries = entries[:limitTo(len(entries), WeeklyChartSize)]
+	for i := range entries {
+		if i > 0 && entries[i].Count == entries[i-1].Count {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	return entries
+}
+
+// weekStartSQL is the start of the chart week of Listen.date as a Unix time. The Unix epoch was a
+// Thursday, so weeks are offset by four days to start on Monday. Listens are saved with Unix times,
+// and dates which couldn't be parsed are left out of the charts.
+const weekStartSQL = `(date - (date - 345600) % 604800)`
+
+// UpdateWeeklyCharts computes the user's weekly charts for every week which ended before now and
+// whose listens have changed since its charts were last computed. It returns the number of weeks
+// computed.
+func (s *SQLiteStore) UpdateWeeklyCharts(ctx context.Context, user string, now time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	current := make(map[int64]chartFingerprint)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+weekStartSQL+` AS week, COUNT(*), MAX(id)
+		FROM Listen
+		WHERE user = ? AND typeof(date) = 'integer'
+		GROUP BY week`, user)
+	if err != nil {
+		return 0, fmt.Errorf("counting listens per week: %w", err)
+	}
+	for rows.Next() {
+		var week int64
+		var f chartFingerprint
+		if err := rows.Scan(&week, &f.listens, &f.lastListenID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		current[week] = f
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	computed := make(map[int64]chartFingerprint)
+	rows, err = tx.QueryContext(ctx, "SELECT week, listens, last_listen_id FROM WeeklyChartWeek WHERE user = ?", user)
+	if err != nil {
+		return 0, fmt.Errorf("reading computed weeks: %w", err)
+	}
+	for rows.Next() {
+		var week int64
+		var f chartFingerprint
+		if err := rows.Scan(&week, &f.listens, &f.lastListenID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		computed[week] = f
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var stale []int64
+	for week, f := range current {
+		if time.Unix(week, 0).Add(chartWeek).After(now) {
+			continue
+		}
+		if c, ok := computed[week]; !ok || c != f {
+			stale = append(stale, week)
+		}
+	}
+	// Weeks whose listens have all been deleted.
+	for week := range computed {
+		if _, ok := current[week]; !ok {
+			stale = append(stale, week)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+
+	for _, week := range stale {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM WeeklyChart WHERE user = ? AND week = ?", user, week); err != nil {
+			return 0, fmt.Errorf("deleting chart for week %d: %w", week, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM WeeklyChartWeek WHERE user = ? AND week = ?", user, week); err != nil {
+			return 0, fmt.Errorf("deleting chart for week %d: %w", week, err)
+		}
+		f, ok := current[week]
+		if !ok {
+			continue
+		}
+		for _, kind := range chartKinds {
+			if err := computeWeeklyChart(ctx, tx, user, kind, week); err != nil {
+				return 0, fmt.Errorf("computing %s chart for week %d: %w", kind, week, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO WeeklyChartWeek (user, week, listens, last_listen_id) VALUES (?, ?, ?, ?)", user, week, f.listens, f.lastListenID); err != nil {
+			return 0, fmt.Errorf("recording chart for week %d: %w", week, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
+
+func computeWeeklyChart(ctx context.Context, tx queryer, user string, kind ChartKind, week int64) error {
+	var query string
+	switch kind {
+	case ChartArtists:
+		query = `
+		SELECT Track.artist, '', COUNT(Listen.id)
+		FROM Listen
+		INNER JOIN Track ON Track.id = Listen.track
+		WHERE user = ? AND Listen.date BETWEEN ? AND ?
+		GROUP BY Track.artist`
+	case ChartAlbums:
+		query = `
+		SELECT Track.artist, Track.album, COUNT(Listen.id)
+		FROM Listen
+		INNER JOIN Track ON Track.id = Listen.track
+		WHERE user = ? AND Listen.date BETWEEN ? AND ? AND Track.album != ''
+		GROUP BY Track.artist, Track.album`
+	case ChartTracks:
+		query = `
+		SELECT Track.artist, Track.name, COUNT(Listen.id)
+		FROM Listen
+		INNER JOIN Track ON Track.id = Listen.track
+		WHERE user = ? AND Listen.date BETWEEN ? AND ?
+		GROUP BY Track.artist, Track.name`
+	default:
+		return fmt.Errorf("unknown chart kind %q", kind)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, user, week, week+int64(chartWeek/time.Second)-1)
+	if err != nil {
+		return err
+	}
+	var entries []ChartEntry
+	for rows.Next() {
+		var e ChartEntry
+		if err := rows.Scan(&e.Artist, &e.Name, &e.Count); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range rankChart(entries) {
+		_, err := tx.ExecContext(ctx, "INSERT INTO WeeklyChart (user, week, kind, artist, name, rank, count) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			user, week, string(kind), e.Artist, e.Name, e.Rank, e.Count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetWeeklyCharts returns the user's charts of the given kind for the weeks starting between start
+// (inclusive) and end (exclusive), ordered by week and then rank. Charts are only as current as
+// the last UpdateWeeklyCharts.
+func (s *SQLiteStore) GetWeeklyCharts(ctx context.Context, user string, kind ChartKind, start, end time.Time) ([]ChartEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT week, rank, artist, name, count
+		FROM WeeklyChart
+		WHERE user = ? AND kind = ? AND week >= ? AND week < ?
+		ORDER BY week, rank, artist, name`, user, string(kind), start.Unix(), end.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("querying weekly charts: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ChartEntry
+	for rows.Next() {
+		var e ChartEntry
+		var week int64
+		if err := rows.Scan(&week, &e.Rank, &e.Artist, &e.Name, &e.Count); err != nil {
+			return nil, err
+		}
+		e.Week = time.Unix(week, 0).UTC()
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func createChartTables(db *sql.DB) error {
+	query := `
+CREATE TABLE IF NOT EXISTS WeeklyChart (
+  user TEXT,
+  week INTEGER,
+  kind TEXT,
+  artist TEXT,
+  name TEXT,
+  rank INTEGER,
+  count INTEGER,
+  FOREIGN KEY (user) REFERENCES User(name),
+  PRIMARY KEY (user, kind, week, artist, name)
+);
+
+CREATE TABLE IF NOT EXISTS WeeklyChartWeek (
+  user TEXT,
+  week INTEGER,
+  listens INTEGER,
+  last_listen_id INTEGER,
+  FOREIGN KEY (user) REFERENCES User(name),
+  PRIMARY KEY (user, week)
+);
+`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("creating chart tables: %w", err)
+	}
+	return nil
+}
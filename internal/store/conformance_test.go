@@ -0,0 +1,796 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Both Store implementations must pass the same suite, so that analyses give the same results
+// whichever one they're run against.
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store { return createTestDb(t) })
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store { return NewMemory() })
+}
+
+func conformanceDate(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func conformanceListens(artist, album, track string, first time.Time, count int) []TrackImport {
+	var tracks []TrackImport
+	for i := 0; i < count; i++ {
+		date := first.Add(time.Duration(i) * time.Hour)
+		tracks = append(tracks, TrackImport{Artist: artist, Album: album, TrackName: track, DateUTS: fmt.Sprintf("%d", date.Unix())})
+	}
+	return tracks
+}
+
+// populateConformanceStore adds listens for alice:
+//   - Alpha: 5 listens of First in June 2019, then 5 of First and 2 of Second in March 2020
+//   - Beta: 4 listens without an album in April 2020
+//   - Gamma: 1 listen of Third in May 2020
+//
+// and a single listen of Alpha's First for bob.
+func populateConformanceStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	for _, user := range []string{"alice", "bob"} {
+		if err := s.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser(%q): %v", user, err)
+		}
+	}
+
+	var tracks []TrackImport
+	tracks = append(tracks, conformanceListens("Alpha", "First", "a1", conformanceDate(2019, 6, 1, 0), 5)...)
+	tracks = append(tracks, conformanceListens("Alpha", "First", "a2", conformanceDate(2020, 3, 1, 0), 5)...)
+	tracks = append(tracks, conformanceListens("Alpha", "Second", "a3", conformanceDate(2020, 3, 2, 0), 2)...)
+	tracks = append(tracks, conformanceListens("Beta", "", "b1", conformanceDate(2020, 4, 1, 0), 4)...)
+	tracks = append(tracks, conformanceListens("Gamma", "Third", "g1", conformanceDate(2020, 5, 1, 0), 1)...)
+	// Duplicates are ignored.
+	tracks = append(tracks, tracks[0])
+	if err := s.AddRecentTracks(ctx, "alice", tracks); err != nil {
+		t.Fatalf("AddRecentTracks(alice): %v", err)
+	}
+
+	if err := s.AddRecentTracks(ctx, "bob", conformanceListens("Alpha", "First", "a1", conformanceDate(2020, 3, 1, 0), 1)); err != nil {
+		t.Fatalf("AddRecentTracks(bob): %v", err)
+	}
+}
+
+func checkEqual(t *testing.T, name string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %+v, want %+v", name, got, want)
+	}
+}
+
+func testStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	ctx := context.Background()
+	allTime := [2]time.Time{time.Unix(0, 0), time.Unix(math.MaxInt32, 0)}
+	year2020 := [2]time.Time{conformanceDate(2020, 1, 1, 0), conformanceDate(2021, 1, 1, 0).Add(-time.Second)}
+
+	t.Run("Users", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+
+		if err := s.CreateUser(ctx, "alice"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := s.CreateUser(ctx, "alice"); err != nil {
+			t.Fatalf("CreateUser (repeat): %v", err)
+		}
+
+		updated, err := s.GetLastUpdated(ctx, "alice")
+		if err != nil || !updated.IsZero() {
+			t.Errorf("GetLastUpdated before update = %v, %v, want zero time", updated, err)
+		}
+		want := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := s.SetLastUpdated(ctx, "alice", want); err != nil {
+			t.Fatalf("SetLastUpdated: %v", err)
+		}
+		updated, err = s.GetLastUpdated(ctx, "alice")
+		if err != nil || !updated.Equal(want) {
+			t.Errorf("GetLastUpdated = %v, %v, want %v", updated, err, want)
+		}
+
+		key, err := s.GetSessionKey(ctx, "alice")
+		if err != nil || key != "" {
+			t.Errorf("GetSessionKey = %q, %v, want empty", key, err)
+		}
+
+		latest, err := s.GetLatestListen(ctx, "alice")
+		if err != nil || !latest.IsZero() {
+			t.Errorf("GetLatestListen without listens = %v, %v, want zero time", latest, err)
+		}
+		if _, err := s.GetFirstListen(ctx, "alice"); err == nil {
+			t.Errorf("GetFirstListen without listens succeeded, want error")
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		if err := s.CreateUser(ctx, "alice"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		err := s.AddRecentTracks(cancelled, "alice", conformanceListens("Alpha", "First", "a1", conformanceDate(2020, 1, 1, 0), 3))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("AddRecentTracks with cancelled context = %v, want %v", err, context.Canceled)
+		}
+		if _, err := s.GetTotalScrobbles(cancelled, "alice"); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetTotalScrobbles with cancelled context = %v, want %v", err, context.Canceled)
+		}
+
+		// Nothing from the cancelled import was saved.
+		total, err := s.GetTotalScrobbles(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetTotalScrobbles: %v", err)
+		}
+		checkEqual(t, "GetTotalScrobbles after cancelled import", total, int64(0))
+	})
+
+	t.Run("InvalidDate", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		if err := s.CreateUser(ctx, "alice"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		tracks := conformanceListens("Alpha", "First", "a1", conformanceDate(2020, 1, 1, 0), 2)
+		tracks = append(tracks, TrackImport{Artist: "Alpha", Album: "First", TrackName: "a2", DateUTS: "yesterday"})
+		if err := s.AddRecentTracks(ctx, "alice", tracks); err == nil {
+			t.Errorf("AddRecentTracks with an unparsable date succeeded, want an error")
+		}
+
+		// Nothing from the import was saved, including the valid listens.
+		total, err := s.GetTotalScrobbles(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetTotalScrobbles: %v", err)
+		}
+		checkEqual(t, "GetTotalScrobbles after invalid import", total, int64(0))
+	})
+
+	t.Run("RFC3339Dates", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		if err := s.CreateUser(ctx, "alice"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		// The second listen is on 2020-03-31 in UTC.
+		tracks := []TrackImport{
+			{Artist: "Alpha", Album: "First", TrackName: "a1", DateUTS: "2020-03-15T10:00:00Z"},
+			{Artist: "Alpha", Album: "First", TrackName: "a1", DateUTS: "2020-04-01T00:30:00+02:00"},
+		}
+		tracks = append(tracks, conformanceListens("Alpha", "First", "a1", conformanceDate(2020, 4, 2, 0), 1)...)
+		if err := s.AddRecentTracks(ctx, "alice", tracks); err != nil {
+			t.Fatalf("AddRecentTracks: %v", err)
+		}
+
+		monthlyCounts, err := s.GetArtistMonthlyCounts(ctx, "alice", allTime[0], allTime[1])
+		if err != nil {
+			t.Fatalf("GetArtistMonthlyCounts: %v", err)
+		}
+		checkEqual(t, "GetArtistMonthlyCounts", monthlyCounts, []ArtistMonthCount{
+			{"Alpha", conformanceDate(2020, 3, 1, 0), 2},
+			{"Alpha", conformanceDate(2020, 4, 1, 0), 1},
+		})
+		total, err := s.GetTotalScrobblesInPeriod(ctx, "alice", year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetTotalScrobblesInPeriod: %v", err)
+		}
+		checkEqual(t, "GetTotalScrobblesInPeriod", total, int64(3))
+	})
+
+	t.Run("Totals", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		total, err := s.GetTotalScrobbles(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetTotalScrobbles: %v", err)
+		}
+		checkEqual(t, "GetTotalScrobbles(alice)", total, int64(17))
+		total, err = s.GetTotalScrobbles(ctx, "bob")
+		if err != nil {
+			t.Fatalf("GetTotalScrobbles: %v", err)
+		}
+		checkEqual(t, "GetTotalScrobbles(bob)", total, int64(1))
+
+		total, err = s.GetTotalScrobblesInPeriod(ctx, "alice", year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetTotalScrobblesInPeriod: %v", err)
+		}
+		checkEqual(t, "GetTotalScrobblesInPeriod", total, int64(12))
+
+		artists, err := s.GetTotalArtists(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetTotalArtists: %v", err)
+		}
+		checkEqual(t, "GetTotalArtists", artists, 3)
+
+		first, err := s.GetFirstListen(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetFirstListen: %v", err)
+		}
+		checkEqual(t, "GetFirstListen", first.Unix(), conformanceDate(2019, 6, 1, 0).Unix())
+
+		latest, err := s.GetLatestListen(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetLatestListen: %v", err)
+		}
+		checkEqual(t, "GetLatestListen", latest.Unix(), conformanceDate(2020, 5, 1, 0).Unix())
+
+		newArtists, err := s.GetNewArtistsCount(ctx, "alice", year2020[0])
+		if err != nil {
+			t.Fatalf("GetNewArtistsCount: %v", err)
+		}
+		checkEqual(t, "GetNewArtistsCount", newArtists, 2)
+
+		// The end of the range is exclusive.
+		listens, err := s.GetListensInRange(ctx, "alice", conformanceDate(2020, 4, 1, 0), conformanceDate(2020, 4, 1, 2))
+		if err != nil {
+			t.Fatalf("GetListensInRange: %v", err)
+		}
+		var got []int64
+		for _, l := range listens {
+			got = append(got, l.Unix())
+		}
+		checkEqual(t, "GetListensInRange", got, []int64{conformanceDate(2020, 4, 1, 0).Unix(), conformanceDate(2020, 4, 1, 1).Unix()})
+
+		history, err := s.GetListenHistory(ctx, "alice", conformanceDate(2020, 3, 1, 4), conformanceDate(2020, 3, 2, 1))
+		if err != nil {
+			t.Fatalf("GetListenHistory: %v", err)
+		}
+		checkEqual(t, "GetListenHistory", history, []TrackListen{
+			{time.Unix(conformanceDate(2020, 3, 1, 4).Unix(), 0), "Alpha", "First", "a2"},
+			{time.Unix(conformanceDate(2020, 3, 2, 0).Unix(), 0), "Alpha", "Second", "a3"},
+		})
+	})
+
+	t.Run("TopArtistsAndAlbums", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		topArtists, err := s.GetTopArtists(ctx, "alice", year2020[0], year2020[1], 2)
+		if err != nil {
+			t.Fatalf("GetTopArtists: %v", err)
+		}
+		checkEqual(t, "GetTopArtists", topArtists, []ArtistScrobbleCount{{"Alpha", 7}, {"Beta", 4}})
+
+		artistCounts, err := s.GetTopArtistsWithCount(ctx, "alice", year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetTopArtistsWithCount: %v", err)
+		}
+		checkEqual(t, "GetTopArtistsWithCount", artistCounts, []ArtistPlayCount{{"Alpha", 7}, {"Beta", 4}, {"Gamma", 1}})
+
+		monthlyCounts, err := s.GetArtistMonthlyCounts(ctx, "alice", conformanceDate(2019, 6, 1, 0), conformanceDate(2020, 5, 1, 0))
+		if err != nil {
+			t.Fatalf("GetArtistMonthlyCounts: %v", err)
+		}
+		checkEqual(t, "GetArtistMonthlyCounts", monthlyCounts, []ArtistMonthCount{
+			{"Alpha", conformanceDate(2019, 6, 1, 0), 5},
+			{"Alpha", conformanceDate(2020, 3, 1, 0), 7},
+			{"Beta", conformanceDate(2020, 4, 1, 0), 4},
+		})
+
+		topAlbums, err := s.GetTopAlbums(ctx, "alice", year2020[0], year2020[1], 10)
+		if err != nil {
+			t.Fatalf("GetTopAlbums: %v", err)
+		}
+		checkEqual(t, "GetTopAlbums", topAlbums, []AlbumScrobbleCount{{"First", "Alpha", 5}, {"Second", "Alpha", 2}, {"Third", "Gamma", 1}})
+
+		albumCounts, err := s.GetTopAlbumsWithCount(ctx, "alice", year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetTopAlbumsWithCount: %v", err)
+		}
+		checkEqual(t, "GetTopAlbumsWithCount", albumCounts, []AlbumPlayCount{{"Alpha", "First", 5}, {"Beta", "", 4}, {"Alpha", "Second", 2}, {"Gamma", "Third", 1}})
+
+		artistAlbums, err := s.GetTopAlbumsForArtist(ctx, "alice", "Alpha", allTime[0], allTime[1], 1)
+		if err != nil {
+			t.Fatalf("GetTopAlbumsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopAlbumsForArtist", artistAlbums, []TagCount{{"First", 10}})
+
+		listens, err := s.GetArtistListenCount(ctx, "alice", "Alpha", year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetArtistListenCount: %v", err)
+		}
+		checkEqual(t, "GetArtistListenCount", listens, int64(7))
+
+		// Ordering isn't specified, so sort before comparing.
+		listenCounts, err := s.GetAlbumListenCounts(ctx, "alice", allTime[0], allTime[1])
+		if err != nil {
+			t.Fatalf("GetAlbumListenCounts: %v", err)
+		}
+		sort.Slice(listenCounts, func(i, j int) bool {
+			return listenCounts[i].Artist+listenCounts[i].Title < listenCounts[j].Artist+listenCounts[j].Title
+		})
+		checkEqual(t, "GetAlbumListenCounts", listenCounts, []AlbumScrobbleCount{{"First", "Alpha", 10}, {"Second", "Alpha", 2}, {"", "Beta", 4}, {"Third", "Gamma", 1}})
+	})
+
+	t.Run("TopTracks", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		// Listens of a track on another album are counted with the original.
+		if err := s.AddRecentTracks(ctx, "alice", conformanceListens("Alpha", "Live", "a3", conformanceDate(2020, 6, 1, 0), 3)); err != nil {
+			t.Fatalf("AddRecentTracks: %v", err)
+		}
+		trackCounts, err := s.GetTopTracksWithCount(ctx, "alice", year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetTopTracksWithCount: %v", err)
+		}
+		checkEqual(t, "GetTopTracksWithCount", trackCounts, []TrackPlayCount{{"Alpha", "a2", 5}, {"Alpha", "a3", 5}, {"Beta", "b1", 4}, {"Gamma", "g1", 1}})
+	})
+
+	t.Run("WeeklyCharts", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		// The week of Gamma's listen on 2020-05-01 hasn't finished yet.
+		now := conformanceDate(2020, 5, 2, 0)
+		weeks, err := s.UpdateWeeklyCharts(ctx, "alice", now)
+		if err != nil {
+			t.Fatalf("UpdateWeeklyCharts: %v", err)
+		}
+		checkEqual(t, "UpdateWeeklyCharts", weeks, 4)
+		weeks, err = s.UpdateWeeklyCharts(ctx, "alice", now)
+		if err != nil {
+			t.Fatalf("UpdateWeeklyCharts: %v", err)
+		}
+		checkEqual(t, "UpdateWeeklyCharts again", weeks, 0)
+
+		artists, err := s.GetWeeklyCharts(ctx, "alice", ChartArtists, allTime[0], allTime[1])
+		if err != nil {
+			t.Fatalf("GetWeeklyCharts: %v", err)
+		}
+		checkEqual(t, "GetWeeklyCharts(artists)", artists, []ChartEntry{
+			{conformanceDate(2019, 5, 27, 0), 1, "Alpha", "", 5},
+			{conformanceDate(2020, 2, 24, 0), 1, "Alpha", "", 5},
+			{conformanceDate(2020, 3, 2, 0), 1, "Alpha", "", 2},
+			{conformanceDate(2020, 3, 30, 0), 1, "Beta", "", 4},
+		})
+
+		// Listens without an album aren't charted as albums.
+		albums, err := s.GetWeeklyCharts(ctx, "alice", ChartAlbums, year2020[0], year2020[1])
+		if err != nil {
+			t.Fatalf("GetWeeklyCharts: %v", err)
+		}
+		checkEqual(t, "GetWeeklyCharts(albums)", albums, []ChartEntry{
+			{conformanceDate(2020, 2, 24, 0), 1, "Alpha", "First", 5},
+			{conformanceDate(2020, 3, 2, 0), 1, "Alpha", "Second", 2},
+		})
+
+		// New listens in a charted week recompute it, and ties share a rank.
+		if err := s.AddRecentTracks(ctx, "alice", conformanceListens("Gamma", "Third", "g1", conformanceDate(2020, 3, 4, 0), 2)); err != nil {
+			t.Fatalf("AddRecentTracks: %v", err)
+		}
+		weeks, err = s.UpdateWeeklyCharts(ctx, "alice", now)
+		if err != nil {
+			t.Fatalf("UpdateWeeklyCharts: %v", err)
+		}
+		checkEqual(t, "UpdateWeeklyCharts after new listens", weeks, 1)
+		tracks, err := s.GetWeeklyCharts(ctx, "alice", ChartTracks, conformanceDate(2020, 3, 2, 0), conformanceDate(2020, 3, 9, 0))
+		if err != nil {
+			t.Fatalf("GetWeeklyCharts: %v", err)
+		}
+		checkEqual(t, "GetWeeklyCharts(tracks)", tracks, []ChartEntry{
+			{conformanceDate(2020, 3, 2, 0), 1, "Alpha", "a3", 2},
+			{conformanceDate(2020, 3, 2, 0), 1, "Gamma", "g1", 2},
+		})
+	})
+
+	t.Run("ListeningPatterns", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		peak, err := s.GetPeakYears(ctx, "alice", "Alpha")
+		if err != nil {
+			t.Fatalf("GetPeakYears: %v", err)
+		}
+		checkEqual(t, "GetPeakYears(Alpha)", peak, "2019-2020")
+		peak, err = s.GetPeakYears(ctx, "alice", "Beta")
+		if err != nil {
+			t.Fatalf("GetPeakYears: %v", err)
+		}
+		checkEqual(t, "GetPeakYears(Beta)", peak, "2020")
+		peak, err = s.GetPeakYears(ctx, "bob", "Beta")
+		if err != nil {
+			t.Fatalf("GetPeakYears: %v", err)
+		}
+		checkEqual(t, "GetPeakYears(unplayed)", peak, "")
+
+		albumTracks, err := s.GetAlbumTracks(ctx, "Alpha", "First")
+		if err != nil {
+			t.Fatalf("GetAlbumTracks: %v", err)
+		}
+		checkEqual(t, "GetAlbumTracks", albumTracks, []string{"a1", "a2"})
+
+		stats, err := s.GetArtistAlbumStats(ctx, "alice", allTime[0], allTime[1])
+		if err != nil {
+			t.Fatalf("GetArtistAlbumStats: %v", err)
+		}
+		checkEqual(t, "GetArtistAlbumStats", stats, []ArtistAlbumStats{{"Alpha", 2, 12}, {"Gamma", 1, 1}})
+	})
+
+	t.Run("Forgotten", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		opts := ForgottenQueryOptions{
+			MinScrobbles:      2,
+			LastListenAfter:   0,
+			LastListenBefore:  conformanceDate(2020, 4, 30, 0).Unix(),
+			FirstListenAfter:  0,
+			FirstListenBefore: math.MaxInt32,
+		}
+
+		artists, err := s.GetForgottenArtists(ctx, "alice", opts)
+		if err != nil {
+			t.Fatalf("GetForgottenArtists: %v", err)
+		}
+		sort.Slice(artists, func(i, j int) bool { return artists[i].Artist < artists[j].Artist })
+		checkEqual(t, "GetForgottenArtists", artists, []ArtistListenStats{
+			{"Alpha", 12, time.Unix(conformanceDate(2019, 6, 1, 0).Unix(), 0), time.Unix(conformanceDate(2020, 3, 2, 1).Unix(), 0)},
+			{"Beta", 4, time.Unix(conformanceDate(2020, 4, 1, 0).Unix(), 0), time.Unix(conformanceDate(2020, 4, 1, 3).Unix(), 0)},
+		})
+
+		// Listens before 2020-03-02 01:00, so the second of Alpha's Second isn't included.
+		artists, err = s.GetArtistListenStats(ctx, "alice", conformanceDate(2019, 1, 1, 0), conformanceDate(2020, 3, 2, 1))
+		if err != nil {
+			t.Fatalf("GetArtistListenStats: %v", err)
+		}
+		checkEqual(t, "GetArtistListenStats", artists, []ArtistListenStats{
+			{"Alpha", 11, time.Unix(conformanceDate(2019, 6, 1, 0).Unix(), 0), time.Unix(conformanceDate(2020, 3, 2, 0).Unix(), 0)},
+		})
+
+		albums, err := s.GetForgottenAlbums(ctx, "alice", opts)
+		if err != nil {
+			t.Fatalf("GetForgottenAlbums: %v", err)
+		}
+		sort.Slice(albums, func(i, j int) bool { return albums[i].Album < albums[j].Album })
+		checkEqual(t, "GetForgottenAlbums", albums, []AlbumListenStats{
+			{"Alpha", "First", 10, time.Unix(conformanceDate(2019, 6, 1, 0).Unix(), 0), time.Unix(conformanceDate(2020, 3, 1, 4).Unix(), 0)},
+			{"Alpha", "Second", 2, time.Unix(conformanceDate(2020, 3, 2, 0).Unix(), 0), time.Unix(conformanceDate(2020, 3, 2, 1).Unix(), 0)},
+		})
+
+		tracks, err := s.GetForgottenTracks(ctx, "alice", opts)
+		if err != nil {
+			t.Fatalf("GetForgottenTracks: %v", err)
+		}
+		sort.Slice(tracks, func(i, j int) bool { return tracks[i].Track < tracks[j].Track })
+		checkEqual(t, "GetForgottenTracks", tracks, []TrackListenStats{
+			{"Alpha", "a1", 5, time.Unix(conformanceDate(2019, 6, 1, 0).Unix(), 0), time.Unix(conformanceDate(2019, 6, 1, 4).Unix(), 0)},
+			{"Alpha", "a2", 5, time.Unix(conformanceDate(2020, 3, 1, 0).Unix(), 0), time.Unix(conformanceDate(2020, 3, 1, 4).Unix(), 0)},
+			{"Alpha", "a3", 2, time.Unix(conformanceDate(2020, 3, 2, 0).Unix(), 0), time.Unix(conformanceDate(2020, 3, 2, 1).Unix(), 0)},
+			{"Beta", "b1", 4, time.Unix(conformanceDate(2020, 4, 1, 0).Unix(), 0), time.Unix(conformanceDate(2020, 4, 1, 3).Unix(), 0)},
+		})
+	})
+
+	t.Run("ForgottenFeedback", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		marked := time.Unix(conformanceDate(2021, 1, 1, 0).Unix(), 0)
+		until := time.Unix(conformanceDate(2021, 4, 1, 0).Unix(), 0)
+		for _, f := range []ForgottenFeedback{
+			{Kind: FeedbackTrack, Artist: "Alpha", Name: "a1", State: FeedbackIgnored, Marked: marked},
+			{Kind: FeedbackArtist, Artist: "Beta", State: FeedbackRevisited, Marked: marked},
+			{Kind: FeedbackAlbum, Artist: "Alpha", Name: "First", State: FeedbackIgnored, Marked: marked},
+			// Replaces the album's earlier feedback.
+			{Kind: FeedbackAlbum, Artist: "Alpha", Name: "First", State: FeedbackSnoozed, Marked: marked, Until: until},
+		} {
+			if err := s.SetForgottenFeedback(ctx, "alice", f); err != nil {
+				t.Fatalf("SetForgottenFeedback(%+v): %v", f, err)
+			}
+		}
+		for _, bad := range []ForgottenFeedback{
+			{Kind: FeedbackAlbum, Artist: "Alpha", State: FeedbackIgnored, Marked: marked},
+			{Kind: FeedbackArtist, Artist: "Alpha", State: "forgotten", Marked: marked},
+			{Kind: FeedbackArtist, Artist: "Alpha", State: FeedbackSnoozed, Marked: until, Until: marked},
+		} {
+			if err := s.SetForgottenFeedback(ctx, "alice", bad); err == nil {
+				t.Errorf("SetForgottenFeedback(%+v) succeeded", bad)
+			}
+		}
+
+		feedback, err := s.GetForgottenFeedback(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetForgottenFeedback: %v", err)
+		}
+		checkEqual(t, "GetForgottenFeedback", feedback, []ForgottenFeedback{
+			{FeedbackAlbum, "Alpha", "First", FeedbackSnoozed, marked, until},
+			{FeedbackArtist, "Beta", "", FeedbackRevisited, marked, time.Time{}},
+			{FeedbackTrack, "Alpha", "a1", FeedbackIgnored, marked, time.Time{}},
+		})
+		feedback, err = s.GetForgottenFeedback(ctx, "bob")
+		if err != nil {
+			t.Fatalf("GetForgottenFeedback(bob): %v", err)
+		}
+		checkEqual(t, "GetForgottenFeedback(bob)", len(feedback), 0)
+
+		deleted, err := s.DeleteForgottenFeedback(ctx, "alice", FeedbackArtist, "Beta", "")
+		if err != nil || !deleted {
+			t.Errorf("DeleteForgottenFeedback = %v, %v, want true", deleted, err)
+		}
+		deleted, err = s.DeleteForgottenFeedback(ctx, "bob", FeedbackTrack, "Alpha", "a1")
+		if err != nil || deleted {
+			t.Errorf("DeleteForgottenFeedback(bob) = %v, %v, want false", deleted, err)
+		}
+		feedback, err = s.GetForgottenFeedback(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetForgottenFeedback: %v", err)
+		}
+		checkEqual(t, "GetForgottenFeedback after delete", len(feedback), 2)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		// Listens from every user count towards the update threshold.
+		artists, err := s.GetArtistsNeedingTagUpdate(ctx, 24*time.Hour)
+		if err != nil {
+			t.Fatalf("GetArtistsNeedingTagUpdate: %v", err)
+		}
+		checkEqual(t, "GetArtistsNeedingTagUpdate", artists, []string{"Alpha"})
+		albums, err := s.GetAlbumsNeedingTagUpdate(ctx, 24*time.Hour)
+		if err != nil {
+			t.Fatalf("GetAlbumsNeedingTagUpdate: %v", err)
+		}
+		checkEqual(t, "GetAlbumsNeedingTagUpdate", albums, []AlbumKey{{"Alpha", "First"}})
+
+		if err := s.SaveArtistTags(ctx, "Alpha", []string{"rock", "indie"}, []int{100, 50}); err != nil {
+			t.Fatalf("SaveArtistTags: %v", err)
+		}
+		// Saving again replaces the count of existing tags.
+		if err := s.SaveArtistTags(ctx, "Alpha", []string{"indie"}, []int{120}); err != nil {
+			t.Fatalf("SaveArtistTags: %v", err)
+		}
+		if err := s.SaveAlbumTags(ctx, "Alpha", "First", []string{"jazz"}, []int{10}); err != nil {
+			t.Fatalf("SaveAlbumTags: %v", err)
+		}
+
+		artists, err = s.GetArtistsNeedingTagUpdate(ctx, 24*time.Hour)
+		if err != nil {
+			t.Fatalf("GetArtistsNeedingTagUpdate: %v", err)
+		}
+		if len(artists) != 0 {
+			t.Errorf("GetArtistsNeedingTagUpdate after update = %v, want none", artists)
+		}
+		albums, err = s.GetAlbumsNeedingTagUpdate(ctx, 24*time.Hour)
+		if err != nil {
+			t.Fatalf("GetAlbumsNeedingTagUpdate: %v", err)
+		}
+		if len(albums) != 0 {
+			t.Errorf("GetAlbumsNeedingTagUpdate after update = %v, want none", albums)
+		}
+
+		tags, err := s.GetTopTagsForArtist(ctx, "Alpha", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForArtist", tags, []string{"indie", "rock"})
+		tags, err = s.GetTopTagsForArtist(ctx, "Alpha", 1)
+		if err != nil {
+			t.Fatalf("GetTopTagsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForArtist(limit 1)", tags, []string{"indie"})
+		tags, err = s.GetTopTagsForAlbum(ctx, "Alpha", "First", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForAlbum: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForAlbum", tags, []string{"jazz"})
+
+		artistTags, err := s.GetAllArtistTags(ctx)
+		if err != nil {
+			t.Fatalf("GetAllArtistTags: %v", err)
+		}
+		sort.Slice(artistTags, func(i, j int) bool { return artistTags[i].Tag < artistTags[j].Tag })
+		checkEqual(t, "GetAllArtistTags", artistTags, []ArtistTagData{{"Alpha", "indie", 120}, {"Alpha", "rock", 100}})
+		albumTags, err := s.GetAllAlbumTags(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAlbumTags: %v", err)
+		}
+		checkEqual(t, "GetAllAlbumTags", albumTags, []AlbumTagData{{"Alpha", "First", "jazz", 10}})
+	})
+
+	t.Run("TagRules", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		if err := s.SaveArtistTags(ctx, "Alpha", []string{"Hip-Hop", "hip hop", "rap", "seen live", "alpha", "90s rock"}, []int{100, 80, 60, 50, 40, 30}); err != nil {
+			t.Fatalf("SaveArtistTags: %v", err)
+		}
+		if err := s.SaveAlbumTags(ctx, "Alpha", "First", []string{"Rap", "seen live"}, []int{10, 5}); err != nil {
+			t.Fatalf("SaveAlbumTags: %v", err)
+		}
+		for _, r := range []TagRule{
+			{Kind: TagRuleSynonym, Pattern: "Rap", Replacement: "hip hop"},
+			{Kind: TagRuleBlock, Pattern: "Seen Live"},
+			{Kind: TagRuleRegex, Pattern: `^\d+s (.*)$`, Replacement: "$1"},
+		} {
+			if err := s.AddTagRule(ctx, r); err != nil {
+				t.Fatalf("AddTagRule(%+v): %v", r, err)
+			}
+		}
+		if err := s.AddTagRule(ctx, TagRule{Kind: TagRuleRegex, Pattern: "("}); err == nil {
+			t.Errorf("AddTagRule with an invalid regex succeeded")
+		}
+
+		rules, err := s.GetTagRules(ctx)
+		if err != nil {
+			t.Fatalf("GetTagRules: %v", err)
+		}
+		checkEqual(t, "GetTagRules", rules, []TagRule{
+			{TagRuleSynonym, "rap", "hip hop"},
+			{TagRuleBlock, "seen live", ""},
+			{TagRuleRegex, `^\d+s (.*)$`, "$1"},
+		})
+
+		raw, err := s.GetArtistTagCounts(ctx, "Alpha")
+		if err != nil {
+			t.Fatalf("GetArtistTagCounts: %v", err)
+		}
+		checkEqual(t, "GetArtistTagCounts", raw, []TagCount{{"Hip-Hop", 100}, {"hip hop", 80}, {"rap", 60}, {"seen live", 50}, {"alpha", 40}, {"90s rock", 30}})
+
+		// Tags with the same canonical form are merged, and the artist's own name is dropped.
+		tags, err := s.GetTopTagsForArtist(ctx, "Alpha", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForArtist", tags, []string{"hip hop", "rock"})
+		tags, err = s.GetTopTagsForAlbum(ctx, "Alpha", "First", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForAlbum: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForAlbum", tags, []string{"hip hop"})
+		artistTags, err := s.GetAllArtistTags(ctx)
+		if err != nil {
+			t.Fatalf("GetAllArtistTags: %v", err)
+		}
+		checkEqual(t, "GetAllArtistTags", artistTags, []ArtistTagData{{"Alpha", "hip hop", 100}, {"Alpha", "rock", 30}})
+		albumTags, err := s.GetAllAlbumTags(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAlbumTags: %v", err)
+		}
+		checkEqual(t, "GetAllAlbumTags", albumTags, []AlbumTagData{{"Alpha", "First", "hip hop", 10}})
+
+		deleted, err := s.DeleteTagRule(ctx, TagRuleBlock, "seen live")
+		if err != nil || !deleted {
+			t.Fatalf("DeleteTagRule = %v, %v, want true", deleted, err)
+		}
+		deleted, err = s.DeleteTagRule(ctx, TagRuleBlock, "seen live")
+		if err != nil || deleted {
+			t.Fatalf("DeleteTagRule again = %v, %v, want false", deleted, err)
+		}
+		tags, err = s.GetTopTagsForArtist(ctx, "Alpha", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForArtist after delete", tags, []string{"hip hop", "seen live", "rock"})
+	})
+
+	t.Run("PersonalTags", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		populateConformanceStore(t, s)
+
+		if err := s.SaveArtistTags(ctx, "Alpha", []string{"rock", "indie"}, []int{100, 50}); err != nil {
+			t.Fatalf("SaveArtistTags: %v", err)
+		}
+		if err := s.SaveAlbumTags(ctx, "Alpha", "First", []string{"jazz"}, []int{10}); err != nil {
+			t.Fatalf("SaveAlbumTags: %v", err)
+		}
+		if err := s.AddPersonalTags(ctx, "Alpha", "", []string{"Dream-Pop", "slowcore"}); err != nil {
+			t.Fatalf("AddPersonalTags: %v", err)
+		}
+		if err := s.AddPersonalTags(ctx, "Alpha", "First", []string{"ambient"}); err != nil {
+			t.Fatalf("AddPersonalTags: %v", err)
+		}
+		if err := s.SetPersonalTagsReplace(ctx, "Alpha", "First", true); err != nil {
+			t.Fatalf("SetPersonalTagsReplace: %v", err)
+		}
+		if err := s.SetPersonalTagsReplace(ctx, "Beta", "", true); err == nil {
+			t.Errorf("SetPersonalTagsReplace without personal tags succeeded")
+		}
+		// Artists don't need fetched tags to have personal tags.
+		if err := s.AddPersonalTags(ctx, "Beta", "", []string{"jazz"}); err != nil {
+			t.Fatalf("AddPersonalTags: %v", err)
+		}
+		// Updating the fetched tags keeps the personal tags.
+		if err := s.SaveArtistTags(ctx, "Alpha", []string{"indie"}, []int{60}); err != nil {
+			t.Fatalf("SaveArtistTags: %v", err)
+		}
+
+		personal, err := s.GetPersonalTags(ctx)
+		if err != nil {
+			t.Fatalf("GetPersonalTags: %v", err)
+		}
+		checkEqual(t, "GetPersonalTags", personal, []PersonalTags{
+			{"Alpha", "", []string{"dream pop", "slowcore"}, false},
+			{"Alpha", "First", []string{"ambient"}, true},
+			{"Beta", "", []string{"jazz"}, false},
+		})
+
+		tags, err := s.GetTopTagsForArtist(ctx, "Alpha", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForArtist", tags, []string{"dream pop", "rock", "slowcore", "indie"})
+		tags, err = s.GetTopTagsForAlbum(ctx, "Alpha", "First", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForAlbum: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForAlbum", tags, []string{"ambient"})
+		artistTags, err := s.GetAllArtistTags(ctx)
+		if err != nil {
+			t.Fatalf("GetAllArtistTags: %v", err)
+		}
+		checkEqual(t, "GetAllArtistTags", artistTags, []ArtistTagData{
+			{"Alpha", "dream pop", PersonalTagCount}, {"Alpha", "indie", 60}, {"Alpha", "rock", 100}, {"Alpha", "slowcore", PersonalTagCount},
+			{"Beta", "jazz", PersonalTagCount},
+		})
+		albumTags, err := s.GetAllAlbumTags(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAlbumTags: %v", err)
+		}
+		checkEqual(t, "GetAllAlbumTags", albumTags, []AlbumTagData{{"Alpha", "First", "ambient", PersonalTagCount}})
+
+		// Tag rules apply to personal tags too.
+		if err := s.AddTagRule(ctx, TagRule{Kind: TagRuleSynonym, Pattern: "slowcore", Replacement: "sadcore"}); err != nil {
+			t.Fatalf("AddTagRule: %v", err)
+		}
+		tags, err = s.GetTopTagsForArtist(ctx, "Alpha", 3)
+		if err != nil {
+			t.Fatalf("GetTopTagsForArtist: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForArtist with rule", tags, []string{"dream pop", "rock", "sadcore"})
+
+		removed, err := s.RemovePersonalTags(ctx, "Alpha", "", []string{"Dream Pop", "shoegaze"})
+		if err != nil || removed != 1 {
+			t.Fatalf("RemovePersonalTags = %d, %v, want 1", removed, err)
+		}
+		removed, err = s.RemovePersonalTags(ctx, "Alpha", "First", nil)
+		if err != nil || removed != 1 {
+			t.Fatalf("RemovePersonalTags(all) = %d, %v, want 1", removed, err)
+		}
+		// Without personal tags, the album's fetched tags are used again.
+		tags, err = s.GetTopTagsForAlbum(ctx, "Alpha", "First", 10)
+		if err != nil {
+			t.Fatalf("GetTopTagsForAlbum: %v", err)
+		}
+		checkEqual(t, "GetTopTagsForAlbum after remove", tags, []string{"jazz"})
+		personal, err = s.GetPersonalTags(ctx)
+		if err != nil {
+			t.Fatalf("GetPersonalTags: %v", err)
+		}
+		checkEqual(t, "GetPersonalTags after remove", personal, []PersonalTags{
+			{"Alpha", "", []string{"slowcore"}, false},
+			{"Beta", "", []string{"jazz"}, false},
+		})
+	})
+}
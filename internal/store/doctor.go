@@ -0,0 +1,548 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IntegrityIssue describes the result of a single database integrity check.
+type IntegrityIssue struct {
+	Check       string
+	Description string
+	Count       int
+	// Examples holds up to maxIntegrityExamples offending rows, for display.
+	Examples []string
+	// Fixed is the number of rows changed by RepairIntegrity. Always zero for CheckIntegrity.
+	Fixed int64
+}
+
+const maxIntegrityExamples = 5
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type integrityCheck struct {
+	name        string
+	description string
+	find        func(ctx context.Context, q queryer, now time.Time) ([]string, error)
+	fix         func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error)
+}
+
+// integrityChecks is ordered so that earlier fixes don't create new problems for later checks, e.g.
+// deleting listens for empty artists happens before looking for orphaned listens.
+var integrityChecks = []integrityCheck{
+	{
+		name:        "schema-drift",
+		description: "Tables, columns or indexes missing compared to the expected schema",
+		find:        findSchemaDrift,
+		fix:         fixSchemaDrift,
+	},
+	{
+		name:        "unparsable-dates",
+		description: "Listens whose date is empty or can't be parsed",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			ids, dates, err := unparsableListens(ctx, q)
+			if err != nil {
+				return nil, err
+			}
+			var found []string
+			for i := range ids {
+				found = append(found, fmt.Sprintf("listen %d (date %q)", ids[i], dates[i]))
+			}
+			return found, nil
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			ids, _, err := unparsableListens(ctx, tx)
+			if err != nil {
+				return 0, err
+			}
+			var fixed int64
+			for _, id := range ids {
+				res, err := tx.ExecContext(ctx, "DELETE FROM Listen WHERE id = ?", id)
+				if err != nil {
+					return fixed, fmt.Errorf("deleting listen %d: %w", id, err)
+				}
+				n, _ := res.RowsAffected()
+				fixed += n
+			}
+			return fixed, nil
+		},
+	},
+	{
+		name:        "future-timestamps",
+		description: "Listens dated after the current time",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			ids, dates, err := futureListens(ctx, q, now)
+			if err != nil {
+				return nil, err
+			}
+			var found []string
+			for i := range ids {
+				found = append(found, fmt.Sprintf("listen %d (%s)", ids[i], dates[i].UTC().Format("2006-01-02 15:04:05")))
+			}
+			return found, nil
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			ids, _, err := futureListens(ctx, tx, now)
+			if err != nil {
+				return 0, err
+			}
+			var fixed int64
+			for _, id := range ids {
+				res, err := tx.ExecContext(ctx, "DELETE FROM Listen WHERE id = ?", id)
+				if err != nil {
+					return fixed, fmt.Errorf("deleting listen %d: %w", id, err)
+				}
+				n, _ := res.RowsAffected()
+				fixed += n
+			}
+			return fixed, nil
+		},
+	},
+	{
+		name:        "duplicate-listens",
+		description: "Listens with the same user, track and date as an earlier listen",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			return queryStrings(ctx, q, `
+				SELECT 'listen ' || l.id || ' duplicates ' || d.keep
+				FROM Listen l
+				JOIN (
+					SELECT user, track, date, MIN(id) AS keep
+					FROM Listen
+					GROUP BY user, track, date
+					HAVING COUNT(*) > 1
+				) d ON l.user = d.user AND l.track = d.track AND l.date = d.date
+				WHERE l.id != d.keep
+			`)
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			return execAffected(ctx, tx, `
+				DELETE FROM Listen
+				WHERE id NOT IN (SELECT MIN(id) FROM Listen GROUP BY user, track, date)
+			`)
+		},
+	},
+	{
+		name:        "empty-artist-names",
+		description: "Tracks, albums or artists with an empty artist name",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			return queryStrings(ctx, q, `
+				SELECT 'track ' || id || ' (' || name || ')' FROM Track WHERE artist IS NULL OR TRIM(artist) = ''
+				UNION ALL
+				SELECT 'album ' || quote(name) FROM Album WHERE artist IS NULL OR TRIM(artist) = ''
+				UNION ALL
+				SELECT 'artist ' || quote(name) FROM Artist WHERE name IS NULL OR TRIM(name) = ''
+			`)
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			queries := []string{
+				"DELETE FROM Listen WHERE track IN (SELECT id FROM Track WHERE artist IS NULL OR TRIM(artist) = '')",
+				"DELETE FROM Track WHERE artist IS NULL OR TRIM(artist) = ''",
+				"DELETE FROM AlbumTag WHERE artist IS NULL OR TRIM(artist) = ''",
+				"DELETE FROM Album WHERE artist IS NULL OR TRIM(artist) = ''",
+				"DELETE FROM ArtistTag WHERE artist IS NULL OR TRIM(artist) = ''",
+				"DELETE FROM Artist WHERE name IS NULL OR TRIM(name) = ''",
+			}
+			var fixed int64
+			for _, query := range queries {
+				n, err := execAffected(ctx, tx, query)
+				if err != nil {
+					return fixed, err
+				}
+				fixed += n
+			}
+			return fixed, nil
+		},
+	},
+	{
+		name:        "orphan-listens",
+		description: "Listens referencing a track that doesn't exist",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			return queryStrings(ctx, q, `
+				SELECT 'listen ' || l.id || ' (track ' || l.track || ')'
+				FROM Listen l
+				WHERE NOT EXISTS (SELECT 1 FROM Track t WHERE t.id = l.track)
+			`)
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			return execAffected(ctx, tx, `
+				DELETE FROM Listen
+				WHERE NOT EXISTS (SELECT 1 FROM Track t WHERE t.id = Listen.track)
+			`)
+		},
+	},
+	{
+		name:        "orphan-tracks",
+		description: "Tracks whose artist or album has no row (repaired by creating it)",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			return queryStrings(ctx, q, `
+				SELECT 'track ' || t.id || ': missing artist ' || quote(t.artist)
+				FROM Track t
+				WHERE NOT EXISTS (SELECT 1 FROM Artist a WHERE a.name = t.artist)
+				UNION ALL
+				SELECT 'track ' || t.id || ': missing album ' || quote(t.album)
+				FROM Track t
+				WHERE NOT EXISTS (SELECT 1 FROM Album a WHERE a.artist = t.artist AND a.name = t.album)
+			`)
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			artists, err := execAffected(ctx, tx, `
+				INSERT INTO Artist (name)
+				SELECT DISTINCT t.artist FROM Track t
+				WHERE NOT EXISTS (SELECT 1 FROM Artist a WHERE a.name = t.artist)
+			`)
+			if err != nil {
+				return 0, err
+			}
+			albums, err := execAffected(ctx, tx, `
+				INSERT INTO Album (artist, name)
+				SELECT DISTINCT t.artist, t.album FROM Track t
+				WHERE NOT EXISTS (SELECT 1 FROM Album a WHERE a.artist = t.artist AND a.name = t.album)
+			`)
+			return artists + albums, err
+		},
+	},
+	{
+		name:        "orphan-albums",
+		description: "Albums whose artist has no row (repaired by creating it)",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			return queryStrings(ctx, q, `
+				SELECT 'album ' || quote(al.name) || ': missing artist ' || quote(al.artist)
+				FROM Album al
+				WHERE NOT EXISTS (SELECT 1 FROM Artist a WHERE a.name = al.artist)
+			`)
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			return execAffected(ctx, tx, `
+				INSERT INTO Artist (name)
+				SELECT DISTINCT al.artist FROM Album al
+				WHERE NOT EXISTS (SELECT 1 FROM Artist a WHERE a.name = al.artist)
+			`)
+		},
+	},
+	{
+		name:        "orphan-tags",
+		description: "ArtistTag and AlbumTag entries whose artist or album doesn't exist",
+		find: func(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+			return queryStrings(ctx, q, `
+				SELECT 'ArtistTag ' || quote(artist) || '/' || quote(tag)
+				FROM ArtistTag at
+				WHERE NOT EXISTS (SELECT 1 FROM Artist a WHERE a.name = at.artist)
+				UNION ALL
+				SELECT 'AlbumTag ' || quote(artist) || '/' || quote(album) || '/' || quote(tag)
+				FROM AlbumTag at
+				WHERE NOT EXISTS (SELECT 1 FROM Album a WHERE a.artist = at.artist AND a.name = at.album)
+			`)
+		},
+		fix: func(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+			artistTags, err := execAffected(ctx, tx, `
+				DELETE FROM ArtistTag
+				WHERE NOT EXISTS (SELECT 1 FROM Artist a WHERE a.name = ArtistTag.artist)
+			`)
+			if err != nil {
+				return 0, err
+			}
+			albumTags, err := execAffected(ctx, tx, `
+				DELETE FROM AlbumTag
+				WHERE NOT EXISTS (SELECT 1 FROM Album a WHERE a.artist = AlbumTag.artist AND a.name = AlbumTag.album)
+			`)
+			return artistTags + albumTags, err
+		},
+	},
+}
+
+// CheckIntegrity runs every integrity check against the database without modifying it.
+func (s *SQLiteStore) CheckIntegrity(ctx context.Context, now time.Time) ([]IntegrityIssue, error) {
+	var issues []IntegrityIssue
+	for _, c := range integrityChecks {
+		issue, err := runIntegrityCheck(ctx, s.db, c, now)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// RepairIntegrity runs every integrity check and repairs what it finds. All repairs happen inside a
+// single transaction, so either every category is repaired or nothing is changed.
+func (s *SQLiteStore) RepairIntegrity(ctx context.Context, now time.Time) ([]IntegrityIssue, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var issues []IntegrityIssue
+	for _, c := range integrityChecks {
+		issue, err := runIntegrityCheck(ctx, tx, c, now)
+		if err != nil {
+			return nil, err
+		}
+		if issue.Count > 0 {
+			issue.Fixed, err = c.fix(ctx, tx, now)
+			if err != nil {
+				return nil, fmt.Errorf("repairing %s: %w", c.name, err)
+			}
+		}
+		issues = append(issues, issue)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return issues, nil
+}
+
+func runIntegrityCheck(ctx context.Context, q queryer, c integrityCheck, now time.Time) (IntegrityIssue, error) {
+	found, err := c.find(ctx, q, now)
+	if err != nil {
+		return IntegrityIssue{}, fmt.Errorf("checking %s: %w", c.name, err)
+	}
+	issue := IntegrityIssue{
+		Check:       c.name,
+		Description: c.description,
+		Count:       len(found),
+	}
+	if len(found) > maxIntegrityExamples {
+		found = found[:maxIntegrityExamples]
+	}
+	issue.Examples = found
+	return issue, nil
+}
+
+func queryStrings(ctx context.Context, q queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var s sql.NullString
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		results = append(results, s.String)
+	}
+	return results, rows.Err()
+}
+
+func execAffected(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func unparsableListens(ctx context.Context, q queryer) ([]int64, []string, error) {
+	// Cast so that the driver returns the stored text rather than converting DATETIME columns.
+	rows, err := q.QueryContext(ctx, "SELECT id, CAST(date AS TEXT) FROM Listen")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var dates []string
+	for rows.Next() {
+		var id int64
+		var date sql.NullString
+		if err := rows.Scan(&id, &date); err != nil {
+			return nil, nil, err
+		}
+		if _, err := parseDate(date.String); err != nil {
+			ids = append(ids, id)
+			dates = append(dates, date.String)
+		}
+	}
+	return ids, dates, rows.Err()
+}
+
+// futureListens returns the listens dated after now, in either of the formats parseDate accepts.
+func futureListens(ctx context.Context, q queryer, now time.Time) ([]int64, []time.Time, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, CAST(date AS TEXT) FROM Listen")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var dates []time.Time
+	for rows.Next() {
+		var id int64
+		var date sql.NullString
+		if err := rows.Scan(&id, &date); err != nil {
+			return nil, nil, err
+		}
+		if t, err := parseDate(date.String); err == nil && t.After(now) {
+			ids = append(ids, id)
+			dates = append(dates, t)
+		}
+	}
+	return ids, dates, rows.Err()
+}
+
+// schemaObject is a table column or index expected to be present in the database.
+type schemaObject struct {
+	kind    string // "table", "column" or "index"
+	table   string
+	name    string
+	typeDef string // column type, or CREATE statement for tables and indexes
+}
+
+func (o schemaObject) String() string {
+	if o.kind == "column" {
+		return fmt.Sprintf("column %s.%s", o.table, o.name)
+	}
+	return fmt.Sprintf("%s %s", o.kind, o.name)
+}
+
+// expectedSchema builds the schema New creates, migration.Create and the tables and columns added
+// since, in an in-memory database.
+func expectedSchema(ctx context.Context) ([]schemaObject, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err := createTables(db); err != nil {
+		return nil, err
+	}
+	if err := ensureSchema(db); err != nil {
+		return nil, err
+	}
+	return readSchema(ctx, db)
+}
+
+func readSchema(ctx context.Context, q queryer) ([]schemaObject, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT type, tbl_name, name, sql FROM sqlite_master
+		WHERE type IN ('table', 'index') AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY type DESC, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var objects []schemaObject
+	for rows.Next() {
+		var o schemaObject
+		if err := rows.Scan(&o.kind, &o.table, &o.name, &o.typeDef); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var columns []schemaObject
+	for _, o := range objects {
+		if o.kind != "table" {
+			continue
+		}
+		colRows, err := q.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", o.name))
+		if err != nil {
+			return nil, err
+		}
+		for colRows.Next() {
+			var cid, notnull, pk int
+			var name, ctype string
+			var dflt interface{}
+			if err := colRows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+				colRows.Close()
+				return nil, err
+			}
+			columns = append(columns, schemaObject{kind: "column", table: o.name, name: name, typeDef: ctype})
+		}
+		colRows.Close()
+		if err := colRows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return append(objects, columns...), nil
+}
+
+// missingSchemaObjects returns the objects in the expected schema which aren't in the database.
+// Columns of missing tables are omitted, since creating the table creates them.
+func missingSchemaObjects(ctx context.Context, q queryer) ([]schemaObject, error) {
+	expected, err := expectedSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("building expected schema: %w", err)
+	}
+	actual, err := readSchema(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+
+	present := make(map[string]bool)
+	for _, o := range actual {
+		present[strings.ToLower(o.String())] = true
+	}
+
+	missingTables := make(map[string]bool)
+	var missing []schemaObject
+	for _, o := range expected {
+		if present[strings.ToLower(o.String())] {
+			continue
+		}
+		if o.kind == "table" {
+			missingTables[o.name] = true
+		}
+		if o.kind == "column" && missingTables[o.table] {
+			continue
+		}
+		missing = append(missing, o)
+	}
+
+	// Tables first, then columns, then indexes, so that indexes can refer to new columns.
+	order := map[string]int{"table": 0, "column": 1, "index": 2}
+	sort.SliceStable(missing, func(i, j int) bool {
+		return order[missing[i].kind] < order[missing[j].kind]
+	})
+	return missing, nil
+}
+
+func findSchemaDrift(ctx context.Context, q queryer, now time.Time) ([]string, error) {
+	missing, err := missingSchemaObjects(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, o := range missing {
+		found = append(found, "missing "+o.String())
+	}
+	return found, nil
+}
+
+func fixSchemaDrift(ctx context.Context, tx *sql.Tx, now time.Time) (int64, error) {
+	missing, err := missingSchemaObjects(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	var fixed int64
+	for _, o := range missing {
+		query := o.typeDef
+		if o.kind == "column" {
+			query = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", o.table, o.name, o.typeDef)
+		}
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fixed, fmt.Errorf("creating %s: %w", o, err)
+		}
+		fixed++
+	}
+	return fixed, nil
+}
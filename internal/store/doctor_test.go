@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func issueCounts(issues []IntegrityIssue) map[string]int {
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Check] = issue.Count
+	}
+	return counts
+}
+
+func TestCheckIntegrityCleanDatabase(t *testing.T) {
+	s := createTestDb(t)
+	defer s.Close()
+
+	user := "testuser"
+	s.CreateUser(context.Background(), user)
+	if err := s.AddRecentTracks(context.Background(), user, []TrackImport{
+		{Artist: "Artist", Album: "Album", TrackName: "Track", DateUTS: "1600000000"},
+	}); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	issues, err := s.CheckIntegrity(context.Background(), time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Count != 0 {
+			t.Errorf("%s: expected no issues, got %d (%v)", issue.Check, issue.Count, issue.Examples)
+		}
+	}
+}
+
+func TestRepairIntegrity(t *testing.T) {
+	s := createTestDb(t)
+	defer s.Close()
+
+	user := "testuser"
+	s.CreateUser(context.Background(), user)
+	if err := s.AddRecentTracks(context.Background(), user, []TrackImport{
+		{Artist: "Artist", Album: "Album", TrackName: "Track", DateUTS: "1600000000"},
+	}); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	setup := []string{
+		// Duplicate of the listen above, from before createListen deduplicated.
+		"INSERT INTO Listen (user, track, date) SELECT user, track, date FROM Listen",
+		"INSERT INTO Listen (user, track, date) VALUES ('testuser', 1, '')",
+		"INSERT INTO Listen (user, track, date) VALUES ('testuser', 1, '2000000000')",
+		"INSERT INTO Listen (user, track, date) VALUES ('testuser', 1, '2033-05-18T03:33:21Z')",
+		"INSERT INTO Listen (user, track, date) VALUES ('testuser', 999, '1600000001')",
+		"INSERT INTO Track (id, name, artist, album) VALUES (2, 'Lost', 'Missing Artist', 'Lost Album')",
+		"INSERT INTO Track (id, name, artist, album) VALUES (3, 'Nameless', '', '')",
+		"INSERT INTO Listen (user, track, date) VALUES ('testuser', 3, '1600000002')",
+		"INSERT INTO AlbumTag (artist, album, tag, count) VALUES ('Artist', 'No Such Album', 'rock', 10)",
+		"DROP INDEX idx_listen_exact",
+		// Tables added after migration.Create are checked too.
+		"DROP TABLE TagRule",
+	}
+	for _, query := range setup {
+		if _, err := s.db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	now := time.Unix(1700000000, 0)
+	issues, err := s.CheckIntegrity(context.Background(), now)
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	want := map[string]int{
+		"schema-drift":       2,
+		"unparsable-dates":   1,
+		"future-timestamps":  2,
+		"duplicate-listens":  1,
+		"empty-artist-names": 1,
+		"orphan-listens":     1,
+		"orphan-tracks":      4,
+		"orphan-albums":      0,
+		"orphan-tags":        1,
+	}
+	got := issueCounts(issues)
+	for check, count := range want {
+		if got[check] != count {
+			t.Errorf("CheckIntegrity %s: got %d issues, want %d", check, got[check], count)
+		}
+	}
+
+	if _, err := s.RepairIntegrity(context.Background(), now); err != nil {
+		t.Fatalf("RepairIntegrity: %v", err)
+	}
+
+	issues, err = s.CheckIntegrity(context.Background(), now)
+	if err != nil {
+		t.Fatalf("CheckIntegrity after repair: %v", err)
+	}
+	for _, issue := range issues {
+		if issue.Count != 0 {
+			t.Errorf("%s: expected no issues after repair, got %d (%v)", issue.Check, issue.Count, issue.Examples)
+		}
+	}
+
+	var listens int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM Listen").Scan(&listens); err != nil {
+		t.Fatalf("counting listens: %v", err)
+	}
+	if listens != 1 {
+		t.Errorf("Expected 1 listen to survive repair, got %d", listens)
+	}
+
+	var artist string
+	if err := s.db.QueryRow("SELECT name FROM Artist WHERE name = 'Missing Artist'").Scan(&artist); err != nil {
+		t.Errorf("Expected missing artist to be created: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FeedbackKind is the kind of item feedback is about.
+type FeedbackKind string
+
+const (
+	FeedbackArtist FeedbackKind = "artist"
+	FeedbackAlbum  FeedbackKind = "album"
+	FeedbackTrack  FeedbackKind = "track"
+)
+
+// FeedbackState is what the user said about an item in the forgotten results.
+type FeedbackState string
+
+const (
+	// The item is hidden until Until.
+	FeedbackSnoozed FeedbackState = "snoozed"
+	// The item is hidden for good.
+	FeedbackIgnored FeedbackState = "ignored"
+	// The item was listened to somewhere that doesn't scrobble, so it counts as listened to at
+	// Marked.
+	FeedbackRevisited FeedbackState = "revisited"
+)
+
+// ForgottenFeedback is the user's feedback on an artist, album or track in the forgotten results.
+// Name is the album or track name, and empty for artists.
+type ForgottenFeedback struct {
+	Kind   FeedbackKind
+	Artist string
+	Name   string
+	State  FeedbackState
+	// When the feedback was given.
+	Marked time.Time
+	// When a snooze ends, and zero for other states.
+	Until time.Time
+}
+
+func (f ForgottenFeedback) validate() error {
+	switch f.Kind {
+	case FeedbackArtist:
+		if f.Name != "" {
+			return fmt.Errorf("feedback on an artist can't have a name")
+		}
+	case FeedbackAlbum, FeedbackTrack:
+		if f.Name == "" {
+			return fmt.Errorf("feedback on an %s needs a name", f.Kind)
+		}
+	default:
+		return fmt.Errorf("invalid feedback kind %q, must be artist, album or track", f.Kind)
+	}
+	if f.Artist == "" {
+		return fmt.Errorf("feedback needs an artist")
+	}
+	switch f.State {
+	case FeedbackSnoozed:
+		if !f.Until.After(f.Marked) {
+			return fmt.Errorf("snooze must end after it starts")
+		}
+	case FeedbackIgnored, FeedbackRevisited:
+	default:
+		return fmt.Errorf("invalid feedback state %q, must be snoozed, ignored or revisited", f.State)
+	}
+	return nil
+}
+
+func createFeedbackTable(db *sql.DB) error {
+	query := `
+CREATE TABLE IF NOT EXISTS ForgottenFeedback (
+  user TEXT,
+  kind TEXT,
+  artist TEXT,
+  name TEXT,
+  state TEXT,
+  marked INTEGER,
+  until INTEGER,
+  PRIMARY KEY (user, kind, artist, name)
+);
+`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("creating forgotten feedback table: %w", err)
+	}
+	return nil
+}
+
+// SetForgottenFeedback records the user's feedback on an item, replacing any earlier feedback on
+// it.
+func (s *SQLiteStore) SetForgottenFeedback(ctx context.Context, user string, feedback ForgottenFeedback) error {
+	if err := feedback.validate(); err != nil {
+		return err
+	}
+	var until int64
+	if !feedback.Until.IsZero() {
+		until = feedback.Until.Unix()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO ForgottenFeedback (user, kind, artist, name, state, marked, until)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user, feedback.Kind, feedback.Artist, feedback.Name, feedback.State, feedback.Marked.Unix(), until)
+	if err != nil {
+		return fmt.Errorf("setting forgotten feedback: %w", err)
+	}
+	return nil
+}
+
+// DeleteForgottenFeedback deletes the user's feedback on an item, returning whether there was any.
+func (s *SQLiteStore) DeleteForgottenFeedback(ctx context.Context, user string, kind FeedbackKind, artist, name string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM ForgottenFeedback WHERE user = ? AND kind = ? AND artist = ? AND name = ?", user, kind, artist, name)
+	if err != nil {
+		return false, fmt.Errorf("deleting forgotten feedback: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetForgottenFeedback returns the user's feedback, ordered by kind, artist and name.
+func (s *SQLiteStore) GetForgottenFeedback(ctx context.Context, user string) ([]ForgottenFeedback, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT kind, artist, name, state, marked, until
+		FROM ForgottenFeedback
+		WHERE user = ?
+		ORDER BY kind, artist, name`, user)
+	if err != nil {
+		return nil, fmt.Errorf("querying forgotten feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var feedback []ForgottenFeedback
+	for rows.Next() {
+		var f ForgottenFeedback
+		var marked, until int64
+		if err := rows.Scan(&f.Kind, &f.Artist, &f.Name, &f.State, &marked, &until); err != nil {
+			return nil, err
+		}
+		f.Marked = time.Unix(marked, 0)
+		if until != 0 {
+			f.Until = time.Unix(until, 0)
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback, rows.Err()
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -20,6 +21,14 @@ type ArtistListenStats struct {
 	LastListen     time.Time
 }
 
+type TrackListenStats struct {
+	Artist         string
+	Track          string
+	TotalScrobbles int64
+	FirstListen    time.Time
+	LastListen     time.Time
+}
+
 type AlbumListenStats struct {
 	Artist         string
 	Album          string
@@ -28,7 +37,7 @@ type AlbumListenStats struct {
 	LastListen     time.Time
 }
 
-func (s *Store) GetForgottenArtists(user string, opts ForgottenQueryOptions) ([]ArtistListenStats, error) {
+func (s *SQLiteStore) GetForgottenArtists(ctx context.Context, user string, opts ForgottenQueryOptions) ([]ArtistListenStats, error) {
 	query := `
 		SELECT
 			t.artist,
@@ -42,7 +51,7 @@ func (s *Store) GetForgottenArtists(user string, opts ForgottenQueryOptions) ([]
 		HAVING total_scrobbles >= ? AND last_listen >= ? AND last_listen <= ? AND first_listen >= ? AND first_listen <= ?
 	`
 
-	rows, err := s.db.Query(query, user, opts.MinScrobbles, opts.LastListenAfter, opts.LastListenBefore, opts.FirstListenAfter, opts.FirstListenBefore)
+	rows, err := s.db.QueryContext(ctx, query, user, opts.MinScrobbles, opts.LastListenAfter, opts.LastListenBefore, opts.FirstListenAfter, opts.FirstListenBefore)
 	if err != nil {
 		return nil, fmt.Errorf("querying forgotten artists: %w", err)
 	}
@@ -62,7 +71,43 @@ func (s *Store) GetForgottenArtists(user string, opts ForgottenQueryOptions) ([]
 	return stats, rows.Err()
 }
 
-func (s *Store) GetForgottenAlbums(user string, opts ForgottenQueryOptions) ([]AlbumListenStats, error) {
+// GetArtistListenStats returns the listen count and first and last listens of each artist the user
+// listened to with start <= date < end, ordered by artist.
+func (s *SQLiteStore) GetArtistListenStats(ctx context.Context, user string, start, end time.Time) ([]ArtistListenStats, error) {
+	query := `
+		SELECT
+			t.artist,
+			COUNT(*),
+			MIN(l.date),
+			MAX(l.date)
+		FROM Listen l
+		JOIN Track t ON l.track = t.id
+		WHERE l.user = ? AND l.date BETWEEN ? AND ?
+		GROUP BY t.artist
+		ORDER BY t.artist
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix()-1)
+	if err != nil {
+		return nil, fmt.Errorf("querying artist listen stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []ArtistListenStats
+	for rows.Next() {
+		var a ArtistListenStats
+		var first, last int64
+		if err := rows.Scan(&a.Artist, &a.TotalScrobbles, &first, &last); err != nil {
+			return nil, err
+		}
+		a.FirstListen = time.Unix(first, 0)
+		a.LastListen = time.Unix(last, 0)
+		stats = append(stats, a)
+	}
+	return stats, rows.Err()
+}
+
+func (s *SQLiteStore) GetForgottenAlbums(ctx context.Context, user string, opts ForgottenQueryOptions) ([]AlbumListenStats, error) {
 	query := `
 		SELECT
 			t.artist,
@@ -77,7 +122,7 @@ func (s *Store) GetForgottenAlbums(user string, opts ForgottenQueryOptions) ([]A
 		HAVING total_scrobbles >= ? AND last_listen >= ? AND last_listen <= ? AND first_listen >= ? AND first_listen <= ?
 	`
 
-	rows, err := s.db.Query(query, user, opts.MinScrobbles, opts.LastListenAfter, opts.LastListenBefore, opts.FirstListenAfter, opts.FirstListenBefore)
+	rows, err := s.db.QueryContext(ctx, query, user, opts.MinScrobbles, opts.LastListenAfter, opts.LastListenBefore, opts.FirstListenAfter, opts.FirstListenBefore)
 	if err != nil {
 		return nil, fmt.Errorf("querying forgotten albums: %w", err)
 	}
@@ -96,3 +141,40 @@ func (s *Store) GetForgottenAlbums(user string, opts ForgottenQueryOptions) ([]A
 	}
 	return stats, rows.Err()
 }
+
+// GetForgottenTracks is like GetForgottenAlbums, for tracks. A track's listens on every album are
+// counted together.
+func (s *SQLiteStore) GetForgottenTracks(ctx context.Context, user string, opts ForgottenQueryOptions) ([]TrackListenStats, error) {
+	query := `
+		SELECT
+			t.artist,
+			t.name,
+			COUNT(*) as total_scrobbles,
+			MIN(l.date) as first_listen,
+			MAX(l.date) as last_listen
+		FROM Listen l
+		JOIN Track t ON l.track = t.id
+		WHERE l.user = ?
+		GROUP BY t.artist, t.name
+		HAVING total_scrobbles >= ? AND last_listen >= ? AND last_listen <= ? AND first_listen >= ? AND first_listen <= ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, user, opts.MinScrobbles, opts.LastListenAfter, opts.LastListenBefore, opts.FirstListenAfter, opts.FirstListenBefore)
+	if err != nil {
+		return nil, fmt.Errorf("querying forgotten tracks: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []TrackListenStats
+	for rows.Next() {
+		var t TrackListenStats
+		var first, last int64
+		if err := rows.Scan(&t.Artist, &t.Track, &t.TotalScrobbles, &first, &last); err != nil {
+			return nil, err
+		}
+		t.FirstListen = time.Unix(first, 0)
+		t.LastListen = time.Unix(last, 0)
+		stats = append(stats, t)
+	}
+	return stats, rows.Err()
+}
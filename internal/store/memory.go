@@ -0,0 +1,1255 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store which keeps everything in memory. It's intended for tests, and for embedding
+// the analyses where SQLite isn't available. It matches SQLiteStore's results, with ties in
+// orderings broken by name.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users map[string]*memoryUser
+	// Known artists and albums, with when their tags were last updated (zero if never).
+	artists map[string]time.Time
+	albums  map[AlbumKey]time.Time
+
+	tracks   []memoryTrack
+	trackIDs map[memoryTrack]int
+	listens  []memoryListen
+	// Listens are deduplicated on the raw date string, like createListen.
+	listenKeys map[memoryListenKey]bool
+
+	artistTags map[string]map[string]int
+	albumTags  map[AlbumKey]map[string]int
+	tagRules   []TagRule
+	// Personal tags by artist and album, with an empty album for artists' tags.
+	personalTags map[AlbumKey]*memoryPersonalTags
+
+	// Forgotten feedback by user.
+	feedback map[string]map[memoryFeedbackKey]ForgottenFeedback
+
+	// Weekly charts by user and week start.
+	charts map[string]map[int64]*memoryChartWeek
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type memoryUser struct {
+	lastUpdated time.Time
+}
+
+type memoryTrack struct {
+	artist, album, name string
+}
+
+type memoryListen struct {
+	user  string
+	track int
+	date  int64
+}
+
+type memoryPersonalTags struct {
+	tags    map[string]bool
+	replace bool
+}
+
+type memoryFeedbackKey struct {
+	kind         FeedbackKind
+	artist, name string
+}
+
+type memoryChartWeek struct {
+	fingerprint chartFingerprint
+	entries     map[ChartKind][]ChartEntry
+}
+
+type memoryListenKey struct {
+	user  string
+	track int
+	date  string
+}
+
+func NewMemory() *MemoryStore {
+	return &MemoryStore{
+		users:      make(map[string]*memoryUser),
+		artists:    make(map[string]time.Time),
+		albums:     make(map[AlbumKey]time.Time),
+		trackIDs:   make(map[memoryTrack]int),
+		listenKeys: make(map[memoryListenKey]bool),
+		artistTags: make(map[string]map[string]int),
+		albumTags:  make(map[AlbumKey]map[string]int),
+		charts:     make(map[string]map[int64]*memoryChartWeek),
+
+		personalTags: make(map[AlbumKey]*memoryPersonalTags),
+		feedback:     make(map[string]map[memoryFeedbackKey]ForgottenFeedback),
+	}
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// eachListen calls fn for each of user's listens with start <= date <= end, matching SQL's BETWEEN.
+func (m *MemoryStore) eachListen(user string, start, end int64, fn func(l memoryListen, t memoryTrack)) {
+	for _, l := range m.listens {
+		if l.user == user && l.date >= start && l.date <= end {
+			fn(l, m.tracks[l.track])
+		}
+	}
+}
+
+func (m *MemoryStore) eachListenAllTime(user string, fn func(l memoryListen, t memoryTrack)) {
+	m.eachListen(user, math.MinInt64, math.MaxInt64, fn)
+}
+
+func limitTo(n, limit int) int {
+	if limit >= 0 && limit < n {
+		return limit
+	}
+	return n
+}
+
+// Users and updates
+
+func (m *MemoryStore) CreateUser(ctx context.Context, user string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[user]; !ok {
+		m.users[user] = &memoryUser{}
+	}
+	return nil
+}
+
+// GetSessionKey always returns an empty key, since sessions are only stored by the authenticate
+// command.
+func (m *MemoryStore) GetSessionKey(ctx context.Context, user string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+func (m *MemoryStore) GetLastUpdated(ctx context.Context, user string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if u, ok := m.users[user]; ok {
+		return u.lastUpdated, nil
+	}
+	return time.Time{}, nil
+}
+
+func (m *MemoryStore) SetLastUpdated(ctx context.Context, user string, updated time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[user]; ok {
+		u.lastUpdated = updated
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetLatestListen(ctx context.Context, user string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var latest time.Time
+	found := false
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		if !found || l.date > latest.Unix() {
+			latest = time.Unix(l.date, 0)
+			found = true
+		}
+	})
+	return latest, nil
+}
+
+// AddRecentTracks inserts a batch of tracks. Like SQLiteStore, nothing is inserted if any track has
+// a date parseDate can't parse.
+func (m *MemoryStore) AddRecentTracks(ctx context.Context, user string, tracks []TrackImport) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dates := make([]int64, len(tracks))
+	for i, track := range tracks {
+		t, err := parseDate(track.DateUTS)
+		if err != nil {
+			return fmt.Errorf("inserting listen: %w", err)
+		}
+		dates[i] = t.Unix()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, track := range tracks {
+		if _, ok := m.artists[track.Artist]; !ok {
+			m.artists[track.Artist] = time.Time{}
+		}
+		album := AlbumKey{Artist: track.Artist, Name: track.Album}
+		if _, ok := m.albums[album]; !ok {
+			m.albums[album] = time.Time{}
+		}
+
+		mt := memoryTrack{track.Artist, track.Album, track.TrackName}
+		id, ok := m.trackIDs[mt]
+		if !ok {
+			id = len(m.tracks)
+			m.tracks = append(m.tracks, mt)
+			m.trackIDs[mt] = id
+		}
+
+		key := memoryListenKey{user, id, track.DateUTS}
+		if m.listenKeys[key] {
+			continue
+		}
+		m.listenKeys[key] = true
+		m.listens = append(m.listens, memoryListen{user, id, dates[i]})
+	}
+	return nil
+}
+
+// Tags
+
+// artistsWithListens counts listens per artist across all users.
+func (m *MemoryStore) artistsWithListens() map[string]int {
+	counts := make(map[string]int)
+	for _, l := range m.listens {
+		counts[m.tracks[l.track].artist]++
+	}
+	return counts
+}
+
+func (m *MemoryStore) GetArtistsNeedingTagUpdate(ctx context.Context, interval time.Duration) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	threshold := time.Now().Add(-interval)
+
+	var artists []string
+	for artist, count := range m.artistsWithListens() {
+		updated := m.artists[artist]
+		if count > 10 && (updated.IsZero() || updated.Before(threshold)) {
+			artists = append(artists, artist)
+		}
+	}
+	sort.Strings(artists)
+	return artists, nil
+}
+
+func (m *MemoryStore) GetAlbumsNeedingTagUpdate(ctx context.Context, interval time.Duration) ([]AlbumKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	threshold := time.Now().Add(-interval)
+
+	counts := make(map[AlbumKey]int)
+	for _, l := range m.listens {
+		t := m.tracks[l.track]
+		if t.album != "" {
+			counts[AlbumKey{Artist: t.artist, Name: t.album}]++
+		}
+	}
+
+	var albums []AlbumKey
+	for album, count := range counts {
+		updated := m.albums[album]
+		if count > 10 && (updated.IsZero() || updated.Before(threshold)) {
+			albums = append(albums, album)
+		}
+	}
+	sort.Slice(albums, func(i, j int) bool {
+		if albums[i].Artist != albums[j].Artist {
+			return albums[i].Artist < albums[j].Artist
+		}
+		return albums[i].Name < albums[j].Name
+	})
+	return albums, nil
+}
+
+func (m *MemoryStore) SaveArtistTags(ctx context.Context, artist string, tags []string, counts []int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.artistTags[artist] == nil {
+		m.artistTags[artist] = make(map[string]int)
+	}
+	for i, tag := range tags {
+		count := 0
+		if i < len(counts) {
+			count = counts[i]
+		}
+		m.artistTags[artist][tag] = count
+	}
+	if _, ok := m.artists[artist]; ok {
+		m.artists[artist] = time.Now()
+	}
+	return nil
+}
+
+func (m *MemoryStore) SaveAlbumTags(ctx context.Context, artist, album string, tags []string, counts []int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := AlbumKey{Artist: artist, Name: album}
+	if m.albumTags[key] == nil {
+		m.albumTags[key] = make(map[string]int)
+	}
+	for i, tag := range tags {
+		count := 0
+		if i < len(counts) {
+			count = counts[i]
+		}
+		m.albumTags[key][tag] = count
+	}
+	if _, ok := m.albums[key]; ok {
+		m.albums[key] = time.Now()
+	}
+	return nil
+}
+
+// tagCounts returns the tags ordered by count.
+func tagCounts(tags map[string]int) []TagCount {
+	var counts []TagCount
+	for tag, count := range tags {
+		counts = append(counts, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Tag < counts[j].Tag
+	})
+	return counts
+}
+
+// tagLayer must be called with m.mu held. The rules were validated when they were added.
+func (m *MemoryStore) tagLayer() *tagLayer {
+	layer, _ := newTagLayer(m.tagRules, m.personalTagList())
+	return layer
+}
+
+func (m *MemoryStore) GetTopTagsForArtist(ctx context.Context, artist string, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return tagNames(m.tagLayer().artistTags(artist, tagCounts(m.artistTags[artist])), limit), nil
+}
+
+func (m *MemoryStore) GetTopTagsForAlbum(ctx context.Context, artist, album string, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return tagNames(m.tagLayer().albumTags(artist, album, tagCounts(m.albumTags[AlbumKey{Artist: artist, Name: album}])), limit), nil
+}
+
+func (m *MemoryStore) GetAllArtistTags(ctx context.Context) ([]ArtistTagData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var data []ArtistTagData
+	for artist, tags := range m.artistTags {
+		for tag, count := range tags {
+			data = append(data, ArtistTagData{Artist: artist, Tag: tag, Count: count})
+		}
+	}
+	return m.tagLayer().allArtistTags(data), nil
+}
+
+func (m *MemoryStore) GetAllAlbumTags(ctx context.Context) ([]AlbumTagData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var data []AlbumTagData
+	for album, tags := range m.albumTags {
+		for tag, count := range tags {
+			data = append(data, AlbumTagData{Artist: album.Artist, Album: album.Name, Tag: tag, Count: count})
+		}
+	}
+	return m.tagLayer().allAlbumTags(data), nil
+}
+
+func (m *MemoryStore) GetArtistTagCounts(ctx context.Context, artist string) ([]TagCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return tagCounts(m.artistTags[artist]), nil
+}
+
+func (m *MemoryStore) AddTagRule(ctx context.Context, rule TagRule) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rule, err := rule.validate()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteTagRule(rule.Kind, rule.Pattern)
+	m.tagRules = append(m.tagRules, rule)
+	return nil
+}
+
+func (m *MemoryStore) DeleteTagRule(ctx context.Context, kind TagRuleKind, pattern string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	pattern, err := tagRulePattern(kind, pattern)
+	if err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteTagRule(kind, pattern), nil
+}
+
+func (m *MemoryStore) deleteTagRule(kind TagRuleKind, pattern string) bool {
+	for i, r := range m.tagRules {
+		if r.Kind == kind && r.Pattern == pattern {
+			m.tagRules = append(m.tagRules[:i:i], m.tagRules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) GetTagRules(ctx context.Context) ([]TagRule, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]TagRule(nil), m.tagRules...), nil
+}
+
+func (m *MemoryStore) AddPersonalTags(ctx context.Context, artist, album string, tags []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("no tags to add")
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := AlbumKey{Artist: artist, Name: album}
+	if m.personalTags[key] == nil {
+		m.personalTags[key] = &memoryPersonalTags{tags: make(map[string]bool)}
+	}
+	for _, tag := range tags {
+		m.personalTags[key].tags[tag] = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) RemovePersonalTags(ctx context.Context, artist, album string, tags []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := AlbumKey{Artist: artist, Name: album}
+	p := m.personalTags[key]
+	if p == nil {
+		return 0, nil
+	}
+	removed := 0
+	if len(tags) == 0 {
+		removed = len(p.tags)
+		p.tags = nil
+	}
+	for _, tag := range tags {
+		if p.tags[tag] {
+			delete(p.tags, tag)
+			removed++
+		}
+	}
+	if len(p.tags) == 0 {
+		delete(m.personalTags, key)
+	}
+	return removed, nil
+}
+
+func (m *MemoryStore) SetPersonalTagsReplace(ctx context.Context, artist, album string, replace bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := m.personalTags[AlbumKey{Artist: artist, Name: album}]
+	if p == nil {
+		return fmt.Errorf("no personal tags for %s", describeTagged(artist, album))
+	}
+	p.replace = replace
+	return nil
+}
+
+func (m *MemoryStore) GetPersonalTags(ctx context.Context) ([]PersonalTags, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.personalTagList(), nil
+}
+
+// personalTagList must be called with m.mu held.
+func (m *MemoryStore) personalTagList() []PersonalTags {
+	var personal []PersonalTags
+	for key, p := range m.personalTags {
+		var tags []string
+		for tag := range p.tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		personal = append(personal, PersonalTags{Artist: key.Artist, Album: key.Name, Tags: tags, Replace: p.replace})
+	}
+	sort.Slice(personal, func(i, j int) bool {
+		if personal[i].Artist != personal[j].Artist {
+			return personal[i].Artist < personal[j].Artist
+		}
+		return personal[i].Album < personal[j].Album
+	})
+	return personal
+}
+
+// Listening history
+
+func (m *MemoryStore) GetListensInRange(ctx context.Context, user string, start, end time.Time) ([]time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var dates []int64
+	m.eachListen(user, start.Unix(), end.Unix()-1, func(l memoryListen, t memoryTrack) {
+		dates = append(dates, l.date)
+	})
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+
+	var listens []time.Time
+	for _, d := range dates {
+		listens = append(listens, time.Unix(d, 0))
+	}
+	return listens, nil
+}
+
+func (m *MemoryStore) GetListenHistory(ctx context.Context, user string, start, end time.Time) ([]TrackListen, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var listens []TrackListen
+	m.eachListen(user, start.Unix(), end.Unix()-1, func(l memoryListen, t memoryTrack) {
+		listens = append(listens, TrackListen{Time: time.Unix(l.date, 0), Artist: t.artist, Album: t.album, Track: t.name})
+	})
+	// Listens are stored in insertion order, like Listen.id.
+	sort.SliceStable(listens, func(i, j int) bool { return listens[i].Time.Before(listens[j].Time) })
+	return listens, nil
+}
+
+func (m *MemoryStore) GetTotalScrobbles(ctx context.Context, user string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int64
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		count++
+	})
+	return count, nil
+}
+
+func (m *MemoryStore) GetTotalScrobblesInPeriod(ctx context.Context, user string, start, end time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int64
+	m.eachListen(user, start.Unix(), end.Unix(), func(l memoryListen, t memoryTrack) {
+		count++
+	})
+	return count, nil
+}
+
+func (m *MemoryStore) GetTotalArtists(ctx context.Context, user string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	artists := make(map[string]bool)
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		artists[t.artist] = true
+	})
+	return len(artists), nil
+}
+
+// GetFirstListen returns an error if the user has no listens, like SQLiteStore.
+func (m *MemoryStore) GetFirstListen(ctx context.Context, user string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var first int64
+	found := false
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		if !found || l.date < first {
+			first = l.date
+			found = true
+		}
+	})
+	if !found {
+		return time.Time{}, fmt.Errorf("no listens for %q", user)
+	}
+	return time.Unix(first, 0), nil
+}
+
+// artistCounts counts user's listens per artist between start and end, most listened first.
+func (m *MemoryStore) artistCounts(user string, start, end time.Time) []ArtistPlayCount {
+	counts := make(map[string]int64)
+	m.eachListen(user, start.Unix(), end.Unix(), func(l memoryListen, t memoryTrack) {
+		counts[t.artist]++
+	})
+
+	var results []ArtistPlayCount
+	for artist, count := range counts {
+		results = append(results, ArtistPlayCount{Artist: artist, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Artist < results[j].Artist
+	})
+	return results
+}
+
+// albumCounts counts user's listens per album between start and end, most listened first. Listens
+// without an album are only included if withUnknown is set.
+func (m *MemoryStore) albumCounts(user string, start, end time.Time, withUnknown bool) []AlbumPlayCount {
+	counts := make(map[AlbumKey]int64)
+	m.eachListen(user, start.Unix(), end.Unix(), func(l memoryListen, t memoryTrack) {
+		if t.album != "" || withUnknown {
+			counts[AlbumKey{Artist: t.artist, Name: t.album}]++
+		}
+	})
+
+	var results []AlbumPlayCount
+	for album, count := range counts {
+		results = append(results, AlbumPlayCount{Artist: album.Artist, Album: album.Name, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		if results[i].Artist != results[j].Artist {
+			return results[i].Artist < results[j].Artist
+		}
+		return results[i].Album < results[j].Album
+	})
+	return results
+}
+
+func (m *MemoryStore) GetTopArtists(ctx context.Context, user string, start, end time.Time, limit int) ([]ArtistScrobbleCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := m.artistCounts(user, start, end)
+
+	var artists []ArtistScrobbleCount
+	for _, c := range counts[:limitTo(len(counts), limit)] {
+		artists = append(artists, ArtistScrobbleCount{Name: c.Artist, Scrobbles: c.Count})
+	}
+	return artists, nil
+}
+
+func (m *MemoryStore) GetTopArtistsWithCount(ctx context.Context, user string, start, end time.Time) ([]ArtistPlayCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.artistCounts(user, start, end), nil
+}
+
+func (m *MemoryStore) GetArtistMonthlyCounts(ctx context.Context, user string, start, end time.Time) ([]ArtistMonthCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	type monthKey struct {
+		artist string
+		month  time.Time
+	}
+	counts := make(map[monthKey]int64)
+	m.eachListen(user, start.Unix(), end.Unix()-1, func(l memoryListen, t memoryTrack) {
+		date := time.Unix(l.date, 0).UTC()
+		counts[monthKey{t.artist, time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)}]++
+	})
+
+	var results []ArtistMonthCount
+	for key, count := range counts {
+		results = append(results, ArtistMonthCount{Artist: key.artist, Month: key.month, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Artist != results[j].Artist {
+			return results[i].Artist < results[j].Artist
+		}
+		return results[i].Month.Before(results[j].Month)
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) GetTopAlbums(ctx context.Context, user string, start, end time.Time, limit int) ([]AlbumScrobbleCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := m.albumCounts(user, start, end, false)
+
+	var albums []AlbumScrobbleCount
+	for _, c := range counts[:limitTo(len(counts), limit)] {
+		albums = append(albums, AlbumScrobbleCount{Title: c.Album, Artist: c.Artist, Scrobbles: c.Count})
+	}
+	return albums, nil
+}
+
+func (m *MemoryStore) GetTopAlbumsWithCount(ctx context.Context, user string, start, end time.Time) ([]AlbumPlayCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.albumCounts(user, start, end, true), nil
+}
+
+func (m *MemoryStore) GetTopTracksWithCount(ctx context.Context, user string, start, end time.Time) ([]TrackPlayCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	type trackKey struct{ artist, name string }
+	counts := make(map[trackKey]int64)
+	m.eachListen(user, start.Unix(), end.Unix(), func(l memoryListen, t memoryTrack) {
+		counts[trackKey{t.artist, t.name}]++
+	})
+
+	var results []TrackPlayCount
+	for track, count := range counts {
+		results = append(results, TrackPlayCount{Artist: track.artist, Track: track.name, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		if results[i].Artist != results[j].Artist {
+			return results[i].Artist < results[j].Artist
+		}
+		return results[i].Track < results[j].Track
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) GetTopAlbumsForArtist(ctx context.Context, user, artist string, start, end time.Time, limit int) ([]TagCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var albums []TagCount
+	for _, c := range m.albumCounts(user, start, end, false) {
+		if c.Artist == artist {
+			albums = append(albums, TagCount{Tag: c.Album, Count: int(c.Count)})
+		}
+	}
+	return albums[:limitTo(len(albums), limit)], nil
+}
+
+func (m *MemoryStore) GetArtistListenCount(ctx context.Context, user, artist string, start, end time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int64
+	m.eachListen(user, start.Unix(), end.Unix(), func(l memoryListen, t memoryTrack) {
+		if t.artist == artist {
+			count++
+		}
+	})
+	return count, nil
+}
+
+func (m *MemoryStore) GetPeakYears(ctx context.Context, user, artist string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byYear := make(map[int]int)
+	total := 0
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		if t.artist == artist {
+			byYear[time.Unix(l.date, 0).UTC().Year()]++
+			total++
+		}
+	})
+	if total == 0 {
+		return "", nil
+	}
+
+	var years []int
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	var counts []yearCount
+	for _, year := range years {
+		counts = append(counts, yearCount{year: strconv.Itoa(year), count: byYear[year]})
+	}
+	return peakYears(counts, total), nil
+}
+
+func (m *MemoryStore) GetAlbumTracks(ctx context.Context, artist, album string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tracks []string
+	for t := range m.trackIDs {
+		if t.artist == artist && t.album == album {
+			tracks = append(tracks, t.name)
+		}
+	}
+	sort.Strings(tracks)
+	return tracks, nil
+}
+
+func (m *MemoryStore) GetAlbumListenCounts(ctx context.Context, user string, start, end time.Time) ([]AlbumScrobbleCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var counts []AlbumScrobbleCount
+	for _, c := range m.albumCounts(user, start, end, true) {
+		counts = append(counts, AlbumScrobbleCount{Title: c.Album, Artist: c.Artist, Scrobbles: c.Count})
+	}
+	return counts, nil
+}
+
+func (m *MemoryStore) GetArtistAlbumStats(ctx context.Context, user string, start, end time.Time) ([]ArtistAlbumStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	albums := make(map[string]map[string]bool)
+	listens := make(map[string]int64)
+	m.eachListen(user, start.Unix(), end.Unix(), func(l memoryListen, t memoryTrack) {
+		if t.album == "" {
+			return
+		}
+		if albums[t.artist] == nil {
+			albums[t.artist] = make(map[string]bool)
+		}
+		albums[t.artist][t.album] = true
+		listens[t.artist]++
+	})
+
+	var stats []ArtistAlbumStats
+	for artist, count := range listens {
+		stats = append(stats, ArtistAlbumStats{Artist: artist, AlbumCount: float64(len(albums[artist])), ListenCount: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].ListenCount != stats[j].ListenCount {
+			return stats[i].ListenCount > stats[j].ListenCount
+		}
+		return stats[i].Artist < stats[j].Artist
+	})
+	return stats, nil
+}
+
+func (m *MemoryStore) GetNewArtistsCount(ctx context.Context, user string, since time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	first := make(map[string]int64)
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		if d, ok := first[t.artist]; !ok || l.date < d {
+			first[t.artist] = l.date
+		}
+	})
+
+	count := 0
+	for _, d := range first {
+		if d >= since.Unix() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// listenStats accumulates the listen count and first and last listen dates of an artist or album.
+type listenStats struct {
+	count       int64
+	first, last int64
+}
+
+func (ls *listenStats) add(date int64) {
+	if ls.count == 0 || date < ls.first {
+		ls.first = date
+	}
+	if ls.count == 0 || date > ls.last {
+		ls.last = date
+	}
+	ls.count++
+}
+
+func (ls listenStats) matches(opts ForgottenQueryOptions) bool {
+	return ls.count >= int64(opts.MinScrobbles) &&
+		ls.last >= opts.LastListenAfter && ls.last <= opts.LastListenBefore &&
+		ls.first >= opts.FirstListenAfter && ls.first <= opts.FirstListenBefore
+}
+
+func (m *MemoryStore) GetForgottenArtists(ctx context.Context, user string, opts ForgottenQueryOptions) ([]ArtistListenStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byArtist := make(map[string]*listenStats)
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		if byArtist[t.artist] == nil {
+			byArtist[t.artist] = &listenStats{}
+		}
+		byArtist[t.artist].add(l.date)
+	})
+
+	var stats []ArtistListenStats
+	for artist, ls := range byArtist {
+		if ls.matches(opts) {
+			stats = append(stats, ArtistListenStats{
+				Artist:         artist,
+				TotalScrobbles: ls.count,
+				FirstListen:    time.Unix(ls.first, 0),
+				LastListen:     time.Unix(ls.last, 0),
+			})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Artist < stats[j].Artist })
+	return stats, nil
+}
+
+func (m *MemoryStore) GetArtistListenStats(ctx context.Context, user string, start, end time.Time) ([]ArtistListenStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byArtist := make(map[string]*listenStats)
+	m.eachListen(user, start.Unix(), end.Unix()-1, func(l memoryListen, t memoryTrack) {
+		if byArtist[t.artist] == nil {
+			byArtist[t.artist] = &listenStats{}
+		}
+		byArtist[t.artist].add(l.date)
+	})
+
+	var stats []ArtistListenStats
+	for artist, ls := range byArtist {
+		stats = append(stats, ArtistListenStats{
+			Artist:         artist,
+			TotalScrobbles: ls.count,
+			FirstListen:    time.Unix(ls.first, 0),
+			LastListen:     time.Unix(ls.last, 0),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Artist < stats[j].Artist })
+	return stats, nil
+}
+
+func (m *MemoryStore) GetForgottenAlbums(ctx context.Context, user string, opts ForgottenQueryOptions) ([]AlbumListenStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byAlbum := make(map[AlbumKey]*listenStats)
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		if t.album == "" {
+			return
+		}
+		key := AlbumKey{Artist: t.artist, Name: t.album}
+		if byAlbum[key] == nil {
+			byAlbum[key] = &listenStats{}
+		}
+		byAlbum[key].add(l.date)
+	})
+
+	var stats []AlbumListenStats
+	for album, ls := range byAlbum {
+		if ls.matches(opts) {
+			stats = append(stats, AlbumListenStats{
+				Artist:         album.Artist,
+				Album:          album.Name,
+				TotalScrobbles: ls.count,
+				FirstListen:    time.Unix(ls.first, 0),
+				LastListen:     time.Unix(ls.last, 0),
+			})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Artist != stats[j].Artist {
+			return stats[i].Artist < stats[j].Artist
+		}
+		return stats[i].Album < stats[j].Album
+	})
+	return stats, nil
+}
+
+func (m *MemoryStore) GetForgottenTracks(ctx context.Context, user string, opts ForgottenQueryOptions) ([]TrackListenStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byTrack := make(map[AlbumKey]*listenStats)
+	m.eachListenAllTime(user, func(l memoryListen, t memoryTrack) {
+		key := AlbumKey{Artist: t.artist, Name: t.name}
+		if byTrack[key] == nil {
+			byTrack[key] = &listenStats{}
+		}
+		byTrack[key].add(l.date)
+	})
+
+	var stats []TrackListenStats
+	for track, ls := range byTrack {
+		if ls.matches(opts) {
+			stats = append(stats, TrackListenStats{
+				Artist:         track.Artist,
+				Track:          track.Name,
+				TotalScrobbles: ls.count,
+				FirstListen:    time.Unix(ls.first, 0),
+				LastListen:     time.Unix(ls.last, 0),
+			})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Artist != stats[j].Artist {
+			return stats[i].Artist < stats[j].Artist
+		}
+		return stats[i].Track < stats[j].Track
+	})
+	return stats, nil
+}
+
+func (m *MemoryStore) SetForgottenFeedback(ctx context.Context, user string, feedback ForgottenFeedback) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := feedback.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.feedback[user] == nil {
+		m.feedback[user] = make(map[memoryFeedbackKey]ForgottenFeedback)
+	}
+	// Times are stored to the second, like SQLiteStore.
+	feedback.Marked = time.Unix(feedback.Marked.Unix(), 0)
+	if !feedback.Until.IsZero() {
+		feedback.Until = time.Unix(feedback.Until.Unix(), 0)
+	}
+	m.feedback[user][memoryFeedbackKey{feedback.Kind, feedback.Artist, feedback.Name}] = feedback
+	return nil
+}
+
+func (m *MemoryStore) DeleteForgottenFeedback(ctx context.Context, user string, kind FeedbackKind, artist, name string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memoryFeedbackKey{kind, artist, name}
+	if _, ok := m.feedback[user][key]; !ok {
+		return false, nil
+	}
+	delete(m.feedback[user], key)
+	return true, nil
+}
+
+func (m *MemoryStore) GetForgottenFeedback(ctx context.Context, user string) ([]ForgottenFeedback, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var feedback []ForgottenFeedback
+	for _, f := range m.feedback[user] {
+		feedback = append(feedback, f)
+	}
+	sort.Slice(feedback, func(i, j int) bool {
+		a, b := feedback[i], feedback[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Artist != b.Artist {
+			return a.Artist < b.Artist
+		}
+		return a.Name < b.Name
+	})
+	return feedback, nil
+}
+
+// Weekly charts
+
+func (m *MemoryStore) UpdateWeeklyCharts(ctx context.Context, user string, now time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Listens are never removed, so their index identifies them like Listen.id.
+	current := make(map[int64]chartFingerprint)
+	for i, l := range m.listens {
+		if l.user != user {
+			continue
+		}
+		week := WeekStart(time.Unix(l.date, 0)).Unix()
+		f := current[week]
+		f.listens++
+		f.lastListenID = int64(i + 1)
+		current[week] = f
+	}
+
+	if m.charts[user] == nil {
+		m.charts[user] = make(map[int64]*memoryChartWeek)
+	}
+	computed := 0
+	for week, f := range current {
+		if time.Unix(week, 0).Add(chartWeek).After(now) {
+			continue
+		}
+		if c, ok := m.charts[user][week]; ok && c.fingerprint == f {
+			continue
+		}
+
+		end := week + int64(chartWeek/time.Second) - 1
+		artists := make(map[string]int64)
+		albums := make(map[AlbumKey]int64)
+		tracks := make(map[AlbumKey]int64)
+		m.eachListen(user, week, end, func(l memoryListen, t memoryTrack) {
+			artists[t.artist]++
+			if t.album != "" {
+				albums[AlbumKey{Artist: t.artist, Name: t.album}]++
+			}
+			tracks[AlbumKey{Artist: t.artist, Name: t.name}]++
+		})
+
+		chart := &memoryChartWeek{fingerprint: f, entries: make(map[ChartKind][]ChartEntry)}
+		var entries []ChartEntry
+		for artist, count := range artists {
+			entries = append(entries, ChartEntry{Artist: artist, Count: count})
+		}
+		chart.entries[ChartArtists] = entries
+		entries = nil
+		for album, count := range albums {
+			entries = append(entries, ChartEntry{Artist: album.Artist, Name: album.Name, Count: count})
+		}
+		chart.entries[ChartAlbums] = entries
+		entries = nil
+		for track, count := range tracks {
+			entries = append(entries, ChartEntry{Artist: track.Artist, Name: track.Name, Count: count})
+		}
+		chart.entries[ChartTracks] = entries
+		for kind, entries := range chart.entries {
+			entries = rankChart(entries)
+			for i := range entries {
+				entries[i].Week = time.Unix(week, 0).UTC()
+			}
+			chart.entries[kind] = entries
+		}
+
+		m.charts[user][week] = chart
+		computed++
+	}
+	return computed, nil
+}
+
+func (m *MemoryStore) GetWeeklyCharts(ctx context.Context, user string, kind ChartKind, start, end time.Time) ([]ChartEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var weeks []int64
+	for week := range m.charts[user] {
+		if week >= start.Unix() && week < end.Unix() {
+			weeks = append(weeks, week)
+		}
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i] < weeks[j] })
+
+	var entries []ChartEntry
+	for _, week := range weeks {
+		entries = append(entries, m.charts[user][week].entries[kind]...)
+	}
+	return entries, nil
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// PersonalTagCount is the count given to personal tags, the highest count last.fm gives, so that
+// they pass tag filters and rank above fetched tags.
+const PersonalTagCount = 100
+
+// PersonalTags are the user's own tags for an artist, or for an album if Album is set. Unlike
+// fetched tags, updates never change them.
+type PersonalTags struct {
+	Artist string
+	Album  string
+	Tags   []string
+	// Whether the tags replace the fetched tags, rather than supplementing them.
+	Replace bool
+}
+
+// merge adds the personal tags to the fetched tags, or replaces them.
+func (p PersonalTags) merge(fetched []TagCount) []TagCount {
+	var merged []TagCount
+	if !p.Replace {
+		merged = append(merged, fetched...)
+	}
+	for _, tag := range p.Tags {
+		merged = append(merged, TagCount{Tag: tag, Count: PersonalTagCount})
+	}
+	return merged
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("empty tag")
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// tagLayer turns fetched tags into the tags used by the analyses: the personal tags are merged in,
+// then the tag rules are applied.
+type tagLayer struct {
+	curator *TagCurator
+	artists map[string]PersonalTags
+	albums  map[AlbumKey]PersonalTags
+}
+
+func newTagLayer(rules []TagRule, personal []PersonalTags) (*tagLayer, error) {
+	curator, err := NewTagCurator(rules)
+	if err != nil {
+		return nil, err
+	}
+	l := &tagLayer{curator: curator, artists: make(map[string]PersonalTags), albums: make(map[AlbumKey]PersonalTags)}
+	for _, p := range personal {
+		if p.Album == "" {
+			l.artists[p.Artist] = p
+		} else {
+			l.albums[AlbumKey{Artist: p.Artist, Name: p.Album}] = p
+		}
+	}
+	return l, nil
+}
+
+func (l *tagLayer) artistTags(artist string, fetched []TagCount) []TagCount {
+	if p, ok := l.artists[artist]; ok {
+		fetched = p.merge(fetched)
+	}
+	return l.curator.Curate(artist, fetched)
+}
+
+func (l *tagLayer) albumTags(artist, album string, fetched []TagCount) []TagCount {
+	if p, ok := l.albums[AlbumKey{Artist: artist, Name: album}]; ok {
+		fetched = p.merge(fetched)
+	}
+	return l.curator.Curate(artist, fetched)
+}
+
+// allArtistTags applies the layer to every artist with fetched or personal tags, returning the
+// tags ordered by artist and tag.
+func (l *tagLayer) allArtistTags(data []ArtistTagData) []ArtistTagData {
+	byArtist := make(map[string][]TagCount)
+	for _, d := range data {
+		byArtist[d.Artist] = append(byArtist[d.Artist], TagCount{Tag: d.Tag, Count: d.Count})
+	}
+	for artist := range l.artists {
+		if _, ok := byArtist[artist]; !ok {
+			byArtist[artist] = nil
+		}
+	}
+	var tags []ArtistTagData
+	for artist, fetched := range byArtist {
+		for _, t := range l.artistTags(artist, fetched) {
+			tags = append(tags, ArtistTagData{Artist: artist, Tag: t.Tag, Count: t.Count})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Artist != tags[j].Artist {
+			return tags[i].Artist < tags[j].Artist
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+// allAlbumTags applies the layer to every album with fetched or personal tags, returning the tags
+// ordered by artist, album and tag.
+func (l *tagLayer) allAlbumTags(data []AlbumTagData) []AlbumTagData {
+	byAlbum := make(map[AlbumKey][]TagCount)
+	for _, d := range data {
+		key := AlbumKey{Artist: d.Artist, Name: d.Album}
+		byAlbum[key] = append(byAlbum[key], TagCount{Tag: d.Tag, Count: d.Count})
+	}
+	for key := range l.albums {
+		if _, ok := byAlbum[key]; !ok {
+			byAlbum[key] = nil
+		}
+	}
+	var tags []AlbumTagData
+	for key, fetched := range byAlbum {
+		for _, t := range l.albumTags(key.Artist, key.Name, fetched) {
+			tags = append(tags, AlbumTagData{Artist: key.Artist, Album: key.Name, Tag: t.Tag, Count: t.Count})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Artist != tags[j].Artist {
+			return tags[i].Artist < tags[j].Artist
+		}
+		if tags[i].Album != tags[j].Album {
+			return tags[i].Album < tags[j].Album
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+func (s *SQLiteStore) tagLayer(ctx context.Context) (*tagLayer, error) {
+	rules, err := s.GetTagRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	personal, err := s.GetPersonalTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newTagLayer(rules, personal)
+}
+
+func createPersonalTagTables(db *sql.DB) error {
+	// Artists' tags have an empty album. PersonalTagSet has a row for each artist or album with
+	// personal tags.
+	query := `
+CREATE TABLE IF NOT EXISTS PersonalTag (
+  artist TEXT,
+  album TEXT,
+  tag TEXT,
+  PRIMARY KEY (artist, album, tag)
+);
+
+CREATE TABLE IF NOT EXISTS PersonalTagSet (
+  artist TEXT,
+  album TEXT,
+  replace_fetched INTEGER,
+  PRIMARY KEY (artist, album)
+);
+`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("creating personal tag tables: %w", err)
+	}
+	return nil
+}
+
+// AddPersonalTags adds tags to the artist, or to the album if album is set. New personal tags
+// supplement the fetched tags.
+func (s *SQLiteStore) AddPersonalTags(ctx context.Context, artist, album string, tags []string) error {
+	if len(tags) == 0 {
+		return fmt.Errorf("no tags to add")
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO PersonalTagSet (artist, album, replace_fetched) VALUES (?, ?, 0)", artist, album); err != nil {
+		return fmt.Errorf("adding personal tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO PersonalTag (artist, album, tag) VALUES (?, ?, ?)", artist, album, tag); err != nil {
+			return fmt.Errorf("adding personal tag: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RemovePersonalTags removes tags from the artist or album, or all of its personal tags if tags is
+// empty, returning how many were removed.
+func (s *SQLiteStore) RemovePersonalTags(ctx context.Context, artist, album string, tags []string) (int, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var removed int64
+	if len(tags) == 0 {
+		res, err := tx.ExecContext(ctx, "DELETE FROM PersonalTag WHERE artist = ? AND album = ?", artist, album)
+		if err != nil {
+			return 0, fmt.Errorf("removing personal tags: %w", err)
+		}
+		if removed, err = res.RowsAffected(); err != nil {
+			return 0, err
+		}
+	}
+	for _, tag := range tags {
+		res, err := tx.ExecContext(ctx, "DELETE FROM PersonalTag WHERE artist = ? AND album = ? AND tag = ?", artist, album, tag)
+		if err != nil {
+			return 0, fmt.Errorf("removing personal tag: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		removed += n
+	}
+	// Once the last personal tag is removed, the fetched tags are used again.
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM PersonalTagSet
+		WHERE artist = ? AND album = ?
+		AND NOT EXISTS (SELECT 1 FROM PersonalTag p WHERE p.artist = PersonalTagSet.artist AND p.album = PersonalTagSet.album)`, artist, album)
+	if err != nil {
+		return 0, fmt.Errorf("removing personal tags: %w", err)
+	}
+	return int(removed), tx.Commit()
+}
+
+// SetPersonalTagsReplace sets whether the artist or album's personal tags replace its fetched tags.
+func (s *SQLiteStore) SetPersonalTagsReplace(ctx context.Context, artist, album string, replace bool) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE PersonalTagSet SET replace_fetched = ? WHERE artist = ? AND album = ?", replace, artist, album)
+	if err != nil {
+		return fmt.Errorf("setting personal tag mode: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no personal tags for %s", describeTagged(artist, album))
+	}
+	return nil
+}
+
+// GetPersonalTags returns every artist and album's personal tags, ordered by artist, album and tag.
+func (s *SQLiteStore) GetPersonalTags(ctx context.Context) ([]PersonalTags, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.artist, s.album, s.replace_fetched, p.tag
+		FROM PersonalTagSet s
+		JOIN PersonalTag p ON p.artist = s.artist AND p.album = s.album
+		ORDER BY s.artist, s.album, p.tag`)
+	if err != nil {
+		return nil, fmt.Errorf("querying personal tags: %w", err)
+	}
+	defer rows.Close()
+
+	var personal []PersonalTags
+	for rows.Next() {
+		var p PersonalTags
+		var tag string
+		if err := rows.Scan(&p.Artist, &p.Album, &p.Replace, &tag); err != nil {
+			return nil, err
+		}
+		if n := len(personal); n > 0 && personal[n-1].Artist == p.Artist && personal[n-1].Album == p.Album {
+			personal[n-1].Tags = append(personal[n-1].Tags, tag)
+			continue
+		}
+		p.Tags = []string{tag}
+		personal = append(personal, p)
+	}
+	return personal, rows.Err()
+}
+
+func describeTagged(artist, album string) string {
+	if album == "" {
+		return fmt.Sprintf("%q", artist)
+	}
+	return fmt.Sprintf("%q by %q", album, artist)
+}
@@ -1,14 +1,15 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
 	"time"
 )
 
-func (s *Store) GetSessionKey(user string) (string, error) {
-	row := s.db.QueryRow("SELECT session_key FROM User WHERE name = ? AND session_key <> ''", user)
+func (s *SQLiteStore) GetSessionKey(ctx context.Context, user string) (string, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT session_key FROM User WHERE name = ? AND session_key <> ''", user)
 	var key string
 	err := row.Scan(&key)
 	if err == sql.ErrNoRows {
@@ -20,8 +21,8 @@ func (s *Store) GetSessionKey(user string) (string, error) {
 	return key, nil
 }
 
-func (s *Store) GetLastUpdated(user string) (time.Time, error) {
-	row := s.db.QueryRow("SELECT last_updated FROM User WHERE name = ?", user)
+func (s *SQLiteStore) GetLastUpdated(ctx context.Context, user string) (time.Time, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT last_updated FROM User WHERE name = ?", user)
 	var t sql.NullTime
 	err := row.Scan(&t)
 	if err == sql.ErrNoRows {
@@ -33,10 +34,10 @@ func (s *Store) GetLastUpdated(user string) (time.Time, error) {
 	return t.Time, nil
 }
 
-func (s *Store) GetLatestListen(user string) (time.Time, error) {
+func (s *SQLiteStore) GetLatestListen(ctx context.Context, user string) (time.Time, error) {
 	// Matches legacy logic: sort by CAST(date AS INTEGER)
 	query := "SELECT date FROM Listen WHERE user = ? ORDER BY CAST(date AS INTEGER) desc LIMIT 1"
-	row := s.db.QueryRow(query, user)
+	row := s.db.QueryRowContext(ctx, query, user)
 	var dateStr string
 	err := row.Scan(&dateStr)
 	if err == sql.ErrNoRows {
@@ -66,7 +67,7 @@ func parseDate(dateStr string) (time.Time, error) {
 
 // Tag Update Helpers
 
-func (s *Store) GetArtistsNeedingTagUpdate(interval time.Duration) ([]string, error) {
+func (s *SQLiteStore) GetArtistsNeedingTagUpdate(ctx context.Context, interval time.Duration) ([]string, error) {
 	threshold := time.Now().Add(-interval)
 	query := `
 		SELECT t.artist
@@ -77,7 +78,7 @@ func (s *Store) GetArtistsNeedingTagUpdate(interval time.Duration) ([]string, er
 		GROUP BY t.artist
 		HAVING COUNT(*) > 10
 	`
-	rows, err := s.db.Query(query, threshold)
+	rows, err := s.db.QueryContext(ctx, query, threshold)
 	if err != nil {
 		return nil, fmt.Errorf("querying artists for tag update: %w", err)
 	}
@@ -99,7 +100,7 @@ type AlbumKey struct {
 	Name   string
 }
 
-func (s *Store) GetAlbumsNeedingTagUpdate(interval time.Duration) ([]AlbumKey, error) {
+func (s *SQLiteStore) GetAlbumsNeedingTagUpdate(ctx context.Context, interval time.Duration) ([]AlbumKey, error) {
 	threshold := time.Now().Add(-interval)
 	query := `
 		SELECT t.artist, t.album
@@ -110,7 +111,7 @@ func (s *Store) GetAlbumsNeedingTagUpdate(interval time.Duration) ([]AlbumKey, e
 		GROUP BY t.artist, t.album
 		HAVING COUNT(*) > 10
 	`
-	rows, err := s.db.Query(query, threshold)
+	rows, err := s.db.QueryContext(ctx, query, threshold)
 	if err != nil {
 		return nil, fmt.Errorf("querying albums for tag update: %w", err)
 	}
@@ -127,7 +128,48 @@ func (s *Store) GetAlbumsNeedingTagUpdate(interval time.Duration) ([]AlbumKey, e
 	return albums, rows.Err()
 }
 
-func (s *Store) GetListensInRange(user string, start, end time.Time) ([]time.Time, error) {
+// TrackListen is a single listen with the track's details.
+type TrackListen struct {
+	Time   time.Time
+	Artist string
+	Album  string
+	Track  string
+}
+
+// GetListenHistory returns user's listens with start <= date < end, oldest first.
+func (s *SQLiteStore) GetListenHistory(ctx context.Context, user string, start, end time.Time) ([]TrackListen, error) {
+	query := `
+		SELECT CAST(Listen.date AS INTEGER), Track.artist, Track.album, Track.name
+		FROM Listen
+		INNER JOIN Track ON Track.id = Listen.track
+		WHERE user = ?
+		AND CAST(Listen.date AS INTEGER) >= ?
+		AND CAST(Listen.date AS INTEGER) < ?
+		ORDER BY CAST(Listen.date AS INTEGER) ASC, Listen.id ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("querying listen history: %w", err)
+	}
+	defer rows.Close()
+
+	var listens []TrackListen
+	for rows.Next() {
+		var l TrackListen
+		var date int64
+		var album sql.NullString
+		if err := rows.Scan(&date, &l.Artist, &album, &l.Track); err != nil {
+			return nil, err
+		}
+		l.Time = time.Unix(date, 0)
+		l.Album = album.String
+		listens = append(listens, l)
+	}
+	return listens, rows.Err()
+}
+
+func (s *SQLiteStore) GetListensInRange(ctx context.Context, user string, start, end time.Time) ([]time.Time, error) {
 	startUTS := start.Unix()
 	endUTS := end.Unix()
 
@@ -140,7 +182,7 @@ func (s *Store) GetListensInRange(user string, start, end time.Time) ([]time.Tim
 		ORDER BY CAST(date AS INTEGER) ASC
 	`
 
-	rows, err := s.db.Query(query, user, startUTS, endUTS)
+	rows, err := s.db.QueryContext(ctx, query, user, startUTS, endUTS)
 	if err != nil {
 		return nil, fmt.Errorf("querying listens: %w", err)
 	}
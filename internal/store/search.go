@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The search index is an FTS5 table when go-sqlite3 is built with the sqlite_fts5 tag (as .bazelrc
+// does), and FTS4 otherwise. Both use the unicode61 tokenizer with diacritics removed, so that
+// "bjork" matches "Björk". Triggers keep it in sync with Artist, Album and Track, so AddRecentTracks
+// and deletes (e.g. from RepairIntegrity) don't need to touch it.
+const (
+	createSearchIndexFTS5 = `CREATE VIRTUAL TABLE SearchIndex USING fts5(
+  kind UNINDEXED, artist UNINDEXED, album UNINDEXED, name,
+  tokenize = 'unicode61 remove_diacritics 2'
+)`
+	createSearchIndexFTS4 = `CREATE VIRTUAL TABLE SearchIndex USING fts4(
+  kind, artist, album, name,
+  notindexed=kind, notindexed=artist, notindexed=album,
+  tokenize=unicode61 "remove_diacritics=2"
+)`
+
+	createSearchTriggers = `
+CREATE TRIGGER IF NOT EXISTS search_artist_insert AFTER INSERT ON Artist BEGIN
+  INSERT INTO SearchIndex (kind, artist, album, name) VALUES ('artist', new.name, '', new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS search_artist_delete AFTER DELETE ON Artist BEGIN
+  DELETE FROM SearchIndex WHERE kind = 'artist' AND artist = old.name;
+END;
+CREATE TRIGGER IF NOT EXISTS search_album_insert AFTER INSERT ON Album WHEN new.name != '' BEGIN
+  INSERT INTO SearchIndex (kind, artist, album, name) VALUES ('album', new.artist, new.name, new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS search_album_delete AFTER DELETE ON Album BEGIN
+  DELETE FROM SearchIndex WHERE kind = 'album' AND artist = old.artist AND album = old.name;
+END;
+CREATE TRIGGER IF NOT EXISTS search_track_insert AFTER INSERT ON Track BEGIN
+  INSERT INTO SearchIndex (kind, artist, album, name) VALUES ('track', new.artist, new.album, new.name);
+END;
+CREATE TRIGGER IF NOT EXISTS search_track_delete AFTER DELETE ON Track BEGIN
+  DELETE FROM SearchIndex WHERE kind = 'track' AND artist = old.artist AND album = old.album AND name = old.name;
+END;
+`
+
+	populateSearchIndex = `
+INSERT INTO SearchIndex (kind, artist, album, name) SELECT 'artist', name, '', name FROM Artist;
+INSERT INTO SearchIndex (kind, artist, album, name) SELECT 'album', artist, name, name FROM Album WHERE name != '';
+INSERT INTO SearchIndex (kind, artist, album, name) SELECT 'track', artist, album, name FROM Track;
+`
+)
+
+const (
+	SearchKindArtist = "artist"
+	SearchKindAlbum  = "album"
+	SearchKindTrack  = "track"
+)
+
+// searchStatsBatch is how many artists' plays are looked up per query, to stay under SQLite's limit
+// on query parameters.
+const searchStatsBatch = 500
+
+type SearchResult struct {
+	Kind        string
+	Artist      string
+	Album       string // Empty for artists and tracks, which are grouped across albums.
+	Name        string
+	Plays       int64
+	FirstListen time.Time
+	LastListen  time.Time
+	Tags        []string
+}
+
+// ensureSearchIndex creates and populates the search index if it doesn't exist yet. An FTS5 index
+// can't be used, or even dropped, by a binary built without the sqlite_fts5 tag, and every insert
+// into Artist, Album or Track would fail through its triggers, so that's reported up front.
+func ensureSearchIndex(db *sql.DB) error {
+	var name string
+	err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'SearchIndex'").Scan(&name)
+	if err == nil {
+		var n int
+		err := db.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM SearchIndex LIMIT 1)").Scan(&n)
+		if err != nil && strings.Contains(err.Error(), "no such module") {
+			return fmt.Errorf("the search index was created by a build with FTS5, which this build doesn't include (%v): build with `go build -tags sqlite_fts5`, as Bazel does", err)
+		}
+		if err != nil {
+			return fmt.Errorf("checking search index: %w", err)
+		}
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("checking search index: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(createSearchIndexFTS5); err != nil {
+		if !strings.Contains(err.Error(), "no such module") {
+			return fmt.Errorf("creating search index: %w", err)
+		}
+		if _, err := tx.Exec(createSearchIndexFTS4); err != nil {
+			return fmt.Errorf("creating search index: %w", err)
+		}
+	}
+	if _, err := tx.Exec(createSearchTriggers); err != nil {
+		return fmt.Errorf("creating search triggers: %w", err)
+	}
+	if _, err := tx.Exec(populateSearchIndex); err != nil {
+		return fmt.Errorf("populating search index: %w", err)
+	}
+	return tx.Commit()
+}
+
+// searchMatchExpression turns free text into a full-text query which requires every word, each
+// matched as a prefix. Words are lowercased so they can't be read as AND/OR/NOT operators, and left
+// unquoted since FTS4 doesn't support prefix matching on quoted strings.
+func searchMatchExpression(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	var terms []string
+	for _, w := range words {
+		terms = append(terms, strings.ToLower(w)+"*")
+	}
+	return strings.Join(terms, " ")
+}
+
+// Search finds artists, albums and tracks whose name matches query, ordered by the user's plays.
+// Tracks with the same name by the same artist are combined across albums.
+func (s *SQLiteStore) Search(ctx context.Context, user, query string, limit int) ([]SearchResult, error) {
+	match := searchMatchExpression(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	// Every match is ranked before the results are limited, so that the most played aren't dropped.
+	rows, err := s.db.QueryContext(ctx, "SELECT kind, artist, album, name FROM SearchIndex WHERE SearchIndex MATCH ?", match)
+	if err != nil {
+		return nil, fmt.Errorf("querying search index: %w", err)
+	}
+
+	type key struct{ kind, artist, album, name string }
+	seen := make(map[key]bool)
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.Kind, &r.Artist, &r.Album, &r.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if r.Kind != SearchKindAlbum {
+			r.Album = ""
+		}
+		k := key{r.Kind, r.Artist, r.Album, r.Name}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		results = append(results, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.fillSearchStats(ctx, user, results); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Plays > results[j].Plays
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	for i := range results {
+		tags, err := s.GetTopTagsForArtist(ctx, results[i].Artist, 3)
+		if err != nil {
+			return nil, err
+		}
+		results[i].Tags = tags
+	}
+	return results, nil
+}
+
+// fillSearchStats sets the plays and first and last listens of results, from one query over the
+// listens of each batch of their artists.
+func (s *SQLiteStore) fillSearchStats(ctx context.Context, user string, results []SearchResult) error {
+	seen := make(map[string]bool)
+	var artists []string
+	for _, r := range results {
+		if !seen[r.Artist] {
+			seen[r.Artist] = true
+			artists = append(artists, r.Artist)
+		}
+	}
+
+	type key struct{ kind, artist, album, name string }
+	type stats struct{ plays, first, last int64 }
+	byKey := make(map[key]*stats)
+	add := func(k key, plays, first, last int64) {
+		st := byKey[k]
+		if st == nil {
+			byKey[k] = &stats{plays, first, last}
+			return
+		}
+		st.plays += plays
+		st.first = min(st.first, first)
+		st.last = max(st.last, last)
+	}
+	for len(artists) > 0 {
+		batch := artists[:min(len(artists), searchStatsBatch)]
+		artists = artists[len(batch):]
+		args := []interface{}{user}
+		for _, artist := range batch {
+			args = append(args, artist)
+		}
+		query := `
+			SELECT t.artist, t.album, t.name, COUNT(*), MIN(CAST(l.date AS INTEGER)), MAX(CAST(l.date AS INTEGER))
+			FROM Listen l
+			JOIN Track t ON l.track = t.id
+			WHERE l.user = ? AND t.artist IN (?` + strings.Repeat(", ?", len(batch)-1) + `)
+			GROUP BY t.artist, t.album, t.name
+		`
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("getting plays for search results: %w", err)
+		}
+		for rows.Next() {
+			var artist, album, name string
+			var plays, first, last int64
+			if err := rows.Scan(&artist, &album, &name, &plays, &first, &last); err != nil {
+				rows.Close()
+				return err
+			}
+			add(key{SearchKindArtist, artist, "", artist}, plays, first, last)
+			add(key{SearchKindAlbum, artist, album, album}, plays, first, last)
+			add(key{SearchKindTrack, artist, "", name}, plays, first, last)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		st := byKey[key{r.Kind, r.Artist, r.Album, r.Name}]
+		if st == nil {
+			continue
+		}
+		r.Plays = st.plays
+		r.FirstListen = time.Unix(st.first, 0)
+		r.LastListen = time.Unix(st.last, 0)
+	}
+	return nil
+}
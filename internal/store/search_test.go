@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	s := createTestDb(t)
+	defer s.Close()
+
+	user := "testuser"
+	s.CreateUser(context.Background(), user)
+
+	var tracks []TrackImport
+	for i := 0; i < 3; i++ {
+		tracks = append(tracks, TrackImport{Artist: "Björk", Album: "Homogenic", TrackName: "Jóga", DateUTS: fmt.Sprintf("%d", 1600000000+i)})
+	}
+	tracks = append(tracks,
+		TrackImport{Artist: "Björk", Album: "Greatest Hits", TrackName: "Jóga", DateUTS: "1600001000"},
+		TrackImport{Artist: "Bjorn Again", Album: "Live", TrackName: "Waterloo", DateUTS: "1600002000"},
+		TrackImport{Artist: "Radiohead", Album: "OK Computer", TrackName: "Airbag", DateUTS: "1600003000"},
+	)
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+	if err := s.SaveArtistTags(context.Background(), "Björk", []string{"electronic", "icelandic"}, []int{100, 80}); err != nil {
+		t.Fatalf("SaveArtistTags: %v", err)
+	}
+
+	results, err := s.Search(context.Background(), user, "bjork", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Search(bjork) = %v, want only Björk", results)
+	}
+	r := results[0]
+	if r.Kind != SearchKindArtist || r.Name != "Björk" || r.Plays != 4 {
+		t.Errorf("Search(bjork) = %+v, want artist Björk with 4 plays", r)
+	}
+	if r.FirstListen.Unix() != 1600000000 || r.LastListen.Unix() != 1600001000 {
+		t.Errorf("Search(bjork) listens %v to %v, want 1600000000 to 1600001000", r.FirstListen.Unix(), r.LastListen.Unix())
+	}
+	if len(r.Tags) != 2 || r.Tags[0] != "electronic" {
+		t.Errorf("Search(bjork) tags = %v, want [electronic icelandic]", r.Tags)
+	}
+
+	// Prefix matching finds both artists, ordered by plays.
+	results, err = s.Search(context.Background(), user, "bjo", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "Björk" || results[1].Name != "Bjorn Again" {
+		t.Errorf("Search(bjo) = %+v, want Björk then Bjorn Again", results)
+	}
+
+	results, err = s.Search(context.Background(), user, "homogenic", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Kind != SearchKindAlbum || results[0].Plays != 3 || results[0].LastListen.Unix() != 1600000002 {
+		t.Errorf("Search(homogenic) = %+v, want album Homogenic with 3 plays, the last at 1600000002", results)
+	}
+
+	// Tracks are grouped across albums.
+	results, err = s.Search(context.Background(), user, "joga", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Kind != SearchKindTrack || results[0].Plays != 4 {
+		t.Errorf("Search(joga) = %+v, want one track with 4 plays", results)
+	}
+
+	// Newly imported entities are indexed.
+	if err := s.AddRecentTracks(context.Background(), user, []TrackImport{{Artist: "Sigur Rós", Album: "Ágætis byrjun", TrackName: "Svefn-g-englar", DateUTS: "1600004000"}}); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+	results, err = s.Search(context.Background(), user, "agaetis", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 0 {
+		// "æ" isn't a diacritic, so this shouldn't match; "agætis" should.
+		t.Errorf("Search(agaetis) = %+v, want no results", results)
+	}
+	results, err = s.Search(context.Background(), user, "agætis byr", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Kind != SearchKindAlbum || results[0].Album != "Ágætis byrjun" {
+		t.Errorf("Search(agætis byr) = %+v, want album Ágætis byrjun", results)
+	}
+}
+
+func TestSearchRanksEveryMatch(t *testing.T) {
+	s := createTestDb(t)
+	defer s.Close()
+
+	user := "testuser"
+	s.CreateUser(context.Background(), user)
+
+	// More matching artists than are looked up in one batch, with the most played added last.
+	var tracks []TrackImport
+	for i := 0; i < 2*searchStatsBatch; i++ {
+		tracks = append(tracks, TrackImport{Artist: fmt.Sprintf("Band %d", i), TrackName: "Song", DateUTS: fmt.Sprintf("%d", 1600000000+i)})
+	}
+	for i := 0; i < 3; i++ {
+		tracks = append(tracks, TrackImport{Artist: "Band Last", TrackName: "Hit", DateUTS: fmt.Sprintf("%d", 1700000000+i)})
+	}
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
+		t.Fatalf("AddRecentTracks: %v", err)
+	}
+
+	results, err := s.Search(context.Background(), user, "band", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "Band Last" || results[0].Plays != 3 {
+		t.Errorf("Search(band) = %+v, want artist Band Last with 3 plays", results)
+	}
+}
+
+func TestSearchIndexMissingModule(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "lastfm.db")
+	s, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Stand in for an FTS5 index opened by a build without FTS5, with a module no build has.
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	for _, query := range []string{
+		"PRAGMA writable_schema = ON",
+		"UPDATE sqlite_master SET sql = 'CREATE VIRTUAL TABLE SearchIndex USING nosuchmodule(name)' WHERE name = 'SearchIndex'",
+		"PRAGMA writable_schema = OFF",
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	conn.Close()
+	s.Close()
+
+	if _, err := New(dbPath); err == nil || !strings.Contains(err.Error(), "sqlite_fts5") {
+		t.Errorf("New() = %v, want an error saying to build with sqlite_fts5", err)
+	}
+}
@@ -1,18 +1,91 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/ademuri/last-fm-tools/internal/migration"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-type Store struct {
+// Store is the listening history storage used by the analyses and the update command. SQLiteStore is
+// the persistent implementation; MemoryStore keeps everything in memory, for tests and for embedding
+// the analyses without SQLite. Maintenance operations (backups, integrity checks, full-text search)
+// are specific to SQLiteStore.
+type Store interface {
+	Close() error
+
+	// Users and updates
+	CreateUser(ctx context.Context, user string) error
+	GetSessionKey(ctx context.Context, user string) (string, error)
+	GetLastUpdated(ctx context.Context, user string) (time.Time, error)
+	SetLastUpdated(ctx context.Context, user string, updated time.Time) error
+	GetLatestListen(ctx context.Context, user string) (time.Time, error)
+	AddRecentTracks(ctx context.Context, user string, tracks []TrackImport) error
+
+	// Tags
+	GetArtistsNeedingTagUpdate(ctx context.Context, interval time.Duration) ([]string, error)
+	GetAlbumsNeedingTagUpdate(ctx context.Context, interval time.Duration) ([]AlbumKey, error)
+	SaveArtistTags(ctx context.Context, artist string, tags []string, counts []int) error
+	SaveAlbumTags(ctx context.Context, artist, album string, tags []string, counts []int) error
+	GetTopTagsForArtist(ctx context.Context, artist string, limit int) ([]string, error)
+	GetTopTagsForAlbum(ctx context.Context, artist, album string, limit int) ([]string, error)
+	GetAllArtistTags(ctx context.Context) ([]ArtistTagData, error)
+	GetAllAlbumTags(ctx context.Context) ([]AlbumTagData, error)
+	// The tag getters above merge in personal tags and apply the tag rules. GetArtistTagCounts
+	// returns the tags as fetched.
+	GetArtistTagCounts(ctx context.Context, artist string) ([]TagCount, error)
+	AddTagRule(ctx context.Context, rule TagRule) error
+	DeleteTagRule(ctx context.Context, kind TagRuleKind, pattern string) (bool, error)
+	GetTagRules(ctx context.Context) ([]TagRule, error)
+	AddPersonalTags(ctx context.Context, artist, album string, tags []string) error
+	RemovePersonalTags(ctx context.Context, artist, album string, tags []string) (int, error)
+	SetPersonalTagsReplace(ctx context.Context, artist, album string, replace bool) error
+	GetPersonalTags(ctx context.Context) ([]PersonalTags, error)
+
+	// Listening history
+	GetListensInRange(ctx context.Context, user string, start, end time.Time) ([]time.Time, error)
+	GetListenHistory(ctx context.Context, user string, start, end time.Time) ([]TrackListen, error)
+	GetTotalScrobbles(ctx context.Context, user string) (int64, error)
+	GetTotalScrobblesInPeriod(ctx context.Context, user string, start, end time.Time) (int64, error)
+	GetTotalArtists(ctx context.Context, user string) (int, error)
+	GetFirstListen(ctx context.Context, user string) (time.Time, error)
+	GetTopArtists(ctx context.Context, user string, start, end time.Time, limit int) ([]ArtistScrobbleCount, error)
+	GetTopArtistsWithCount(ctx context.Context, user string, start, end time.Time) ([]ArtistPlayCount, error)
+	GetArtistMonthlyCounts(ctx context.Context, user string, start, end time.Time) ([]ArtistMonthCount, error)
+	GetTopAlbums(ctx context.Context, user string, start, end time.Time, limit int) ([]AlbumScrobbleCount, error)
+	GetTopAlbumsWithCount(ctx context.Context, user string, start, end time.Time) ([]AlbumPlayCount, error)
+	GetTopTracksWithCount(ctx context.Context, user string, start, end time.Time) ([]TrackPlayCount, error)
+	GetTopAlbumsForArtist(ctx context.Context, user, artist string, start, end time.Time, limit int) ([]TagCount, error)
+	GetArtistListenCount(ctx context.Context, user, artist string, start, end time.Time) (int64, error)
+	GetPeakYears(ctx context.Context, user, artist string) (string, error)
+	GetAlbumTracks(ctx context.Context, artist, album string) ([]string, error)
+	GetAlbumListenCounts(ctx context.Context, user string, start, end time.Time) ([]AlbumScrobbleCount, error)
+	GetArtistAlbumStats(ctx context.Context, user string, start, end time.Time) ([]ArtistAlbumStats, error)
+	GetNewArtistsCount(ctx context.Context, user string, since time.Time) (int, error)
+	GetForgottenArtists(ctx context.Context, user string, opts ForgottenQueryOptions) ([]ArtistListenStats, error)
+	GetArtistListenStats(ctx context.Context, user string, start, end time.Time) ([]ArtistListenStats, error)
+	GetForgottenAlbums(ctx context.Context, user string, opts ForgottenQueryOptions) ([]AlbumListenStats, error)
+	GetForgottenTracks(ctx context.Context, user string, opts ForgottenQueryOptions) ([]TrackListenStats, error)
+	SetForgottenFeedback(ctx context.Context, user string, feedback ForgottenFeedback) error
+	DeleteForgottenFeedback(ctx context.Context, user string, kind FeedbackKind, artist, name string) (bool, error)
+	GetForgottenFeedback(ctx context.Context, user string) ([]ForgottenFeedback, error)
+
+	// Weekly charts
+	UpdateWeeklyCharts(ctx context.Context, user string, now time.Time) (int, error)
+	GetWeeklyCharts(ctx context.Context, user string, kind ChartKind, start, end time.Time) ([]ChartEntry, error)
+}
+
+// SQLiteStore is the Store backed by a SQLite database file.
+type SQLiteStore struct {
 	db *sql.DB
 }
 
-func New(dbPath string) (*Store, error) {
+var _ Store = (*SQLiteStore)(nil)
+
+func New(dbPath string) (*SQLiteStore, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
@@ -28,10 +101,15 @@ func New(dbPath string) (*Store, error) {
 		return nil, fmt.Errorf("ensuring schema: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	if err := ensureSearchIndex(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ensuring search index: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
 }
 
-func (s *Store) Close() error {
+func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
@@ -48,7 +126,19 @@ func createTables(db *sql.DB) error {
 		}
 	}
 
-	return createTagTables(db)
+	if err := createTagTables(db); err != nil {
+		return err
+	}
+	if err := createTagRuleTable(db); err != nil {
+		return err
+	}
+	if err := createPersonalTagTables(db); err != nil {
+		return err
+	}
+	if err := createFeedbackTable(db); err != nil {
+		return err
+	}
+	return createChartTables(db)
 }
 
 func dbExists(db *sql.DB) (bool, error) {
@@ -118,6 +208,30 @@ func ensureSchema(db *sql.DB) error {
 	if err := addColumnIfNotExists(db, "Album", "tags_last_updated", "DATETIME"); err != nil {
 		return err
 	}
+	// Report columns added after the initial schema, matching cmd's legacy ensureSchema.
+	if err := addColumnIfNotExists(db, "Report", "params", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(db, "Report", "next_run", "DATETIME"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(db, "Report", "interval_days", "INTEGER"); err != nil {
+		return err
+	}
+	return normalizeListenDates(db)
+}
+
+// normalizeListenDates converts listen dates saved as RFC3339 text, which older versions saved as
+// they were given, to Unix times like createListen saves. Dates SQLite can't parse are left for
+// doctor to report.
+func normalizeListenDates(db *sql.DB) error {
+	query := `
+UPDATE Listen SET date = CAST(strftime('%s', date) AS INTEGER)
+WHERE typeof(date) = 'text' AND strftime('%s', date) IS NOT NULL
+`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("normalizing listen dates: %w", err)
+	}
 	return nil
 }
 
@@ -1,12 +1,14 @@
 package store
 
 import (
+	"context"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 )
 
-func createTestDb(t *testing.T) *Store {
+func createTestDb(t *testing.T) *SQLiteStore {
 	t.Helper()
 	dbPath := filepath.Join(t.TempDir(), "lastfm.db")
 
@@ -23,13 +25,13 @@ func TestCreateUser(t *testing.T) {
 	defer s.Close()
 
 	user := "testuser"
-	err := s.CreateUser(user)
+	err := s.CreateUser(context.Background(), user)
 	if err != nil {
 		t.Fatalf("CreateUser(%q) error: %v", user, err)
 	}
 
 	// Idempotency
-	err = s.CreateUser(user)
+	err = s.CreateUser(context.Background(), user)
 	if err != nil {
 		t.Fatalf("CreateUser(%q) error: %v", user, err)
 	}
@@ -40,7 +42,7 @@ func TestAddRecentTracks(t *testing.T) {
 	defer s.Close()
 
 	user := "testuser"
-	if err := s.CreateUser(user); err != nil {
+	if err := s.CreateUser(context.Background(), user); err != nil {
 		t.Fatalf("CreateUser: %v", err)
 	}
 
@@ -53,7 +55,7 @@ func TestAddRecentTracks(t *testing.T) {
 		},
 	}
 
-	err := s.AddRecentTracks(user, tracks)
+	err := s.AddRecentTracks(context.Background(), user, tracks)
 	if err != nil {
 		t.Fatalf("AddRecentTracks failed: %v", err)
 	}
@@ -69,7 +71,7 @@ func TestAddRecentTracks(t *testing.T) {
 	}
 
 	// Test idempotent insert (same data)
-	err = s.AddRecentTracks(user, tracks)
+	err = s.AddRecentTracks(context.Background(), user, tracks)
 	if err != nil {
 		t.Fatalf("AddRecentTracks (repeat) failed: %v", err)
 	}
@@ -87,7 +89,7 @@ func TestGetLatestListenWithBadDate(t *testing.T) {
 	defer s.Close()
 
 	user := "reproUser"
-	if err := s.CreateUser(user); err != nil {
+	if err := s.CreateUser(context.Background(), user); err != nil {
 		t.Fatalf("CreateUser: %v", err)
 	}
 
@@ -104,19 +106,19 @@ func TestGetLatestListenWithBadDate(t *testing.T) {
 			DateUTS:   "0001-01-01T00:00:00Z", // Text date
 		},
 	}
-	if err := s.AddRecentTracks(user, tracks); err != nil {
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
 		t.Fatalf("AddRecentTracks bad date: %v", err)
 	}
 
 	// Track 2: Good Date (Unix Timestamp)
 	// 1593490750 = 2020-06-30...
 	tracks[0].DateUTS = "1593490750"
-	if err := s.AddRecentTracks(user, tracks); err != nil {
+	if err := s.AddRecentTracks(context.Background(), user, tracks); err != nil {
 		t.Fatalf("AddRecentTracks good date: %v", err)
 	}
 
 	// Attempt to get latest listen
-	date, err := s.GetLatestListen(user)
+	date, err := s.GetLatestListen(context.Background(), user)
 	if err != nil {
 		t.Fatalf("GetLatestListen failed: %v", err)
 	}
@@ -127,13 +129,57 @@ func TestGetLatestListenWithBadDate(t *testing.T) {
 	}
 }
 
+func TestNormalizeListenDates(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "lastfm.db")
+	s, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New(%s) error: %v", dbPath, err)
+	}
+	// Older versions saved dates as they were given.
+	for _, date := range []string{"2020-03-15T10:00:00Z", "1600000000", "yesterday"} {
+		if _, err := s.db.Exec("INSERT INTO Listen (user, track, date) VALUES ('testuser', 1, ?)", date); err != nil {
+			t.Fatalf("inserting listen: %v", err)
+		}
+	}
+	s.Close()
+
+	s, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("New(%s) error: %v", dbPath, err)
+	}
+	defer s.Close()
+	rows, err := s.db.Query("SELECT CAST(date AS TEXT) FROM Listen ORDER BY id")
+	if err != nil {
+		t.Fatalf("querying dates: %v", err)
+	}
+	defer rows.Close()
+	var dates []string
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			t.Fatalf("scanning date: %v", err)
+		}
+		dates = append(dates, date)
+	}
+	want := []string{strconv.FormatInt(time.Date(2020, 3, 15, 10, 0, 0, 0, time.UTC).Unix(), 10), "1600000000", "yesterday"}
+	if len(dates) != len(want) {
+		t.Fatalf("dates = %v, want %v", dates, want)
+	}
+	for i := range want {
+		if dates[i] != want[i] {
+			t.Errorf("dates = %v, want %v", dates, want)
+			break
+		}
+	}
+}
+
 func TestTagUpdates(t *testing.T) {
 	s := createTestDb(t)
 	defer s.Close()
 
 	// Setup: Add listen for artist/album
 	user := "testuser"
-	s.CreateUser(user)
+	s.CreateUser(context.Background(), user)
 	
 	// Insert 11 listens to trigger "COUNT(*) > 10" check
 	tracks := []TrackImport{}
@@ -150,13 +196,13 @@ func TestTagUpdates(t *testing.T) {
 	// Ah, createListen uses SELECT id... IF exists return nil.
 	// So we need different dates.
 	for i := range tracks {
-		tracks[i].DateUTS =  "16000000" + string(rune(i+48)) // Hacky but works for string
+		tracks[i].DateUTS =  "16000000" + strconv.Itoa(i) // Hacky but works for string
 	}
 	
-	s.AddRecentTracks(user, tracks)
+	s.AddRecentTracks(context.Background(), user, tracks)
 
 	// Check Artists Needing Update
-	artists, err := s.GetArtistsNeedingTagUpdate(24 * time.Hour)
+	artists, err := s.GetArtistsNeedingTagUpdate(context.Background(), 24 * time.Hour)
 	if err != nil {
 		t.Fatalf("GetArtistsNeedingTagUpdate: %v", err)
 	}
@@ -165,13 +211,13 @@ func TestTagUpdates(t *testing.T) {
 	}
 
 	// Update Tags
-	err = s.SaveArtistTags("The Beatles", []string{"Classic Rock"}, []int{100})
+	err = s.SaveArtistTags(context.Background(), "The Beatles", []string{"Classic Rock"}, []int{100})
 	if err != nil {
 		t.Fatalf("SaveArtistTags: %v", err)
 	}
 
 	// Check again - should be empty (recently updated)
-	artists, err = s.GetArtistsNeedingTagUpdate(24 * time.Hour)
+	artists, err = s.GetArtistsNeedingTagUpdate(context.Background(), 24 * time.Hour)
 	if err != nil {
 		t.Fatalf("GetArtistsNeedingTagUpdate 2: %v", err)
 	}
@@ -180,7 +226,7 @@ func TestTagUpdates(t *testing.T) {
 	}
 
 	// Check Albums Needing Update
-	albums, err := s.GetAlbumsNeedingTagUpdate(24 * time.Hour)
+	albums, err := s.GetAlbumsNeedingTagUpdate(context.Background(), 24 * time.Hour)
 	if err != nil {
 		t.Fatalf("GetAlbumsNeedingTagUpdate: %v", err)
 	}
@@ -189,13 +235,13 @@ func TestTagUpdates(t *testing.T) {
 	}
 
 	// Update Album Tags
-	err = s.SaveAlbumTags("The Beatles", "Abbey Road", []string{"Masterpiece"}, []int{100})
+	err = s.SaveAlbumTags(context.Background(), "The Beatles", "Abbey Road", []string{"Masterpiece"}, []int{100})
 	if err != nil {
 		t.Fatalf("SaveAlbumTags: %v", err)
 	}
 
 	// Check again
-	albums, err = s.GetAlbumsNeedingTagUpdate(24 * time.Hour)
+	albums, err = s.GetAlbumsNeedingTagUpdate(context.Background(), 24 * time.Hour)
 	if err != nil {
 		t.Fatalf("GetAlbumsNeedingTagUpdate 2: %v", err)
 	}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TagRuleKind is how a tag rule changes tags.
+type TagRuleKind string
+
+const (
+	// Tags equal to Pattern are replaced by Replacement.
+	TagRuleSynonym TagRuleKind = "synonym"
+	// Tags equal to Pattern are dropped.
+	TagRuleBlock TagRuleKind = "block"
+	// Tags matching the regular expression Pattern are rewritten with Replacement, which may refer
+	// to submatches like $1. Tags which are rewritten to nothing are dropped.
+	TagRuleRegex TagRuleKind = "regex"
+)
+
+// TagRule is a user-configured rule for curating last.fm's tags. Patterns are matched against
+// normalized tags.
+type TagRule struct {
+	Kind        TagRuleKind
+	Pattern     string
+	Replacement string
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// NormalizeTag lowercases tag, treats hyphens and underscores as spaces and collapses whitespace,
+// so that e.g. "Hip-Hop" and "hip hop" are the same tag.
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(tag)
+	tag = strings.NewReplacer("-", " ", "_", " ").Replace(tag)
+	return strings.TrimSpace(spaces.ReplaceAllString(tag, " "))
+}
+
+// tagRulePattern returns pattern as it's stored for a rule of the kind.
+func tagRulePattern(kind TagRuleKind, pattern string) (string, error) {
+	switch kind {
+	case TagRuleSynonym, TagRuleBlock:
+		pattern = NormalizeTag(pattern)
+	case TagRuleRegex:
+		if _, err := regexp.Compile(pattern); err != nil {
+			return "", fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+	default:
+		return "", fmt.Errorf("invalid tag rule kind %q, must be synonym, block or regex", kind)
+	}
+	if pattern == "" {
+		return "", fmt.Errorf("tag rule needs a pattern")
+	}
+	return pattern, nil
+}
+
+// validate checks the rule and normalizes its pattern and replacement.
+func (r TagRule) validate() (TagRule, error) {
+	pattern, err := tagRulePattern(r.Kind, r.Pattern)
+	if err != nil {
+		return r, err
+	}
+	r.Pattern = pattern
+	switch r.Kind {
+	case TagRuleSynonym:
+		r.Replacement = NormalizeTag(r.Replacement)
+		if r.Replacement == "" {
+			return r, fmt.Errorf("synonym for %q needs a replacement", r.Pattern)
+		}
+	case TagRuleBlock:
+		r.Replacement = ""
+	}
+	return r, nil
+}
+
+// TagCurator turns last.fm's tags into canonical tags using the tag rules.
+type TagCurator struct {
+	synonyms map[string]string
+	blocked  map[string]bool
+	regexes  []tagRegex
+}
+
+type tagRegex struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// NewTagCurator returns a curator which applies the regexes in order, then the synonyms. Blocked tags
+// are dropped both before and after they're rewritten.
+func NewTagCurator(rules []TagRule) (*TagCurator, error) {
+	c := &TagCurator{synonyms: make(map[string]string), blocked: make(map[string]bool)}
+	for _, r := range rules {
+		r, err := r.validate()
+		if err != nil {
+			return nil, err
+		}
+		switch r.Kind {
+		case TagRuleSynonym:
+			c.synonyms[r.Pattern] = r.Replacement
+		case TagRuleBlock:
+			c.blocked[r.Pattern] = true
+		case TagRuleRegex:
+			c.regexes = append(c.regexes, tagRegex{re: regexp.MustCompile(r.Pattern), replacement: r.Replacement})
+		}
+	}
+	return c, nil
+}
+
+// Canonical returns the canonical form of one of artist's tags, or false if the tag is dropped.
+// Tags which are just the artist's name are always dropped.
+func (c *TagCurator) Canonical(artist, tag string) (string, bool) {
+	tag = NormalizeTag(tag)
+	if c.blocked[tag] {
+		return "", false
+	}
+	for _, r := range c.regexes {
+		if r.re.MatchString(tag) {
+			tag = NormalizeTag(r.re.ReplaceAllString(tag, r.replacement))
+		}
+	}
+	if synonym, ok := c.synonyms[tag]; ok {
+		tag = synonym
+	}
+	if tag == "" || c.blocked[tag] || tag == NormalizeTag(artist) {
+		return "", false
+	}
+	return tag, true
+}
+
+// Curate returns the canonical forms of one of artist's tags, ordered by count. Tags with the same
+// canonical form are merged, keeping the highest count.
+func (c *TagCurator) Curate(artist string, tags []TagCount) []TagCount {
+	counts := make(map[string]int)
+	for _, t := range tags {
+		canonical, ok := c.Canonical(artist, t.Tag)
+		if !ok {
+			continue
+		}
+		if count, seen := counts[canonical]; !seen || t.Count > count {
+			counts[canonical] = t.Count
+		}
+	}
+	curated := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		curated = append(curated, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(curated, func(i, j int) bool {
+		if curated[i].Count != curated[j].Count {
+			return curated[i].Count > curated[j].Count
+		}
+		return curated[i].Tag < curated[j].Tag
+	})
+	return curated
+}
+
+func tagNames(tags []TagCount, limit int) []string {
+	var names []string
+	for _, t := range tags[:limitTo(len(tags), limit)] {
+		names = append(names, t.Tag)
+	}
+	return names
+}
+
+func createTagRuleTable(db *sql.DB) error {
+	query := `
+CREATE TABLE IF NOT EXISTS TagRule (
+  kind TEXT,
+  pattern TEXT,
+  replacement TEXT,
+  PRIMARY KEY (kind, pattern)
+);
+`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("creating tag rule table: %w", err)
+	}
+	return nil
+}
+
+// AddTagRule adds a rule, replacing any rule of the same kind for the same pattern.
+func (s *SQLiteStore) AddTagRule(ctx context.Context, rule TagRule) error {
+	rule, err := rule.validate()
+	if err != nil {
+		return err
+	}
+	// Replacing moves the rule to the end, so regexes apply in the order they were last added.
+	_, err = s.db.ExecContext(ctx, "INSERT OR REPLACE INTO TagRule (kind, pattern, replacement) VALUES (?, ?, ?)", rule.Kind, rule.Pattern, rule.Replacement)
+	if err != nil {
+		return fmt.Errorf("adding tag rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteTagRule deletes a rule, returning whether there was one.
+func (s *SQLiteStore) DeleteTagRule(ctx context.Context, kind TagRuleKind, pattern string) (bool, error) {
+	pattern, err := tagRulePattern(kind, pattern)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.ExecContext(ctx, "DELETE FROM TagRule WHERE kind = ? AND pattern = ?", kind, pattern)
+	if err != nil {
+		return false, fmt.Errorf("deleting tag rule: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetTagRules returns the tag rules in the order they were added.
+func (s *SQLiteStore) GetTagRules(ctx context.Context) ([]TagRule, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT kind, pattern, replacement FROM TagRule ORDER BY rowid")
+	if err != nil {
+		return nil, fmt.Errorf("querying tag rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []TagRule
+	for rows.Next() {
+		var r TagRule
+		if err := rows.Scan(&r.Kind, &r.Pattern, &r.Replacement); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetArtistTagCounts returns artist's tags as fetched from last.fm, before curation, ordered by
+// count.
+func (s *SQLiteStore) GetArtistTagCounts(ctx context.Context, artist string) ([]TagCount, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT tag, count FROM ArtistTag WHERE artist = ? ORDER BY count DESC, tag", artist)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []TagCount
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -10,13 +11,20 @@ type ArtistPlayCount struct {
 	Count  int64
 }
 
+// ArtistMonthCount is the number of plays of an artist in a month, which starts in UTC.
+type ArtistMonthCount struct {
+	Artist string
+	Month  time.Time
+	Count  int64
+}
+
 type AlbumPlayCount struct {
 	Artist string
 	Album  string
 	Count  int64
 }
 
-func (s *Store) GetTopArtistsWithCount(user string, start, end time.Time) ([]ArtistPlayCount, error) {
+func (s *SQLiteStore) GetTopArtistsWithCount(ctx context.Context, user string, start, end time.Time) ([]ArtistPlayCount, error) {
 	query := `
 	SELECT Track.artist, COUNT(Listen.id)
 	FROM Listen
@@ -26,7 +34,7 @@ func (s *Store) GetTopArtistsWithCount(user string, start, end time.Time) ([]Art
 	GROUP BY Track.artist
 	ORDER BY COUNT(*) DESC
 	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix())
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix())
 	if err != nil {
 		return nil, fmt.Errorf("querying top artists: %w", err)
 	}
@@ -43,7 +51,40 @@ func (s *Store) GetTopArtistsWithCount(user string, start, end time.Time) ([]Art
 	return results, rows.Err()
 }
 
-func (s *Store) GetTopAlbumsWithCount(user string, start, end time.Time) ([]AlbumPlayCount, error) {
+// GetArtistMonthlyCounts returns the plays of each artist in each month with listens with
+// start <= date < end, ordered by artist and month.
+func (s *SQLiteStore) GetArtistMonthlyCounts(ctx context.Context, user string, start, end time.Time) ([]ArtistMonthCount, error) {
+	query := `
+	SELECT Track.artist, strftime('%Y-%m', Listen.date, 'unixepoch') AS month, COUNT(Listen.id)
+	FROM Listen
+	INNER JOIN Track ON Track.id = Listen.track
+	WHERE user = ?
+	AND Listen.date BETWEEN ? AND ?
+	GROUP BY Track.artist, month
+	ORDER BY Track.artist, month
+	`
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix()-1)
+	if err != nil {
+		return nil, fmt.Errorf("querying artist monthly counts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []ArtistMonthCount
+	for rows.Next() {
+		var amc ArtistMonthCount
+		var month string
+		if err := rows.Scan(&amc.Artist, &month, &amc.Count); err != nil {
+			return nil, err
+		}
+		if amc.Month, err = time.Parse("2006-01", month); err != nil {
+			return nil, fmt.Errorf("parsing month %q: %w", month, err)
+		}
+		results = append(results, amc)
+	}
+	return results, rows.Err()
+}
+
+func (s *SQLiteStore) GetTopAlbumsWithCount(ctx context.Context, user string, start, end time.Time) ([]AlbumPlayCount, error) {
 	query := `
 	SELECT Track.artist, Track.album, COUNT(Listen.id)
 	FROM Listen
@@ -53,7 +94,7 @@ func (s *Store) GetTopAlbumsWithCount(user string, start, end time.Time) ([]Albu
 	GROUP BY Track.artist, Track.album
 	ORDER BY COUNT(*) DESC
 	`
-	rows, err := s.db.Query(query, user, start.Unix(), end.Unix())
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix())
 	if err != nil {
 		return nil, fmt.Errorf("querying top albums: %w", err)
 	}
@@ -69,3 +110,39 @@ func (s *Store) GetTopAlbumsWithCount(user string, start, end time.Time) ([]Albu
 	}
 	return results, rows.Err()
 }
+
+type TrackPlayCount struct {
+	Artist string
+	Track  string
+	Count  int64
+}
+
+// GetTopTracksWithCount counts user's listens per track between start and end, most listened first.
+// Listens of the same track on different albums (e.g. an album and its deluxe edition) are counted
+// together.
+func (s *SQLiteStore) GetTopTracksWithCount(ctx context.Context, user string, start, end time.Time) ([]TrackPlayCount, error) {
+	query := `
+	SELECT Track.artist, Track.name, COUNT(Listen.id)
+	FROM Listen
+	INNER JOIN Track ON Track.id = Listen.track
+	WHERE user = ?
+	AND Listen.date BETWEEN ? AND ?
+	GROUP BY Track.artist, Track.name
+	ORDER BY COUNT(*) DESC, Track.artist, Track.name
+	`
+	rows, err := s.db.QueryContext(ctx, query, user, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("querying top tracks: %w", err)
+	}
+	defer rows.Close()
+
+	var results []TrackPlayCount
+	for rows.Next() {
+		var tpc TrackPlayCount
+		if err := rows.Scan(&tpc.Artist, &tpc.Track, &tpc.Count); err != nil {
+			return nil, err
+		}
+		results = append(results, tpc)
+	}
+	return results, rows.Err()
+}
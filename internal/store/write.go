@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -14,12 +15,12 @@ type TrackImport struct {
 }
 
 // CreateUser ensures a user exists in the database.
-func (s *Store) CreateUser(user string) error {
-	row := s.db.QueryRow("SELECT name FROM User WHERE name = ?", user)
+func (s *SQLiteStore) CreateUser(ctx context.Context, user string) error {
+	row := s.db.QueryRowContext(ctx, "SELECT name FROM User WHERE name = ?", user)
 	var name string
 	err := row.Scan(&name)
 	if err == sql.ErrNoRows {
-		_, err := s.db.Exec("INSERT INTO User (name) VALUES (?)", user)
+		_, err := s.db.ExecContext(ctx, "INSERT INTO User (name) VALUES (?)", user)
 		if err != nil {
 			return fmt.Errorf("inserting user %q: %w", user, err)
 		}
@@ -31,8 +32,8 @@ func (s *Store) CreateUser(user string) error {
 	return nil
 }
 
-func (s *Store) SetLastUpdated(user string, updated time.Time) error {
-	_, err := s.db.Exec("UPDATE User SET last_updated = ? WHERE name = ?", updated, user)
+func (s *SQLiteStore) SetLastUpdated(ctx context.Context, user string, updated time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE User SET last_updated = ? WHERE name = ?", updated, user)
 	if err != nil {
 		return fmt.Errorf("updating last_updated for %q: %w", user, err)
 	}
@@ -40,25 +41,25 @@ func (s *Store) SetLastUpdated(user string, updated time.Time) error {
 }
 
 // AddRecentTracks inserts a batch of tracks transactionally.
-func (s *Store) AddRecentTracks(user string, tracks []TrackImport) error {
-	tx, err := s.db.Begin()
+func (s *SQLiteStore) AddRecentTracks(ctx context.Context, user string, tracks []TrackImport) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("beginning transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	for _, track := range tracks {
-		if err := createArtist(tx, track.Artist); err != nil {
+		if err := createArtist(ctx, tx, track.Artist); err != nil {
 			return err
 		}
-		if err := createAlbum(tx, track.Artist, track.Album); err != nil {
+		if err := createAlbum(ctx, tx, track.Artist, track.Album); err != nil {
 			return err
 		}
-		trackID, err := createTrack(tx, track.Artist, track.Album, track.TrackName)
+		trackID, err := createTrack(ctx, tx, track.Artist, track.Album, track.TrackName)
 		if err != nil {
 			return err
 		}
-		if err := createListen(tx, user, trackID, track.DateUTS); err != nil {
+		if err := createListen(ctx, tx, user, trackID, track.DateUTS); err != nil {
 			return err
 		}
 	}
@@ -71,15 +72,15 @@ func (s *Store) AddRecentTracks(user string, tracks []TrackImport) error {
 
 // Internal helper functions (private, taking *sql.Tx)
 
-func createArtist(tx *sql.Tx, name string) error {
+func createArtist(ctx context.Context, tx *sql.Tx, name string) error {
 	// Optimization: we could cache artists in a map during the transaction if needed,
 	// but for now relying on DB uniqueness checks.
 	// Using INSERT OR IGNORE or checking existence first.
 	// Legacy code checked existence.
 	var dummy string
-	err := tx.QueryRow("SELECT name FROM Artist WHERE name = ?", name).Scan(&dummy)
+	err := tx.QueryRowContext(ctx, "SELECT name FROM Artist WHERE name = ?", name).Scan(&dummy)
 	if err == sql.ErrNoRows {
-		_, err := tx.Exec("INSERT INTO Artist (name) VALUES (?)", name)
+		_, err := tx.ExecContext(ctx, "INSERT INTO Artist (name) VALUES (?)", name)
 		if err != nil {
 			return fmt.Errorf("inserting artist %q: %w", name, err)
 		}
@@ -91,11 +92,11 @@ func createArtist(tx *sql.Tx, name string) error {
 	return nil
 }
 
-func createAlbum(tx *sql.Tx, artist, name string) error {
+func createAlbum(ctx context.Context, tx *sql.Tx, artist, name string) error {
 	var dummy string
-	err := tx.QueryRow("SELECT name FROM Album WHERE artist = ? AND name = ?", artist, name).Scan(&dummy)
+	err := tx.QueryRowContext(ctx, "SELECT name FROM Album WHERE artist = ? AND name = ?", artist, name).Scan(&dummy)
 	if err == sql.ErrNoRows {
-		_, err := tx.Exec("INSERT INTO Album (artist, name) VALUES (?, ?)", artist, name)
+		_, err := tx.ExecContext(ctx, "INSERT INTO Album (artist, name) VALUES (?, ?)", artist, name)
 		if err != nil {
 			return fmt.Errorf("inserting album %q for %q: %w", name, artist, err)
 		}
@@ -107,9 +108,9 @@ func createAlbum(tx *sql.Tx, artist, name string) error {
 	return nil
 }
 
-func createTrack(tx *sql.Tx, artist, album, name string) (int64, error) {
+func createTrack(ctx context.Context, tx *sql.Tx, artist, album, name string) (int64, error) {
 	var id int64
-	err := tx.QueryRow("SELECT id FROM Track WHERE artist = ? AND album = ? AND name = ?", artist, album, name).Scan(&id)
+	err := tx.QueryRowContext(ctx, "SELECT id FROM Track WHERE artist = ? AND album = ? AND name = ?", artist, album, name).Scan(&id)
 	if err == nil {
 		return id, nil
 	}
@@ -117,17 +118,25 @@ func createTrack(tx *sql.Tx, artist, album, name string) (int64, error) {
 		return 0, fmt.Errorf("checking track %q: %w", name, err)
 	}
 
-	res, err := tx.Exec("INSERT INTO Track (artist, album, name) VALUES (?, ?, ?)", artist, album, name)
+	res, err := tx.ExecContext(ctx, "INSERT INTO Track (artist, album, name) VALUES (?, ?, ?)", artist, album, name)
 	if err != nil {
 		return 0, fmt.Errorf("inserting track %q: %w", name, err)
 	}
 	return res.LastInsertId()
 }
 
-func createListen(tx *sql.Tx, user string, trackID int64, date string) error {
+// createListen saves the listen with its date as a Unix time, whichever format parseDate read it
+// from, so that queries can compare and bucket dates as numbers.
+func createListen(ctx context.Context, tx *sql.Tx, user string, trackID int64, dateStr string) error {
+	t, err := parseDate(dateStr)
+	if err != nil {
+		return fmt.Errorf("inserting listen: %w", err)
+	}
+	date := t.Unix()
+
 	// Check for duplicate listen
 	var dummy int64
-	err := tx.QueryRow("SELECT id FROM Listen WHERE user = ? AND date = ? AND track = ?", user, date, trackID).Scan(&dummy)
+	err = tx.QueryRowContext(ctx, "SELECT id FROM Listen WHERE user = ? AND date = ? AND track = ?", user, date, trackID).Scan(&dummy)
 	if err == nil {
 		return nil // Already exists
 	}
@@ -135,7 +144,7 @@ func createListen(tx *sql.Tx, user string, trackID int64, date string) error {
 		return fmt.Errorf("checking listen: %w", err)
 	}
 
-	_, err = tx.Exec("INSERT INTO Listen (user, track, date) VALUES (?, ?, ?)", user, trackID, date)
+	_, err = tx.ExecContext(ctx, "INSERT INTO Listen (user, track, date) VALUES (?, ?, ?)", user, trackID, date)
 	if err != nil {
 		return fmt.Errorf("inserting listen: %w", err)
 	}
@@ -144,8 +153,8 @@ func createListen(tx *sql.Tx, user string, trackID int64, date string) error {
 
 // Tag Operations
 
-func (s *Store) SaveArtistTags(artist string, tags []string, counts []int) error {
-	tx, err := s.db.Begin()
+func (s *SQLiteStore) SaveArtistTags(ctx context.Context, artist string, tags []string, counts []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -158,33 +167,33 @@ func (s *Store) SaveArtistTags(artist string, tags []string, counts []int) error
 		}
 
 		// Ensure Tag exists
-		_, err := tx.Exec("INSERT OR IGNORE INTO Tag (name) VALUES (?)", tag)
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO Tag (name) VALUES (?)", tag)
 		if err != nil {
 			return fmt.Errorf("inserting tag %q: %w", tag, err)
 		}
 
 		// Insert/Update ArtistTag
-		_, err = tx.Exec("INSERT OR REPLACE INTO ArtistTag (artist, tag, count) VALUES (?, ?, ?)", artist, tag, count)
+		_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO ArtistTag (artist, tag, count) VALUES (?, ?, ?)", artist, tag, count)
 		if err != nil {
 			return fmt.Errorf("linking tag %q to artist %q: %w", tag, artist, err)
 		}
 	}
 
-	if err := s.MarkArtistTagsUpdated(tx, artist); err != nil {
+	if err := s.MarkArtistTagsUpdated(ctx, tx, artist); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (s *Store) MarkArtistTagsUpdated(tx *sql.Tx, artist string) error {
+func (s *SQLiteStore) MarkArtistTagsUpdated(ctx context.Context, tx *sql.Tx, artist string) error {
 	query := "UPDATE Artist SET tags_last_updated = ? WHERE name = ?"
 	
 	var err error
 	if tx != nil {
-		_, err = tx.Exec(query, time.Now(), artist)
+		_, err = tx.ExecContext(ctx, query, time.Now(), artist)
 	} else {
-		_, err = s.db.Exec(query, time.Now(), artist)
+		_, err = s.db.ExecContext(ctx, query, time.Now(), artist)
 	}
 	
 	if err != nil {
@@ -193,8 +202,8 @@ func (s *Store) MarkArtistTagsUpdated(tx *sql.Tx, artist string) error {
 	return nil
 }
 
-func (s *Store) SaveAlbumTags(artist, album string, tags []string, counts []int) error {
-	tx, err := s.db.Begin()
+func (s *SQLiteStore) SaveAlbumTags(ctx context.Context, artist, album string, tags []string, counts []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -206,32 +215,32 @@ func (s *Store) SaveAlbumTags(artist, album string, tags []string, counts []int)
 			count = counts[i]
 		}
 
-		_, err := tx.Exec("INSERT OR IGNORE INTO Tag (name) VALUES (?)", tag)
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO Tag (name) VALUES (?)", tag)
 		if err != nil {
 			return fmt.Errorf("inserting tag %q: %w", tag, err)
 		}
 
-		_, err = tx.Exec("INSERT OR REPLACE INTO AlbumTag (artist, album, tag, count) VALUES (?, ?, ?, ?)", artist, album, tag, count)
+		_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO AlbumTag (artist, album, tag, count) VALUES (?, ?, ?, ?)", artist, album, tag, count)
 		if err != nil {
 			return fmt.Errorf("linking tag %q to album %q: %w", tag, album, err)
 		}
 	}
 
-	if err := s.MarkAlbumTagsUpdated(tx, artist, album); err != nil {
+	if err := s.MarkAlbumTagsUpdated(ctx, tx, artist, album); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (s *Store) MarkAlbumTagsUpdated(tx *sql.Tx, artist, album string) error {
+func (s *SQLiteStore) MarkAlbumTagsUpdated(ctx context.Context, tx *sql.Tx, artist, album string) error {
 	query := "UPDATE Album SET tags_last_updated = ? WHERE artist = ? AND name = ?"
 	
 	var err error
 	if tx != nil {
-		_, err = tx.Exec(query, time.Now(), artist, album)
+		_, err = tx.ExecContext(ctx, query, time.Now(), artist, album)
 	} else {
-		_, err = s.db.Exec(query, time.Now(), artist, album)
+		_, err = s.db.ExecContext(ctx, query, time.Now(), artist, album)
 	}
 	
 	if err != nil {
@@ -15,3 +15,4 @@ git pull
 
 # Note: must specify `user`, even though it's not used
 bazel run //:last-fm-tools -- send-reports --user notuser
+bazel run //:last-fm-tools -- backup --gzip